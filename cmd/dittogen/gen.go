@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/Hammerstone-AU/ditto-go-sdk/ditto"
+)
+
+// initialisms are words written in upper case in Go identifiers.
+var initialisms = map[string]bool{
+	"API": true, "HTTP": true, "ID": true, "IP": true, "JSON": true,
+	"SQL": true, "URI": true, "URL": true, "UUID": true, "TTL": true,
+}
+
+// collectionSchema is the inferred shape of one collection.
+type collectionSchema struct {
+	Name    string
+	Sampled int
+	Schema  *ditto.JSONSchema
+}
+
+// generator renders Go source for a set of collections.
+type generator struct {
+	pkg      string
+	source   string // where the schemas came from, for the header comment
+	buf      bytes.Buffer
+	types    map[string]bool // type names already emitted
+	pending  []pendingType   // nested struct types still to emit
+	usesTime bool
+}
+
+// pendingType is a nested object type discovered while emitting a struct.
+type pendingType struct {
+	name   string
+	doc    string
+	schema *ditto.JSONSchema
+}
+
+// generate returns gofmt-formatted Go source declaring collection name
+// constants, field path constants, and a struct per collection.
+func (g *generator) generate(cols []collectionSchema) ([]byte, error) {
+	g.types = map[string]bool{}
+	g.buf.Reset()
+
+	g.printf("// Collection names.\nconst (\n")
+	for _, c := range cols {
+		g.printf("\tCollection%s = %q\n", exported(c.Name), c.Name)
+	}
+	g.printf(")\n\n")
+
+	for _, c := range cols {
+		prefix := exported(c.Name)
+		var paths []string
+		fieldPaths(c.Schema, "", &paths)
+		if len(paths) > 0 {
+			g.printf("// Field paths of %s, for QueryOptions, predicates, and ditto.Get.\nconst (\n", c.Name)
+			seen := map[string]bool{}
+			for _, p := range paths {
+				name := unique(prefix+"Field"+exported(p), seen)
+				g.printf("\t%s = %q\n", name, p)
+			}
+			g.printf(")\n\n")
+		}
+	}
+
+	for _, c := range cols {
+		name := exported(c.Name)
+		doc := fmt.Sprintf("%s is a document of the %s collection, inferred from %d sampled documents.", name, c.Name, c.Sampled)
+		g.structType(name, doc, c.Schema)
+		for len(g.pending) > 0 {
+			p := g.pending[0]
+			g.pending = g.pending[1:]
+			g.structType(p.name, p.doc, p.schema)
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by dittogen from %s; DO NOT EDIT.\n\npackage %s\n\n", g.source, g.pkg)
+	if g.usesTime {
+		out.WriteString("import \"time\"\n\n")
+	}
+	out.Write(g.buf.Bytes())
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return out.Bytes(), fmt.Errorf("format generated source: %w", err)
+	}
+	return src, nil
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// structType emits a struct for an object schema.
+func (g *generator) structType(name, doc string, js *ditto.JSONSchema) {
+	if g.types[name] {
+		return
+	}
+	g.types[name] = true
+	required := map[string]bool{}
+	for _, r := range js.Required {
+		required[r] = true
+	}
+	g.printf("// %s\ntype %s struct {\n", doc, name)
+	seen := map[string]bool{}
+	for _, k := range sortedProps(js) {
+		field := unique(exported(k), seen)
+		typ := g.goType(name+field, k, js.Properties[k])
+		tag := k
+		if !required[k] {
+			tag += ",omitempty"
+			if !nilable(typ) {
+				typ = "*" + typ
+			}
+		}
+		g.printf("\t%s %s `json:%q`\n", field, typ, tag)
+	}
+	g.printf("}\n\n")
+}
+
+// goType maps a schema to a Go type, queueing nested struct types. A type
+// list containing null makes the type a pointer; other mixes become any.
+func (g *generator) goType(name, field string, js *ditto.JSONSchema) string {
+	types, nullable := schemaTypes(js)
+	if len(types) != 1 {
+		return "any"
+	}
+	var t string
+	switch types[0] {
+	case "string":
+		t = "string"
+		if js.Format == "date-time" {
+			t = "time.Time"
+			g.usesTime = true
+		}
+	case "integer":
+		t = "int64"
+	case "number":
+		t = "float64"
+	case "boolean":
+		t = "bool"
+	case "array":
+		if js.Items == nil {
+			return "[]any"
+		}
+		return "[]" + g.goType(name+"Item", field, js.Items)
+	case "object":
+		if len(js.Properties) == 0 {
+			return "map[string]any"
+		}
+		g.pending = append(g.pending, pendingType{
+			name:   name,
+			doc:    fmt.Sprintf("%s is the %s field of its parent document.", name, field),
+			schema: js,
+		})
+		t = name
+	default:
+		return "any"
+	}
+	if nullable {
+		return "*" + t
+	}
+	return t
+}
+
+// schemaTypes returns the non-null types of js and whether null was seen.
+func schemaTypes(js *ditto.JSONSchema) ([]string, bool) {
+	var all []string
+	switch t := js.Type.(type) {
+	case string:
+		all = []string{t}
+	case []string:
+		all = t
+	case []any:
+		for _, x := range t {
+			if s, ok := x.(string); ok {
+				all = append(all, s)
+			}
+		}
+	}
+	var out []string
+	nullable := false
+	for _, t := range all {
+		if t == "null" {
+			nullable = true
+		} else {
+			out = append(out, t)
+		}
+	}
+	return out, nullable
+}
+
+// fieldPaths appends the dotted path of every field in js, depth first.
+func fieldPaths(js *ditto.JSONSchema, prefix string, out *[]string) {
+	if js == nil {
+		return
+	}
+	for _, k := range sortedProps(js) {
+		p := prefix + k
+		*out = append(*out, p)
+		if sub := js.Properties[k]; sub != nil && len(sub.Properties) > 0 {
+			fieldPaths(sub, p+".", out)
+		}
+	}
+}
+
+// sortedProps returns the property names of js, _id first.
+func sortedProps(js *ditto.JSONSchema) []string {
+	keys := make([]string, 0, len(js.Properties))
+	for k := range js.Properties {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if (keys[i] == "_id") != (keys[j] == "_id") {
+			return keys[i] == "_id"
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// nilable reports whether a Go type already has a nil value.
+func nilable(t string) bool {
+	return t == "any" || strings.HasPrefix(t, "[]") || strings.HasPrefix(t, "map[") || strings.HasPrefix(t, "*")
+}
+
+// exported turns a field or collection name into an exported Go
+// identifier: "sensor_readings" becomes SensorReadings, "_id" ID, and
+// "meta.created_at" MetaCreatedAt.
+func exported(s string) string {
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var b strings.Builder
+	for _, w := range words {
+		// Split camelCase words so "createdAt" keeps its word boundary
+		for _, part := range splitCamel(w) {
+			if up := strings.ToUpper(part); initialisms[up] {
+				b.WriteString(up)
+				continue
+			}
+			r := []rune(part)
+			r[0] = unicode.ToUpper(r[0])
+			b.WriteString(string(r))
+		}
+	}
+	id := b.String()
+	if id == "" {
+		return "X"
+	}
+	if unicode.IsDigit([]rune(id)[0]) {
+		return "X" + id
+	}
+	return id
+}
+
+// splitCamel splits "createdAt" into "created" and "At".
+func splitCamel(w string) []string {
+	var parts []string
+	start := 0
+	rs := []rune(w)
+	for i := 1; i < len(rs); i++ {
+		if unicode.IsUpper(rs[i]) && unicode.IsLower(rs[i-1]) {
+			parts = append(parts, string(rs[start:i]))
+			start = i
+		}
+	}
+	return append(parts, string(rs[start:]))
+}
+
+// unique returns name, or name with a numeric suffix if seen already has it.
+func unique(name string, seen map[string]bool) string {
+	out := name
+	for i := 2; seen[out]; i++ {
+		out = fmt.Sprintf("%s%d", name, i)
+	}
+	seen[out] = true
+	return out
+}
@@ -0,0 +1,75 @@
+// Command dittogen connects to a running Ditto instance, samples the named
+// collections, and writes Go constants for their collection names and
+// field paths, plus a struct per collection, so application code stays in
+// sync with what is actually stored on devices:
+//
+//	go run github.com/Hammerstone-AU/ditto-go-sdk/cmd/dittogen \
+//		-url http://localhost:8090 -app myapp \
+//		-collections users,sensor_readings -pkg models -o models/ditto_gen.go
+//
+// Types are inferred with InferSchema: fields present in every sampled
+// document are plain values, others pointers with omitempty; timestamps
+// become time.Time, and fields holding several types become any. Ditto's
+// HTTP API has no way to list collections, so they are named explicitly.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Hammerstone-AU/ditto-go-sdk/ditto"
+)
+
+func main() {
+	url := flag.String("url", "http://localhost:8090", "Ditto HTTP API base URL")
+	app := flag.String("app", "", "Ditto app (database) ID")
+	collections := flag.String("collections", "", "comma-separated collections to sample")
+	sample := flag.Int("sample", 1000, "documents sampled per collection")
+	pkg := flag.String("pkg", "models", "package name of the generated file")
+	out := flag.String("o", "", "output file; empty writes to stdout")
+	timeout := flag.Duration("timeout", time.Minute, "overall timeout")
+	flag.Parse()
+
+	if *app == "" || *collections == "" {
+		fmt.Fprintln(os.Stderr, "dittogen: -app and -collections are required")
+		flag.Usage()
+		os.Exit(2)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	svc := ditto.NewService(*url, *app)
+	var cols []collectionSchema
+	for _, name := range strings.Split(*collections, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		res, err := svc.FindRecords(ctx, name, nil, ditto.QueryOptions{Limit: *sample})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "dittogen: sample %s: %v\n", name, err)
+			os.Exit(1)
+		}
+		docs := ditto.Documents(res)
+		cols = append(cols, collectionSchema{Name: name, Sampled: len(docs), Schema: ditto.InferSchemaFromDocs(docs)})
+	}
+
+	g := &generator{pkg: *pkg, source: *url + "/" + *app}
+	src, err := g.generate(cols)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "dittogen:", err)
+		os.Exit(1)
+	}
+	if *out == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, "dittogen:", err)
+		os.Exit(1)
+	}
+}
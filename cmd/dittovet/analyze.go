@@ -0,0 +1,347 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// finding is one unparameterized query.
+type finding struct {
+	Pos     token.Position
+	Message string
+}
+
+// ignoreDirective on a flagged line, or the line above, silences it.
+const ignoreDirective = "//dittovet:ignore"
+
+// queryCalls maps method and function names to the index of their DQL
+// argument.
+var queryCalls = map[string]int{
+	"Execute":      1, // (ctx, query, args)
+	"exec":         1, // (ctx, query)
+	"execWithArgs": 1, // (ctx, query, args)
+	"Where":        0, // (clause, args)
+}
+
+// queryFields maps composite literal types to their DQL field.
+var queryFields = map[string]string{
+	"Statement": "Query",
+	"Predicate": "Clause",
+}
+
+// formatFuncs are the fmt functions whose results are checked; the value is
+// the index of the format argument, or -1 when every argument is an operand.
+var formatFuncs = map[string]int{
+	"Sprintf":  0,
+	"Sprint":   -1,
+	"Sprintln": -1,
+}
+
+// safeVerbs format numbers, booleans, or hex digits, which cannot end a
+// DQL string or identifier.
+const safeVerbs = "bdeEfFgGoOtUxX"
+
+// analyzer checks the files of one package.
+type analyzer struct {
+	fset     *token.FileSet
+	files    []*ast.File
+	safe     map[string]bool // sanitizer functions whose results are trusted
+	consts   map[string]bool // package-level constants
+	findings []finding
+}
+
+// run reports every query argument built from non-constant strings with
+// fmt or concatenation.
+func (a *analyzer) run() []finding {
+	a.consts = map[string]bool{}
+	for _, f := range a.files {
+		for _, d := range f.Decls {
+			collectConsts(d, a.consts)
+		}
+	}
+	for _, f := range a.files {
+		ignored := ignoredLines(a.fset, f)
+		for _, d := range f.Decls {
+			fn, ok := d.(*ast.FuncDecl)
+			if !ok || fn.Body == nil {
+				continue
+			}
+			s := &scope{a: a, consts: map[string]bool{}, assigns: map[string][]ast.Expr{}}
+			s.collect(fn.Body)
+			ast.Inspect(fn.Body, func(n ast.Node) bool {
+				expr, what := queryArg(n)
+				if expr == nil {
+					return true
+				}
+				if why := s.built(expr, map[string]bool{}); why != "" {
+					pos := a.fset.Position(expr.Pos())
+					if !ignored[pos.Line] && !ignored[pos.Line-1] {
+						a.findings = append(a.findings, finding{Pos: pos, Message: fmt.Sprintf(
+							"%s is %s from non-constant values; bind them as :params in query_args or use the ditto builders", what, why)})
+					}
+				}
+				return true
+			})
+		}
+	}
+	return a.findings
+}
+
+// queryArg returns the DQL expression of n, when n passes one, and a
+// description of where it goes.
+func queryArg(n ast.Node) (ast.Expr, string) {
+	switch n := n.(type) {
+	case *ast.CallExpr:
+		name := calleeName(n.Fun)
+		i, ok := queryCalls[name]
+		if !ok || i >= len(n.Args) {
+			return nil, ""
+		}
+		return n.Args[i], "query passed to " + name
+	case *ast.CompositeLit:
+		field, ok := queryFields[calleeName(n.Type)]
+		if !ok {
+			return nil, ""
+		}
+		for _, e := range n.Elts {
+			kv, ok := e.(*ast.KeyValueExpr)
+			if !ok {
+				continue
+			}
+			if k, ok := kv.Key.(*ast.Ident); ok && k.Name == field {
+				return kv.Value, calleeName(n.Type) + "." + field
+			}
+		}
+	}
+	return nil, ""
+}
+
+// calleeName returns the final name of an identifier or selector.
+func calleeName(e ast.Expr) string {
+	switch e := e.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.SelectorExpr:
+		return e.Sel.Name
+	}
+	return ""
+}
+
+// scope holds the local constants and assignments of one function.
+type scope struct {
+	a       *analyzer
+	consts  map[string]bool
+	assigns map[string][]ast.Expr
+}
+
+// collect records local constants and every value assigned to each local
+// name, treating q += x as q = q + x.
+func (s *scope) collect(body *ast.BlockStmt) {
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.DeclStmt:
+			collectConsts(n.Decl, s.consts)
+			if g, ok := n.Decl.(*ast.GenDecl); ok && g.Tok == token.VAR {
+				for _, sp := range g.Specs {
+					vs := sp.(*ast.ValueSpec)
+					for i, name := range vs.Names {
+						if i < len(vs.Values) {
+							s.assigns[name.Name] = append(s.assigns[name.Name], vs.Values[i])
+						}
+					}
+				}
+			}
+		case *ast.AssignStmt:
+			if len(n.Lhs) != len(n.Rhs) {
+				return true
+			}
+			for i, lhs := range n.Lhs {
+				id, ok := lhs.(*ast.Ident)
+				if !ok {
+					continue
+				}
+				rhs := n.Rhs[i]
+				if n.Tok == token.ADD_ASSIGN {
+					rhs = &ast.BinaryExpr{X: id, Op: token.ADD, Y: rhs, OpPos: n.TokPos}
+				}
+				s.assigns[id.Name] = append(s.assigns[id.Name], rhs)
+			}
+		}
+		return true
+	})
+}
+
+// built describes how e was assembled from non-constant values ("built with
+// fmt.Sprintf", "concatenated"), or returns "" when it is constant or merely
+// passed through.
+func (s *scope) built(e ast.Expr, seen map[string]bool) string {
+	switch e := e.(type) {
+	case *ast.ParenExpr:
+		return s.built(e.X, seen)
+	case *ast.CallExpr:
+		if fn, ok := fmtCall(e); ok && !s.static(e, seen) {
+			return "built with fmt." + fn
+		}
+	case *ast.BinaryExpr:
+		if e.Op == token.ADD && !s.static(e, seen) {
+			return "concatenated"
+		}
+	case *ast.Ident:
+		if seen[e.Name] {
+			return ""
+		}
+		seen[e.Name] = true
+		defer delete(seen, e.Name)
+		for _, rhs := range s.assigns[e.Name] {
+			if why := s.built(rhs, seen); why != "" {
+				return why + " (line " + strconv.Itoa(s.a.fset.Position(rhs.Pos()).Line) + ")"
+			}
+		}
+	}
+	return ""
+}
+
+// static reports whether e can only produce developer-written text:
+// literals, constants, locals only ever assigned static values, sanitizer
+// calls, Predicate clauses, and fmt or + combinations of those. Operands of %d, %f, %t and
+// similar verbs (safeVerbs) cannot inject DQL.
+func (s *scope) static(e ast.Expr, seen map[string]bool) bool {
+	switch e := e.(type) {
+	case *ast.BasicLit:
+		return true
+	case *ast.ParenExpr:
+		return s.static(e.X, seen)
+	case *ast.Ident:
+		if s.consts[e.Name] || s.a.consts[e.Name] {
+			return true
+		}
+		rhs := s.assigns[e.Name]
+		if len(rhs) == 0 || seen[e.Name] {
+			return len(rhs) > 0 // parameters and fields are not static
+		}
+		seen[e.Name] = true
+		defer delete(seen, e.Name)
+		for _, r := range rhs {
+			if !s.static(r, seen) {
+				return false
+			}
+		}
+		return true
+	case *ast.SelectorExpr:
+		// The clause of a Predicate or the query of a Statement was checked
+		// where it was built
+		return e.Sel.Name == "Clause" || e.Sel.Name == "Query"
+	case *ast.BinaryExpr:
+		return e.Op == token.ADD && s.static(e.X, seen) && s.static(e.Y, seen)
+	case *ast.CallExpr:
+		if s.a.safe[calleeName(e.Fun)] {
+			return true
+		}
+		fn, ok := fmtCall(e)
+		if !ok {
+			return false
+		}
+		operands := e.Args
+		if fi := formatFuncs[fn]; fi >= 0 {
+			if len(e.Args) <= fi {
+				return false
+			}
+			lit, ok := e.Args[fi].(*ast.BasicLit)
+			if !ok {
+				return s.static(e.Args[fi], seen) && s.allStatic(e.Args[fi+1:], seen)
+			}
+			format, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				return false
+			}
+			verbs := formatVerbs(format)
+			operands = nil
+			for i, arg := range e.Args[fi+1:] {
+				if i >= len(verbs) || !strings.ContainsRune(safeVerbs, verbs[i]) {
+					operands = append(operands, arg)
+				}
+			}
+		}
+		return s.allStatic(operands, seen)
+	}
+	return false
+}
+
+// allStatic reports whether every expression in es is static.
+func (s *scope) allStatic(es []ast.Expr, seen map[string]bool) bool {
+	for _, e := range es {
+		if !s.static(e, seen) {
+			return false
+		}
+	}
+	return true
+}
+
+// fmtCall reports whether call is fmt.Sprintf, fmt.Sprint, or fmt.Sprintln.
+func fmtCall(call *ast.CallExpr) (string, bool) {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return "", false
+	}
+	pkg, ok := sel.X.(*ast.Ident)
+	if !ok || pkg.Name != "fmt" {
+		return "", false
+	}
+	_, ok = formatFuncs[sel.Sel.Name]
+	return sel.Sel.Name, ok
+}
+
+// formatVerbs returns the verb of each operand consumed by format, in order.
+// A '*' width or precision consumes an operand and is reported as 'd'.
+func formatVerbs(format string) []rune {
+	var verbs []rune
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		for i++; i < len(format); i++ {
+			c := rune(format[i])
+			if c == '*' {
+				verbs = append(verbs, 'd')
+				continue
+			}
+			if strings.ContainsRune("+-# 0123456789.[]", c) {
+				continue
+			}
+			if c != '%' {
+				verbs = append(verbs, c)
+			}
+			break
+		}
+	}
+	return verbs
+}
+
+// collectConsts adds the names declared by a const declaration to consts.
+func collectConsts(d ast.Decl, consts map[string]bool) {
+	g, ok := d.(*ast.GenDecl)
+	if !ok || g.Tok != token.CONST {
+		return
+	}
+	for _, sp := range g.Specs {
+		for _, name := range sp.(*ast.ValueSpec).Names {
+			consts[name.Name] = true
+		}
+	}
+}
+
+// ignoredLines returns the lines of f carrying the ignore directive.
+func ignoredLines(fset *token.FileSet, f *ast.File) map[int]bool {
+	lines := map[int]bool{}
+	for _, cg := range f.Comments {
+		for _, c := range cg.List {
+			if strings.HasPrefix(c.Text, ignoreDirective) {
+				lines[fset.Position(c.Pos()).Line] = true
+			}
+		}
+	}
+	return lines
+}
@@ -0,0 +1,254 @@
+// Command dittovet flags DQL built from non-constant strings: calls to
+// Execute (and the SDK's internal exec helpers), Where, and Statement or
+// Predicate literals whose query comes from fmt.Sprintf or string
+// concatenation of values that are not constants. Such queries are open to
+// DQL injection; bind the values as :params in query_args or use the ditto
+// builders instead.
+//
+// Run it standalone over directories:
+//
+//	go run github.com/Hammerstone-AU/ditto-go-sdk/cmd/dittovet ./...
+//
+// or as a go vet tool:
+//
+//	go build -o dittovet github.com/Hammerstone-AU/ditto-go-sdk/cmd/dittovet
+//	go vet -vettool=$(pwd)/dittovet ./...
+//
+// The check is syntactic: it follows assignments to local variables within
+// a function but not across calls, and it trusts values passed in by
+// callers. Calls to the functions named by -safe (the SDK's escapeIdent,
+// escapePath, QuoteIdent, and QuotePath by default) are treated as sanitized,
+// and so are the Clause of a Predicate and the Query of a Statement. A
+// //dittovet:ignore comment on the flagged line, or the line above it,
+// silences a finding.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func main() {
+	version := flag.String("V", "", "print version and exit (go vet protocol)")
+	printFlags := flag.Bool("flags", false, "print flags as JSON and exit (go vet protocol)")
+	jsonOut := flag.Bool("json", false, "emit findings as JSON (go vet protocol)")
+	flag.Int("c", -1, "ignored; accepted for go vet")
+	safe := flag.String("safe", "escapeIdent,escapePath,QuoteIdent,QuotePath", "comma-separated functions whose results are safe to splice into DQL")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: dittovet [-safe=f,g] [dir | dir/... | file.go]...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	switch {
+	case *version != "":
+		printVersion()
+		return
+	case *printFlags:
+		// Only -safe is meant to be passed through by go vet
+		json.NewEncoder(os.Stdout).Encode([]struct {
+			Name  string
+			Bool  bool
+			Usage string
+		}{{Name: "safe", Usage: flag.Lookup("safe").Usage}})
+		return
+	}
+
+	sanitizers := map[string]bool{}
+	for _, name := range strings.Split(*safe, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			sanitizers[name] = true
+		}
+	}
+
+	args := flag.Args()
+	if len(args) == 1 && strings.HasSuffix(args[0], ".cfg") {
+		os.Exit(vetUnit(args[0], sanitizers, *jsonOut))
+	}
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+	pkgs, err := expand(args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "dittovet:", err)
+		os.Exit(1)
+	}
+	exit := 0
+	for _, files := range pkgs {
+		findings, err := check(files, sanitizers)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "dittovet:", err)
+			exit = 1
+		}
+		report(os.Stdout, findings)
+		if len(findings) > 0 {
+			exit = 1
+		}
+	}
+	os.Exit(exit)
+}
+
+// printVersion answers go vet's -V=full probe. A devel version must end in
+// a buildID, which go vet uses to cache results; the executable's hash
+// changes whenever the tool does.
+func printVersion() {
+	id := "unknown"
+	if exe, err := os.Executable(); err == nil {
+		if f, err := os.Open(exe); err == nil {
+			h := sha256.New()
+			if _, err := io.Copy(h, f); err == nil {
+				id = fmt.Sprintf("%x", h.Sum(nil))
+			}
+			f.Close()
+		}
+	}
+	fmt.Printf("%s version devel buildID=%s\n", filepath.Base(os.Args[0]), id)
+}
+
+// vetConfig is the part of go vet's per-package .cfg file dittovet uses.
+type vetConfig struct {
+	ImportPath string
+	GoFiles    []string
+	VetxOnly   bool   // run only to produce facts for dependents
+	VetxOutput string // facts file go vet expects to be written
+}
+
+// vetUnit checks one package described by a go vet .cfg file and returns
+// the exit code. With jsonOut, findings go to stdout in go vet's JSON shape
+// and do not fail the run.
+func vetUnit(path string, sanitizers map[string]bool, jsonOut bool) int {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "dittovet:", err)
+		return 1
+	}
+	var cfg vetConfig
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "dittovet: %s: %v\n", path, err)
+		return 1
+	}
+	// dittovet produces no facts, but go vet expects the file
+	if cfg.VetxOutput != "" {
+		if err := os.WriteFile(cfg.VetxOutput, nil, 0o666); err != nil {
+			fmt.Fprintln(os.Stderr, "dittovet:", err)
+			return 1
+		}
+	}
+	if cfg.VetxOnly {
+		return 0
+	}
+	findings, err := check(cfg.GoFiles, sanitizers)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "dittovet:", err)
+		return 1
+	}
+	if jsonOut {
+		type diag struct {
+			Posn    string `json:"posn"`
+			Message string `json:"message"`
+		}
+		diags := []diag{}
+		for _, f := range findings {
+			diags = append(diags, diag{Posn: f.Pos.String(), Message: f.Message})
+		}
+		b, _ := json.MarshalIndent(map[string]map[string][]diag{cfg.ImportPath: {"dittovet": diags}}, "", "\t")
+		fmt.Printf("%s\n", b)
+		return 0
+	}
+	report(os.Stderr, findings)
+	if len(findings) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// check parses files as one package and returns its findings.
+func check(files []string, sanitizers map[string]bool) ([]finding, error) {
+	fset := token.NewFileSet()
+	var parsed []*ast.File
+	for _, name := range files {
+		f, err := parser.ParseFile(fset, name, nil, parser.ParseComments|parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, f)
+	}
+	a := &analyzer{fset: fset, files: parsed, safe: sanitizers}
+	return a.run(), nil
+}
+
+// report writes findings to w, one per line.
+func report(w io.Writer, findings []finding) {
+	for _, f := range findings {
+		fmt.Fprintf(w, "%s: %s\n", f.Pos, f.Message)
+	}
+}
+
+// expand turns command-line arguments into Go files grouped by directory.
+// "dir/..." walks dir recursively, skipping testdata, vendor, and hidden
+// directories.
+func expand(args []string) ([][]string, error) {
+	byDir := map[string][]string{}
+	addDir := func(dir string) error {
+		matches, err := filepath.Glob(filepath.Join(dir, "*.go"))
+		if err != nil {
+			return err
+		}
+		if len(matches) > 0 {
+			byDir[dir] = matches
+		}
+		return nil
+	}
+	for _, arg := range args {
+		switch {
+		case strings.HasSuffix(arg, ".go"):
+			dir := filepath.Dir(arg)
+			byDir[dir] = append(byDir[dir], arg)
+		case arg == "..." || strings.HasSuffix(arg, "/..."):
+			root := strings.TrimSuffix(strings.TrimSuffix(arg, "..."), "/")
+			if root == "" {
+				root = "."
+			}
+			err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if !d.IsDir() {
+					return nil
+				}
+				name := d.Name()
+				if path != root && (name == "testdata" || name == "vendor" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+					return filepath.SkipDir
+				}
+				return addDir(path)
+			})
+			if err != nil {
+				return nil, err
+			}
+		default:
+			if err := addDir(arg); err != nil {
+				return nil, err
+			}
+		}
+	}
+	dirs := make([]string, 0, len(byDir))
+	for dir := range byDir {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	out := make([][]string, len(dirs))
+	for i, dir := range dirs {
+		out[i] = byDir[dir]
+	}
+	return out, nil
+}
@@ -0,0 +1,82 @@
+package ditto
+
+import (
+	"context"
+	"encoding/hex"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// requestIDHeader carries the request ID to the server and back.
+const requestIDHeader = "X-Request-ID"
+
+// requestIDKey is the context key for WithRequestID.
+type requestIDKey struct{}
+
+// WithAccessLog writes one structured record per statement sent to the
+// Ditto HTTP API through l, with the attributes op (verb), collection,
+// duration, status (HTTP status, 0 when no response arrived), req_bytes,
+// resp_bytes, request_id, and error on failure. Successful statements log
+// at Info and failures at Warn. The logger is a *slog.Logger, so any slog
+// handler works, including the zap and zerolog slog adapters. Each request
+// carries its ID in an X-Request-ID header; a server that echoes a
+// different ID back is logged with that one. Statements and arguments are
+// left out unless WithQueryLogging adds them.
+func (s *service) WithAccessLog(l *slog.Logger) *service {
+	s.accessLog = l
+	return s
+}
+
+// WithRequestID returns a context whose statements are sent and logged with
+// id instead of a generated request ID, e.g. to correlate them with the
+// inbound request being served.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// requestID returns the ID to send for one request: the context's, or a
+// generated one when the access log is on, or "" for none.
+func (s *service) requestID(ctx context.Context) string {
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok && id != "" {
+		return id
+	}
+	if s.accessLog == nil {
+		return ""
+	}
+	b := make([]byte, 8)
+	if _, err := s.random().Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// logAccess writes the access log record for one statement.
+func (s *service) logAccess(
+	ctx context.Context, query string, args map[string]any,
+	d time.Duration, status int, reqBytes, respBytes int64, requestID string, err error,
+) {
+	l := s.accessLog
+	if l == nil {
+		return
+	}
+	op, coll, _ := strings.Cut(statementKey(query), " ")
+	attrs := []slog.Attr{
+		slog.String("op", op),
+		slog.String("collection", coll),
+		slog.Duration("duration", d),
+		slog.Int("status", status),
+		slog.Int64("req_bytes", reqBytes),
+		slog.Int64("resp_bytes", respBytes),
+		slog.String("request_id", requestID),
+	}
+	if s.queryLog != nil {
+		attrs = append(attrs, slog.String("query", query), slog.Any("args", RedactArgs(*s.queryLog, args)))
+	}
+	level := slog.LevelInfo
+	if err != nil {
+		level = slog.LevelWarn
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	l.LogAttrs(ctx, level, "ditto access", attrs...)
+}
@@ -0,0 +1,145 @@
+package ditto
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// AdaptiveTimeouts derives the read and write deadlines from observed
+// latency instead of fixed values: each deadline is Multiplier times the
+// Percentile of the last Window successful statements of its class, clamped
+// to [Floor, Ceiling]. Until MinSamples have been seen the fixed
+// OperationTimeouts apply. Zero fields take the DefaultAdaptiveTimeouts
+// value.
+type AdaptiveTimeouts struct {
+	Percentile float64 // e.g. 0.99
+	Multiplier float64 // headroom over the percentile
+	Floor      time.Duration
+	Ceiling    time.Duration
+	Window     int // samples kept per class
+	MinSamples int // samples needed before adapting
+}
+
+// DefaultAdaptiveTimeouts fills the zero fields of WithAdaptiveTimeouts.
+var DefaultAdaptiveTimeouts = AdaptiveTimeouts{
+	Percentile: 0.99,
+	Multiplier: 3,
+	Floor:      time.Second,
+	Ceiling:    2 * time.Minute,
+	Window:     200,
+	MinSamples: 20,
+}
+
+// adaptiveTimeouts keeps a latency window per statement class.
+type adaptiveTimeouts struct {
+	cfg     AdaptiveTimeouts
+	mu      sync.Mutex
+	samples [2]latencyWindow // indexed by opRead, opWrite
+}
+
+// latencyWindow is a ring of recent latencies.
+type latencyWindow struct {
+	buf  []time.Duration
+	next int
+}
+
+func (w *latencyWindow) add(d time.Duration, size int) {
+	if len(w.buf) < size {
+		w.buf = append(w.buf, d)
+		return
+	}
+	w.buf[w.next] = d
+	w.next = (w.next + 1) % size
+}
+
+// WithAdaptiveTimeouts enables adaptive read and write deadlines for
+// slow-but-working links such as satellite backhaul, where fixed timeouts
+// either fire spuriously or are too loose everywhere else. Bulk operations
+// keep their fixed deadline. Like WithOperationTimeouts, the deadline only
+// applies when the caller's context has none, and the HTTP client's overall
+// Timeout is cleared. Status reports the current deadlines.
+func (s *service) WithAdaptiveTimeouts(a AdaptiveTimeouts) *service {
+	d := DefaultAdaptiveTimeouts
+	if a.Percentile <= 0 || a.Percentile > 1 {
+		a.Percentile = d.Percentile
+	}
+	if a.Multiplier <= 0 {
+		a.Multiplier = d.Multiplier
+	}
+	if a.Floor <= 0 {
+		a.Floor = d.Floor
+	}
+	if a.Ceiling <= 0 {
+		a.Ceiling = d.Ceiling
+	}
+	if a.Ceiling < a.Floor {
+		a.Ceiling = a.Floor
+	}
+	if a.Window <= 0 {
+		a.Window = d.Window
+	}
+	if a.MinSamples <= 0 {
+		a.MinSamples = d.MinSamples
+	}
+	if a.MinSamples > a.Window {
+		a.MinSamples = a.Window
+	}
+	s.adaptive = &adaptiveTimeouts{cfg: a}
+	if s.opTimeouts == nil {
+		s.WithOperationTimeouts(OperationTimeouts{})
+	}
+	return s
+}
+
+// recordLatency feeds one statement's latency into the adaptive window.
+// Failures other than a deadline say nothing about link speed and are
+// skipped; a statement that hit its deadline is counted at the deadline so
+// the window grows when the link slows down.
+func (s *service) recordLatency(query string, d time.Duration, err error) {
+	a := s.adaptive
+	if a == nil || (err != nil && !errors.Is(err, context.DeadlineExceeded)) {
+		return
+	}
+	class := opWrite
+	if isReadStatement(query) {
+		class = opRead
+	}
+	a.mu.Lock()
+	a.samples[class].add(d, a.cfg.Window)
+	a.mu.Unlock()
+}
+
+// adaptiveDeadline returns the learned deadline for class, or false while
+// too few samples have been seen.
+func (s *service) adaptiveDeadline(class opClass) (time.Duration, bool) {
+	a := s.adaptive
+	if a == nil || class == opBulk {
+		return 0, false
+	}
+	a.mu.Lock()
+	sorted := append([]time.Duration(nil), a.samples[class].buf...)
+	a.mu.Unlock()
+	if len(sorted) < a.cfg.MinSamples {
+		return 0, false
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	p := sorted[int(a.cfg.Percentile*float64(len(sorted)-1))]
+	d := time.Duration(float64(p) * a.cfg.Multiplier)
+	return min(max(d, a.cfg.Floor), a.cfg.Ceiling), true
+}
+
+// adaptiveStatus reports the current adaptive deadlines for Status.
+func (s *service) adaptiveStatus() map[string]any {
+	st := map[string]any{}
+	for name, class := range map[string]opClass{"read": opRead, "write": opWrite} {
+		if d, ok := s.adaptiveDeadline(class); ok {
+			st[name] = d.String()
+		} else {
+			st[name] = "learning"
+		}
+	}
+	return st
+}
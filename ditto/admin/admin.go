@@ -0,0 +1,268 @@
+// Package admin wraps Ditto HTTP endpoints other than /execute (app and
+// device information, authentication, attachments, and sync control) in
+// typed client methods.
+//
+// The ditto package only relies on the documented {appID}/execute endpoint
+// of the Edge server. The other endpoints default to the Edge server's
+// layout under the same {appID} prefix (see DefaultEndpoints); set fields of
+// Endpoints to override individual paths for other server releases or
+// deployments such as the cloud Big Peer.
+package admin
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrNotConfigured is returned by Do when called without a path.
+var ErrNotConfigured = errors.New("admin: endpoint not configured")
+
+// DefaultEndpoints are the Edge server paths used for every field left empty
+// in Client.Endpoints.
+var DefaultEndpoints = Endpoints{
+	AppInfo:    "{app}/info",
+	DeviceInfo: "{app}/device",
+	Auth:       "{app}/auth",
+	Attachment: "{app}/attachments",
+	SyncPause:  "{app}/sync/pause",
+	SyncResume: "{app}/sync/resume",
+}
+
+// Endpoints holds endpoint paths relative to the client's BaseURL. In each
+// path "{app}" is replaced by the app ID and "{id}" by the attachment id.
+// Empty fields fall back to DefaultEndpoints.
+type Endpoints struct {
+	AppInfo    string // GET: application metadata
+	DeviceInfo string // GET: information about the serving peer
+	Auth       string // POST: exchange a provider token for a session
+	Attachment string // POST to upload; GET ".../{id}" to download
+	SyncPause  string // POST: stop syncing with peers
+	SyncResume string // POST: resume syncing with peers
+}
+
+// Client calls the administrative endpoints of one Ditto app.
+type Client struct {
+	BaseURL   string
+	AppID     string
+	Token     string // sent as a bearer token when set
+	HTTP      *http.Client
+	Endpoints Endpoints
+}
+
+// New returns a Client with a default HTTP client. Pass a zero Endpoints to
+// use DefaultEndpoints, or set only the paths that differ.
+func New(baseURL, appID string, ep Endpoints) *Client {
+	return &Client{
+		BaseURL:   baseURL,
+		AppID:     appID,
+		HTTP:      &http.Client{Timeout: 30 * time.Second},
+		Endpoints: ep,
+	}
+}
+
+// AuthResult is the outcome of Authenticate. Raw holds the full response.
+type AuthResult struct {
+	Authenticated bool           `json:"authenticated"`
+	UserID        string         `json:"userID,omitempty"`
+	ExpiresAt     string         `json:"expiresAt,omitempty"`
+	Raw           map[string]any `json:"-"`
+}
+
+// AttachmentInfo describes an uploaded attachment. Raw holds the full
+// response.
+type AttachmentInfo struct {
+	ID   string         `json:"id"`
+	Len  int64          `json:"len,omitempty"`
+	Meta map[string]any `json:"metadata,omitempty"`
+	Raw  map[string]any `json:"-"`
+}
+
+// AppInfo returns the application's metadata.
+func (c *Client) AppInfo(ctx context.Context) (map[string]any, error) {
+	var out map[string]any
+	return out, c.call(ctx, http.MethodGet, c.endpoints().AppInfo, "", nil, &out)
+}
+
+// DeviceInfo returns information about the serving peer.
+func (c *Client) DeviceInfo(ctx context.Context) (map[string]any, error) {
+	var out map[string]any
+	return out, c.call(ctx, http.MethodGet, c.endpoints().DeviceInfo, "", nil, &out)
+}
+
+// Authenticate exchanges a token from an authentication provider for a
+// session. On success the client does not change its own Token.
+func (c *Client) Authenticate(ctx context.Context, provider, token string) (AuthResult, error) {
+	var raw map[string]any
+	err := c.call(ctx, http.MethodPost, c.endpoints().Auth, "",
+		map[string]any{"provider": provider, "token": token}, &raw)
+	if err != nil {
+		return AuthResult{}, err
+	}
+	var res AuthResult
+	if err := remarshal(raw, &res); err != nil {
+		return AuthResult{}, err
+	}
+	res.Raw = raw
+	return res, nil
+}
+
+// UploadAttachment uploads the bytes of r as a new attachment.
+func (c *Client) UploadAttachment(ctx context.Context, r io.Reader) (AttachmentInfo, error) {
+	req, err := c.request(ctx, http.MethodPost, c.endpoints().Attachment, "", r)
+	if err != nil {
+		return AttachmentInfo{}, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	var raw map[string]any
+	if err := c.do(req, &raw); err != nil {
+		return AttachmentInfo{}, err
+	}
+	var info AttachmentInfo
+	if err := remarshal(raw, &info); err != nil {
+		return AttachmentInfo{}, err
+	}
+	info.Raw = raw
+	return info, nil
+}
+
+// DownloadAttachment copies the attachment with the given id to w.
+func (c *Client) DownloadAttachment(ctx context.Context, id string, w io.Writer) error {
+	path := c.endpoints().Attachment
+	if !strings.Contains(path, "{id}") {
+		path = strings.TrimRight(path, "/") + "/{id}"
+	}
+	req, err := c.request(ctx, http.MethodGet, path, id, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return statusError(req, resp)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// SetSync pauses (enabled false) or resumes syncing with peers.
+func (c *Client) SetSync(ctx context.Context, enabled bool) error {
+	ep := c.endpoints()
+	path := ep.SyncPause
+	if enabled {
+		path = ep.SyncResume
+	}
+	return c.call(ctx, http.MethodPost, path, "", nil, nil)
+}
+
+// Do sends in (JSON-encoded when non-nil) to path and decodes the JSON
+// response into out (ignored when nil), for endpoints without a typed
+// method.
+func (c *Client) Do(ctx context.Context, method, path string, in, out any) error {
+	return c.call(ctx, method, path, "", in, out)
+}
+
+// endpoints returns c.Endpoints with empty paths taken from
+// DefaultEndpoints.
+func (c *Client) endpoints() Endpoints {
+	ep, def := c.Endpoints, DefaultEndpoints
+	return Endpoints{
+		AppInfo:    cmp.Or(ep.AppInfo, def.AppInfo),
+		DeviceInfo: cmp.Or(ep.DeviceInfo, def.DeviceInfo),
+		Auth:       cmp.Or(ep.Auth, def.Auth),
+		Attachment: cmp.Or(ep.Attachment, def.Attachment),
+		SyncPause:  cmp.Or(ep.SyncPause, def.SyncPause),
+		SyncResume: cmp.Or(ep.SyncResume, def.SyncResume),
+	}
+}
+
+// call performs one JSON round trip.
+func (c *Client) call(ctx context.Context, method, path, id string, in, out any) error {
+	if path == "" {
+		return ErrNotConfigured
+	}
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := c.request(ctx, method, path, id, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.do(req, out)
+}
+
+// request builds a request for path with placeholders expanded.
+func (c *Client) request(ctx context.Context, method, path, id string, body io.Reader) (*http.Request, error) {
+	path = strings.ReplaceAll(path, "{app}", url.PathEscape(c.AppID))
+	path = strings.ReplaceAll(path, "{id}", url.PathEscape(id))
+	u := strings.TrimRight(c.BaseURL, "/") + "/" + strings.TrimLeft(path, "/")
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	return req, nil
+}
+
+// do sends req and decodes a JSON response into out.
+func (c *Client) do(req *http.Request, out any) error {
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return statusError(req, resp)
+	}
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	dec := json.NewDecoder(resp.Body)
+	dec.UseNumber()
+	if err := dec.Decode(out); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("decode %s %s: %w", req.Method, req.URL.Path, err)
+	}
+	return nil
+}
+
+// statusError reports a non-2xx response with an excerpt of its body.
+func statusError(req *http.Request, resp *http.Response) error {
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 257))
+	snippet := strings.TrimSpace(string(raw))
+	if len(snippet) > 256 {
+		snippet = snippet[:256] + "..."
+	}
+	return fmt.Errorf("ditto http %d: %s | %s %s", resp.StatusCode, snippet, req.Method, req.URL.Path)
+}
+
+// remarshal converts a decoded JSON object into a typed struct.
+func remarshal(in map[string]any, out any) error {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
@@ -0,0 +1,104 @@
+package ditto
+
+import (
+	"sync"
+	"time"
+)
+
+// ArgEncoder converts one query_args value before it is JSON-encoded,
+// reporting false to leave the value to the next encoder (and finally to
+// encoding/json). Encoders see every value, including those nested inside
+// map[string]any, []any, and []map[string]any arguments such as inserted
+// documents.
+type ArgEncoder func(v any) (any, bool)
+
+// globalArgEncoders apply to every service, after its own encoders.
+var globalArgEncoders struct {
+	mu   sync.RWMutex
+	encs []ArgEncoder
+}
+
+// RegisterArgEncoder adds enc for all services. Register encoders during
+// program initialization; per-service encoders (WithArgEncoder) take
+// precedence.
+func RegisterArgEncoder(enc ArgEncoder) {
+	globalArgEncoders.mu.Lock()
+	globalArgEncoders.encs = append(globalArgEncoders.encs, enc)
+	globalArgEncoders.mu.Unlock()
+}
+
+// WithArgEncoder adds enc for this service; encoders run in the order
+// added, before the global ones.
+func (s *service) WithArgEncoder(enc ArgEncoder) *service {
+	s.argEncoders = append(s.argEncoders, enc)
+	return s
+}
+
+// EncodeType returns an ArgEncoder that converts values of type T with fn,
+// e.g. EncodeType(func(u uuid.UUID) any { return u.String() }).
+func EncodeType[T any](fn func(T) any) ArgEncoder {
+	return func(v any) (any, bool) {
+		t, ok := v.(T)
+		if !ok {
+			return nil, false
+		}
+		return fn(t), true
+	}
+}
+
+// TimeAsEpochMillis encodes time.Time values as Unix milliseconds.
+func TimeAsEpochMillis() ArgEncoder {
+	return EncodeType(func(t time.Time) any { return t.UnixMilli() })
+}
+
+// TimeAsTimestamp encodes time.Time values with FormatTimestamp, so they
+// compare correctly against timestamps written by this SDK.
+func TimeAsTimestamp() ArgEncoder {
+	return EncodeType(func(t time.Time) any { return FormatTimestamp(t) })
+}
+
+// encodeArgs applies the service and global encoders to args, returning
+// args unchanged when none are registered.
+func (s *service) encodeArgs(args map[string]any) map[string]any {
+	globalArgEncoders.mu.RLock()
+	encs := append(append([]ArgEncoder(nil), s.argEncoders...), globalArgEncoders.encs...)
+	globalArgEncoders.mu.RUnlock()
+	if len(encs) == 0 || args == nil {
+		return args
+	}
+	out := make(map[string]any, len(args))
+	for k, v := range args {
+		out[k] = encodeValue(encs, v)
+	}
+	return out
+}
+
+// encodeValue applies encs to v and, recursively, to its elements.
+func encodeValue(encs []ArgEncoder, v any) any {
+	for _, enc := range encs {
+		if out, ok := enc(v); ok {
+			return out
+		}
+	}
+	switch x := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(x))
+		for k, e := range x {
+			out[k] = encodeValue(encs, e)
+		}
+		return out
+	case []any:
+		out := make([]any, len(x))
+		for i, e := range x {
+			out[i] = encodeValue(encs, e)
+		}
+		return out
+	case []map[string]any:
+		out := make([]any, len(x))
+		for i, e := range x {
+			out[i] = encodeValue(encs, e)
+		}
+		return out
+	}
+	return v
+}
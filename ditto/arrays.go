@@ -0,0 +1,124 @@
+package ditto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+)
+
+// ArrayOp is an update patch value that modifies an array field instead of
+// replacing it. Build one with AppendToArray or RemoveFromArray.
+type ArrayOp struct {
+	remove bool
+	values []any
+}
+
+// AppendToArray appends values to an array field:
+// patch{"tags": ditto.AppendToArray("urgent")}. A missing field starts empty.
+func AppendToArray(values ...any) ArrayOp { return ArrayOp{values: values} }
+
+// RemoveFromArray removes every element equal to one of values from an array
+// field: patch{"tags": ditto.RemoveFromArray("stale")}.
+func RemoveFromArray(values ...any) ArrayOp { return ArrayOp{remove: true, values: values} }
+
+// apply returns the array resulting from applying op to current.
+func (op ArrayOp) apply(current any) ([]any, error) {
+	var arr []any
+	switch c := current.(type) {
+	case nil:
+	case []any:
+		arr = append(arr, c...)
+	default:
+		return nil, fmt.Errorf("field is %T, not an array", current)
+	}
+	if !op.remove {
+		return append(arr, op.values...), nil
+	}
+	out := arr[:0]
+	for _, el := range arr {
+		drop := false
+		for _, v := range op.values {
+			if reflect.DeepEqual(el, normalizeJSON(v)) {
+				drop = true
+				break
+			}
+		}
+		if !drop {
+			out = append(out, el)
+		}
+	}
+	return out, nil
+}
+
+// normalizeJSON maps common Go scalar types onto their decoded-JSON form so
+// they compare equal to array elements read back from Ditto.
+func normalizeJSON(v any) any {
+	if f, ok := toFloat(v); ok {
+		if _, isNum := v.(float64); !isNum {
+			return f
+		}
+	}
+	return v
+}
+
+// hasArrayOps reports whether patch contains any ArrayOp values.
+func hasArrayOps(patch map[string]any) bool {
+	for _, v := range patch {
+		if _, ok := v.(ArrayOp); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveArrayOps reads the current document and replaces every ArrayOp in
+// patch with the resulting full array. This is a read-modify-write, so
+// concurrent writers to the same array can race; combine with
+// UpdateRecordRevision when that matters.
+func (s *service) resolveArrayOps(ctx context.Context, collection, id string, patch map[string]any) (map[string]any, error) {
+	if !hasArrayOps(patch) {
+		return patch, nil
+	}
+	s.cache.invalidate(collection, id)
+	res, err := s.GetRecord(ctx, collection, id)
+	if err != nil {
+		return nil, err
+	}
+	docs := Documents(res)
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("update %s/%s: record not found", collection, id)
+	}
+	out := make(map[string]any, len(patch))
+	for k, v := range patch {
+		op, ok := v.(ArrayOp)
+		if !ok {
+			out[k] = v
+			continue
+		}
+		arr, err := op.apply(lookupPath(docs[0], k))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+		out[k] = arr
+	}
+	return out, nil
+}
+
+// errArrayOpUnsupported is returned when array mutators reach a bulk update.
+var errArrayOpUnsupported = errors.New("array mutators are only supported by single-record updates")
+
+// ArrayContains returns a predicate matching documents whose array field
+// contains value, using DQL's array_contains.
+func ArrayContains(field string, value any) Predicate {
+	name := "contains_" + paramSafe.ReplaceAllString(field, "_")
+	return Predicate{
+		//dittovet:ignore name is field with paramSafe replacing everything but [A-Za-z0-9_]
+		Clause: fmt.Sprintf("array_contains(%s, :%s)", escapePath(field), name),
+		Args:   map[string]any{name: value},
+	}
+}
+
+// paramSafe matches characters not allowed in DQL parameter names.
+var paramSafe = regexp.MustCompile(`[^A-Za-z0-9_]`)
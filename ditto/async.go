@@ -0,0 +1,166 @@
+package ditto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrAsyncQueueFull is returned by a Future whose write could not be queued
+// because the async queue was full.
+var ErrAsyncQueueFull = errors.New("ditto: async queue full")
+
+// Async worker defaults used until WithAsyncWorkers is called.
+const (
+	defaultAsyncWorkers = 4
+	defaultAsyncQueue   = 256
+)
+
+// Future is the pending result of an async write.
+type Future struct {
+	done chan struct{}
+	res  any
+	err  error
+}
+
+func newFuture() *Future { return &Future{done: make(chan struct{})} }
+
+func (f *Future) complete(res any, err error) {
+	f.res, f.err = res, err
+	close(f.done)
+}
+
+// Done is closed once the write has finished.
+func (f *Future) Done() <-chan struct{} { return f.done }
+
+// Wait blocks until the write finishes or ctx is done. Abandoning the wait
+// does not cancel the write.
+func (f *Future) Wait(ctx context.Context) (any, error) {
+	select {
+	case <-f.done:
+		return f.res, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// asyncJob is one queued write.
+type asyncJob struct {
+	run func(ctx context.Context) (any, error)
+	f   *Future
+}
+
+// asyncPool is the worker pool behind the *Async methods. Workers start on
+// first use and stop with Close.
+type asyncPool struct {
+	mu      sync.Mutex
+	workers int
+	jobs    chan asyncJob
+	running int
+}
+
+// WithAsyncWorkers sizes the worker pool used by CreateDocumentAsync and
+// UpdateRecordAsync: workers concurrent writes and a queue of queue pending
+// ones. Call it before the first async write.
+func (s *service) WithAsyncWorkers(workers, queue int) *service {
+	s.async.mu.Lock()
+	defer s.async.mu.Unlock()
+	if workers > 0 {
+		s.async.workers = workers
+	}
+	if queue > 0 && s.async.running == 0 {
+		s.async.jobs = make(chan asyncJob, queue)
+	}
+	return s
+}
+
+// CreateDocumentAsync queues CreateDocument and returns immediately. The
+// write keeps ctx's values but not its cancellation, so it survives the
+// request handler that issued it; Close waits for it during the shutdown
+// grace period and cancels it after that. doc must not be modified until
+// the Future is done.
+func (s *service) CreateDocumentAsync(ctx context.Context, collection string, doc map[string]any) *Future {
+	return s.enqueue(ctx, func(ctx context.Context) (any, error) {
+		return s.CreateDocument(ctx, collection, doc)
+	})
+}
+
+// UpdateRecordAsync queues UpdateRecord like CreateDocumentAsync.
+func (s *service) UpdateRecordAsync(ctx context.Context, collection, id string, patch map[string]any) *Future {
+	return s.enqueue(ctx, func(ctx context.Context) (any, error) {
+		return s.UpdateRecord(ctx, collection, id, patch)
+	})
+}
+
+// enqueue starts the workers if needed and queues run without blocking.
+func (s *service) enqueue(ctx context.Context, run func(ctx context.Context) (any, error)) *Future {
+	f := newFuture()
+	detached := context.WithoutCancel(ctx)
+	job := asyncJob{f: f, run: func(bg context.Context) (any, error) {
+		// Cancelled by Close, not by the caller
+		jctx, cancel := context.WithCancel(detached)
+		defer cancel()
+		stop := context.AfterFunc(bg, cancel)
+		defer stop()
+		return run(jctx)
+	}}
+
+	p := &s.async
+	p.mu.Lock()
+	if p.jobs == nil {
+		p.jobs = make(chan asyncJob, defaultAsyncQueue)
+	}
+	if p.workers <= 0 {
+		p.workers = defaultAsyncWorkers
+	}
+	for p.running < p.workers {
+		p.running++
+		jobs := p.jobs
+		s.goBackground("async-worker", func(ctx context.Context) { s.asyncWorker(ctx, jobs) })
+	}
+	jobs := p.jobs
+	p.mu.Unlock()
+
+	// Queued writes count as in flight so Close waits for them
+	s.ops.add()
+	select {
+	case jobs <- job:
+	default:
+		s.ops.done()
+		f.complete(nil, ErrAsyncQueueFull)
+	}
+	return f
+}
+
+// asyncWorker runs queued writes until ctx ends, then fails what is left.
+// Workers stay counted in async.running until stopBackground returns.
+func (s *service) asyncWorker(ctx context.Context, jobs chan asyncJob) {
+	for {
+		select {
+		case job := <-jobs:
+			job.f.complete(runJob(ctx, job))
+			s.ops.done()
+		case <-ctx.Done():
+			for {
+				select {
+				case job := <-jobs:
+					job.f.complete(nil, ctx.Err())
+					s.ops.done()
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// runJob runs job, turning a panic into an error so its Future completes.
+func runJob(ctx context.Context, job asyncJob) (res any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("ditto: async write panicked: %v", r)
+		}
+	}()
+	return job.run(ctx)
+}
@@ -0,0 +1,119 @@
+package ditto
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Backoff between restarts of a background goroutine that panicked.
+const (
+	minRestartDelay = time.Second
+	maxRestartDelay = time.Minute
+)
+
+// background tracks goroutines owned by the service (view refreshers and
+// other scheduled work) so Close can stop them and wait for them to exit.
+// Each runs under a supervisor that recovers panics and restarts it.
+type background struct {
+	mu     sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	tasks  map[string]*TaskHealth
+}
+
+// TaskHealth describes the background goroutines sharing one name.
+type TaskHealth struct {
+	Name        string
+	Running     int       // goroutines currently running under this name
+	Restarts    int       // restarts after a panic
+	LastPanic   string    // panic value of the latest panic
+	LastPanicAt time.Time // zero when it never panicked
+}
+
+// goBackground runs fn in a goroutine with a context cancelled by Close. If
+// fn panics, the panic is logged and fn is started again after a backoff, so
+// fn must be safe to restart.
+func (s *service) goBackground(name string, fn func(ctx context.Context)) {
+	s.bg.mu.Lock()
+	if s.bg.ctx == nil {
+		s.bg.ctx, s.bg.cancel = context.WithCancel(context.Background())
+	}
+	if s.bg.tasks == nil {
+		s.bg.tasks = map[string]*TaskHealth{}
+	}
+	h := s.bg.tasks[name]
+	if h == nil {
+		h = &TaskHealth{Name: name}
+		s.bg.tasks[name] = h
+	}
+	h.Running++
+	ctx := s.bg.ctx
+	s.bg.wg.Add(1)
+	s.bg.mu.Unlock()
+
+	go func() {
+		defer s.bg.wg.Done()
+		defer func() {
+			s.bg.mu.Lock()
+			h.Running--
+			s.bg.mu.Unlock()
+		}()
+		delay := minRestartDelay
+		for {
+			if s.supervise(ctx, h, fn) {
+				return
+			}
+			if s.sleep(ctx, delay) != nil {
+				return
+			}
+			delay = min(delay*2, maxRestartDelay)
+			s.bg.mu.Lock()
+			h.Restarts++
+			s.bg.mu.Unlock()
+		}
+	}()
+}
+
+// supervise runs fn once, reporting false if it panicked.
+func (s *service) supervise(ctx context.Context, h *TaskHealth, fn func(ctx context.Context)) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.bg.mu.Lock()
+			h.LastPanic, h.LastPanicAt = fmt.Sprint(r), s.now()
+			s.bg.mu.Unlock()
+			s.log().Error("ditto background task panicked", "task", h.Name, "panic", r, "stack", string(debug.Stack()))
+		}
+	}()
+	fn(ctx)
+	return true
+}
+
+// BackgroundHealth reports the service's background goroutines by name,
+// including those that have exited, in name order.
+func (s *service) BackgroundHealth() []TaskHealth {
+	s.bg.mu.Lock()
+	defer s.bg.mu.Unlock()
+	out := make([]TaskHealth, 0, len(s.bg.tasks))
+	for _, h := range s.bg.tasks {
+		out = append(out, *h)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// stopBackground cancels all background goroutines and waits for them.
+func (s *service) stopBackground() {
+	s.bg.mu.Lock()
+	cancel := s.bg.cancel
+	s.bg.ctx, s.bg.cancel = nil, nil
+	s.bg.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	s.bg.wg.Wait()
+}
@@ -0,0 +1,586 @@
+package ditto
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupPrefix starts the name of every backup archive, followed by the
+// UTC creation time in backupStamp layout.
+const (
+	backupPrefix = "ditto-backup-"
+	backupStamp  = "20060102T150405.000Z"
+)
+
+// Backup archive suffixes by kind, before the compression extension.
+const (
+	ndjsonSuffix  = ".ndjson"
+	dataDirSuffix = ".tar"
+)
+
+// BackupKind selects what a backup captures.
+type BackupKind int
+
+const (
+	// BackupNDJSON exports the listed collections through the HTTP API into
+	// a compressed NDJSON archive that RestoreBackup can load.
+	BackupNDJSON BackupKind = iota
+	// BackupDataDir archives the container's data directory
+	// (DockerOptions.DataPath) as a compressed tar.
+	BackupDataDir
+)
+
+// Uploader copies a finished backup archive elsewhere, e.g. to object
+// storage.
+type Uploader interface {
+	Upload(ctx context.Context, name string, r io.Reader) error
+}
+
+// BackupStore is an Uploader that can also list, read, and remove archives,
+// so backups can be rotated and restored without local disk (see the s3
+// subpackage).
+type BackupStore interface {
+	Uploader
+	List(ctx context.Context, prefix string) ([]string, error)
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
+	Remove(ctx context.Context, name string) error
+}
+
+// BackupOptions configures Backup and ScheduleBackup.
+type BackupOptions struct {
+	Kind BackupKind
+	// Dir is the local directory holding the archives. When empty the
+	// archive is streamed straight to Uploader without touching local disk.
+	Dir         string
+	Collections []string // collections exported by BackupNDJSON
+	// PauseContainer stops the container while BackupDataDir copies the
+	// data directory, for a consistent copy; otherwise files are copied
+	// while the server runs.
+	PauseContainer bool
+	KeepLast       int           // keep at most this many archives; zero keeps all
+	MaxAge         time.Duration // remove archives older than this; zero keeps all
+	// Uploader receives every new archive. When it is a BackupStore,
+	// KeepLast and MaxAge rotate the remote archives too.
+	Uploader Uploader
+	Interval time.Duration // schedule period for ScheduleBackup; defaults to 24h
+	// Keys encrypts archives with AES-GCM (adding ".enc" to their names);
+	// nil falls back to the service's WithBackupKeys provider.
+	Keys KeyProvider
+	// Compression compresses archives, adding its extension to their
+	// names; nil means Gzip. Use NoCompression for plain archives.
+	Compression Compression
+}
+
+// BackupInfo describes one archive.
+type BackupInfo struct {
+	Name        string
+	Path        string
+	Kind        BackupKind
+	Size        int64
+	CreatedAt   time.Time
+	Documents   int    // documents exported; BackupNDJSON only
+	Encrypted   bool   // sealed with a KeyProvider
+	Compression string // codec name, e.g. "gzip", "zstd", or "none"
+}
+
+// backupHeader is the first line of an NDJSON archive.
+type backupHeader struct {
+	Version     int      `json:"ditto_backup"`
+	CreatedAt   string   `json:"created_at"`
+	Collections []string `json:"collections"`
+}
+
+// backupLine is one document of an NDJSON archive.
+type backupLine struct {
+	Collection string         `json:"collection"`
+	Doc        map[string]any `json:"doc"`
+}
+
+// Backup writes one archive to opts.Dir, uploads it when an Uploader is
+// set, and then rotates old archives by KeepLast and MaxAge. Without a Dir
+// the archive is streamed to the Uploader directly.
+func (s *service) Backup(ctx context.Context, opts BackupOptions) (BackupInfo, error) {
+	ctx, cancel := s.opDeadline(ctx, opBulk)
+	defer cancel()
+	if opts.Dir == "" && opts.Uploader == nil {
+		return BackupInfo{}, errors.New("backup needs Dir or Uploader")
+	}
+	now := s.now().UTC()
+	if opts.Compression == nil {
+		opts.Compression = Gzip
+	}
+	info := BackupInfo{Kind: opts.Kind, CreatedAt: now, Compression: opts.Compression.Name()}
+	suffix := ndjsonSuffix
+	if opts.Kind == BackupDataDir {
+		suffix = dataDirSuffix
+	}
+	suffix += opts.Compression.Extension()
+	if opts.Keys == nil {
+		opts.Keys = s.backupKeys
+	}
+	if opts.Keys != nil {
+		suffix += encryptSuffix
+		info.Encrypted = true
+	}
+	info.Name = backupPrefix + now.Format(backupStamp) + suffix
+	if opts.Dir == "" {
+		return info, s.streamBackup(ctx, opts, &info)
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return BackupInfo{}, err
+	}
+	info.Path = filepath.Join(opts.Dir, info.Name)
+
+	tmp, err := os.CreateTemp(opts.Dir, ".backup-*")
+	if err != nil {
+		return info, err
+	}
+	defer os.Remove(tmp.Name())
+	info.Documents, err = s.writeBackup(ctx, tmp, opts, now)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return info, fmt.Errorf("backup: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), info.Path); err != nil {
+		return info, fmt.Errorf("backup: %w", err)
+	}
+	if st, err := os.Stat(info.Path); err == nil {
+		info.Size = st.Size()
+	}
+
+	if opts.Uploader != nil {
+		f, err := os.Open(info.Path)
+		if err != nil {
+			return info, err
+		}
+		err = opts.Uploader.Upload(ctx, info.Name, f)
+		f.Close()
+		if err != nil {
+			return info, fmt.Errorf("backup upload %s: %w", info.Name, err)
+		}
+	}
+	if err := s.rotateBackups(ctx, opts, info.Name); err != nil {
+		return info, fmt.Errorf("backup rotate: %w", err)
+	}
+	return info, nil
+}
+
+// streamBackup pipes the archive into opts.Uploader as it is produced.
+func (s *service) streamBackup(ctx context.Context, opts BackupOptions, info *BackupInfo) error {
+	pr, pw := io.Pipe()
+	counted := &countingWriter{w: pw}
+	done := make(chan struct{})
+	var werr error
+	go func() {
+		defer close(done)
+		info.Documents, werr = s.writeBackup(ctx, counted, opts, info.CreatedAt)
+		pw.CloseWithError(werr)
+	}()
+	err := opts.Uploader.Upload(ctx, info.Name, pr)
+	pr.CloseWithError(err) // unblock the writer if the upload gave up early
+	<-done
+	if err != nil {
+		return fmt.Errorf("backup upload %s: %w", info.Name, err)
+	}
+	// An uploader that stops reading early, or one that ignores the read
+	// error of a failed archive, must not turn a truncated backup into a
+	// success
+	if werr != nil {
+		return fmt.Errorf("backup %s: %w", info.Name, werr)
+	}
+	info.Size = counted.n
+	if err := s.rotateBackups(ctx, opts, info.Name); err != nil {
+		return fmt.Errorf("backup rotate: %w", err)
+	}
+	return nil
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// ScheduleBackup runs Backup every opts.Interval as the maintenance job
+// "backup:<dir>" ("backup:upload" when streaming).
+func (s *service) ScheduleBackup(opts BackupOptions) error {
+	if opts.Dir == "" && opts.Uploader == nil {
+		return errors.New("backup needs Dir or Uploader")
+	}
+	if opts.Kind == BackupNDJSON && len(opts.Collections) == 0 {
+		return errors.New("NDJSON backup needs Collections")
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = 24 * time.Hour
+	}
+	name := "backup:" + opts.Dir
+	if opts.Dir == "" {
+		name = "backup:upload"
+	}
+	return s.ScheduleMaintenance(MaintenanceJob{
+		Name:     name,
+		Interval: opts.Interval,
+		Run: func(ctx context.Context) error {
+			_, err := s.Backup(ctx, opts)
+			return err
+		},
+	})
+}
+
+// writeBackup writes the archive selected by opts.Kind to w, encrypting it
+// when opts.Keys is set.
+func (s *service) writeBackup(ctx context.Context, w io.Writer, opts BackupOptions, now time.Time) (int, error) {
+	if opts.Keys != nil {
+		ew, err := EncryptWriter(ctx, w, opts.Keys)
+		if err != nil {
+			return 0, err
+		}
+		opts.Keys = nil
+		n, err := s.writeBackup(ctx, ew, opts, now)
+		if cerr := ew.Close(); err == nil {
+			err = cerr
+		}
+		return n, err
+	}
+	if opts.Kind == BackupDataDir {
+		return 0, s.writeDataDirBackup(ctx, w, opts)
+	}
+	return s.writeNDJSONBackup(ctx, w, opts, now)
+}
+
+// writeNDJSONBackup streams the collections into a compressed NDJSON
+// archive.
+func (s *service) writeNDJSONBackup(ctx context.Context, w io.Writer, opts BackupOptions, now time.Time) (int, error) {
+	collections := opts.Collections
+	if len(collections) == 0 {
+		return 0, errors.New("NDJSON backup needs Collections")
+	}
+	zw, err := opts.Compression.NewWriter(w)
+	if err != nil {
+		return 0, err
+	}
+	bw := bufio.NewWriter(zw)
+	enc := json.NewEncoder(bw)
+	if err := enc.Encode(backupHeader{Version: 1, CreatedAt: FormatTimestamp(now), Collections: collections}); err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, c := range collections {
+		err := s.scanWhere(ctx, c, nil, QueryOptions{IncludeDeleted: true}, func(docs []map[string]any) error {
+			for _, d := range docs {
+				if err := enc.Encode(backupLine{Collection: c, Doc: d}); err != nil {
+					return err
+				}
+				n++
+			}
+			return nil
+		})
+		if err != nil {
+			return n, fmt.Errorf("export %s: %w", c, err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return n, err
+	}
+	return n, zw.Close()
+}
+
+// writeDataDirBackup archives the container data directory as a compressed
+// tar.
+func (s *service) writeDataDirBackup(ctx context.Context, w io.Writer, opts BackupOptions) error {
+	root := s.dockerOpts.DataPath
+	if root == "" {
+		return errors.New("data-dir backup needs DockerOptions.DataPath")
+	}
+	if opts.PauseContainer && s.docker != nil {
+		ctx := s.dockerCtx(ctx)
+		if err := s.docker.StopContainer(ctx, s.dockerOpts.ContainerName); err != nil {
+			return fmt.Errorf("pause container: %w", err)
+		}
+		defer func() {
+			_ = s.docker.StartContainer(context.WithoutCancel(ctx), s.dockerOpts.ContainerName)
+		}()
+	}
+	zw, err := opts.Compression.NewWriter(w)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(zw)
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() && !fi.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(fi, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.CopyN(tw, f, hdr.Size)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// rotateBackups removes archives beyond KeepLast or older than MaxAge from
+// Dir and, when the Uploader is a BackupStore, from the store; never the
+// one just written.
+func (s *service) rotateBackups(ctx context.Context, opts BackupOptions, keep string) error {
+	if opts.KeepLast <= 0 && opts.MaxAge <= 0 {
+		return nil
+	}
+	var errs []error
+	if opts.Dir != "" {
+		all, err := ListBackups(opts.Dir)
+		if err != nil {
+			return err
+		}
+		for _, b := range s.expiredBackups(opts, all, keep) {
+			if err := os.Remove(b.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if store, ok := opts.Uploader.(BackupStore); ok {
+		names, err := store.List(ctx, backupPrefix)
+		if err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+		var all []BackupInfo
+		for _, n := range names {
+			if b, ok := parseBackupName(n); ok {
+				all = append(all, b)
+			}
+		}
+		sortBackups(all)
+		for _, b := range s.expiredBackups(opts, all, keep) {
+			if err := store.Remove(ctx, b.Name); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// expiredBackups selects from all (newest first) the archives rotation
+// removes.
+func (s *service) expiredBackups(opts BackupOptions, all []BackupInfo, keep string) []BackupInfo {
+	now := s.now()
+	var out []BackupInfo
+	for i, b := range all {
+		if b.Name == keep {
+			continue
+		}
+		if (opts.KeepLast > 0 && i >= opts.KeepLast) || (opts.MaxAge > 0 && now.Sub(b.CreatedAt) > opts.MaxAge) {
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// ListBackups returns the archives in dir, newest first.
+func ListBackups(dir string) ([]BackupInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var out []BackupInfo
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		info, ok := parseBackupName(e.Name())
+		if !ok {
+			continue
+		}
+		info.Path = filepath.Join(dir, info.Name)
+		if fi, err := e.Info(); err == nil {
+			info.Size = fi.Size()
+		}
+		out = append(out, info)
+	}
+	sortBackups(out)
+	return out, nil
+}
+
+// parseBackupName recovers the kind and creation time from an archive name
+// (which may carry a directory or key prefix).
+func parseBackupName(name string) (BackupInfo, bool) {
+	base := name[strings.LastIndex(name, "/")+1:]
+	if !strings.HasPrefix(base, backupPrefix) {
+		return BackupInfo{}, false
+	}
+	info := BackupInfo{Name: name}
+	stamp := strings.TrimPrefix(base, backupPrefix)
+	if strings.HasSuffix(stamp, encryptSuffix) {
+		stamp, info.Encrypted = strings.TrimSuffix(stamp, encryptSuffix), true
+	}
+	if len(stamp) < len(backupStamp) {
+		return BackupInfo{}, false
+	}
+	stamp, rest := stamp[:len(backupStamp)], stamp[len(backupStamp):]
+	switch {
+	case strings.HasPrefix(rest, ndjsonSuffix):
+		rest, info.Kind = strings.TrimPrefix(rest, ndjsonSuffix), BackupNDJSON
+	case strings.HasPrefix(rest, dataDirSuffix):
+		rest, info.Kind = strings.TrimPrefix(rest, dataDirSuffix), BackupDataDir
+	default:
+		return BackupInfo{}, false
+	}
+	if rest != "" && !strings.HasPrefix(rest, ".") {
+		return BackupInfo{}, false
+	}
+	info.Compression = compressionName(rest)
+	t, err := time.Parse(backupStamp, stamp)
+	if err != nil {
+		return BackupInfo{}, false
+	}
+	info.CreatedAt = t
+	return info, true
+}
+
+// sortBackups orders archives newest first.
+func sortBackups(all []BackupInfo) {
+	sort.Slice(all, func(i, j int) bool { return all[i].CreatedAt.After(all[j].CreatedAt) })
+}
+
+// RestoreBackupFrom restores the named NDJSON archive from store.
+func (s *service) RestoreBackupFrom(ctx context.Context, store BackupStore, name string) (map[string]int, error) {
+	rc, err := store.Open(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("restore %s: %w", name, err)
+	}
+	defer rc.Close()
+	return s.RestoreBackup(ctx, rc)
+}
+
+// RestoreBackup loads an NDJSON archive read from r, upserting every
+// document into its collection, and returns the count per collection.
+// Encrypted archives are opened with the WithBackupKeys provider; the
+// compression is recognized from the archive's leading bytes among Gzip and
+// the codecs added with RegisterCompression. Data-dir
+// archives are restored by extracting them into DataPath while the
+// container is stopped; the SDK does not do that itself.
+func (s *service) RestoreBackup(ctx context.Context, r io.Reader) (map[string]int, error) {
+	ctx, cancel := s.opDeadline(ctx, opBulk)
+	defer cancel()
+	br := bufio.NewReader(r)
+	r = br
+	if isEncrypted(br) {
+		if s.backupKeys == nil {
+			return nil, errors.New("restore: archive is encrypted; configure WithBackupKeys")
+		}
+		dr, err := DecryptReader(ctx, br, s.backupKeys)
+		if err != nil {
+			return nil, fmt.Errorf("restore: %w", err)
+		}
+		r = dr
+	}
+	zr, err := decompress(bufio.NewReader(r))
+	if err != nil {
+		return nil, fmt.Errorf("restore: %w", err)
+	}
+	defer zr.Close()
+	sc := bufio.NewScanner(zr)
+	sc.Buffer(make([]byte, 64*1024), maxImportLine)
+	if !sc.Scan() {
+		return nil, fmt.Errorf("restore: empty archive: %w", sc.Err())
+	}
+	var hdr backupHeader
+	if err := json.Unmarshal(sc.Bytes(), &hdr); err != nil || hdr.Version != 1 {
+		return nil, errors.New("restore: not an NDJSON backup archive")
+	}
+
+	counts := map[string]int{}
+	batches := map[string][]map[string]any{}
+	flush := func(c string) error {
+		if len(batches[c]) == 0 {
+			return nil
+		}
+		if err := s.upsertDocs(ctx, c, batches[c]); err != nil {
+			return fmt.Errorf("restore %s: %w", c, err)
+		}
+		s.cache.invalidateCollection(c)
+		counts[c] += len(batches[c])
+		batches[c] = batches[c][:0]
+		return nil
+	}
+	for line := 2; sc.Scan(); line++ {
+		doc, err := decodeNDJSONLine(sc.Bytes())
+		if err != nil {
+			return counts, fmt.Errorf("restore: line %d: %w", line, err)
+		}
+		if doc == nil {
+			continue
+		}
+		c, _ := doc["collection"].(string)
+		d, _ := doc["doc"].(map[string]any)
+		if c == "" || d == nil {
+			return counts, fmt.Errorf("restore: line %d: malformed entry", line)
+		}
+		batches[c] = append(batches[c], d)
+		if len(batches[c]) == maxDocsPerInsert {
+			if err := flush(c); err != nil {
+				return counts, err
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return counts, fmt.Errorf("restore: %w", err)
+	}
+	for _, c := range sortedKeys(batches) {
+		if err := flush(c); err != nil {
+			return counts, err
+		}
+	}
+	return counts, nil
+}
@@ -0,0 +1,100 @@
+package ditto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// maxBatchConcurrency caps how many statements of one ExecuteBatch call are
+// in flight at once.
+const maxBatchConcurrency = 8
+
+// Statement is one DQL statement with its bound arguments.
+type Statement struct {
+	Query string
+	Args  map[string]any
+}
+
+// BatchResult is the outcome of one statement of a batch.
+type BatchResult struct {
+	Result any
+	Err    error
+}
+
+// ExecuteBatch runs several statements behind one call and returns their
+// results in order. Ditto's HTTP API accepts one statement per request, so
+// the statements are sent concurrently (up to maxBatchConcurrency at a time)
+// rather than in one round trip; they are not atomic and may apply in any
+// order. The returned error joins every per-statement failure.
+//
+// Statements are started in order. When the context deadline gets too close
+// to start the next one, the rest are left with ErrNotAttempted and the
+// error is a *PartialResult whose Continuation resumes the batch (see
+// WithContinuation); statements skipped by a continuation are left zero.
+func (s *service) ExecuteBatch(ctx context.Context, stmts []Statement) ([]BatchResult, error) {
+	ctx, cancel := s.opDeadline(ctx, opBulk)
+	defer cancel()
+	out := make([]BatchResult, len(stmts))
+	for i, st := range stmts {
+		if strings.TrimSpace(st.Query) == "" {
+			return nil, fmt.Errorf("statement %d: query required", i)
+		}
+	}
+	start, err := resumeIndex(ctx, "ExecuteBatch", len(stmts))
+	if err != nil {
+		return nil, err
+	}
+	guard := s.newDeadlineGuard(ctx)
+	prog := s.progress(ctx, knownTotal(len(stmts)))
+	prog.add(start)
+	sem := make(chan struct{}, maxBatchConcurrency)
+	var wg sync.WaitGroup
+	next, stopped := start, false
+	for ; next < len(stmts); next++ {
+		if guard.near() {
+			stopped = true
+			break
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			stopped = true
+		}
+		// Waiting for a free slot may have used up the time left
+		if !stopped && guard.near() {
+			<-sem
+			stopped = true
+		}
+		if stopped {
+			break
+		}
+		wg.Add(1)
+		go func(i int, st Statement) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			t0 := s.now()
+			out[i].Result, out[i].Err = s.execWithArgs(ctx, st.Query, st.Args)
+			guard.observe(s.now().Sub(t0))
+			prog.add(1)
+		}(next, stmts[next])
+	}
+	wg.Wait()
+
+	if stopped {
+		for i := next; i < len(stmts); i++ {
+			out[i].Err = ErrNotAttempted
+		}
+		return out, guard.partial("ExecuteBatch", start, next, len(stmts), func(i int) error { return out[i].Err })
+	}
+	prog.finish()
+	var errs []error
+	for i, r := range out[start:] {
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("statement %d: %w", start+i, r.Err))
+		}
+	}
+	return out, errors.Join(errs...)
+}
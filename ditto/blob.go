@@ -0,0 +1,226 @@
+package ditto
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// chunkSuffix is appended to a collection name to form the collection that
+// holds blob chunks; manifests live in the collection itself.
+const chunkSuffix = "_chunks"
+
+// blobChunkSize is the raw byte size of one chunk. Chunks are stored base64
+// encoded, so each chunk document is roughly 256 KiB on the wire.
+const blobChunkSize = 192 << 10
+
+// defaultBlobBatchBytes is the request body budget for one chunk write when
+// WithMaxPayloadBytes is unset.
+const defaultBlobBatchBytes = 8 << 20
+
+// blobBatchOverhead is the room left in each chunk write for the statement
+// and the request envelope, and blobChunkOverhead the per-document room for
+// the fields around a chunk's data.
+const (
+	blobBatchOverhead = 1 << 10
+	blobChunkOverhead = 256
+)
+
+// ErrBlobCorrupt is returned when reassembled chunks do not match the
+// manifest (missing chunks or checksum mismatch).
+var ErrBlobCorrupt = errors.New("ditto: blob corrupt")
+
+// BlobInfo is the manifest stored for a blob.
+type BlobInfo struct {
+	ID        string
+	Size      int
+	Chunks    int
+	SHA256    string
+	CreatedAt time.Time
+}
+
+// PutBlob stores data under id as a manifest document in collection plus
+// chunk documents in <collection>_chunks, for deployments where attachments
+// are not available. Chunks are written under a fresh generation before the
+// manifest, so readers never see a partially written blob; chunks of the
+// previous generation are removed afterwards.
+func (s *service) PutBlob(ctx context.Context, collection, id string, data []byte) (BlobInfo, error) {
+	if collection == "" || id == "" {
+		return BlobInfo{}, errors.New("collection and id required")
+	}
+	old, err := s.blobManifest(ctx, collection, id)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return BlobInfo{}, err
+	}
+
+	gen := strconv.FormatInt(s.now().UnixNano(), 36)
+	var chunks []map[string]any
+	for i, off := 0, 0; off < len(data) || i == 0; i, off = i+1, off+blobChunkSize {
+		end := min(off+blobChunkSize, len(data))
+		chunks = append(chunks, map[string]any{
+			"_id":     fmt.Sprintf("%s#%s#%d", id, gen, i),
+			"blob_id": id,
+			"gen":     gen,
+			"idx":     i,
+			"data":    base64.StdEncoding.EncodeToString(data[off:end]),
+		})
+	}
+	for _, batch := range s.blobBatches(chunks) {
+		if err := s.upsertDocs(ctx, collection+chunkSuffix, batch); err != nil {
+			return BlobInfo{}, fmt.Errorf("blob %s: write chunks: %w", id, err)
+		}
+	}
+
+	sum := sha256.Sum256(data)
+	info := BlobInfo{
+		ID:        id,
+		Size:      len(data),
+		Chunks:    len(chunks),
+		SHA256:    hex.EncodeToString(sum[:]),
+		CreatedAt: s.now().UTC(),
+	}
+	manifest := map[string]any{
+		"_id":        id,
+		"size":       info.Size,
+		"chunks":     info.Chunks,
+		"sha256":     info.SHA256,
+		"gen":        gen,
+		"created_at": FormatTimestamp(info.CreatedAt),
+	}
+	if err := s.upsertDocs(ctx, collection, []map[string]any{manifest}); err != nil {
+		return BlobInfo{}, fmt.Errorf("blob %s: write manifest: %w", id, err)
+	}
+	s.cache.invalidate(collection, id)
+
+	if old != nil {
+		if err := s.deleteChunks(ctx, collection, id, old["gen"]); err != nil {
+			return info, fmt.Errorf("blob %s: prune old chunks: %w", id, err)
+		}
+	}
+	return info, nil
+}
+
+// blobBatches splits chunks into writes whose bodies stay under the
+// WithMaxPayloadBytes limit (8 MiB without one); every batch holds at least
+// one chunk.
+func (s *service) blobBatches(chunks []map[string]any) [][]map[string]any {
+	budget := defaultBlobBatchBytes
+	if s.maxPayload > 0 {
+		budget = s.maxPayload
+	}
+	budget -= blobBatchOverhead
+	var out [][]map[string]any
+	var cur []map[string]any
+	size := 0
+	for _, c := range chunks {
+		data, _ := c["data"].(string)
+		n := len(data) + len(fmt.Sprint(c["_id"])) + blobChunkOverhead
+		if len(cur) > 0 && size+n > budget {
+			out = append(out, cur)
+			cur, size = nil, 0
+		}
+		cur = append(cur, c)
+		size += n
+	}
+	return append(out, cur)
+}
+
+// GetBlob reassembles the blob stored under id. It returns ErrNotFound when
+// there is no manifest and ErrBlobCorrupt when chunks are missing or the
+// checksum does not match.
+func (s *service) GetBlob(ctx context.Context, collection, id string) ([]byte, error) {
+	m, err := s.blobManifest(ctx, collection, id)
+	if err != nil {
+		return nil, err
+	}
+	n, _ := toInt64(m["chunks"])
+	q := fmt.Sprintf(
+		"SELECT * FROM %s WHERE blob_id == :id AND gen == :gen ORDER BY idx ASC",
+		escapeIdent(collection+chunkSuffix),
+	)
+	res, err := s.execWithArgs(ctx, q, map[string]any{"id": id, "gen": m["gen"]})
+	if err != nil {
+		return nil, fmt.Errorf("blob %s: read chunks: %w", id, err)
+	}
+	docs := Documents(res)
+	if int64(len(docs)) != n {
+		return nil, fmt.Errorf("%w: %s has %d of %d chunks", ErrBlobCorrupt, id, len(docs), n)
+	}
+	var data []byte
+	for i, d := range docs {
+		if idx, _ := toInt64(d["idx"]); idx != int64(i) {
+			return nil, fmt.Errorf("%w: %s missing chunk %d", ErrBlobCorrupt, id, i)
+		}
+		enc, _ := d["data"].(string)
+		b, err := base64.StdEncoding.DecodeString(enc)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s chunk %d: %v", ErrBlobCorrupt, id, i, err)
+		}
+		data = append(data, b...)
+	}
+	sum := sha256.Sum256(data)
+	if want, _ := m["sha256"].(string); want != hex.EncodeToString(sum[:]) {
+		return nil, fmt.Errorf("%w: %s checksum mismatch", ErrBlobCorrupt, id)
+	}
+	return data, nil
+}
+
+// StatBlob returns the manifest of the blob stored under id.
+func (s *service) StatBlob(ctx context.Context, collection, id string) (BlobInfo, error) {
+	m, err := s.blobManifest(ctx, collection, id)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	size, _ := toInt64(m["size"])
+	chunks, _ := toInt64(m["chunks"])
+	created, _ := ParseTimestamp(m["created_at"])
+	sha, _ := m["sha256"].(string)
+	return BlobInfo{ID: id, Size: int(size), Chunks: int(chunks), SHA256: sha, CreatedAt: created}, nil
+}
+
+// DeleteBlob removes the manifest and every chunk of the blob. The manifest
+// goes first so a concurrent reader sees ErrNotFound rather than a partial
+// blob.
+func (s *service) DeleteBlob(ctx context.Context, collection, id string) error {
+	if collection == "" || id == "" {
+		return errors.New("collection and id required")
+	}
+	if _, err := s.DeleteRecord(ctx, collection, id); err != nil {
+		return fmt.Errorf("blob %s: delete manifest: %w", id, err)
+	}
+	if err := s.deleteChunks(ctx, collection, id, nil); err != nil {
+		return fmt.Errorf("blob %s: delete chunks: %w", id, err)
+	}
+	return nil
+}
+
+// blobManifest reads the manifest for id, returning ErrNotFound if absent.
+func (s *service) blobManifest(ctx context.Context, collection, id string) (map[string]any, error) {
+	res, err := s.GetRecord(ctx, collection, id)
+	if err != nil {
+		return nil, err
+	}
+	docs := Documents(res)
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("blob %s: %w", id, ErrNotFound)
+	}
+	return docs[0], nil
+}
+
+// deleteChunks removes the chunks of blob id; a nil gen removes every
+// generation.
+func (s *service) deleteChunks(ctx context.Context, collection, id string, gen any) error {
+	q := fmt.Sprintf("DELETE FROM %s WHERE blob_id == :id", escapeIdent(collection+chunkSuffix))
+	args := map[string]any{"id": id}
+	if gen != nil {
+		q += " AND gen == :gen"
+		args["gen"] = gen
+	}
+	_, err := s.execWithArgs(ctx, q, args)
+	return err
+}
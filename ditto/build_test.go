@@ -0,0 +1,177 @@
+package ditto
+
+import (
+	"reflect"
+	"testing"
+)
+
+// repeat runs each builder this many times, so map iteration order gets a
+// chance to vary between runs.
+const repeat = 50
+
+func TestBuildSelectGolden(t *testing.T) {
+	tests := []struct {
+		name       string
+		collection string
+		filters    map[string]string
+		limit      int
+		sortBy     string
+		sortOrder  string
+		want       string
+	}{
+		{
+			name:       "bare",
+			collection: "cars",
+			want:       "SELECT * FROM cars",
+		},
+		{
+			name:       "filters sorted by key",
+			collection: "cars",
+			filters:    map[string]string{"year": "2020", "make": "Ford", "color": "red", "body": "ute"},
+			limit:      10,
+			sortBy:     "year",
+			sortOrder:  "desc",
+			want:       `SELECT * FROM cars WHERE body == "ute" AND color == "red" AND make == "Ford" AND year == "2020" ORDER BY year DESC LIMIT 10`,
+		},
+		{
+			name:       "quoted names and escaped values",
+			collection: "my-cars",
+			filters:    map[string]string{"owner.name": `O"Neil`, "owner.first name": "Pat\n"},
+			sortBy:     "owner.since",
+			sortOrder:  "ASC",
+			want:       "SELECT * FROM `my-cars` WHERE owner.`first name` == \"Pat\\n\" AND owner.name == \"O\\\"Neil\" ORDER BY owner.since ASC",
+		},
+		{
+			name:       "unknown sort order omitted",
+			collection: "cars",
+			sortBy:     "order",
+			sortOrder:  "sideways",
+			want:       "SELECT * FROM cars ORDER BY `order`",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < repeat; i++ {
+				got := BuildSelect(tt.collection, tt.filters, tt.limit, tt.sortBy, tt.sortOrder)
+				if got != tt.want {
+					t.Fatalf("run %d:\ngot  %s\nwant %s", i, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildSelectBoundGolden(t *testing.T) {
+	filters := map[string]string{"model": "Ranger", "make": "Ford", "colour": "blue"}
+	o := QueryOptions{Fields: []string{"make", "order", "specs.hp"}, Limit: 5, Offset: 10}
+	want := "SELECT make, `order`, specs.hp FROM cars WHERE colour == :filter_0 AND make == :filter_1 AND model == :filter_2 AND deleted_at IS NULL LIMIT 5 OFFSET 10"
+	wantArgs := map[string]any{"filter_0": "blue", "filter_1": "Ford", "filter_2": "Ranger"}
+	for i := 0; i < repeat; i++ {
+		got, args := buildSelect("cars", filters, "deleted_at IS NULL", o, true)
+		if got != want {
+			t.Fatalf("run %d:\ngot  %s\nwant %s", i, got, want)
+		}
+		if !reflect.DeepEqual(args, wantArgs) {
+			t.Fatalf("run %d: args %v, want %v", i, args, wantArgs)
+		}
+	}
+}
+
+func TestBuildUpdateGolden(t *testing.T) {
+	tests := []struct {
+		name     string
+		patch    map[string]any
+		want     string
+		wantArgs map[string]any
+	}{
+		{
+			name:     "set sorted by field",
+			patch:    map[string]any{"year": 2021, "color": "blue", "specs.hp": 300, "active": true},
+			want:     "UPDATE cars SET active = :p_0, color = :p_1, specs.hp = :p_2, year = :p_3 WHERE _id == :id",
+			wantArgs: map[string]any{"p_0": true, "p_1": "blue", "p_2": 300, "p_3": 2021, "id": "c1"},
+		},
+		{
+			name:     "null and unset",
+			patch:    map[string]any{"z": Unset, "a": Null, "m": 1, "b": Unset},
+			want:     "UPDATE cars SET a = null, m = :p_2 UNSET b, z WHERE _id == :id",
+			wantArgs: map[string]any{"p_2": 1, "id": "c1"},
+		},
+		{
+			name:     "quoted fields",
+			patch:    map[string]any{"tyre-size": 17, "select": "x", "owner.first name": "Pat"},
+			want:     "UPDATE cars SET owner.`first name` = :p_0, `select` = :p_1, `tyre-size` = :p_2 WHERE _id == :id",
+			wantArgs: map[string]any{"p_0": "Pat", "p_1": "x", "p_2": 17, "id": "c1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < repeat; i++ {
+				got, args, err := BuildUpdate("cars", "c1", tt.patch)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != tt.want {
+					t.Fatalf("run %d:\ngot  %s\nwant %s", i, got, tt.want)
+				}
+				if !reflect.DeepEqual(args, tt.wantArgs) {
+					t.Fatalf("run %d: args %v, want %v", i, args, tt.wantArgs)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildInsertGolden(t *testing.T) {
+	doc := map[string]any{"_id": "c1", "make": "Ford"}
+	got, args, err := BuildInsert("fleet cars", doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "INSERT INTO `fleet cars` DOCUMENTS (:doc)"; got != want {
+		t.Fatalf("got  %s\nwant %s", got, want)
+	}
+	if !reflect.DeepEqual(args, map[string]any{"doc": doc}) {
+		t.Fatalf("args %v", args)
+	}
+}
+
+func TestBuildUpdateRejectsInvalidFields(t *testing.T) {
+	for _, key := range []string{"", ".", "a..b", ".a", "a."} {
+		if q, _, err := BuildUpdate("cars", "c1", map[string]any{key: 1}); err == nil {
+			t.Errorf("key %q: got %s, want an error", key, q)
+		}
+		if q, _, err := BuildPatch("cars", "c1", Patch{Unset: []string{key}}); err == nil {
+			t.Errorf("unset %q: got %s, want an error", key, q)
+		}
+	}
+}
+
+func TestBuildUpdateKeepsDistinctFields(t *testing.T) {
+	// Keys that differ only in characters a sanitizer would rewrite must
+	// still address different fields, each with its own parameter.
+	patch := map[string]any{"a-b": 1, "a_b": 2, "a b": 3, "a.b": 4}
+	got, args, err := BuildUpdate("cars", "c1", patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "UPDATE cars SET `a b` = :p_0, `a-b` = :p_1, a.b = :p_2, a_b = :p_3 WHERE _id == :id"
+	if got != want {
+		t.Fatalf("got  %s\nwant %s", got, want)
+	}
+	wantArgs := map[string]any{"p_0": 3, "p_1": 1, "p_2": 4, "p_3": 2, "id": "c1"}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Fatalf("args %v, want %v", args, wantArgs)
+	}
+}
+
+func TestBuildUpdateParamNamesArePositional(t *testing.T) {
+	// Field names never leak into parameter names
+	_, args, err := BuildUpdate("cars", "c1", map[string]any{"owner.first-name": "Pat", "id": "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantArgs := map[string]any{"p_0": "x", "p_1": "Pat", "id": "c1"}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Fatalf("args %v, want %v", args, wantArgs)
+	}
+}
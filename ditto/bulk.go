@@ -0,0 +1,144 @@
+package ditto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// maxDocsPerInsert caps how many documents one INSERT statement carries.
+const maxDocsPerInsert = 500
+
+// upsertDocs inserts docs into collection, overwriting documents whose _id
+// already exists, in batches of maxDocsPerInsert.
+func (s *service) upsertDocs(ctx context.Context, collection string, docs []map[string]any) error {
+	if err := s.checkInsertQuota(ctx, collection, docs...); err != nil {
+		return err
+	}
+	return insertDocs(ctx, s, collection, docs, "DO UPDATE")
+}
+
+// insertDocs writes docs through svc.Execute in batches of maxDocsPerInsert,
+// resolving existing ids with the given ON ID CONFLICT action ("DO UPDATE"
+// or "DO NOTHING"). It works against any Service, not just this package's.
+func insertDocs(ctx context.Context, svc Service, collection string, docs []map[string]any, onConflict string) error {
+	if collection == "" {
+		return errors.New("collection required")
+	}
+	for start := 0; start < len(docs); start += maxDocsPerInsert {
+		end := min(start+maxDocsPerInsert, len(docs))
+		var values []string
+		args := map[string]any{}
+		for i, d := range docs[start:end] {
+			name := fmt.Sprintf("d_%d", i)
+			values = append(values, ":"+name)
+			args[name] = d
+		}
+		q := fmt.Sprintf(
+			"INSERT INTO %s DOCUMENTS (%s) ON ID CONFLICT %s",
+			escapeIdent(collection), strings.Join(values, "), ("), onConflict,
+		)
+		//dittovet:ignore values are generated :d_N names; onConflict is one of the SDK's own clauses
+		if _, err := svc.Execute(ctx, q, args); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteIDs removes the documents with the given ids, chunked into
+// `_id IN (...)` statements.
+func (s *service) deleteIDs(ctx context.Context, collection string, ids []string) error {
+	return s.removeIDs(ctx, "DELETE", collection, ids, nil)
+}
+
+// removeIDs runs `<verb> FROM collection WHERE _id IN (...)` in chunks, where
+// verb is DELETE or EVICT, reporting each chunk to p.
+func (s *service) removeIDs(ctx context.Context, verb, collection string, ids []string, p *progressTracker) error {
+	for _, chunk := range chunkIDs(ids, maxIDsPerStatement) {
+		where := idsPredicate(chunk)
+		q := fmt.Sprintf("%s FROM %s WHERE %s", verb, escapeIdent(collection), where.Clause)
+		//dittovet:ignore verb is DELETE or EVICT from the SDK; the clause is idsPredicate's
+		if _, err := s.execWithArgs(ctx, q, where.Args); err != nil {
+			return err
+		}
+		p.add(len(chunk))
+	}
+	return nil
+}
+
+// deleteDocs deletes the documents with the given ids the way DeleteRecord
+// would: tombstoned for soft-delete collections, removed otherwise.
+func (s *service) deleteDocs(ctx context.Context, collection string, ids []string) error {
+	defer s.cache.invalidate(collection, ids...)
+	if !s.softDeleted[collection] {
+		return s.deleteIDs(ctx, collection, ids)
+	}
+	for _, chunk := range chunkIDs(ids, maxIDsPerStatement) {
+		if _, err := s.softDelete(ctx, collection, idsPredicate(chunk)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// selectIDs returns the _id of every document in collection.
+func (s *service) selectIDs(ctx context.Context, collection string) ([]string, error) {
+	res, err := s.execWithArgs(ctx, fmt.Sprintf("SELECT _id FROM %s", escapeIdent(collection)), nil)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, d := range Documents(res) {
+		ids = append(ids, fmt.Sprint(d["_id"]))
+	}
+	return ids, nil
+}
+
+// scanPageSize is how many documents scanCollection reads per page.
+const scanPageSize = 500
+
+// scanCollection reads every live document of collection in _id order, one
+// page at a time, and hands each page to fn. It stops at the first error.
+func (s *service) scanCollection(ctx context.Context, collection string, fn func(docs []map[string]any) error) error {
+	return s.scanWhere(ctx, collection, nil, QueryOptions{}, fn)
+}
+
+// scanWhere is scanCollection restricted to documents matching where (nil
+// for all), with base supplying options such as IncludeDeleted or Fields.
+func (s *service) scanWhere(
+	ctx context.Context, collection string, where *Predicate, base QueryOptions,
+	fn func(docs []map[string]any) error,
+) error {
+	return scanService(ctx, s, collection, where, base, fn)
+}
+
+// scanService pages through a collection of any Service; see scanWhere.
+func scanService(
+	ctx context.Context, svc Service, collection string, where *Predicate, base QueryOptions,
+	fn func(docs []map[string]any) error,
+) error {
+	for offset := 0; ; offset += scanPageSize {
+		page := QueryOptions{Limit: scanPageSize, Offset: offset, SortBy: "_id", SortOrder: "ASC"}
+		var res any
+		var err error
+		if where != nil {
+			res, err = svc.FindWhere(ctx, collection, *where, base, page)
+		} else {
+			res, err = svc.FindRecords(ctx, collection, nil, base, page)
+		}
+		if err != nil {
+			return err
+		}
+		docs := Documents(res)
+		if len(docs) > 0 {
+			if err := fn(docs); err != nil {
+				return err
+			}
+		}
+		if len(docs) < scanPageSize {
+			return nil
+		}
+	}
+}
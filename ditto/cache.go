@@ -0,0 +1,206 @@
+package ditto
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// recordCache is a small LRU cache of GetRecord responses keyed by
+// (collection, _id) with a TTL. Writes through the same service invalidate
+// the affected entries, and a read that overlapped an invalidation of its
+// key does not store what it fetched.
+type recordCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	max     int
+	order   *list.List // front = most recently used
+	entries map[cacheKey]*list.Element
+	fills   map[cacheKey]*cacheFill // reads in flight, by key
+	now     func() time.Time
+}
+
+// cacheFill tracks the GetRecord round trips in flight for one key. gen is
+// bumped by every invalidation of the key, so put can tell whether the
+// value it holds was fetched before the latest write.
+type cacheFill struct {
+	readers int
+	gen     uint64
+}
+
+// cacheKey identifies a cached record.
+type cacheKey struct {
+	collection string
+	id         string
+}
+
+// cacheEntry is one cached GetRecord response.
+type cacheEntry struct {
+	key     cacheKey
+	value   any
+	expires time.Time
+}
+
+// WithCache enables a read cache for GetRecord holding up to maxEntries
+// records for ttl each. Updates and deletes made through this service
+// invalidate affected entries before and after the write, and a concurrent
+// read that fetched the old document does not cache it; writes from other clients are only picked up
+// once the TTL expires. Cached responses are shared and must not be mutated.
+func (s *service) WithCache(ttl time.Duration, maxEntries int) *service {
+	if ttl <= 0 || maxEntries <= 0 {
+		s.cache = nil
+		return s
+	}
+	s.cache = &recordCache{
+		ttl:     ttl,
+		max:     maxEntries,
+		order:   list.New(),
+		entries: map[cacheKey]*list.Element{},
+		fills:   map[cacheKey]*cacheFill{},
+		now:     s.now,
+	}
+	return s
+}
+
+// get returns a fresh cached response for (collection, id).
+func (c *recordCache) get(collection, id string) (any, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[cacheKey{collection, id}]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*cacheEntry)
+	if c.now().After(e.expires) {
+		c.order.Remove(el)
+		delete(c.entries, e.key)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return e.value, true
+}
+
+// begin registers a read of (collection, id) about to go to the server and
+// returns the key's generation, to be passed to put or release.
+func (c *recordCache) begin(collection, id string) uint64 {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := cacheKey{collection, id}
+	f := c.fills[key]
+	if f == nil {
+		f = &cacheFill{}
+		c.fills[key] = f
+	}
+	f.readers++
+	return f.gen
+}
+
+// release ends a read registered with begin without caching its result.
+func (c *recordCache) release(collection, id string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.releaseLocked(cacheKey{collection, id})
+}
+
+// releaseLocked ends one read of key.
+func (c *recordCache) releaseLocked(key cacheKey) {
+	f := c.fills[key]
+	if f == nil {
+		return
+	}
+	if f.readers--; f.readers == 0 {
+		delete(c.fills, key)
+	}
+}
+
+// put ends a read registered with begin and stores its response, unless
+// the key was invalidated since gen, evicting the least recently used entry
+// when full.
+func (c *recordCache) put(collection, id string, gen uint64, value any) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := cacheKey{collection, id}
+	f := c.fills[key]
+	c.releaseLocked(key)
+	if f == nil || f.gen != gen {
+		return
+	}
+	if el, ok := c.entries[key]; ok {
+		e := el.Value.(*cacheEntry)
+		e.value, e.expires = value, c.now().Add(c.ttl)
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, value: value, expires: c.now().Add(c.ttl)})
+	for c.order.Len() > c.max {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// invalidate drops the cached records for the given ids.
+func (c *recordCache) invalidate(collection string, ids ...string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, id := range ids {
+		key := cacheKey{collection, id}
+		if el, ok := c.entries[key]; ok {
+			c.order.Remove(el)
+			delete(c.entries, key)
+		}
+		if f := c.fills[key]; f != nil {
+			f.gen++
+		}
+	}
+}
+
+// invalidateCollection drops every cached record of a collection, used after
+// predicate-based writes whose affected ids are unknown.
+func (c *recordCache) invalidateCollection(collection string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, el := range c.entries {
+		if key.collection == collection {
+			c.order.Remove(el)
+			delete(c.entries, key)
+		}
+	}
+	for key, f := range c.fills {
+		if key.collection == collection {
+			f.gen++
+		}
+	}
+}
+
+// clear drops every cached record.
+func (c *recordCache) clear() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	clear(c.entries)
+	for _, f := range c.fills {
+		f.gen++
+	}
+}
@@ -0,0 +1,107 @@
+package ditto
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCacheDropsReadsRacingWrites(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(s *service) error
+		want  any // value served after the write
+	}{
+		{"update", func(s *service) error {
+			_, err := s.UpdateRecord(context.Background(), "users", "u1", map[string]any{"role": "user"})
+			return err
+		}, "user"},
+		{"delete", func(s *service) error {
+			_, err := s.DeleteRecord(context.Background(), "users", "u1")
+			return err
+		}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu      sync.Mutex
+				doc     = map[string]any{"_id": "u1", "role": "admin"}
+				reading = make(chan struct{})
+				release = make(chan struct{})
+				first   = true
+			)
+			s, _ := newFakeService(t, func(query string, args map[string]any) any {
+				mu.Lock()
+				switch {
+				case strings.HasPrefix(query, "SELECT"):
+					snapshot, block := doc, first
+					first = false
+					mu.Unlock()
+					if block {
+						// The first read fetched the old document and is
+						// held until the write has completed
+						close(reading)
+						<-release
+					}
+					if snapshot == nil {
+						return items()
+					}
+					return items(snapshot)
+				case strings.HasPrefix(query, "UPDATE"):
+					doc = map[string]any{"_id": "u1", "role": args["p_0"]}
+				case strings.HasPrefix(query, "DELETE"):
+					doc = nil
+				}
+				mu.Unlock()
+				return map[string]any{"items": []any{}, "mutatedDocumentIds": []any{"u1"}}
+			})
+			s.WithCache(time.Hour, 10)
+
+			done := make(chan error)
+			go func() {
+				_, err := s.GetRecord(context.Background(), "users", "u1")
+				done <- err
+			}()
+			<-reading
+			if err := tt.write(s); err != nil {
+				t.Fatal(err)
+			}
+			close(release)
+			if err := <-done; err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := s.GetRecord(context.Background(), "users", "u1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got any
+			if docs := Documents(res); len(docs) > 0 {
+				got = docs[0]["role"]
+			}
+			if got != tt.want {
+				t.Fatalf("read after %s = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCacheServesAfterCleanRead(t *testing.T) {
+	s, f := newFakeService(t, func(query string, args map[string]any) any {
+		return items(map[string]any{"_id": "u1"})
+	})
+	s.WithCache(time.Hour, 10)
+	for i := 0; i < 3; i++ {
+		if _, err := s.GetRecord(context.Background(), "users", "u1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(f.sent()); n != 1 {
+		t.Fatalf("%d round trips, want 1", n)
+	}
+	if n := len(s.cache.fills); n != 0 {
+		t.Fatalf("%d reads still tracked", n)
+	}
+}
@@ -0,0 +1,139 @@
+package ditto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// capabilityProbeCollection is queried by the capability probes; the probes
+// only ever match a random id, so nothing in it is read or changed.
+const capabilityProbeCollection = "__ditto_sdk_probe"
+
+// Capabilities reports what the connected server supports.
+type Capabilities struct {
+	ServerVersion string // Server response header, when the server sends one
+	Select        bool
+	Delete        bool // DELETE statements (removal synced to peers)
+	Evict         bool // EVICT statements (local removal)
+	Explain       bool // EXPLAIN statements
+	// Transactions, Subscriptions, and Attachments are features of the
+	// Ditto SDKs that the HTTP /execute API does not expose; they are
+	// reported false so callers can branch on them uniformly.
+	Transactions  bool
+	Subscriptions bool
+	Attachments   bool
+	ProbedAt      time.Time
+	// Skipped lists probes not sent, e.g. write probes in read-only mode.
+	Skipped []string
+	// Rejected maps a probe to the server's error for statements it
+	// refused.
+	Rejected map[string]string
+}
+
+// capabilityCache holds the result of the first successful probe.
+type capabilityCache struct {
+	mu   sync.Mutex
+	caps *Capabilities
+}
+
+// Capabilities probes the server once and reports what it supports; later
+// calls return the cached result. A statement the server answers with a 4xx
+// is reported unsupported; network errors and 5xx responses fail the call
+// so a flaky link is not mistaken for a missing feature.
+func (s *service) Capabilities(ctx context.Context) (Capabilities, error) {
+	s.caps.mu.Lock()
+	defer s.caps.mu.Unlock()
+	if s.caps.caps != nil {
+		return *s.caps.caps, nil
+	}
+	c, err := s.probeCapabilities(ctx)
+	if err != nil {
+		return c, err
+	}
+	s.caps.caps = &c
+	return c, nil
+}
+
+// ResetCapabilities discards the cached Capabilities, e.g. after the server
+// was upgraded.
+func (s *service) ResetCapabilities() {
+	s.caps.mu.Lock()
+	s.caps.caps = nil
+	s.caps.mu.Unlock()
+}
+
+// probeCapabilities sends one statement per feature.
+func (s *service) probeCapabilities(ctx context.Context) (Capabilities, error) {
+	c := Capabilities{ProbedAt: s.now(), Rejected: map[string]string{}}
+	id, err := s.probeID()
+	if err != nil {
+		return c, err
+	}
+	args := map[string]any{"id": id}
+	where := fmt.Sprintf("FROM %s WHERE _id == :id", capabilityProbeCollection)
+	probes := []struct {
+		name  string
+		query string
+		ok    *bool
+	}{
+		{"SELECT", "SELECT * " + where, &c.Select},
+		{"EXPLAIN", "EXPLAIN SELECT * " + where, &c.Explain},
+		{"DELETE", "DELETE " + where, &c.Delete},
+		{"EVICT", "EVICT " + where, &c.Evict},
+	}
+	for _, p := range probes {
+		if s.readOnly && !isReadStatement(p.query) {
+			c.Skipped = append(c.Skipped, p.name)
+			continue
+		}
+		version, rejected, err := s.probe(ctx, p.query, args)
+		if err != nil {
+			return c, fmt.Errorf("capability probe %s: %w", p.name, err)
+		}
+		if version != "" {
+			c.ServerVersion = version
+		}
+		if rejected != "" {
+			c.Rejected[p.name] = rejected
+			continue
+		}
+		*p.ok = true
+	}
+	return c, nil
+}
+
+// probe sends one statement, returning the Server header and, for a 4xx
+// response, the server's error.
+func (s *service) probe(ctx context.Context, query string, args map[string]any) (version, rejected string, err error) {
+	req, err := s.newExecuteRequest(ctx, query, args)
+	if err != nil {
+		return "", "", err
+	}
+	resp, err := s.HTTP.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	version = resp.Header.Get("Server")
+	switch {
+	case resp.StatusCode/100 == 2:
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return version, "", nil
+	case resp.StatusCode/100 == 4:
+		return version, httpError(resp.StatusCode, resp.Body, query).Error(), nil
+	}
+	return version, "", httpError(resp.StatusCode, resp.Body, query)
+}
+
+// probeID returns a random id no real document uses.
+func (s *service) probeID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := s.random().Read(b); err != nil {
+		return "", errors.New("capability probe: no randomness")
+	}
+	return fmt.Sprintf("probe-%x", b), nil
+}
@@ -0,0 +1,129 @@
+package ditto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Checkpoint records how far an ExportCollection or ImportCollection got,
+// so a later call with the same checkpoint file continues from there.
+type Checkpoint struct {
+	Op          string    `json:"op"` // "export" or "import"
+	Collection  string    `json:"collection"`
+	Count       int       `json:"count"`                 // documents written so far
+	LastID      any       `json:"last_id,omitempty"`     // export: _id of the last document written
+	Offset      int64     `json:"offset,omitempty"`      // export: output bytes written
+	Line        int       `json:"line,omitempty"`        // import: last input line applied
+	Compression string    `json:"compression,omitempty"` // export: codec name; "" when uncompressed
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// checkpointKey is the context key for WithCheckpointFile.
+type checkpointKey struct{}
+
+// WithCheckpointFile returns a context that makes ExportCollection and
+// ImportCollection resumable. After every page (export) or batch (import)
+// the progress is saved to path; if the operation fails, calling it again
+// with the same path continues where it stopped instead of starting over.
+// The file is removed once the operation completes.
+//
+// An export resumes after the last _id written and appends to the output,
+// which must be the same file: when it is an *os.File (or anything with
+// Truncate and Seek) it is first cut back to the last checkpoint, dropping
+// a partly written line; a compressed export ends its compressed stream at
+// every checkpoint and appends a new one on resume, which gzip and zstd
+// readers treat as one. An import skips the input lines already applied;
+// the input must be the same NDJSON from its start. Returned counts include
+// the documents handled before the resume.
+func WithCheckpointFile(ctx context.Context, path string) context.Context {
+	return context.WithValue(ctx, checkpointKey{}, path)
+}
+
+// checkpointPath returns the checkpoint file set on ctx, if any.
+func checkpointPath(ctx context.Context) string {
+	p, _ := ctx.Value(checkpointKey{}).(string)
+	return p
+}
+
+// loadCheckpoint reads the checkpoint at path for op on collection. A
+// missing file means starting from scratch.
+func loadCheckpoint(path, op, collection string) (*Checkpoint, error) {
+	if path == "" {
+		return nil, nil
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read checkpoint: %w", err)
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(b, &cp); err != nil {
+		return nil, fmt.Errorf("read checkpoint %s: %w", path, err)
+	}
+	if cp.Op != op || cp.Collection != collection {
+		return nil, fmt.Errorf("checkpoint %s is for %s of %s, not %s of %s", path, cp.Op, cp.Collection, op, collection)
+	}
+	return &cp, nil
+}
+
+// save writes cp to path atomically, so a crash mid-write keeps the
+// previous checkpoint.
+func (cp *Checkpoint) save(path string) error {
+	b, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// rewindOutput cuts w back to offset when it supports truncation, so a
+// resumed export does not follow a partly written line.
+func rewindOutput(w io.Writer, offset int64) error {
+	f, ok := w.(interface {
+		Truncate(size int64) error
+		io.Seeker
+	})
+	if !ok {
+		return nil
+	}
+	if err := f.Truncate(offset); err != nil {
+		return fmt.Errorf("rewind output: %w", err)
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("rewind output: %w", err)
+	}
+	return nil
+}
+
+// removeCheckpoint deletes the checkpoint of a completed operation.
+func removeCheckpoint(path string) error {
+	if path == "" {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
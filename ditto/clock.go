@@ -0,0 +1,125 @@
+package ditto
+
+import (
+	"context"
+	crand "crypto/rand"
+	"math/rand/v2"
+	"time"
+)
+
+// Clock tells the time. Inject a fake with WithClock to test TTLs, lease
+// expiry, and backoff deterministically.
+type Clock interface {
+	Now() time.Time
+}
+
+// Sleeper waits between retries and polls. Sleep returns ctx.Err() if ctx
+// ends first.
+type Sleeper interface {
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+// Rand supplies randomness: Read for identifiers (lock owners, container
+// suffixes) and Float64 for probabilistic decisions.
+type Rand interface {
+	Read(p []byte) (int, error)
+	Float64() float64
+}
+
+// System implementations used when no override is installed.
+var (
+	SystemClock   Clock   = systemClock{}
+	SystemSleeper Sleeper = systemSleeper{}
+	SystemRand    Rand    = systemRand{}
+)
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+type systemSleeper struct{}
+
+func (systemSleeper) Sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+type systemRand struct{}
+
+func (systemRand) Read(p []byte) (int, error) { return crand.Read(p) }
+func (systemRand) Float64() float64           { return rand.Float64() }
+
+// WithClock replaces the clock used for timestamps, TTLs, lease expiry,
+// quota checks, and deadlines.
+func (s *service) WithClock(c Clock) *service {
+	s.clock = c
+	return s
+}
+
+// WithSleeper replaces how the service waits during backoff, polling, and
+// periodic background work.
+func (s *service) WithSleeper(sl Sleeper) *service {
+	s.sleeper = sl
+	return s
+}
+
+// WithRand replaces the randomness source.
+func (s *service) WithRand(r Rand) *service {
+	s.rnd = r
+	return s
+}
+
+// now returns the current time from the configured clock.
+func (s *service) now() time.Time {
+	if s.clock != nil {
+		return s.clock.Now()
+	}
+	return time.Now()
+}
+
+// sleep waits d on the configured sleeper.
+func (s *service) sleep(ctx context.Context, d time.Duration) error {
+	if s.sleeper != nil {
+		return s.sleeper.Sleep(ctx, d)
+	}
+	return SystemSleeper.Sleep(ctx, d)
+}
+
+// random returns the configured randomness source.
+func (s *service) random() Rand {
+	if s.rnd != nil {
+		return s.rnd
+	}
+	return SystemRand
+}
+
+// timeSources exposes the injected clock, sleeper, and randomness to helpers
+// that only hold a Service, such as Lock.
+func (s *service) timeSources() (Clock, Sleeper, Rand) {
+	c := s.clock
+	if c == nil {
+		c = SystemClock
+	}
+	sl := s.sleeper
+	if sl == nil {
+		sl = SystemSleeper
+	}
+	return c, sl, s.random()
+}
+
+// timeSourcesOf returns svc's time sources, or the system ones when svc
+// does not expose any.
+func timeSourcesOf(svc Service) (Clock, Sleeper, Rand) {
+	if ts, ok := svc.(interface {
+		timeSources() (Clock, Sleeper, Rand)
+	}); ok {
+		return ts.timeSources()
+	}
+	return SystemClock, SystemSleeper, SystemRand
+}
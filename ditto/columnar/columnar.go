@@ -0,0 +1,434 @@
+// Package columnar decodes Ditto query results into column-oriented record
+// batches for on-device analytics. Each column keeps its values in the
+// Apache Arrow memory layout (LSB-ordered validity bitmap, bit-packed
+// booleans, contiguous int64/float64 values, int32 offsets plus UTF-8 data
+// for strings, microsecond timestamps), so DataFrame libraries built on
+// Arrow can wrap the buffers without copying them, e.g. with
+// memory.NewBufferBytes and array.NewData from the Arrow Go module. Arrow
+// buffers are little-endian, so on big-endian platforms the byte accessors
+// return swapped copies instead. The package itself has no dependencies
+// beyond the standard library.
+//
+//	res, _ := svc.Execute(ctx, "SELECT * FROM readings", nil)
+//	batches, err := columnar.FromResult(res, columnar.Options{BatchSize: 4096})
+package columnar
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+	"unsafe"
+
+	"github.com/Hammerstone-AU/ditto-go-sdk/ditto"
+)
+
+// DefaultBatchSize is the number of rows per batch when Options leaves
+// BatchSize at zero.
+const DefaultBatchSize = 65536
+
+// Type is the logical type of a column.
+type Type int
+
+const (
+	// Null is a column with no non-null values.
+	Null Type = iota
+	// Bool values are bit-packed in Column.Bools.
+	Bool
+	// Int64 values are in Column.Int64s.
+	Int64
+	// Float64 values are in Column.Float64s.
+	Float64
+	// String values are UTF-8 in Column.Data, delimited by Column.Offsets.
+	// Objects, arrays, and mixed-type values are stored as their JSON text.
+	String
+	// Timestamp values are microseconds since the Unix epoch (UTC) in
+	// Column.Int64s, from strings in Ditto's timestamp format.
+	Timestamp
+)
+
+func (t Type) String() string {
+	switch t {
+	case Null:
+		return "null"
+	case Bool:
+		return "bool"
+	case Int64:
+		return "int64"
+	case Float64:
+		return "float64"
+	case String:
+		return "utf8"
+	case Timestamp:
+		return "timestamp[us, UTC]"
+	}
+	return fmt.Sprintf("Type(%d)", int(t))
+}
+
+// Field describes one column.
+type Field struct {
+	Name     string // column name: a top-level field or a dotted path
+	Type     Type
+	Nullable bool
+}
+
+// Column holds the values of one field for the rows of a batch. Only the
+// buffers of the field's Type are set.
+type Column struct {
+	Field     Field
+	Len       int
+	NullCount int
+	Validity  []byte    // bit i set when row i is not null; nil when NullCount is 0
+	Bools     []byte    // Bool: bit i is the value of row i
+	Int64s    []int64   // Int64, Timestamp
+	Float64s  []float64 // Float64
+	Offsets   []int32   // String: row i is Data[Offsets[i]:Offsets[i+1]]
+	Data      []byte    // String
+}
+
+// RecordBatch is a set of equal-length columns.
+type RecordBatch struct {
+	Schema  []Field
+	NumRows int
+	Columns []Column
+}
+
+// Options configures FromResult and FromDocuments.
+type Options struct {
+	// BatchSize is the maximum number of rows per batch.
+	BatchSize int
+	// Columns selects and orders the columns, as top-level field names or
+	// dotted paths (see ditto.Get). Empty means every top-level field of
+	// the documents, _id first and the rest sorted.
+	Columns []string
+}
+
+// FromResult decodes the documents of a query response into record batches
+// that share one schema, inferred from all documents.
+func FromResult(res any, opts Options) ([]RecordBatch, error) {
+	return FromDocuments(ditto.Documents(res), opts)
+}
+
+// FromDocuments decodes docs into record batches that share one schema,
+// inferred from all documents. A field whose values mix integers and
+// floats is Float64; any other mix is String.
+func FromDocuments(docs []map[string]any, opts Options) ([]RecordBatch, error) {
+	size := opts.BatchSize
+	if size <= 0 {
+		size = DefaultBatchSize
+	}
+	names := opts.Columns
+	if len(names) == 0 {
+		names = topLevelFields(docs)
+	}
+	schema := make([]Field, len(names))
+	for i, name := range names {
+		schema[i] = inferField(name, docs)
+	}
+	var out []RecordBatch
+	for start := 0; start < len(docs); start += size {
+		rows := docs[start:min(start+size, len(docs))]
+		b := RecordBatch{Schema: schema, NumRows: len(rows), Columns: make([]Column, len(schema))}
+		for i, f := range schema {
+			col, err := buildColumn(f, rows)
+			if err != nil {
+				return nil, err
+			}
+			b.Columns[i] = col
+		}
+		out = append(out, b)
+	}
+	return out, nil
+}
+
+// Column returns the column named name, or nil.
+func (b *RecordBatch) Column(name string) *Column {
+	for i := range b.Columns {
+		if b.Columns[i].Field.Name == name {
+			return &b.Columns[i]
+		}
+	}
+	return nil
+}
+
+// IsNull reports whether row i is null.
+func (c *Column) IsNull(i int) bool {
+	return c.Validity != nil && !bit(c.Validity, i)
+}
+
+// Bool returns row i of a Bool column.
+func (c *Column) Bool(i int) bool { return bit(c.Bools, i) }
+
+// Int64 returns row i of an Int64 or Timestamp column.
+func (c *Column) Int64(i int) int64 { return c.Int64s[i] }
+
+// Float64 returns row i of a Float64 column.
+func (c *Column) Float64(i int) float64 { return c.Float64s[i] }
+
+// String returns row i of a String column.
+func (c *Column) String(i int) string { return string(c.Data[c.Offsets[i]:c.Offsets[i+1]]) }
+
+// Time returns row i of a Timestamp column.
+func (c *Column) Time(i int) time.Time { return time.UnixMicro(c.Int64s[i]).UTC() }
+
+// Value returns row i as a Go value (nil for null), for debugging and
+// tests rather than bulk access.
+func (c *Column) Value(i int) any {
+	if c.IsNull(i) {
+		return nil
+	}
+	switch c.Field.Type {
+	case Bool:
+		return c.Bool(i)
+	case Int64:
+		return c.Int64(i)
+	case Float64:
+		return c.Float64(i)
+	case String:
+		return c.String(i)
+	case Timestamp:
+		return c.Time(i)
+	}
+	return nil
+}
+
+// Int64Bytes returns the Int64s buffer as little-endian bytes, the form
+// Arrow buffers take. It does not copy on little-endian platforms.
+func (c *Column) Int64Bytes() []byte { return sliceBytes(c.Int64s) }
+
+// Float64Bytes returns the Float64s buffer as little-endian bytes. It does
+// not copy on little-endian platforms.
+func (c *Column) Float64Bytes() []byte { return sliceBytes(c.Float64s) }
+
+// OffsetBytes returns the Offsets buffer as little-endian bytes. It does not
+// copy on little-endian platforms.
+func (c *Column) OffsetBytes() []byte { return sliceBytes(c.Offsets) }
+
+// littleEndian reports whether the platform stores values little-endian, so
+// their memory already has Arrow's byte order.
+var littleEndian = binary.NativeEndian.Uint16([]byte{1, 0}) == 1
+
+// sliceBytes returns s as little-endian bytes: a view of its memory on
+// little-endian platforms (amd64, arm64, ...), a byte-swapped copy on
+// big-endian ones (s390x, ppc64, mips).
+func sliceBytes[T int64 | float64 | int32](s []T) []byte {
+	if len(s) == 0 {
+		return nil
+	}
+	if littleEndian {
+		return unsafe.Slice((*byte)(unsafe.Pointer(&s[0])), len(s)*int(unsafe.Sizeof(s[0])))
+	}
+	return appendLittleEndian(nil, s)
+}
+
+// appendLittleEndian appends each value of s to b in little-endian order.
+func appendLittleEndian[T int64 | float64 | int32](b []byte, s []T) []byte {
+	for _, v := range s {
+		switch v := any(v).(type) {
+		case int64:
+			b = binary.LittleEndian.AppendUint64(b, uint64(v))
+		case float64:
+			b = binary.LittleEndian.AppendUint64(b, math.Float64bits(v))
+		case int32:
+			b = binary.LittleEndian.AppendUint32(b, uint32(v))
+		}
+	}
+	return b
+}
+
+// topLevelFields returns the field names of docs, _id first.
+func topLevelFields(docs []map[string]any) []string {
+	seen := map[string]bool{}
+	for _, d := range docs {
+		for k := range d {
+			seen[k] = true
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for k := range seen {
+		names = append(names, k)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if (names[i] == "_id") != (names[j] == "_id") {
+			return names[i] == "_id"
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// lookup returns the value of a column in doc.
+func lookup(doc map[string]any, name string) (any, bool) {
+	if v, ok := doc[name]; ok {
+		return v, true
+	}
+	return ditto.Get(doc, name)
+}
+
+// inferField picks the narrowest type holding every value of name.
+func inferField(name string, docs []map[string]any) Field {
+	f := Field{Name: name, Type: Null}
+	for _, d := range docs {
+		v, ok := lookup(d, name)
+		if !ok || v == nil {
+			f.Nullable = true
+			continue
+		}
+		f.Type = widen(f.Type, valueType(v))
+	}
+	return f
+}
+
+// valueType is the column type a single value needs.
+func valueType(v any) Type {
+	switch x := v.(type) {
+	case bool:
+		return Bool
+	case float64:
+		if x == math.Trunc(x) && math.Abs(x) < 1<<53 {
+			return Int64
+		}
+		return Float64
+	case int, int32, int64:
+		return Int64
+	case float32:
+		return Float64
+	case json.Number:
+		if _, err := x.Int64(); err == nil {
+			return Int64
+		}
+		return Float64
+	case string:
+		if _, ok := ditto.ParseTimestamp(x); ok {
+			return Timestamp
+		}
+		return String
+	}
+	return String
+}
+
+// widen combines the type seen so far with the type of another value.
+func widen(have, next Type) Type {
+	switch {
+	case have == Null || have == next:
+		return next
+	case (have == Int64 && next == Float64) || (have == Float64 && next == Int64):
+		return Float64
+	}
+	return String
+}
+
+// buildColumn fills the buffers of f for rows.
+func buildColumn(f Field, rows []map[string]any) (Column, error) {
+	n := len(rows)
+	c := Column{Field: f, Len: n}
+	validity := make([]byte, (n+7)/8)
+	switch f.Type {
+	case Bool:
+		c.Bools = make([]byte, (n+7)/8)
+	case Int64, Timestamp:
+		c.Int64s = make([]int64, n)
+	case Float64:
+		c.Float64s = make([]float64, n)
+	case String:
+		c.Offsets = make([]int32, n+1)
+	}
+	for i, d := range rows {
+		v, ok := lookup(d, f.Name)
+		if !ok || v == nil || f.Type == Null {
+			c.NullCount++
+			if f.Type == String {
+				c.Offsets[i+1] = c.Offsets[i]
+			}
+			continue
+		}
+		setBit(validity, i)
+		switch f.Type {
+		case Bool:
+			if v.(bool) {
+				setBit(c.Bools, i)
+			}
+		case Int64:
+			x, ok := toInt64(v)
+			if !ok {
+				return c, fmt.Errorf("columnar: %s row %d: %v is not an integer", f.Name, i, v)
+			}
+			c.Int64s[i] = x
+		case Float64:
+			x, ok := toFloat64(v)
+			if !ok {
+				return c, fmt.Errorf("columnar: %s row %d: %v is not a number", f.Name, i, v)
+			}
+			c.Float64s[i] = x
+		case Timestamp:
+			t, _ := ditto.ParseTimestamp(v)
+			c.Int64s[i] = t.UnixMicro()
+		case String:
+			s, err := text(v)
+			if err != nil {
+				return c, fmt.Errorf("columnar: %s row %d: %w", f.Name, i, err)
+			}
+			c.Data = append(c.Data, s...)
+			if len(c.Data) > math.MaxInt32 {
+				return c, errors.New("columnar: string column over 2 GiB; use a smaller BatchSize")
+			}
+			c.Offsets[i+1] = int32(len(c.Data))
+		}
+	}
+	if c.NullCount > 0 {
+		c.Validity = validity
+	}
+	return c, nil
+}
+
+// text renders a value for a String column.
+func text(v any) (string, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+func toInt64(v any) (int64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return int64(x), true
+	case int:
+		return int64(x), true
+	case int32:
+		return int64(x), true
+	case int64:
+		return x, true
+	case json.Number:
+		n, err := x.Int64()
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func toFloat64(v any) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case float32:
+		return float64(x), true
+	case int:
+		return float64(x), true
+	case int32:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case json.Number:
+		f, err := x.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func bit(bits []byte, i int) bool { return bits[i/8]&(1<<(i%8)) != 0 }
+
+func setBit(bits []byte, i int) { bits[i/8] |= 1 << (i % 8) }
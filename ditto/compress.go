@@ -0,0 +1,220 @@
+package ditto
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// zstdMagic starts every zstd frame.
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// Compression is a streaming codec for backup archives and exports. Gzip
+// and NoCompression are built in; other codecs such as zstd come from the
+// caller (see Zstd) so the SDK keeps to the standard library.
+type Compression interface {
+	// Name identifies the codec, e.g. "gzip", "zstd", or "none".
+	Name() string
+	// Extension is appended to archive names, e.g. ".gz"; "" for none.
+	Extension() string
+	// Magic is the leading bytes of compressed output, used to recognize
+	// the codec when reading; nil for none.
+	Magic() []byte
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+// Built-in codecs. Gzip is the default for backups.
+var (
+	Gzip          Compression = GzipLevel(gzip.DefaultCompression)
+	NoCompression Compression = noCompression{}
+)
+
+// GzipLevel returns gzip at level (gzip.BestSpeed to gzip.BestCompression).
+func GzipLevel(level int) Compression {
+	return gzipCompression{level: level}
+}
+
+type gzipCompression struct{ level int }
+
+func (gzipCompression) Name() string      { return "gzip" }
+func (gzipCompression) Extension() string { return ".gz" }
+func (gzipCompression) Magic() []byte     { return []byte{0x1f, 0x8b} }
+
+func (c gzipCompression) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriterLevel(w, c.level)
+}
+
+func (gzipCompression) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+type noCompression struct{}
+
+func (noCompression) Name() string      { return "none" }
+func (noCompression) Extension() string { return "" }
+func (noCompression) Magic() []byte     { return nil }
+
+func (noCompression) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return nopWriteCloser{w}, nil
+}
+
+func (noCompression) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(r), nil
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+// Zstd returns a zstd Compression built from the caller's encoder and
+// decoder, e.g. with github.com/klauspost/compress/zstd:
+//
+//	ditto.Zstd(
+//		func(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) },
+//		func(r io.Reader) (io.ReadCloser, error) {
+//			d, err := zstd.NewReader(r)
+//			if err != nil {
+//				return nil, err
+//			}
+//			return d.IOReadCloser(), nil
+//		})
+//
+// Register it with RegisterCompression so restores and imports recognize
+// zstd input.
+func Zstd(newWriter func(io.Writer) (io.WriteCloser, error), newReader func(io.Reader) (io.ReadCloser, error)) Compression {
+	return funcCompression{name: "zstd", ext: ".zst", magic: zstdMagic, newWriter: newWriter, newReader: newReader}
+}
+
+type funcCompression struct {
+	name, ext string
+	magic     []byte
+	newWriter func(io.Writer) (io.WriteCloser, error)
+	newReader func(io.Reader) (io.ReadCloser, error)
+}
+
+func (c funcCompression) Name() string      { return c.name }
+func (c funcCompression) Extension() string { return c.ext }
+func (c funcCompression) Magic() []byte     { return c.magic }
+
+func (c funcCompression) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	if c.newWriter == nil {
+		return nil, fmt.Errorf("%s: no encoder configured", c.name)
+	}
+	return c.newWriter(w)
+}
+
+func (c funcCompression) NewReader(r io.Reader) (io.ReadCloser, error) {
+	if c.newReader == nil {
+		return nil, fmt.Errorf("%s: no decoder configured", c.name)
+	}
+	return c.newReader(r)
+}
+
+// compressions are the codecs recognized when reading, by extension, and
+// in registration order for sniffing.
+var compressions = struct {
+	mu    sync.RWMutex
+	byExt map[string]Compression
+	order []Compression
+}{
+	byExt: map[string]Compression{".gz": Gzip},
+	order: []Compression{Gzip},
+}
+
+// RegisterCompression makes c recognized by RestoreBackup and
+// ImportCollection, replacing a codec with the same extension. Register
+// codecs during program initialization.
+func RegisterCompression(c Compression) {
+	compressions.mu.Lock()
+	defer compressions.mu.Unlock()
+	order := []Compression{}
+	for _, o := range compressions.order {
+		if o.Extension() != c.Extension() {
+			order = append(order, o)
+		}
+	}
+	compressions.byExt[c.Extension()] = c
+	compressions.order = append(order, c)
+}
+
+// compressionByExt returns the registered codec for an archive extension.
+func compressionByExt(ext string) (Compression, bool) {
+	if ext == "" {
+		return NoCompression, true
+	}
+	compressions.mu.RLock()
+	defer compressions.mu.RUnlock()
+	c, ok := compressions.byExt[ext]
+	return c, ok
+}
+
+// compressionName names the codec of an archive extension, falling back
+// to the extension itself for unregistered codecs.
+func compressionName(ext string) string {
+	if c, ok := compressionByExt(ext); ok {
+		return c.Name()
+	}
+	if ext == ".zst" {
+		return "zstd"
+	}
+	return strings.TrimPrefix(ext, ".")
+}
+
+// ErrUnknownCompression reports input whose leading bytes match a known
+// compressed format that has no registered codec.
+var ErrUnknownCompression = errors.New("input is compressed with an unregistered codec; see RegisterCompression")
+
+// decompress wraps br with the registered codec whose magic starts it;
+// input matching none is read as is.
+func decompress(br *bufio.Reader) (io.ReadCloser, error) {
+	compressions.mu.RLock()
+	order := compressions.order
+	compressions.mu.RUnlock()
+	for _, c := range order {
+		m := c.Magic()
+		if len(m) == 0 {
+			continue
+		}
+		if head, _ := br.Peek(len(m)); bytes.Equal(head, m) {
+			return c.NewReader(br)
+		}
+	}
+	if head, _ := br.Peek(len(zstdMagic)); bytes.Equal(head, zstdMagic) {
+		return nil, fmt.Errorf("zstd: %w", ErrUnknownCompression)
+	}
+	return io.NopCloser(br), nil
+}
+
+// exportCompressionKey is the context key for WithExportCompression.
+type exportCompressionKey struct{}
+
+// WithExportCompression returns a context that makes ExportCollection
+// compress its output with c. ImportCollection recognizes compressed input
+// by itself.
+func WithExportCompression(ctx context.Context, c Compression) context.Context {
+	return context.WithValue(ctx, exportCompressionKey{}, c)
+}
+
+// compressionLabel names c for a checkpoint, "" when uncompressed.
+func compressionLabel(c Compression) string {
+	if c == nil {
+		return ""
+	}
+	return c.Name()
+}
+
+// exportCompression returns the codec set on ctx, or nil.
+func exportCompression(ctx context.Context) Compression {
+	c, _ := ctx.Value(exportCompressionKey{}).(Compression)
+	if c == nil || c.Name() == NoCompression.Name() {
+		return nil
+	}
+	return c
+}
@@ -0,0 +1,137 @@
+package ditto
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// conditionalCache remembers the last response of recent reads so an
+// unchanged result can skip decoding.
+type conditionalCache struct {
+	mu      sync.Mutex
+	max     int
+	order   *list.List // front = most recently used
+	entries map[string]*list.Element
+}
+
+// conditionalEntry is the last response to one statement and its arguments.
+type conditionalEntry struct {
+	key   string
+	etag  string   // server validator, if the server sent one
+	sum   [32]byte // SHA-256 of the response body
+	value any      // decoded response
+}
+
+// notModifiedKey is the context key ExecuteIfChanged uses to learn that
+// the response was unchanged.
+type notModifiedKey struct{}
+
+// WithConditionalReads remembers the last response of up to maxEntries
+// distinct reads (statement plus arguments). When the server answers with
+// an ETag, the next identical read sends If-None-Match and a 304 reuses the
+// remembered result; otherwise the body is hashed and, if identical to last
+// time, the remembered result is returned without decoding it again. Either
+// way the request still reaches the server, so results are never stale.
+// Remembered results are shared and must not be mutated. Use
+// ExecuteIfChanged to learn whether a result changed.
+func (s *service) WithConditionalReads(maxEntries int) *service {
+	if maxEntries <= 0 {
+		s.cond = nil
+		return s
+	}
+	s.cond = &conditionalCache{max: maxEntries, order: list.New(), entries: map[string]*list.Element{}}
+	return s
+}
+
+// ExecuteIfChanged runs a statement like Execute and also reports whether
+// its result differs from the last time the same statement and arguments
+// were read through this service. changed is false only when the result is
+// known to be identical (a 304 or an identical body with
+// WithConditionalReads enabled); callers can then skip their own work, e.g.
+// re-rendering a dashboard.
+func (s *service) ExecuteIfChanged(ctx context.Context, query string, args map[string]any) (res any, changed bool, err error) {
+	unchanged := new(bool)
+	ctx = context.WithValue(ctx, notModifiedKey{}, unchanged)
+	res, err = s.Execute(ctx, query, args)
+	return res, err != nil || !*unchanged, err
+}
+
+// conditionalLookup returns the key and remembered entry for a read, or
+// false when conditional reads do not apply.
+func (s *service) conditionalLookup(query string, args map[string]any) (string, *conditionalEntry, bool) {
+	if s.cond == nil || !isReadStatement(query) {
+		return "", nil, false
+	}
+	key, err := flightKey(query, args)
+	if err != nil {
+		return "", nil, false
+	}
+	c := s.cond
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.order.MoveToFront(el)
+		e := *el.Value.(*conditionalEntry)
+		return key, &e, true
+	}
+	return key, nil, true
+}
+
+// decodeConditional decodes a 2xx or 304 response to a remembered read,
+// reusing prev when the server or the body hash says nothing changed.
+func (s *service) decodeConditional(ctx context.Context, key string, prev *conditionalEntry, resp *http.Response, body io.Reader) (any, error) {
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode == http.StatusNotModified && prev != nil {
+		markUnchanged(ctx)
+		if etag == "" {
+			etag = prev.etag
+		}
+		s.cond.put(&conditionalEntry{key: key, etag: etag, sum: prev.sum, value: prev.value})
+		return prev.value, nil
+	}
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(raw)
+	if prev != nil && sum == prev.sum {
+		markUnchanged(ctx)
+		s.cond.put(&conditionalEntry{key: key, etag: etag, sum: sum, value: prev.value})
+		return prev.value, nil
+	}
+	res, err := s.decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	s.cond.put(&conditionalEntry{key: key, etag: etag, sum: sum, value: res})
+	return res, nil
+}
+
+// markUnchanged tells ExecuteIfChanged the result was not modified.
+func markUnchanged(ctx context.Context) {
+	if p, ok := ctx.Value(notModifiedKey{}).(*bool); ok {
+		*p = true
+	}
+}
+
+// put stores e, evicting the least recently used entry when full.
+func (c *conditionalCache) put(e *conditionalEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[e.key]; ok {
+		el.Value = e
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[e.key] = c.order.PushFront(e)
+	for c.order.Len() > c.max {
+		old := c.order.Back()
+		c.order.Remove(old)
+		delete(c.entries, old.Value.(*conditionalEntry).key)
+	}
+}
@@ -0,0 +1,152 @@
+package ditto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrConflict is returned when a revision-checked update finds a different
+// stored revision and no resolver (or the resolver) settles it.
+var ErrConflict = errors.New("ditto: revision conflict")
+
+// defaultRevisionField is the document field holding the revision counter.
+const defaultRevisionField = "revision"
+
+// maxConflictAttempts bounds resolve-and-retry rounds for one update.
+const maxConflictAttempts = 3
+
+// Resolution is a ConflictResolver's decision.
+type Resolution int
+
+const (
+	// ResolveOurs applies the caller's patch on top of the stored revision.
+	ResolveOurs Resolution = iota
+	// ResolveTheirs keeps the stored document and drops the caller's patch.
+	ResolveTheirs
+	// ResolveMerged applies the resolver-supplied merged patch instead.
+	ResolveMerged
+)
+
+// Conflict describes a failed revision-checked update.
+type Conflict struct {
+	Collection string
+	ID         string
+	Expected   int64          // revision the caller based the patch on
+	Stored     map[string]any // document as currently stored
+	Patch      map[string]any // caller's patch
+}
+
+// ConflictResolver decides what to do about a Conflict. For ResolveMerged it
+// returns the patch to apply; the merged patch is ignored otherwise.
+type ConflictResolver func(ctx context.Context, c Conflict) (Resolution, map[string]any, error)
+
+// WithConflictResolver enables revision-checked updates through
+// UpdateRecordRevision. field names the revision counter (default
+// "revision"); resolver may be nil, in which case conflicts return ErrConflict.
+func (s *service) WithConflictResolver(field string, resolver ConflictResolver) *service {
+	if field == "" {
+		field = defaultRevisionField
+	}
+	s.revisionField = field
+	s.resolver = resolver
+	return s
+}
+
+// UpdateRecordRevision applies patch only if the stored revision equals rev,
+// bumping the revision in the same UPDATE. A document without the revision
+// field counts as revision 0. When another writer got there
+// first, the configured ConflictResolver decides between ours, theirs, and a
+// merged patch, instead of silently overwriting.
+func (s *service) UpdateRecordRevision(
+	ctx context.Context,
+	collection, id string,
+	rev int64,
+	patch map[string]any,
+) (any, error) {
+	field := s.revisionField
+	if field == "" {
+		field = defaultRevisionField
+	}
+	if _, ok := patch[field]; ok {
+		return nil, fmt.Errorf("patch must not set revision field %q", field)
+	}
+	for attempt := 0; attempt < maxConflictAttempts; attempt++ {
+		res, err := s.updateAtRevision(ctx, collection, id, field, rev, patch)
+		if err != nil {
+			return nil, err
+		}
+		if len(mutatedIDs(res)) > 0 {
+			return res, nil
+		}
+
+		// Conflict (or missing document): look at what is stored, bypassing
+		// any cached copy
+		s.cache.invalidate(collection, id)
+		stored, err := s.GetRecord(ctx, collection, id)
+		if err != nil {
+			return nil, err
+		}
+		docs := Documents(stored)
+		if len(docs) == 0 {
+			return nil, fmt.Errorf("update %s/%s: record not found", collection, id)
+		}
+		current, _ := toInt64(docs[0][field])
+		if s.resolver == nil {
+			return nil, fmt.Errorf("%w: %s/%s expected revision %d, stored %d", ErrConflict, collection, id, rev, current)
+		}
+		decision, merged, err := s.resolver(ctx, Conflict{
+			Collection: collection,
+			ID:         id,
+			Expected:   rev,
+			Stored:     docs[0],
+			Patch:      patch,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("resolve conflict: %w", err)
+		}
+		switch decision {
+		case ResolveTheirs:
+			return stored, nil
+		case ResolveMerged:
+			patch = merged
+		case ResolveOurs:
+		default:
+			return nil, fmt.Errorf("resolve conflict: unknown resolution %d", decision)
+		}
+		rev = current
+	}
+	return nil, fmt.Errorf("%w: %s/%s still conflicting after %d attempts", ErrConflict, collection, id, maxConflictAttempts)
+}
+
+// updateAtRevision runs the conditional UPDATE ... WHERE _id == :id AND
+// <field> == :rev, setting <field> to rev+1. Revision 0 also matches a
+// document that has never had the field, so its first checked update
+// succeeds.
+func (s *service) updateAtRevision(
+	ctx context.Context,
+	collection, id, field string,
+	rev int64,
+	patch map[string]any,
+) (any, error) {
+	full := make(map[string]any, len(patch)+1)
+	for k, v := range patch {
+		full[k] = v
+	}
+	full[field] = rev + 1
+	f := escapePath(field)
+	cond := fmt.Sprintf("%s == :expected_rev", f)
+	if rev == 0 {
+		cond = fmt.Sprintf("(%s IS MISSING OR %s == :expected_rev)", f, f)
+	}
+	where := Where("_id == :id AND "+cond, map[string]any{"id": id, "expected_rev": rev})
+	q, args, err := buildUpdateWhere(collection, where, full, s.allowReserved)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.archive(ctx, collection, where); err != nil {
+		return nil, err
+	}
+	defer s.cache.invalidate(collection, id)
+	return s.execWithArgs(ctx, q, args)
+}
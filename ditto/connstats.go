@@ -0,0 +1,299 @@
+package ditto
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// connStatsWindow is how many recent samples each PhaseTiming covers.
+const connStatsWindow = 256
+
+// PhaseTiming summarizes one phase of recent requests.
+type PhaseTiming struct {
+	Count int64 // samples since WithConnStats
+	// Over the last connStatsWindow samples
+	P50, P95, Max time.Duration
+}
+
+// ConnStats is a snapshot of the connections to the Ditto HTTP API and
+// where recent requests spent their time. A request that reuses a pooled
+// connection has no DNS, Connect, or TLS sample, so comparing Requests with
+// Reused and those counts shows whether slow edge requests pay for new
+// connections.
+type ConnStats struct {
+	Open       int   // connections dialed and not yet closed
+	Idle       int   // of Open, waiting in the pool for a request
+	Dialed     int64 // connections opened since WithConnStats
+	Closed     int64
+	DialErrors int64
+	Requests   int64 // requests that got a connection
+	Reused     int64 // of Requests, served on a pooled connection
+	DNS        PhaseTiming
+	Connect    PhaseTiming // TCP (or unix socket) connect
+	TLS        PhaseTiming
+	FirstByte  PhaseTiming // request written to first response byte
+}
+
+// connTracker counts connections and request phases for WithConnStats.
+type connTracker struct {
+	open, idle, dialed, closed, dialErrors, requests, reused atomic.Int64
+
+	mu                                sync.Mutex
+	dns, connect, tlsShake, firstByte phaseWindow
+}
+
+// phaseWindow is a PhaseTiming in the making.
+type phaseWindow struct {
+	count int64
+	win   latencyWindow
+}
+
+func (p *phaseWindow) add(d time.Duration) {
+	p.count++
+	p.win.add(d, connStatsWindow)
+}
+
+func (p *phaseWindow) timing() PhaseTiming {
+	t := PhaseTiming{Count: p.count}
+	sorted := append([]time.Duration(nil), p.win.buf...)
+	if len(sorted) == 0 {
+		return t
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	t.P50 = sorted[(len(sorted)-1)/2]
+	t.P95 = sorted[int(0.95*float64(len(sorted)-1))]
+	t.Max = sorted[len(sorted)-1]
+	return t
+}
+
+// WithConnStats tracks the connections to the Ditto HTTP API and traces
+// every request (DNS, connect, TLS, time to first byte) for Stats and
+// Status. It wraps the dialer of the client's *http.Transport (a clone of
+// http.DefaultTransport if none is set), so call it after WithDialContext
+// or WithUnixSocket; a custom RoundTripper that is not an *http.Transport is
+// replaced.
+func (s *service) WithConnStats() *service {
+	if s.HTTP == nil {
+		s.HTTP = &http.Client{}
+	} else {
+		// Copy the client so one shared by several services is left alone
+		c := *s.HTTP
+		s.HTTP = &c
+	}
+	var t *http.Transport
+	if ht, ok := s.HTTP.Transport.(*http.Transport); ok {
+		t = ht.Clone()
+	} else {
+		t = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	ct := &connTracker{}
+	dial := t.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	t.DialContext = ct.wrapDial(dial)
+	if t.DialTLSContext != nil {
+		t.DialTLSContext = ct.wrapDial(t.DialTLSContext)
+	}
+	s.HTTP.Transport = t
+	s.connStats = ct
+	return s
+}
+
+// Stats returns the connection and request-phase statistics gathered since
+// WithConnStats; without it the result is zero.
+func (s *service) Stats() ConnStats {
+	ct := s.connStats
+	if ct == nil {
+		return ConnStats{}
+	}
+	st := ConnStats{
+		Open:       int(ct.open.Load()),
+		Idle:       int(ct.idle.Load()),
+		Dialed:     ct.dialed.Load(),
+		Closed:     ct.closed.Load(),
+		DialErrors: ct.dialErrors.Load(),
+		Requests:   ct.requests.Load(),
+		Reused:     ct.reused.Load(),
+	}
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	st.DNS = ct.dns.timing()
+	st.Connect = ct.connect.timing()
+	st.TLS = ct.tlsShake.timing()
+	st.FirstByte = ct.firstByte.timing()
+	return st
+}
+
+// connStatsStatus reports Stats for Status.
+func (s *service) connStatsStatus() map[string]any {
+	st := s.Stats()
+	return map[string]any{
+		"open":           st.Open,
+		"idle":           st.Idle,
+		"dialed":         st.Dialed,
+		"requests":       st.Requests,
+		"reused":         st.Reused,
+		"dialErrors":     st.DialErrors,
+		"connectP95":     st.Connect.P95.String(),
+		"tlsP95":         st.TLS.P95.String(),
+		"firstByteP95":   st.FirstByte.P95.String(),
+		"firstByteCount": st.FirstByte.Count,
+	}
+}
+
+// wrapDial counts the connections dial opens.
+func (ct *connTracker) wrapDial(dial DialFunc) DialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		c, err := dial(ctx, network, addr)
+		if err != nil {
+			ct.dialErrors.Add(1)
+			return nil, err
+		}
+		ct.dialed.Add(1)
+		ct.open.Add(1)
+		return &trackedConn{Conn: c, ct: ct}, nil
+	}
+}
+
+// trackedConn is a connection counted by a connTracker.
+type trackedConn struct {
+	net.Conn
+	ct     *connTracker
+	idle   atomic.Bool
+	closed atomic.Bool
+}
+
+func (c *trackedConn) Close() error {
+	if c.closed.CompareAndSwap(false, true) {
+		c.ct.open.Add(-1)
+		c.ct.closed.Add(1)
+		if c.idle.Swap(false) {
+			c.ct.idle.Add(-1)
+		}
+	}
+	return c.Conn.Close()
+}
+
+// setIdle moves c in or out of the idle count.
+func (c *trackedConn) setIdle(idle bool) {
+	if c.idle.Swap(idle) == idle {
+		return
+	}
+	if idle {
+		c.ct.idle.Add(1)
+	} else {
+		c.ct.idle.Add(-1)
+	}
+	// Closed while being marked idle
+	if idle && c.closed.Load() && c.idle.Swap(false) {
+		c.ct.idle.Add(-1)
+	}
+}
+
+// trackedOf finds the trackedConn under a connection the transport hands
+// out, which may be a TLS connection on top of it.
+func trackedOf(c net.Conn) *trackedConn {
+	if u, ok := c.(interface{ NetConn() net.Conn }); ok {
+		c = u.NetConn()
+	}
+	tc, _ := c.(*trackedConn)
+	return tc
+}
+
+// withConnTrace attaches a request trace feeding WithConnStats.
+func (s *service) withConnTrace(req *http.Request) *http.Request {
+	ct := s.connStats
+	if ct == nil {
+		return req
+	}
+	var mu sync.Mutex // hedged duplicates share the trace
+	var dnsStart, connectStart, tlsStart, wrote time.Time
+	var conn *trackedConn
+	record := func(p *phaseWindow, since time.Time) {
+		if since.IsZero() {
+			return
+		}
+		d := time.Since(since)
+		ct.mu.Lock()
+		p.add(d)
+		ct.mu.Unlock()
+	}
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			mu.Lock()
+			dnsStart = time.Now()
+			mu.Unlock()
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			if info.Err == nil {
+				record(&ct.dns, dnsStart)
+			}
+		},
+		ConnectStart: func(string, string) {
+			mu.Lock()
+			connectStart = time.Now()
+			mu.Unlock()
+		},
+		ConnectDone: func(_, _ string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				record(&ct.connect, connectStart)
+			}
+		},
+		TLSHandshakeStart: func() {
+			mu.Lock()
+			tlsStart = time.Now()
+			mu.Unlock()
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				record(&ct.tlsShake, tlsStart)
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			ct.requests.Add(1)
+			if info.Reused {
+				ct.reused.Add(1)
+			}
+			tc := trackedOf(info.Conn)
+			if tc != nil {
+				tc.setIdle(false)
+			}
+			mu.Lock()
+			conn = tc
+			mu.Unlock()
+		},
+		PutIdleConn: func(err error) {
+			mu.Lock()
+			tc := conn
+			mu.Unlock()
+			if err == nil && tc != nil {
+				tc.setIdle(true)
+			}
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			mu.Lock()
+			wrote = time.Now()
+			mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			record(&ct.firstByte, wrote)
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
@@ -0,0 +1,83 @@
+package ditto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// CopyOptions configures CopyCollection.
+type CopyOptions struct {
+	// Transform rewrites each document before it is written; returning nil
+	// skips the document. The _id may be changed.
+	Transform func(doc map[string]any) map[string]any
+	// Where restricts the copy to matching source documents.
+	Where *Predicate
+	// IncludeDeleted also copies soft-deleted tombstones.
+	IncludeDeleted bool
+}
+
+// CopyCollection streams the documents of src into dst page by page,
+// upserting by _id, and returns how many documents were written. Documents
+// already in dst with other ids are left alone.
+func (s *service) CopyCollection(ctx context.Context, src, dst string, opts CopyOptions) (int, error) {
+	ctx, cancel := s.opDeadline(ctx, opBulk)
+	defer cancel()
+	if src == "" || dst == "" {
+		return 0, errors.New("source and destination collections required")
+	}
+	if src == dst {
+		return 0, errors.New("cannot copy a collection onto itself")
+	}
+	total := knownTotal(-1)
+	if opts.Where == nil {
+		total = s.countTotal(ctx, src)
+	}
+	prog := s.progress(ctx, total)
+	n := 0
+	err := s.scanWhere(ctx, src, opts.Where, QueryOptions{IncludeDeleted: opts.IncludeDeleted},
+		func(docs []map[string]any) error {
+			batch := docs[:0]
+			for _, d := range docs {
+				if opts.Transform != nil {
+					if d = opts.Transform(d); d == nil {
+						continue
+					}
+				}
+				if _, ok := d["_id"]; !ok {
+					return fmt.Errorf("copy %s: transformed document without _id", src)
+				}
+				batch = append(batch, d)
+			}
+			if err := s.upsertDocs(ctx, dst, batch); err != nil {
+				return err
+			}
+			n += len(batch)
+			prog.add(len(docs))
+			return nil
+		})
+	if err != nil {
+		return n, fmt.Errorf("copy %s to %s: %w", src, dst, err)
+	}
+	s.cache.invalidateCollection(dst)
+	prog.finish()
+	return n, nil
+}
+
+// RenameCollection copies every document of src, tombstones included, into
+// dst and then evicts src. Ditto has no native rename; if the copy fails src
+// is left untouched.
+func (s *service) RenameCollection(ctx context.Context, src, dst string) (int, error) {
+	ctx, cancel := s.opDeadline(ctx, opBulk)
+	defer cancel()
+	n, err := s.CopyCollection(ctx, src, dst, CopyOptions{IncludeDeleted: true})
+	if err != nil {
+		return n, err
+	}
+	q := fmt.Sprintf("EVICT FROM %s WHERE _id LIKE :pattern", escapeIdent(src))
+	if _, err := s.execWithArgs(ctx, q, map[string]any{"pattern": "%"}); err != nil {
+		return n, fmt.Errorf("rename %s: evict source: %w", src, err)
+	}
+	s.cache.invalidateCollection(src)
+	return n, nil
+}
@@ -0,0 +1,174 @@
+package ditto
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// debugStatusTimeout bounds the container status lookup made per request.
+const debugStatusTimeout = 2 * time.Second
+
+// DebugHandler returns an http.Handler serving live SDK state as JSON:
+// request counts and error rates per statement, latency histograms,
+// per-collection traffic, recent slow queries, container status, and the
+// effective configuration with credentials redacted. Mount it in the host application, e.g.
+//
+//	mux.Handle("/debug/ditto/", http.StripPrefix("/debug/ditto", svc.DebugHandler()))
+//
+// The root path returns every section; /stats, /collections, /slow,
+// /container, and /config return one.
+func (s *service) DebugHandler() http.Handler {
+	sections := map[string]func(ctx context.Context) any{
+		"stats":       func(context.Context) any { return s.debugStats() },
+		"collections": func(context.Context) any { return s.debugCollections() },
+		"slow":        func(context.Context) any { return s.SlowQueries() },
+		"container":   s.debugContainer,
+		"config":      func(context.Context) any { return s.debugConfig() },
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var out any
+		switch name := strings.Trim(r.URL.Path, "/"); name {
+		case "":
+			all := map[string]any{}
+			for k, fn := range sections {
+				all[k] = fn(r.Context())
+			}
+			out = all
+		default:
+			fn, ok := sections[name]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			out = fn(r.Context())
+		}
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(out)
+	})
+}
+
+// debugCollections reports CollectionStats with derived rates.
+func (s *service) debugCollections() map[string]any {
+	out := map[string]any{}
+	for k, c := range s.CollectionStats() {
+		out[k] = map[string]any{
+			"reads":              c.Reads,
+			"writes":             c.Writes,
+			"errors":             c.Errors,
+			"error_rate":         c.ErrorRate(),
+			"documents_read":     c.DocumentsRead,
+			"documents_written":  c.DocumentsWritten,
+			"avg_document_bytes": c.AvgDocumentBytes(),
+		}
+	}
+	return out
+}
+
+// debugStats summarizes the latency histograms.
+func (s *service) debugStats() map[string]any {
+	var total, errs int64
+	statements := map[string]any{}
+	for k, h := range s.LatencyHistograms() {
+		total += h.Total
+		errs += h.Errors
+		st := map[string]any{"requests": h.Total, "errors": h.Errors}
+		if h.Total > 0 {
+			st["error_rate"] = float64(h.Errors) / float64(h.Total)
+			st["mean_ms"] = float64(h.Sum.Microseconds()) / 1000 / float64(h.Total)
+		}
+		buckets := map[string]int64{}
+		for i, c := range h.Counts {
+			le := "+Inf"
+			if i < len(h.Buckets) {
+				le = h.Buckets[i].String()
+			}
+			buckets["le_"+le] = c
+		}
+		st["buckets"] = buckets
+		statements[k] = st
+	}
+	out := map[string]any{"requests": total, "errors": errs, "statements": statements}
+	if total > 0 {
+		out["error_rate"] = float64(errs) / float64(total)
+	}
+	return out
+}
+
+// debugContainer reports the managed container's state, if any.
+func (s *service) debugContainer(ctx context.Context) any {
+	if s.docker == nil {
+		return map[string]any{"docker": "disabled"}
+	}
+	ctx, cancel := context.WithTimeout(ctx, debugStatusTimeout)
+	defer cancel()
+	st, err := s.docker.ContainerStatus(s.dockerCtx(ctx), s.dockerOpts.ContainerName)
+	if err != nil {
+		return map[string]any{"container": s.dockerOpts.ContainerName, "error": err.Error()}
+	}
+	return map[string]any{"container": s.dockerOpts.ContainerName, "status": st}
+}
+
+// debugConfig reports the effective configuration. URLs lose userinfo and
+// query strings, which may carry credentials.
+func (s *service) debugConfig() map[string]any {
+	cfg := map[string]any{
+		"base_url":        redactURL(s.baseURL()),
+		"app_id":          s.AppID,
+		"read_only":       s.readOnly,
+		"precise_numbers": s.preciseNumbers,
+		"allow_reserved":  s.allowReserved,
+		"max_payload":     s.maxPayload,
+		"cache":           s.cache != nil,
+		"versioned":       sortedKeys(s.versioned),
+		"soft_deleted":    sortedKeys(s.softDeleted),
+	}
+	if s.HTTP != nil {
+		cfg["http_timeout"] = s.HTTP.Timeout.String()
+	}
+	if s.obs != nil {
+		s.obs.mu.Lock()
+		cfg["slow_query_threshold"] = s.obs.threshold.String()
+		s.obs.mu.Unlock()
+	}
+	if len(s.quotas) > 0 {
+		q := map[string]any{}
+		for c, qs := range s.quotas {
+			q[c] = map[string]any{"max_document_bytes": qs.MaxDocumentBytes, "max_documents": qs.MaxDocuments}
+		}
+		cfg["quotas"] = q
+	}
+	if s.docker != nil {
+		cfg["docker"] = map[string]any{
+			"container": s.dockerOpts.ContainerName,
+			"image":     s.dockerOpts.ImageName,
+			"host_port": s.dockerOpts.HostPort,
+			"isolated":  s.dockerOpts.Isolated,
+		}
+	}
+	return cfg
+}
+
+// redactURL strips userinfo and the query string from raw.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "[unparseable]"
+	}
+	if u.User != nil {
+		u.User = url.User("REDACTED")
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = "REDACTED"
+	}
+	return u.String()
+}
@@ -0,0 +1,362 @@
+package ditto
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+)
+
+// Call describes one Service method invocation seen by an Interceptor.
+type Call struct {
+	Method     string // Service method name, e.g. "GetRecord"
+	Collection string // empty for Execute and Lifecycle methods
+	Args       []any  // method arguments after ctx
+	Read       bool   // a Reader method
+}
+
+// readOnly reports whether the call cannot change data: Reader methods,
+// Status, and Execute of a read statement.
+func (c Call) readOnly() bool {
+	if c.Method == "Execute" {
+		q, _ := c.Args[0].(string)
+		return isReadStatement(q)
+	}
+	return c.Read || c.Method == "Status"
+}
+
+// Interceptor runs around one Service call. It normally calls next once
+// and returns its result, but may call it several times (retries) or not at
+// all (cache hits). Results carry the method's own return value as any:
+// nil for InitDB and Close, bool for Exists and ExistsWhere.
+type Interceptor func(ctx context.Context, call Call, next func(context.Context) (any, error)) (any, error)
+
+// Decorator wraps a Service with cross-cutting behavior.
+type Decorator func(Service) Service
+
+// Intercept returns a Service that routes every method of svc through fn.
+func Intercept(svc Service, fn Interceptor) Service {
+	return &intercepted{next: svc, fn: fn}
+}
+
+// Chain applies decorators to svc so the first one listed is outermost:
+// Chain(svc, Logging(nil), Retrying(RetryPolicy{})) logs each call once,
+// around its retries.
+func Chain(svc Service, decorators ...Decorator) Service {
+	for i := len(decorators) - 1; i >= 0; i-- {
+		svc = decorators[i](svc)
+	}
+	return svc
+}
+
+// Logging returns LoggingService as a Decorator.
+func Logging(logger *slog.Logger) Decorator {
+	return func(svc Service) Service { return LoggingService(svc, logger) }
+}
+
+// Metrics returns MetricsService as a Decorator.
+func Metrics(observe func(call Call, d time.Duration, err error)) Decorator {
+	return func(svc Service) Service { return MetricsService(svc, observe) }
+}
+
+// Caching returns CachingService as a Decorator.
+func Caching(ttl time.Duration, maxEntries int) Decorator {
+	return func(svc Service) Service { return CachingService(svc, ttl, maxEntries) }
+}
+
+// Retrying returns RetryingService as a Decorator.
+func Retrying(p RetryPolicy) Decorator {
+	return func(svc Service) Service { return RetryingService(svc, p) }
+}
+
+// LoggingService logs every call with its duration at debug level, and
+// failed calls at warn level. A nil logger means slog.Default().
+func LoggingService(svc Service, logger *slog.Logger) Service {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return Intercept(svc, func(ctx context.Context, call Call, next func(context.Context) (any, error)) (any, error) {
+		start := time.Now()
+		res, err := next(ctx)
+		attrs := []any{"method", call.Method, "collection", call.Collection, "duration", time.Since(start)}
+		if err != nil {
+			logger.WarnContext(ctx, "ditto call failed", append(attrs, "error", err)...)
+		} else {
+			logger.DebugContext(ctx, "ditto call", attrs...)
+		}
+		return res, err
+	})
+}
+
+// MetricsService reports the duration and outcome of every call to
+// observe, e.g. to feed Prometheus histograms keyed by call.Method.
+func MetricsService(svc Service, observe func(call Call, d time.Duration, err error)) Service {
+	return Intercept(svc, func(ctx context.Context, call Call, next func(context.Context) (any, error)) (any, error) {
+		start := time.Now()
+		res, err := next(ctx)
+		observe(call, time.Since(start), err)
+		return res, err
+	})
+}
+
+// CachingService caches the results of Reader calls for ttl, holding up to
+// maxEntries results. Writer and Admin calls drop the cached results of
+// their collection; mutating Execute statements, InitDB, and Close drop
+// everything. Writes by other clients show up once the TTL expires. Cached
+// results are shared and must not be mutated.
+func CachingService(svc Service, ttl time.Duration, maxEntries int) Service {
+	if ttl <= 0 || maxEntries <= 0 {
+		return svc
+	}
+	cache := &recordCache{
+		ttl:     ttl,
+		max:     maxEntries,
+		order:   list.New(),
+		entries: map[cacheKey]*list.Element{},
+		fills:   map[cacheKey]*cacheFill{},
+		now:     time.Now,
+	}
+	return Intercept(svc, func(ctx context.Context, call Call, next func(context.Context) (any, error)) (any, error) {
+		if !call.Read {
+			res, err := next(ctx)
+			switch {
+			case call.readOnly():
+			case call.Collection != "":
+				cache.invalidateCollection(call.Collection)
+			default:
+				cache.clear()
+			}
+			return res, err
+		}
+		b, err := json.Marshal(call.Args)
+		if err != nil {
+			return next(ctx)
+		}
+		key := call.Method + string(b)
+		if res, ok := cache.get(call.Collection, key); ok {
+			return res, nil
+		}
+		// A write that completes while this call runs makes its result stale
+		gen := cache.begin(call.Collection, key)
+		res, err := next(ctx)
+		if err != nil {
+			cache.release(call.Collection, key)
+			return res, err
+		}
+		cache.put(call.Collection, key, gen, res)
+		return res, nil
+	})
+}
+
+// RetryPolicy configures RetryingService.
+type RetryPolicy struct {
+	MaxAttempts int           // total attempts per call; defaults to 3
+	BaseDelay   time.Duration // first backoff, doubled per retry; defaults to 100ms
+	MaxDelay    time.Duration // backoff cap; defaults to 2s
+	// RetryWrites also retries Writer and Admin calls. Inserts are not
+	// idempotent, so a retried CreateDocument may fail with a duplicate _id
+	// if the first attempt reached the server.
+	RetryWrites bool
+	// Retryable decides whether err is worth another attempt; nil means the
+	// package-level Retryable (network errors, timeouts, 429, and 5xx
+	// responses).
+	Retryable func(call Call, err error) bool
+	Sleeper   Sleeper // waits between attempts; nil means real timers
+}
+
+// RetryingService retries failed read-only calls (Reader methods, Status,
+// and Execute of a read statement) with exponential backoff according to p;
+// with RetryWrites it retries every call.
+func RetryingService(svc Service, p RetryPolicy) Service {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 3
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = 100 * time.Millisecond
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = 2 * time.Second
+	}
+	if p.Retryable == nil {
+		p.Retryable = func(_ Call, err error) bool { return Retryable(err) }
+	}
+	if p.Sleeper == nil {
+		p.Sleeper = SystemSleeper
+	}
+	return Intercept(svc, func(ctx context.Context, call Call, next func(context.Context) (any, error)) (any, error) {
+		retry := call.readOnly() || p.RetryWrites
+		delay := p.BaseDelay
+		for attempt := 1; ; attempt++ {
+			res, err := next(ctx)
+			if err == nil || !retry || attempt >= p.MaxAttempts || !p.Retryable(call, err) {
+				return res, err
+			}
+			if serr := p.Sleeper.Sleep(ctx, delay); serr != nil {
+				return res, err
+			}
+			delay = min(delay*2, p.MaxDelay)
+		}
+	})
+}
+
+// intercepted forwards every Service method through an Interceptor.
+type intercepted struct {
+	next Service
+	fn   Interceptor
+}
+
+// intercepted must keep satisfying Service as methods are added
+var _ Service = (*intercepted)(nil)
+
+func (i *intercepted) InitDB(ctx context.Context) error {
+	_, err := i.fn(ctx, Call{Method: "InitDB"}, func(ctx context.Context) (any, error) {
+		return nil, i.next.InitDB(ctx)
+	})
+	return err
+}
+
+func (i *intercepted) Close(ctx context.Context) error {
+	_, err := i.fn(ctx, Call{Method: "Close"}, func(ctx context.Context) (any, error) {
+		return nil, i.next.Close(ctx)
+	})
+	return err
+}
+
+func (i *intercepted) Status(ctx context.Context) (map[string]any, error) {
+	res, err := i.fn(ctx, Call{Method: "Status"}, func(ctx context.Context) (any, error) {
+		return i.next.Status(ctx)
+	})
+	m, _ := res.(map[string]any)
+	return m, err
+}
+
+func (i *intercepted) CreateDocument(ctx context.Context, collection string, doc map[string]any) (any, error) {
+	return i.fn(ctx, Call{Method: "CreateDocument", Collection: collection, Args: []any{collection, doc}}, func(ctx context.Context) (any, error) {
+		return i.next.CreateDocument(ctx, collection, doc)
+	})
+}
+
+func (i *intercepted) GetRecord(ctx context.Context, collection, id string) (any, error) {
+	return i.fn(ctx, Call{Method: "GetRecord", Collection: collection, Args: []any{collection, id}, Read: true}, func(ctx context.Context) (any, error) {
+		return i.next.GetRecord(ctx, collection, id)
+	})
+}
+
+func (i *intercepted) GetRecordsByIDs(ctx context.Context, collection string, ids []string) (any, error) {
+	return i.fn(ctx, Call{Method: "GetRecordsByIDs", Collection: collection, Args: []any{collection, ids}, Read: true}, func(ctx context.Context) (any, error) {
+		return i.next.GetRecordsByIDs(ctx, collection, ids)
+	})
+}
+
+func (i *intercepted) Exists(ctx context.Context, collection, id string) (bool, error) {
+	res, err := i.fn(ctx, Call{Method: "Exists", Collection: collection, Args: []any{collection, id}, Read: true}, func(ctx context.Context) (any, error) {
+		return i.next.Exists(ctx, collection, id)
+	})
+	ok, _ := res.(bool)
+	return ok, err
+}
+
+func (i *intercepted) ExistsWhere(ctx context.Context, collection string, where Predicate) (bool, error) {
+	res, err := i.fn(ctx, Call{Method: "ExistsWhere", Collection: collection, Args: []any{collection, where}, Read: true}, func(ctx context.Context) (any, error) {
+		return i.next.ExistsWhere(ctx, collection, where)
+	})
+	ok, _ := res.(bool)
+	return ok, err
+}
+
+func (i *intercepted) GetRecords(ctx context.Context, collection string, limit int, sortBy, sortOrder string) (any, error) {
+	return i.fn(ctx, Call{Method: "GetRecords", Collection: collection, Args: []any{collection, limit, sortBy, sortOrder}, Read: true}, func(ctx context.Context) (any, error) {
+		return i.next.GetRecords(ctx, collection, limit, sortBy, sortOrder)
+	})
+}
+
+func (i *intercepted) LatestRecord(ctx context.Context, collection, sortBy string) (any, error) {
+	return i.fn(ctx, Call{Method: "LatestRecord", Collection: collection, Args: []any{collection, sortBy}, Read: true}, func(ctx context.Context) (any, error) {
+		return i.next.LatestRecord(ctx, collection, sortBy)
+	})
+}
+
+func (i *intercepted) Search(ctx context.Context, collection string, filters map[string]string, limit int, sortBy, sortOrder string) (any, error) {
+	return i.fn(ctx, Call{Method: "Search", Collection: collection, Args: []any{collection, filters, limit, sortBy, sortOrder}, Read: true}, func(ctx context.Context) (any, error) {
+		return i.next.Search(ctx, collection, filters, limit, sortBy, sortOrder)
+	})
+}
+
+func (i *intercepted) FindRecords(ctx context.Context, collection string, filters map[string]string, opts ...QueryOptions) (any, error) {
+	return i.fn(ctx, Call{Method: "FindRecords", Collection: collection, Args: []any{collection, filters, opts}, Read: true}, func(ctx context.Context) (any, error) {
+		return i.next.FindRecords(ctx, collection, filters, opts...)
+	})
+}
+
+func (i *intercepted) FindWhere(ctx context.Context, collection string, where Predicate, opts ...QueryOptions) (any, error) {
+	return i.fn(ctx, Call{Method: "FindWhere", Collection: collection, Args: []any{collection, where, opts}, Read: true}, func(ctx context.Context) (any, error) {
+		return i.next.FindWhere(ctx, collection, where, opts...)
+	})
+}
+
+func (i *intercepted) GetRecordsWith(ctx context.Context, req GetRecordsRequest) (any, error) {
+	return i.fn(ctx, Call{Method: "GetRecordsWith", Collection: req.Collection, Args: []any{req}, Read: true}, func(ctx context.Context) (any, error) {
+		return i.next.GetRecordsWith(ctx, req)
+	})
+}
+
+func (i *intercepted) SearchWith(ctx context.Context, req SearchRequest) (any, error) {
+	return i.fn(ctx, Call{Method: "SearchWith", Collection: req.Collection, Args: []any{req}, Read: true}, func(ctx context.Context) (any, error) {
+		return i.next.SearchWith(ctx, req)
+	})
+}
+
+func (i *intercepted) LatestRecordWith(ctx context.Context, req LatestRecordRequest) (any, error) {
+	return i.fn(ctx, Call{Method: "LatestRecordWith", Collection: req.Collection, Args: []any{req}, Read: true}, func(ctx context.Context) (any, error) {
+		return i.next.LatestRecordWith(ctx, req)
+	})
+}
+
+func (i *intercepted) UpdateRecord(ctx context.Context, collection, id string, patch map[string]any) (any, error) {
+	return i.fn(ctx, Call{Method: "UpdateRecord", Collection: collection, Args: []any{collection, id, patch}}, func(ctx context.Context) (any, error) {
+		return i.next.UpdateRecord(ctx, collection, id, patch)
+	})
+}
+
+func (i *intercepted) UpdateWhere(ctx context.Context, collection string, where Predicate, patch map[string]any) (any, error) {
+	return i.fn(ctx, Call{Method: "UpdateWhere", Collection: collection, Args: []any{collection, where, patch}}, func(ctx context.Context) (any, error) {
+		return i.next.UpdateWhere(ctx, collection, where, patch)
+	})
+}
+
+func (i *intercepted) UpdateMany(ctx context.Context, collection string, ids []string, patch map[string]any) (any, error) {
+	return i.fn(ctx, Call{Method: "UpdateMany", Collection: collection, Args: []any{collection, ids, patch}}, func(ctx context.Context) (any, error) {
+		return i.next.UpdateMany(ctx, collection, ids, patch)
+	})
+}
+
+func (i *intercepted) DeleteRecord(ctx context.Context, collection, id string) (any, error) {
+	return i.fn(ctx, Call{Method: "DeleteRecord", Collection: collection, Args: []any{collection, id}}, func(ctx context.Context) (any, error) {
+		return i.next.DeleteRecord(ctx, collection, id)
+	})
+}
+
+func (i *intercepted) UpdateRecordWith(ctx context.Context, req UpdateRecordRequest) (any, error) {
+	return i.fn(ctx, Call{Method: "UpdateRecordWith", Collection: req.Collection, Args: []any{req}}, func(ctx context.Context) (any, error) {
+		return i.next.UpdateRecordWith(ctx, req)
+	})
+}
+
+func (i *intercepted) UpdateWhereWith(ctx context.Context, req UpdateWhereRequest) (any, error) {
+	return i.fn(ctx, Call{Method: "UpdateWhereWith", Collection: req.Collection, Args: []any{req}}, func(ctx context.Context) (any, error) {
+		return i.next.UpdateWhereWith(ctx, req)
+	})
+}
+
+func (i *intercepted) DeleteAllRecords(ctx context.Context, collection string, confirm ...DestructiveOption) (any, error) {
+	return i.fn(ctx, Call{Method: "DeleteAllRecords", Collection: collection, Args: []any{collection}}, func(ctx context.Context) (any, error) {
+		return i.next.DeleteAllRecords(ctx, collection, confirm...)
+	})
+}
+
+func (i *intercepted) Execute(ctx context.Context, query string, args map[string]any) (any, error) {
+	return i.fn(ctx, Call{Method: "Execute", Args: []any{query, args}}, func(ctx context.Context) (any, error) {
+		return i.next.Execute(ctx, query, args)
+	})
+}
@@ -0,0 +1,142 @@
+package ditto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DedupeAction is what Dedupe does with the duplicates it finds.
+type DedupeAction string
+
+const (
+	// DedupeReport only reports duplicate groups (dry run).
+	DedupeReport DedupeAction = "report"
+	// DedupeDelete deletes every duplicate except the survivor.
+	DedupeDelete DedupeAction = "delete"
+	// DedupeMerge copies fields the survivor lacks from the duplicates into
+	// it, then deletes the duplicates.
+	DedupeMerge DedupeAction = "merge"
+)
+
+// DedupeOptions configures Dedupe.
+type DedupeOptions struct {
+	// Key lists the dotted fields whose combined values identify a duplicate,
+	// e.g. []string{"serial_number"}. Documents missing any key field are
+	// never considered duplicates.
+	Key []string
+	// KeyFunc overrides Key when set; returning false skips the document.
+	KeyFunc func(doc map[string]any) (string, bool)
+	// OrderBy picks the survivor of each group: the document with the
+	// smallest value, or the largest when KeepLatest is set. Defaults to _id.
+	OrderBy    string
+	KeepLatest bool
+	Action     DedupeAction // defaults to DedupeReport
+}
+
+// DuplicateGroup is a set of documents sharing one key.
+type DuplicateGroup struct {
+	Key        string
+	Keep       string   // _id of the survivor
+	Duplicates []string // _ids of the other documents
+}
+
+// Dedupe finds documents of collection that share a key and, depending on
+// opts.Action, reports, deletes, or merges them into one survivor per group.
+// Groups are returned in key order whatever the action.
+func (s *service) Dedupe(ctx context.Context, collection string, opts DedupeOptions) ([]DuplicateGroup, error) {
+	ctx, cancel := s.opDeadline(ctx, opBulk)
+	defer cancel()
+	if collection == "" {
+		return nil, errors.New("collection required")
+	}
+	keyOf := opts.KeyFunc
+	if keyOf == nil {
+		if len(opts.Key) == 0 {
+			return nil, errors.New("dedupe needs Key or KeyFunc")
+		}
+		keyOf = func(d map[string]any) (string, bool) {
+			parts := make([]string, len(opts.Key))
+			for i, f := range opts.Key {
+				v := lookupPath(d, f)
+				if v == nil {
+					return "", false
+				}
+				parts[i] = fmt.Sprint(v)
+			}
+			return strings.Join(parts, "\x1f"), true
+		}
+	}
+	action := opts.Action
+	if action == "" {
+		action = DedupeReport
+	}
+	orderBy := opts.OrderBy
+	if orderBy == "" {
+		orderBy = "_id"
+	}
+
+	groups := map[string][]map[string]any{}
+	err := s.scanCollection(ctx, collection, func(docs []map[string]any) error {
+		for _, d := range docs {
+			if k, ok := keyOf(d); ok {
+				groups[k] = append(groups[k], d)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("dedupe %s: %w", collection, err)
+	}
+
+	var out []DuplicateGroup
+	for _, k := range sortedKeys(groups) {
+		docs := groups[k]
+		if len(docs) < 2 {
+			continue
+		}
+		keep := docs[0]
+		for _, d := range docs[1:] {
+			c := compareValues(lookupPath(d, orderBy), lookupPath(keep, orderBy))
+			if (opts.KeepLatest && c > 0) || (!opts.KeepLatest && c < 0) {
+				keep = d
+			}
+		}
+		g := DuplicateGroup{Key: strings.ReplaceAll(k, "\x1f", "|"), Keep: fmt.Sprint(keep["_id"])}
+		for _, d := range docs {
+			if id := fmt.Sprint(d["_id"]); id != g.Keep {
+				g.Duplicates = append(g.Duplicates, id)
+			}
+		}
+		out = append(out, g)
+
+		switch action {
+		case DedupeReport:
+			continue
+		case DedupeMerge:
+			merged := false
+			for _, d := range docs {
+				for f, v := range d {
+					if _, ok := keep[f]; !ok {
+						keep[f] = v
+						merged = true
+					}
+				}
+			}
+			if merged {
+				if err := s.upsertDocs(ctx, collection, []map[string]any{keep}); err != nil {
+					return out, fmt.Errorf("dedupe %s: merge into %s: %w", collection, g.Keep, err)
+				}
+			}
+		case DedupeDelete:
+		default:
+			return out, fmt.Errorf("unknown dedupe action %q", action)
+		}
+		if err := s.deleteDocs(ctx, collection, g.Duplicates); err != nil {
+			return out, fmt.Errorf("dedupe %s: delete duplicates of %s: %w", collection, g.Keep, err)
+		}
+		s.cache.invalidate(collection, g.Keep)
+	}
+	return out, nil
+}
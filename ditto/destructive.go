@@ -0,0 +1,38 @@
+package ditto
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrDestructiveOpNotConfirmed is returned by operations that remove every
+// document of a collection when the call was not confirmed.
+var ErrDestructiveOpNotConfirmed = errors.New("ditto: destructive operation not confirmed")
+
+// DestructiveOption confirms an operation that removes every document of a
+// collection, e.g. DeleteAllRecords(ctx, "orders", ditto.ConfirmDeleteAll).
+type DestructiveOption struct{ op string }
+
+// ConfirmDeleteAll confirms DeleteAllRecords.
+var ConfirmDeleteAll = DestructiveOption{op: "delete-all"}
+
+// WithAllowDestructiveOps lets destructive operations run without a
+// confirmation option, e.g. in test fixtures that reset collections.
+func (s *service) WithAllowDestructiveOps(allow bool) *service {
+	s.allowDestructive = allow
+	return s
+}
+
+// confirmDestructive checks that op was confirmed by an option or by
+// WithAllowDestructiveOps.
+func (s *service) confirmDestructive(op DestructiveOption, collection string, opts []DestructiveOption) error {
+	if s.allowDestructive {
+		return nil
+	}
+	for _, o := range opts {
+		if o == op {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s on %s needs ditto.ConfirmDeleteAll", ErrDestructiveOpNotConfirmed, op.op, collection)
+}
@@ -0,0 +1,55 @@
+package ditto
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+// DialFunc opens the connection for an HTTP request, like
+// net.Dialer.DialContext.
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// WithDialContext routes every connection to the Ditto HTTP API through dial.
+// It installs dial on the client's *http.Transport (a clone of
+// http.DefaultTransport if none is set). A custom RoundTripper that is not an
+// *http.Transport is replaced, so install wrappers such as the dittotest
+// transports afterwards, around s.HTTP.Transport.
+func (s *service) WithDialContext(dial DialFunc) *service {
+	if s.HTTP == nil {
+		s.HTTP = &http.Client{}
+	} else {
+		// Copy the client so one shared by several services is left alone
+		c := *s.HTTP
+		s.HTTP = &c
+	}
+	var t *http.Transport
+	if ht, ok := s.HTTP.Transport.(*http.Transport); ok {
+		t = ht.Clone()
+	} else {
+		t = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	t.DialContext = dial
+	// A proxy would receive the dial instead of the server
+	t.Proxy = nil
+	s.HTTP.Transport = t
+	return s
+}
+
+// WithUnixSocket dials the Ditto HTTP API over the unix domain socket at
+// path, for an edge server on the same host that need not expose a TCP port.
+// BaseURL still supplies the scheme and request paths; its host is only
+// sent as the Host header, so a placeholder such as "http://ditto" works.
+func (s *service) WithUnixSocket(path string) *service {
+	return s.WithDialContext(UnixSocketDialer(path))
+}
+
+// UnixSocketDialer returns a DialFunc that ignores the requested address and
+// connects to the unix domain socket at path, for use with WithDialContext
+// or a hand-built http.Transport.
+func UnixSocketDialer(path string) DialFunc {
+	var d net.Dialer
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return d.DialContext(ctx, "unix", path)
+	}
+}
@@ -0,0 +1,110 @@
+package ditto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Patch is a minimal change set between two versions of a document. Set maps
+// dotted field paths to their new values; Unset lists paths to remove.
+type Patch struct {
+	Set   map[string]any
+	Unset []string
+}
+
+// Map flattens the patch into a field map usable with UpdateRecord and the
+// other update helpers: Set entries as-is and Unset paths as the Unset
+// sentinel.
+func (p Patch) Map() map[string]any {
+	m := make(map[string]any, len(p.Set)+len(p.Unset))
+	for k, v := range p.Set {
+		m[k] = v
+	}
+	for _, k := range p.Unset {
+		m[k] = Unset
+	}
+	return m
+}
+
+// Empty reports whether the patch changes nothing.
+func (p Patch) Empty() bool { return len(p.Set) == 0 && len(p.Unset) == 0 }
+
+// Diff compares two documents and returns the minimal patch turning old into
+// new. Nested objects are compared field by field and produce dotted paths
+// ("address.city"); arrays and scalars are replaced whole. _id is ignored.
+func Diff(old, new map[string]any) Patch {
+	p := Patch{Set: map[string]any{}}
+	diffInto(&p, "", old, new)
+	sort.Strings(p.Unset)
+	return p
+}
+
+// diffInto appends the differences between old and new under prefix to p.
+func diffInto(p *Patch, prefix string, old, new map[string]any) {
+	for k, nv := range new {
+		if prefix == "" && k == "_id" {
+			continue
+		}
+		path := prefix + k
+		ov, ok := old[k]
+		if !ok {
+			p.Set[path] = nv
+			continue
+		}
+		om, oIsMap := ov.(map[string]any)
+		nm, nIsMap := nv.(map[string]any)
+		if oIsMap && nIsMap {
+			diffInto(p, path+".", om, nm)
+			continue
+		}
+		if !reflect.DeepEqual(ov, nv) {
+			p.Set[path] = nv
+		}
+	}
+	for k := range old {
+		if prefix == "" && k == "_id" {
+			continue
+		}
+		if _, ok := new[k]; !ok {
+			p.Unset = append(p.Unset, prefix+k)
+		}
+	}
+}
+
+// BuildPatch constructs an UPDATE DQL applying a Patch to the record with the
+// given _id: SET for changed paths (parameterized) and UNSET for removed ones.
+func BuildPatch(collection, id string, p Patch) (string, map[string]any, error) {
+	return buildPatch(collection, id, p, false)
+}
+
+// buildPatch is BuildPatch with an override for reserved-field checks.
+func buildPatch(collection, id string, p Patch, allowReserved bool) (string, map[string]any, error) {
+	if collection == "" || id == "" {
+		return "", nil, errors.New("collection and id required")
+	}
+	if p.Empty() {
+		return "", nil, errors.New("patch is empty")
+	}
+	return buildUpdate(collection, id, p.Map(), allowReserved)
+}
+
+// ApplyPatch applies a Patch (typically from Diff) to a record by _id. An
+// empty patch is a no-op returning nil. It is equivalent to
+// UpdateRecord(ctx, collection, id, p.Map()).
+func (s *service) ApplyPatch(ctx context.Context, collection, id string, p Patch) (any, error) {
+	if p.Empty() {
+		return nil, nil
+	}
+	q, args, err := buildPatch(collection, id, p, s.allowReserved)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.archive(ctx, collection, Where("_id == :id", map[string]any{"id": id})); err != nil {
+		return nil, fmt.Errorf("apply patch: %w", err)
+	}
+	defer s.cache.invalidate(collection, id)
+	return s.execWithArgs(ctx, q, args)
+}
@@ -11,10 +11,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"os/exec"
+	"sort"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 )
 
 /*
@@ -36,50 +40,365 @@ import (
        The provided DockerOptions are stored for use during InitDB and Close.
    - (s *service) InitDB(ctx context.Context) error
        Ensures the Ditto Edge container is running. If a DockerRunner is attached,
-       it checks for the image (loading from tar if necessary), starts an existing
-       container if exited, or runs a new one if not found. Marks the container as
-       started by this process so Close can stop it. If no DockerRunner is attached,
-       this is a no-op.
+       it checks for the image (loading from tar if necessary), starts or
+       recreates an exited container according to DockerOptions.RecreatePolicy,
+       or runs a new one if not found. Marks the container as
+       started by this process so Close can stop it. With StartupTimeout set it
+       tails the container logs until a ready line appears, returning a
+       *StartupError with a log excerpt on crash or timeout, then polls the HTTP
+       API with exponential backoff until a query succeeds (ReadyTimeout). If no
+       DockerRunner is attached, this is a no-op.
    - (s *service) Close(ctx context.Context) error
        Attempts to stop the Ditto container if a DockerRunner is attached. Safe to
-       call multiple times; ignores errors on shutdown.
+       call multiple times; ignores errors on shutdown. Draining comes first: OnClose
+       hooks run, in-flight requests and async writes get the shutdown grace period
+       (WithShutdownGrace), and background work is stopped. Isolated containers and
+       their data directories are removed.
+   - (s *service) Teardown(ctx context.Context, opts TeardownOptions) error
+       Stops the Ditto container and optionally removes the container, its
+       volumes, and the image. Intended for CI and uninstall flows.
+   - (s *service) WithVersioning(collections ...string) *service
+       Enables versioning: before each SDK update the previous document state is
+       copied into <collection>_history with a version counter and timestamp.
+   - (s *service) GetHistory(ctx context.Context, collection, id string) (any, error)
+       Returns the archived versions of a document, oldest first.
+   - (s *service) WithSoftDelete(collections ...string) *service
+       Turns deletes into deleted_at tombstones for the named collections and
+       filters tombstoned documents out of every read helper.
+   - (s *service) PurgeDeleted(ctx context.Context, collection string, olderThan time.Duration) (any, error)
+       Physically removes tombstoned documents deleted more than olderThan ago.
+   - (s *service) Expand(ctx context.Context, docs []map[string]any, specs ...RefSpec) ([]map[string]any, error)
+       Client-side join: batch-fetches the documents referenced by each RefSpec
+       field and embeds them into docs under RefSpec.As.
+   - Documents(res any) []map[string]any
+       Extracts the document items from a decoded /execute response.
+   - (s *service) RegisterView(v View) error
+       Registers a materialized view (derived collection) computed from a source
+       collection; refreshed in the background every v.Interval if set.
+   - (s *service) RefreshView(ctx context.Context, name string) error
+       Recomputes a view now: upserts computed documents and prunes stale ones.
+   - LatestPerKey(key, orderBy string) ViewFunc / Rollup(key, timeField, value string, window time.Duration) ViewFunc
+       Ready-made view computations: latest document per key, and windowed
+       count/sum/avg/min/max aggregates.
+   - (s *service) WithConflictResolver(field string, resolver ConflictResolver) *service
+       Configures the revision field and resolver used by UpdateRecordRevision.
+   - (s *service) UpdateRecordRevision(ctx context.Context, collection, id string, rev int64, patch map[string]any) (any, error)
+       Applies a patch only at the expected revision; on conflict the resolver
+       chooses ours, theirs, or a merged patch (ErrConflict without a resolver).
+   - (s *service) WithCache(ttl time.Duration, maxEntries int) *service
+       Enables an LRU cache for GetRecord keyed by (collection, _id) with a TTL,
+       invalidated by updates and deletes made through the same service.
+   - (s *service) WithAllowReserved() *service
+       Lets update methods write reserved fields such as _id (power users only).
+   - (s *service) WithPreciseNumbers() *service
+       Decodes response numbers as json.Number so large int64 and decimal values
+       keep their exact digits.
+   - Scan(res any, dst any) error
+       Converts the documents of a response into a slice of structs or maps,
+       keeping numbers exact.
+   - ResultOf(res any) QueryResult
+       Typed view of a response (Items, MutatedIDs) with Documents, Scan, and
+       ScanOne; numbers stay json.Number under WithPreciseNumbers.
+   - NewLock(svc Service, collection, key string) *Lock
+       Lease-based distributed lock over a collection (TryAcquire, Acquire,
+       Renew, Heartbeat, Release) using conditional updates.
+   - (s *service) NextSequence(ctx context.Context, name string) (int64, error)
+       Atomically increments a named counter document (compare-and-set with
+       retry) for human-friendly incrementing numbers.
+   - (s *service) KV(collection string) *KV
+       Key-value facade over a collection with typed getters, TTLs, and
+       namespaces; missing keys return ErrNotFound.
+   - (s *service) PutBlob / GetBlob / StatBlob / DeleteBlob
+       Chunked []byte storage: a manifest in the collection plus chunk
+       documents in <collection>_chunks, checksummed on read.
+   - (s *service) PutEnvelope / GetEnvelope
+       Versioned envelopes {_schema: "name:vN", data}; GetEnvelope runs the
+       SchemaRegistry upgrade steps so old documents read as the current version.
+   - (s *service) InferSchema(ctx, collection string, sample int) (*JSONSchema, error)
+       Samples a collection and infers a JSON Schema (types, optionality);
+       JSONSchema.WriteFile exports it.
+   - (s *service) ValidateCollection(ctx, collection string, rules ValidationRules) (*ValidationReport, error)
+       Scans a collection against required/enum/reference/custom rules and
+       reports violations by document id.
+   - (s *service) Dedupe(ctx, collection string, opts DedupeOptions) ([]DuplicateGroup, error)
+       Groups documents by a key expression and reports, deletes, or merges
+       duplicates into one survivor per group.
+   - (s *service) CopyCollection(ctx, src, dst string, opts CopyOptions) (int, error)
+   - (s *service) RenameCollection(ctx, src, dst string) (int, error)
+       Batched copy with optional transform; rename is copy followed by EVICT.
+   - Replicate(ctx, src, dst Service, collections []string, opts ReplicateOptions) (*ReplicateResult, error)
+       Copies collections between instances outside native sync, incrementally
+       by an updated_at watermark, with a conflict policy.
+   - Compare(ctx, a, b Service, collection string) (*CompareReport, error)
+       Per-document hash comparison of one collection across two instances.
+   - HashDocument(doc map[string]any) (string, error)
+   - (s *service) HashCollection(ctx, collection string) (string, error)
+       Canonical document hashing (sorted keys, normalized numbers) and a
+       Merkle-style collection digest for cheap drift detection.
+   - (s *service) WithReadOnly() *service
+       Rejects every non-SELECT statement with ErrReadOnly before sending it.
+   - (s *service) WithQuota(collection string, q Quota) *service
+       Client-side max document size / max document count per collection;
+       violating writes fail with ErrQuotaExceeded.
+   - (s *service) WithMaxPayloadBytes(n int) *service
+       Rejects oversized documents and request bodies with a
+       *DocumentTooLargeError (ErrDocumentTooLarge) before sending.
+   - (s *service) SlowQueries() []SlowQuery
+   - (s *service) LatencyHistograms() map[string]LatencyHistogram
+       Per-statement latency histograms and an in-memory slow query log
+       (threshold via WithSlowQueryLog, logged through WithLogger/slog).
+   - (s *service) DebugHandler() http.Handler
+       JSON debug endpoint: request counts, error rates, latencies, slow
+       queries, container status, and redacted config.
+   - (s *service) WithClock(Clock) / WithSleeper(Sleeper) / WithRand(Rand) *service
+       Injectable time, waiting, and randomness for deterministic tests of
+       backoff, TTLs, leases, and polling.
+   - ValidateDQL(query string, args map[string]any) error
+       Lightweight DQL well-formedness check (clauses, identifiers, bound
+       params); WithStrictDQL applies it to every request.
+   - (s *service) WithSingleflight() *service
+       Collapses identical concurrent reads (same query and args) into one
+       HTTP round trip.
+   - (s *service) Prefetch(spec PrefetchSpec) error / Prefetched(name) / StopPrefetch(name)
+       Keeps registered hot queries warm in the background; results carry
+       their age and may serve matching reads within MaxStale.
+   - (s *service) CreateDocumentAsync / UpdateRecordAsync(...) *Future
+       Queue writes on a worker pool (WithAsyncWorkers) and return a Future
+       so request handlers need not wait for the edge round trip.
+   - (s *service) ExecuteBatch(ctx, stmts []Statement) ([]BatchResult, error)
+       Runs several statements behind one call (concurrently; the HTTP API
+       takes one statement per request) with per-statement results.
+   - (s *service) StreamQuery(ctx, query string, args map[string]any, opts ...StreamOptions) *DocStream
+       Yields documents as the response is decoded; reconnects and skips
+       already-delivered documents when the connection breaks.
+   - OpenQueue(svc Service, path string) (*Queue, error)
+       Disk-backed, checksummed FIFO of mutations that survives restarts;
+       Enqueue, Drain(ctx), Depth, and Stats. Recovered statements are sent
+       only by Drain, at least once each.
+   - (s *service) NewIngestor(collection string, opts IngestOptions) (*Ingestor, error)
+       Bounded buffer in front of batched inserts with block, drop-oldest,
+       or spill-to-disk overflow; Add, Flush, Stats, Close.
+   - (s *service) ScheduleMaintenance(job MaintenanceJob) error
+       Runs housekeeping periodically in the background; RunMaintenance,
+       Unschedule, and Maintenance report and control jobs.
+   - (s *service) ScheduleRollup(job RollupJob) error
+       Declarative downsampling of telemetry into a rollup collection with
+       retention-based EVICT of raw readings; RunRollup runs one pass.
+   - (s *service) ApplyRetention(ctx, p RetentionPolicy, dryRun bool) (RetentionReport, error)
+       Removes documents past MaxAge or beyond the newest KeepLast per key
+       with EVICT or DELETE; dry runs report the ids instead.
+       ScheduleRetention runs a policy as a maintenance job.
+   - (s *service) CollectionStats() map[string]CollectionStats
+       Per-collection read/write counts, document sizes, and error rates,
+       also served under /collections by DebugHandler.
+   - (s *service) Capabilities(ctx context.Context) (Capabilities, error)
+       Probes once which statements the server accepts (SELECT, EXPLAIN,
+       DELETE, EVICT) and its version; cached until ResetCapabilities.
+   - (s *service) WithArgEncoder(enc ArgEncoder) *service
+       Customizes how query_args values are serialized (e.g. EncodeType,
+       TimeAsEpochMillis); RegisterArgEncoder adds encoders for all services.
+   - (s *service) WithCollectionPrefix(prefix string) *service
+       Prefixes every collection name in sent statements (e.g. "staging_");
+       WithCollectionPrefixContext overrides it per context.
+   - (s *service) ExportCollection / ImportCollection
+       Streams a collection to or from NDJSON, one document per line.
+   - (s *service) Backup(ctx, opts BackupOptions) (BackupInfo, error)
+       Writes a compressed NDJSON export or data-dir tar, uploads it through
+       an optional Uploader, and rotates archives by count and age;
+       ScheduleBackup runs it periodically, RestoreBackup loads an archive.
+   - (s *service) RestoreBackupFrom(ctx, store BackupStore, name string) (map[string]int, error)
+       Restores an archive read from a BackupStore (e.g. ditto/s3); with no
+       BackupOptions.Dir, Backup streams straight to the store instead.
+   - (s *service) WithBackupKeys(kp KeyProvider) *service
+       Encrypts backup archives with AES-GCM and opens encrypted archives on
+       restore; StaticKey, EnvKey, and KMSKeys supply keys, and
+       EncryptWriter / DecryptReader wrap export streams directly.
+   - Chain(svc Service, decorators ...Decorator) Service
+       Composes Service wrappers: LoggingService, MetricsService,
+       CachingService, and RetryingService (or Logging, Metrics, Caching,
+       Retrying as Decorators); Intercept builds custom ones.
+   - HTTPError type
+       Returned for non-2xx responses; carries the status code and excerpts
+       of the response body and query.
+   - (s *service) WithOperationTimeouts(t OperationTimeouts) *service
+       Default deadlines for reads, writes, and bulk operations (5s/10s/120s
+       unless set), applied when the caller's context has none.
+   - (s *service) WithShutdownGrace(d time.Duration) *service / OnClose(name, fn)
+       Bound how long Close drains in-flight requests and async writes, and
+       register hooks (flush, stop relays) that run while the server is up.
+   - Get / GetString / GetInt64 / GetFloat64 / GetBool / GetTime(doc map[string]any, path string)
+       Typed field access by path ("meta.created_at", "readings[0].value"),
+       reporting false when the value is missing or has another type.
+   - Extract(v any, expr string) ([]any, error)
+       JSONPath-style extraction over a response or document, e.g.
+       "$.items[*].payload.temperature" or "$..temperature".
+   - RenderTable(w io.Writer, res any, columns ...string) error / RenderJSON(w io.Writer, v any, color bool) error
+       Debug output: documents as an aligned text table, or any value as
+       indented JSON with sorted keys and optional ANSI colors.
+   - NewTemplates() *Templates / (t *Templates) Define / Render / Where
+       Named, reusable DQL fragments composed as {{name}} placeholders, with
+       argument merging, collision and cycle detection, and unbound-parameter
+       checks.
+   - (s *service) WithStrictParams() *service / BuildSelectArgs(collection string, filters map[string]string, limit int, sortBy, sortOrder string) (string, map[string]any)
+       Strict params mode: read helpers bind filter values as query_args, and
+       any statement with an inline string literal fails with ErrInlineLiteral.
+   - (s *service) WithProbeQuery(query string) *service / WithProbe(name string, fn ProbeFunc) *service
+       Configures the HTTP probe statement used by Status and the readiness
+       wait, and registers extra checks (QueryProbe runs a statement) whose
+       results Status reports under "probes".
+   - (s *service) WithResolver(resolve Resolver, opts WatchdogOptions) *service / SRVResolver(service, proto, name, scheme string) Resolver
+       Liveness watchdog: after repeated failed probes (or a request that
+       cannot connect) re-resolves the base URL and reattaches without a
+       restart, for edge nodes whose address changes.
+   - (s *service) WithDialContext(dial DialFunc) *service / WithUnixSocket(path string) *service
+       Routes connections to the HTTP API through a custom dialer, e.g. a
+       unix domain socket when the edge server runs on the same host.
+   - (s *service) WithHedgedReads(delay time.Duration, secondary string) *service
+       Sends a duplicate of any SELECT still unanswered after delay (to the
+       same or a secondary endpoint) and takes the first response.
+   - (s *service) WithAdaptiveTimeouts(a AdaptiveTimeouts) *service
+       Derives read and write deadlines from a rolling latency percentile,
+       bounded by a floor and ceiling, instead of fixed values.
+   - (s *service) WithConditionalReads(maxEntries int) *service / ExecuteIfChanged(ctx context.Context, query string, args map[string]any) (any, bool, error)
+       Revalidates repeated reads with If-None-Match (or a body hash when the
+       server sends no ETag) so unchanged results skip decoding, and reports
+       whether a result changed.
+   - (s *service) WithAccessLog(l *slog.Logger) *service / WithRequestID(ctx context.Context, id string) context.Context
+       Writes one structured access-log record per statement (op, collection,
+       duration, status, bytes, request ID) for centralized log pipelines.
+   - (s *service) WithQueryLogging(rules RedactionRules) *service / RedactArgs(rules RedactionRules, args map[string]any) map[string]any
+       Adds statements and arguments to the access log, with values matched
+       by argument name pattern or field path replaced by Redacted.
+   - ClassOf(err error) ErrorClass / Retryable(err error) bool
+       Classifies any SDK error as Network, Timeout, Syntax, Conflict,
+       NotFound, Server, or Client; HTTPError carries Class and Retryable().
+   - WithContinuation(ctx context.Context, token string) context.Context
+       ExecuteBatch and UpdateMany stop before the context deadline instead
+       of failing outright, returning a *PartialResult (succeeded, failed,
+       not attempted, continuation token); WithContinuation resumes them.
+   - WithProgress(ctx context.Context, fn ProgressFunc) context.Context
+       Reports done/total/rate of import, export, copy, UpdateMany,
+       retention, and batch operations to fn for progress bars and ETAs.
+   - WithCheckpointFile(ctx context.Context, path string) context.Context
+       Makes ExportCollection and ImportCollection resumable: progress (last
+       _id and output offset, or input line) is saved after every page or
+       batch and a rerun with the same file continues from it.
+   - Compression / RegisterCompression(c Compression) / WithExportCompression(ctx, c Compression)
+       Streaming codecs for backups (BackupOptions.Compression, gzip by
+       default) and exports: Gzip, GzipLevel, NoCompression, and Zstd over a
+       caller-supplied encoder; restores and imports detect the codec.
+   - (s *service) WithConnStats() *service / Stats() ConnStats
+       Counts open and idle connections to the HTTP API and traces DNS,
+       connect, TLS, and time-to-first-byte of recent requests, to tell slow
+       links from connection churn; also reported by Status.
+   - (s *service) WithDockerTrace(t DockerTrace) *service / WithDockerTraceContext(ctx, t DockerTrace)
+       Logs the exact commands the default Docker runners run and, with
+       DryRun, prints host-changing commands instead of running them.
+   - (s *service) BackgroundHealth() []TaskHealth
+       Reports each background goroutine (view refreshers, maintenance jobs,
+       ingest loops, async workers) by name: how many run and how often a
+       panic forced a restart. Panics are logged and restarted with backoff.
    - (s *service) Status(ctx context.Context) (map[string]any, error)
-       Returns diagnostic information including Docker (Compose) container status
-       and a Ditto HTTP probe result using a lightweight SELECT query.
+       Returns diagnostic information including Docker (Compose) container status,
+       background task health, watchdog, hedging and adaptive timeout state,
+       custom probe results, and a Ditto HTTP probe result using a lightweight
+       SELECT query (WithProbeQuery).
    - (s *service) CreateDocument(ctx context.Context, collection string, doc map[string]any) (any, error)
        Inserts a single JSON document into the specified collection using a
        parameterized INSERT DQL statement.
    - (s *service) GetRecord(ctx context.Context, collection, id string) (any, error)
        Fetches a single record by its _id using a parameterized SELECT query.
+   - (s *service) GetRecordsByIDs(ctx context.Context, collection string, ids []string) (any, error)
+       Fetches many records by _id in one parameterized `_id IN (...)` query,
+       chunking long id lists and merging the items.
+   - (s *service) Exists(ctx context.Context, collection, id string) (bool, error)
+       Reports whether a record with the given _id exists, projecting only _id.
+   - (s *service) ExistsWhere(ctx context.Context, collection string, where Predicate) (bool, error)
+       Reports whether any record matches the predicate (SELECT _id ... LIMIT 1).
    - (s *service) GetRecords(ctx context.Context, collection string, limit int, sortBy, sortOrder string) (any, error)
        Returns documents from the specified collection with optional LIMIT and ORDER BY.
    - (s *service) UpdateRecord(ctx context.Context, collection, id string, patch map[string]any) (any, error)
        Applies a JSON patch (field map) to a record identified by _id using
        parameterized SET clauses in an UPDATE DQL statement.
+   - (s *service) UpdateWhere(ctx context.Context, collection string, where Predicate, patch map[string]any) (any, error)
+       Applies one patch to every document matching a parameterized predicate in
+       a single UPDATE statement.
+   - (s *service) UpdateMany(ctx context.Context, collection string, ids []string, patch map[string]any) (any, error)
+       Applies one patch to the listed ids with `_id IN (...)`, chunked for long
+       lists; returns the per-chunk results.
    - (s *service) DeleteRecord(ctx context.Context, collection, id string) (any, error)
        Removes a single record by its _id using a parameterized EVICT DQL statement.
-   - (s *service) DeleteAllRecords(ctx context.Context, collection string) (any, error)
+   - (s *service) DeleteAllRecords(ctx context.Context, collection string, confirm ...DestructiveOption) (any, error)
        Removes all documents in a collection using an EVICT statement with a
-       LIKE pattern that matches all identifiers.
+       LIKE pattern that matches all identifiers. Requires ConfirmDeleteAll
+       (or WithAllowDestructiveOps), else ErrDestructiveOpNotConfirmed.
    - (s *service) LatestRecord(ctx context.Context, collection, sortBy string) (any, error)
        Returns the most recent record in a collection according to the provided
        field (descending order), limited to a single result.
    - (s *service) Search(ctx context.Context, collection string, filters map[string]string, limit int, sortBy, sortOrder string) (any, error)
        Builds a simple exact-match WHERE clause from the provided filters and
        applies optional LIMIT and ORDER BY.
+   - (s *service) FindRecords(ctx context.Context, collection string, filters map[string]string, opts ...QueryOptions) (any, error)
+       General read: exact-match filters plus QueryOptions (limit, offset,
+       projection, sorting, soft-deleted inclusion, per-call timeout).
+       GetRecords, Search, and LatestRecord are thin wrappers over it.
+   - (s *service) FindWhere(ctx context.Context, collection string, where Predicate, opts ...QueryOptions) (any, error)
+       Reads the documents matching a parameterized predicate.
+   - (s *service) WithinRadius(ctx context.Context, collection, latField, lngField string, center GeoPoint, radiusMeters float64, opts ...QueryOptions) ([]GeoResult, error)
+       Radius query: server-side bounding box, client-side haversine filter,
+       nearest first.
+   - BoundingBox(latField, lngField string, sw, ne GeoPoint) Predicate / Haversine(a, b GeoPoint) float64
+       Geo helpers for lat/lng fields.
+   - (s *service) GetRecordsWith / SearchWith / LatestRecordWith / UpdateRecordWith / UpdateWhereWith
+       Request-struct forms (GetRecordsRequest, SearchRequest, ...) of the
+       positional methods so new options don't change method signatures.
+   - (s *service) Execute(ctx context.Context, query string, args map[string]any) (any, error)
+       Runs an arbitrary DQL statement with bound query_args.
+   - FormatTimestamp(t time.Time) string / ParseTimestamp(v any) (time.Time, bool)
+       The fixed-width UTC timestamp format used for SDK-managed fields.
    - BuildSelect(collection string, filters map[string]string, limit int, sortBy, sortOrder string) string
        Constructs a DQL SELECT statement for the specified collection with optional
-       exact-match filters, limit, and ordering.
+       exact-match filters, limit, and ordering. Filters are emitted in sorted key
+       order so the same input always yields the same DQL.
    - BuildInsert(collection string, doc map[string]any) (string, map[string]any, error)
        Constructs an INSERT DQL statement with a parameterized document (:doc).
    - BuildUpdate(collection, id string, patch map[string]any) (string, map[string]any, error)
-       Constructs an UPDATE DQL statement with parameterized SET clauses and a
-       bound :id for the target record.
+       Constructs an UPDATE DQL statement with parameterized SET clauses (sorted
+       by field, parameters named p_0, p_1, ...) and a bound :id for the target
+       record. Invalid or colliding field paths are rejected, and reserved fields
+       (_id) fail with ErrImmutableField. Patch values Null and Unset compile to
+       `field = null` and an UNSET clause respectively.
+   - BuildUpdateWhere(collection string, where Predicate, patch map[string]any) (string, map[string]any, error)
+       Constructs a bulk UPDATE DQL statement with parameterized SET clauses and
+       the predicate's bound arguments.
+   - Where(clause string, args map[string]any) Predicate
+       Pairs a DQL boolean clause with the parameters it binds.
+   - Diff(old, new map[string]any) Patch
+       Produces a minimal Patch (dotted set paths and unset paths) between two
+       document versions.
+   - BuildPatch(collection, id string, p Patch) (string, map[string]any, error)
+       Constructs an UPDATE DQL with parameterized SET and UNSET clauses.
+   - (s *service) ApplyPatch(ctx context.Context, collection, id string, p Patch) (any, error)
+       Sends only the changed fields of a Patch to a record by _id.
+   - Null / Unset
+       Patch sentinels distinguishing "set field to null" from "remove field".
+   - AppendToArray(values ...any) ArrayOp / RemoveFromArray(values ...any) ArrayOp
+       Patch values that append to or remove from an array field; resolved by
+       UpdateRecord against the current document.
+   - ArrayContains(field string, value any) Predicate
+       Predicate matching documents whose array field contains value.
    - escapeIdent(s string) string
-       Performs minimal identifier sanitization suitable for DQL by removing
-       backticks and replacing spaces with underscores.
+       Quotes an identifier for DQL: plain names as is, anything else (and
+       reserved words) in backticks with embedded backticks doubled. Names
+       are no longer rewritten ("my coll" used to become my_coll).
+   - escapePath(s string) string
+       escapeIdent applied to each segment of a dotted field path.
+   - QuoteIdent(name string) string / QuotePath(path string) string
+       Exported escapeIdent / escapePath, for DQL built in subpackages and
+       caller code.
    - escapeString(s string) string
-       Escapes double quotes in a string literal.
+       Escapes a double-quoted string literal: backslashes, quotes, control
+       characters, separators, and typographic quotes.
    - NewDockerRunnerDefault() DockerRunner
        Returns a DockerRunner that manages containers using plain `docker` CLI
        commands (no Compose integration).
@@ -100,6 +419,12 @@ import (
        Starts a previously created container using `docker start`.
    - (d *dockerRunnerDefault) StopContainer(ctx context.Context, name string) error
        Stops a running container using `docker stop`.
+   - (d *dockerRunnerDefault) RemoveContainer(ctx context.Context, name string) error
+       Force-removes a container using `docker rm -f`; a missing container is
+       not an error.
+   - (d *dockerRunnerDefault) Teardown(ctx context.Context, opts DockerOptions, t TeardownOptions) error
+       Stops the container, then removes it (with -v for volumes) and the image
+       as selected.
    - (d *composeRunnerDefault) EnsureImageLoaded(ctx context.Context, imageName, tarPath string) error
        Mirrors the behavior of dockerRunnerDefault for parity.
    - (d *composeRunnerDefault) ContainerStatus(ctx context.Context, name string) (string, error)
@@ -107,10 +432,15 @@ import (
    - (d *composeRunnerDefault) RunContainer(ctx context.Context, opts DockerOptions) error
        Brings the compose service up with `docker compose up -d`.
    - (d *composeRunnerDefault) StartContainer(ctx context.Context, name string) error
-       Starts a stopped compose service using `docker compose start`.
+       Starts a stopped compose-managed container in place using `docker start`.
+   - (d *composeRunnerDefault) RemoveContainer(ctx context.Context, name string) error
+       Force-removes the compose-managed container by name.
    - (d *composeRunnerDefault) StopContainer(ctx context.Context, name string) error
        Stops the compose service and then best-effort stops/removes any lingering
        container by name.
+   - (d *composeRunnerDefault) Teardown(ctx context.Context, opts DockerOptions, t TeardownOptions) error
+       Runs `docker compose down` (with -v for volumes) and optionally removes
+       the image.
    - runCmd(ctx context.Context, name string, args ...string) error
        Executes a CLI command and returns a formatted error including stdout/stderr
        when the command fails.
@@ -120,10 +450,24 @@ import (
    - DockerOptions struct
        Collects parameters for starting a Ditto Edge container, including optional
        Docker Compose settings.
+   - (s *service) isolate() error
+       Derives a unique container name, free host port, and private data
+       directory (under DataPath when set) for DockerOptions.Isolated and
+       points BaseURL at the port.
+   - LogStreamer interface
+       Optional DockerRunner extension for following container logs; used by
+       InitDB's startup watcher. Both default runners implement it.
+   - RecreatePolicy type
+       Controls whether InitDB starts an exited container in place
+       (RecreateNever) or removes and re-runs it (RecreateAlways).
    - Service interface
        Defines the operations the HTTP handlers expect. Implementations are
        responsible for connecting to Ditto's HTTP API and translating these methods
        into appropriate DQL statements (parameterized when mutating state).
+   - Lifecycle / Reader / Writer / Admin interfaces
+       The parts Service is composed of (server lifecycle, reads, document
+       writes, raw and collection-wide statements), for consumers and mocks
+       that need only some of it.
    - service struct
        Implements Service using a standard net/http client and optional
        Docker/Compose integration to manage the Ditto Edge container.
@@ -168,22 +512,35 @@ const dittoModuleCode = 300
 // Service defines the operations the HTTP handlers expect. Implementations are
 // responsible for connecting to Ditto's HTTP API and translating these methods
 // into appropriate DQL statements (parameterized when mutating state).
+//
+// Service is the union of Lifecycle, Reader, Writer, and Admin; depend on the
+// smallest of these that covers what a consumer uses so it is easy to mock.
 type Service interface {
+	Lifecycle
+	Reader
+	Writer
+	Admin
+}
+
+// Lifecycle starts, stops, and inspects the Ditto server.
+type Lifecycle interface {
 	InitDB(ctx context.Context) error
 	Close(ctx context.Context) error
 	Status(ctx context.Context) (map[string]any, error)
+}
 
-	CreateDocument(ctx context.Context, collection string, doc map[string]any) (any, error)
+// Reader fetches documents.
+type Reader interface {
 	GetRecord(ctx context.Context, collection, id string) (any, error)
+	GetRecordsByIDs(ctx context.Context, collection string, ids []string) (any, error)
+	Exists(ctx context.Context, collection, id string) (bool, error)
+	ExistsWhere(ctx context.Context, collection string, where Predicate) (bool, error)
 	GetRecords(
 		ctx context.Context,
 		collection string,
 		limit int,
 		sortBy, sortOrder string,
 	) (any, error)
-	UpdateRecord(ctx context.Context, collection, id string, patch map[string]any) (any, error)
-	DeleteRecord(ctx context.Context, collection, id string) (any, error)
-	DeleteAllRecords(ctx context.Context, collection string) (any, error)
 	LatestRecord(ctx context.Context, collection, sortBy string) (any, error)
 	Search(
 		ctx context.Context,
@@ -192,6 +549,32 @@ type Service interface {
 		limit int,
 		sortBy, sortOrder string,
 	) (any, error)
+	FindRecords(ctx context.Context, collection string, filters map[string]string, opts ...QueryOptions) (any, error)
+	FindWhere(ctx context.Context, collection string, where Predicate, opts ...QueryOptions) (any, error)
+
+	// Request-struct forms; prefer these over the positional methods above
+	GetRecordsWith(ctx context.Context, req GetRecordsRequest) (any, error)
+	SearchWith(ctx context.Context, req SearchRequest) (any, error)
+	LatestRecordWith(ctx context.Context, req LatestRecordRequest) (any, error)
+}
+
+// Writer creates, updates, and deletes individual documents.
+type Writer interface {
+	CreateDocument(ctx context.Context, collection string, doc map[string]any) (any, error)
+	UpdateRecord(ctx context.Context, collection, id string, patch map[string]any) (any, error)
+	UpdateWhere(ctx context.Context, collection string, where Predicate, patch map[string]any) (any, error)
+	UpdateMany(ctx context.Context, collection string, ids []string, patch map[string]any) (any, error)
+	DeleteRecord(ctx context.Context, collection, id string) (any, error)
+
+	// Request-struct forms; prefer these over the positional methods above
+	UpdateRecordWith(ctx context.Context, req UpdateRecordRequest) (any, error)
+	UpdateWhereWith(ctx context.Context, req UpdateWhereRequest) (any, error)
+}
+
+// Admin runs raw and collection-wide statements.
+type Admin interface {
+	DeleteAllRecords(ctx context.Context, collection string, confirm ...DestructiveOption) (any, error)
+	Execute(ctx context.Context, query string, args map[string]any) (any, error)
 }
 
 // Implementation -------------------------------------------------------------
@@ -199,14 +582,64 @@ type Service interface {
 // service implements Service using a standard net/http client and optional
 // Docker/Compose integration to manage the Ditto Edge container.
 type service struct {
-	BaseURL       string
-	AppID         string
-	HTTP          *http.Client
-	docker        DockerRunner
-	dockerOpts    DockerOptions
-	startedDocker bool
+	BaseURL            string
+	AppID              string
+	HTTP               *http.Client
+	docker             DockerRunner
+	dockerOpts         DockerOptions
+	startedDocker      bool
+	isolation          *isolation
+	versioned          map[string]bool // collections archived to <name>_history on update
+	softDeleted        map[string]bool // collections where deletes set deleted_at
+	views              viewRegistry
+	bg                 background
+	revisionField      string                 // revision counter for UpdateRecordRevision
+	resolver           ConflictResolver       // settles revision conflicts; nil means ErrConflict
+	cache              *recordCache           // optional GetRecord cache; nil when disabled
+	allowReserved      bool                   // permit patches touching reserved fields (_id)
+	preciseNumbers     bool                   // decode numbers as json.Number
+	sequenceCollection string                 // counters for NextSequence
+	schemas            *SchemaRegistry        // envelope codecs for PutEnvelope/GetEnvelope
+	readOnly           bool                   // reject every non-SELECT statement with ErrReadOnly
+	quotas             map[string]*quotaState // per-collection write limits
+	maxPayload         int                    // request body limit in bytes; zero means unlimited
+	obs                *observer              // latency histograms and slow query log
+	logger             *slog.Logger           // nil means slog.Default()
+	accessLog          *slog.Logger           // one record per statement; nil when disabled
+	queryLog           *RedactionRules        // add redacted statements and arguments to the access log; nil when off
+	clock              Clock                  // nil means the system clock
+	sleeper            Sleeper                // nil means real timers
+	rnd                Rand                   // nil means crypto/rand and math/rand
+	strictDQL          bool                   // validate statements with ValidateDQL before sending
+	strictParams       bool                   // bind filter values as query_args and reject string literals
+	probeStatement     string                 // Status and readiness probe; empty means defaultProbeQuery
+	probes             []namedProbe           // extra Status checks registered with WithProbe
+	watch              *watchdog              // liveness watchdog started by WithResolver; nil when unused
+	hedge              *hedging               // duplicate slow reads; nil when disabled
+	urlMu              sync.RWMutex           // guards BaseURL once a watchdog may re-resolve it
+	flights            *flightGroup           // shares identical in-flight reads; nil when disabled
+	prefetch           prefetcher             // warm results of registered hot queries
+	async              asyncPool              // workers for CreateDocumentAsync/UpdateRecordAsync
+	maint              maintenance            // scheduled housekeeping jobs
+	caps               capabilityCache        // result of the first Capabilities probe
+	argEncoders        []ArgEncoder           // converters applied to query_args before encoding
+	collPrefix         string                 // prepended to every collection name in sent statements
+	allowDestructive   bool                   // run DeleteAllRecords without ConfirmDeleteAll
+	backupKeys         KeyProvider            // encrypts backups and opens encrypted archives
+	opTimeouts         *OperationTimeouts     // default deadlines by operation class; nil means none
+	adaptive           *adaptiveTimeouts      // latency-derived read/write deadlines; nil when disabled
+	connStats          *connTracker           // connection counts and request phase timings; nil when disabled
+	dockerTrace        *DockerTrace           // command logging and dry-run for the Docker runners; nil when disabled
+	cond               *conditionalCache      // last responses of recent reads; nil when disabled
+	ops                inflight               // requests and async writes Close waits for
+	shutdownGrace      time.Duration          // how long Close drains; zero means 10s
+	closeMu            sync.Mutex             // guards closeHooks
+	closeHooks         []closeHook            // run by Close before background work stops
 }
 
+// service must keep satisfying Service as methods are added
+var _ Service = (*service)(nil)
+
 // NewService constructs a new Ditto service targeting the given Ditto HTTP API
 // base URL and application (DB) ID. A default HTTP client with a reasonable
 // timeout is installed. To enable container management, call WithDocker.
@@ -215,6 +648,7 @@ func NewService(baseURL, appID string) *service {
 		BaseURL: baseURL,
 		AppID:   appID,
 		HTTP:    &http.Client{Timeout: 30 * time.Second},
+		obs:     newObserver(),
 	}
 }
 
@@ -232,14 +666,26 @@ func (s *service) WithDocker(docker DockerRunner, opts DockerOptions) *service {
 // InitDB ensures the Ditto Edge container is ready. Behavior:
 // - If a DockerRunner is not attached, this is a no-op.
 // - Loads the image from a tar if missing; otherwise relies on existing image.
-// - Starts an existing container if exited; otherwise runs a new one.
+// - Starts an exited container in place or recreates it, per RecreatePolicy;
+//   otherwise runs a new one.
 // - Marks the container as started by this process so Close can stop it.
+// - With StartupTimeout set, watches the logs until the server reports ready
+//   and returns a *StartupError (with log excerpt) on crash or timeout.
+// - Polls the HTTP API with exponential backoff until queries succeed or
+//   ReadyTimeout elapses.
 func (s *service) InitDB(ctx context.Context) error {
 	// No-op if no DockerRunner attached
 	if s.docker == nil {
 		// Docker disabled
 		return nil
 	}
+	ctx = s.dockerCtx(ctx)
+	// Allocate a private name/port/data dir on first use when isolated
+	if s.dockerOpts.Isolated && s.isolation == nil {
+		if err := s.isolate(); err != nil {
+			return fmt.Errorf("isolate container: %w", err)
+		}
+	}
 	// Ensure image is present and container is running
 	if err := s.docker.EnsureImageLoaded(ctx, s.dockerOpts.ImageName, s.dockerOpts.ImageTarPath); err != nil {
 		return fmt.Errorf("ensure image: %w", err)
@@ -257,14 +703,25 @@ func (s *service) InitDB(ctx context.Context) error {
 		return nil
 	}
 
-	// Exited, start it
-    if status == "exited" {
-        // Recreate via RunContainer to pick up volume/mount changes in compose.
-        if err := s.docker.RunContainer(ctx, s.dockerOpts); err != nil {
-            return fmt.Errorf("start container: %w", err)
-        }
-        return nil
-    }
+	// since marks the start so the log watcher ignores earlier runs
+	since := s.now()
+
+	// Exited, start it in place or recreate it depending on RecreatePolicy
+	if status == "exited" {
+		if s.recreatePolicy() == RecreateAlways {
+			// Remove first so the fresh run doesn't hit a name conflict
+			if err := s.docker.RemoveContainer(ctx, s.dockerOpts.ContainerName); err != nil {
+				return fmt.Errorf("remove container: %w", err)
+			}
+			if err := s.docker.RunContainer(ctx, s.dockerOpts); err != nil {
+				return fmt.Errorf("recreate container: %w", err)
+			}
+		} else if err := s.docker.StartContainer(ctx, s.dockerOpts.ContainerName); err != nil {
+			return fmt.Errorf("start container: %w", err)
+		}
+		s.startedDocker = true
+		return s.awaitStartup(ctx, since)
+	}
 	// Not found, run new
 	if err := s.docker.RunContainer(ctx, s.dockerOpts); err != nil {
 		return fmt.Errorf("run container: %w", err)
@@ -272,21 +729,85 @@ func (s *service) InitDB(ctx context.Context) error {
 
 	// Mark as started by this process
 	s.startedDocker = true
-	return nil
+	return s.awaitStartup(ctx, since)
+}
+
+// awaitStartup waits for a freshly started container: first the log watcher
+// (if enabled) to catch crashes early, then the HTTP readiness probe so the
+// first query after InitDB doesn't race the server. A dry run started
+// nothing to wait for.
+func (s *service) awaitStartup(ctx context.Context, since time.Time) error {
+	if dockerDryRun(ctx) {
+		s.startedDocker = false
+		return nil
+	}
+	if err := s.awaitStartupLogs(ctx, since); err != nil {
+		return err
+	}
+	return s.waitReady(ctx)
+}
+
+// recreatePolicy resolves the effective RecreatePolicy. An empty policy falls
+// back to the runner's default (start in place for plain Docker, recreate for
+// Compose so mount changes in docker-compose.yml are picked up).
+func (s *service) recreatePolicy() RecreatePolicy {
+	if s.dockerOpts.RecreatePolicy != "" {
+		return s.dockerOpts.RecreatePolicy
+	}
+	if d, ok := s.docker.(interface{ defaultRecreatePolicy() RecreatePolicy }); ok {
+		return d.defaultRecreatePolicy()
+	}
+	return RecreateNever
 }
 
 // Close attempts to stop the Ditto container using the attached DockerRunner.
-// This method is safe to call multiple times and ignores errors on shutdown.
+// It first drains: close hooks run (flushing ingestors), in-flight requests
+// and queued async writes get the shutdown grace period to finish, and
+// background work is stopped. The container is stopped even if draining
+// times out; the returned error reports what did not finish. This method is
+// safe to call multiple times and ignores errors stopping the container.
 func (s *service) Close(ctx context.Context) error {
+	// Drain and stop background work (view refreshers, workers) before the
+	// server goes away
+	err := s.drain(ctx)
+	ctx = s.dockerCtx(ctx)
 	// No-op if no DockerRunner attached or if we didn't start the container
 	if s.docker != nil {
 		_ = s.docker.StopContainer(ctx, s.dockerOpts.ContainerName)
 	}
+	// Isolated instances leave nothing behind
+	if s.isolation != nil {
+		s.cleanupIsolation(ctx)
+	}
+	return err
+}
+
+// TeardownOptions selects what Teardown removes in addition to stopping the
+// container.
+type TeardownOptions struct {
+	RemoveContainer bool // remove the stopped container
+	RemoveVolumes   bool // remove volumes attached to the container (implies RemoveContainer)
+	RemoveImage     bool // remove the Ditto Edge image from the local store
+}
+
+// Teardown stops the Ditto container and, depending on opts, removes the
+// container, its volumes, and the image. Intended for CI and uninstall flows
+// that need to wipe everything, unlike Close which only stops the container.
+// It is a no-op when no DockerRunner is attached.
+func (s *service) Teardown(ctx context.Context, opts TeardownOptions) error {
+	if s.docker == nil {
+		return nil
+	}
+	if err := s.docker.Teardown(s.dockerCtx(ctx), s.dockerOpts, opts); err != nil {
+		return fmt.Errorf("teardown: %w", err)
+	}
+	s.startedDocker = false
 	return nil
 }
 
 // Status returns diagnostic information including Docker (Compose) container
-// status and a Ditto HTTP probe result using a lightweight SELECT.
+// status, the results of probes registered with WithProbe, and a Ditto HTTP
+// probe result using a lightweight SELECT (see WithProbeQuery).
 func (s *service) Status(ctx context.Context) (map[string]any, error) {
 	// Base info
 	// Docker status if enabled, plus HTTP probe
@@ -300,9 +821,9 @@ func (s *service) Status(ctx context.Context) (map[string]any, error) {
 	// If Docker status check fails, include the error message
 	// If HTTP probe fails, include the error message
 	// Return the result map and any error encountered
-	res := map[string]any{"baseURL": s.BaseURL, "appID": s.AppID}
+	res := map[string]any{"baseURL": s.baseURL(), "appID": s.AppID}
 	if s.docker != nil {
-		st, err := s.docker.ContainerStatus(ctx, s.dockerOpts.ContainerName)
+		st, err := s.docker.ContainerStatus(s.dockerCtx(ctx), s.dockerOpts.ContainerName)
 		if err != nil {
 			res["dockerError"] = err.Error()
 		} else {
@@ -311,9 +832,33 @@ func (s *service) Status(ctx context.Context) (map[string]any, error) {
 	} else {
 		res["docker"] = "disabled"
 	}
+	// Supervised background goroutines, with their restart counts
+	if tasks := s.BackgroundHealth(); len(tasks) > 0 {
+		res["background"] = tasks
+	}
+	// Liveness watchdog state when WithResolver is in use
+	if s.watch != nil {
+		res["watchdog"] = s.watchdogStatus()
+	}
+	// Hedged read counters when WithHedgedReads is in use
+	if s.hedge != nil {
+		res["hedging"] = s.hedgeStatus()
+	}
+	// Learned deadlines when WithAdaptiveTimeouts is in use
+	if s.adaptive != nil {
+		res["adaptiveTimeouts"] = s.adaptiveStatus()
+	}
+	// Connection counts and request phases when WithConnStats is in use
+	if s.connStats != nil {
+		res["connections"] = s.connStatsStatus()
+	}
+	// Custom probes registered with WithProbe
+	if len(s.probes) > 0 {
+		res["probes"] = s.runProbes(ctx)
+	}
 	// Probe Ditto HTTP server (use FROM to satisfy DQL)
-	url := fmt.Sprintf("%s/%s/execute", strings.TrimRight(s.BaseURL, "/"), s.AppID)
-	body := map[string]string{"query": "SELECT * FROM chat LIMIT 1"}
+	url := s.executeURL()
+	body := map[string]string{"query": s.probeQuery()}
 	b, _ := json.Marshal(body)
 	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
 	req.Header.Set("Content-Type", "application/json")
@@ -346,6 +891,15 @@ func (s *service) CreateDocument(
 	if err != nil {
 		return nil, err
 	}
+	if err := s.checkDocumentSize(collection, doc); err != nil {
+		return nil, err
+	}
+	if err := s.checkInsertQuota(ctx, collection, doc); err != nil {
+		return nil, err
+	}
+	if id, ok := doc["_id"]; ok {
+		s.cache.invalidate(collection, fmt.Sprint(id))
+	}
 	return s.execWithArgs(ctx, q, args)
 }
 
@@ -353,11 +907,88 @@ func (s *service) CreateDocument(
 func (s *service) GetRecord(ctx context.Context, collection, id string) (any, error) {
 	// Use parameterized query to avoid injection issues
 	// q stands for query
-	q := fmt.Sprintf("SELECT * FROM %s WHERE _id == :id LIMIT 1", escapeIdent(collection))
-	return s.execWithArgs(ctx, q, map[string]any{"id": id})
+	// The cache is keyed by bare collection names, so skip it when the
+	// context selects a different collection prefix
+	cached := !s.contextPrefixed(ctx)
+	var gen uint64
+	if cached {
+		if res, ok := s.cache.get(collection, id); ok {
+			return res, nil
+		}
+		gen = s.cache.begin(collection, id)
+	}
+	q := fmt.Sprintf("SELECT * FROM %s WHERE _id == :id%s LIMIT 1", escapeIdent(collection), s.andLive(collection))
+	//dittovet:ignore andLive is the SDK's own soft-delete clause
+	res, err := s.execWithArgs(ctx, q, map[string]any{"id": id})
+	if err != nil {
+		if cached {
+			s.cache.release(collection, id)
+		}
+		return nil, err
+	}
+	if cached {
+		s.cache.put(collection, id, gen, res)
+	}
+	return res, nil
+}
+
+// GetRecordsByIDs fetches the documents with the given ids using a
+// parameterized `_id IN (...)` query. Long id lists are split into chunks of
+// maxIDsPerStatement and the items merged into a single {"items": [...]}
+// response.
+func (s *service) GetRecordsByIDs(ctx context.Context, collection string, ids []string) (any, error) {
+	if collection == "" {
+		return nil, errors.New("collection required")
+	}
+	if len(ids) == 0 {
+		return map[string]any{"items": []any{}}, nil
+	}
+	chunks := chunkIDs(ids, maxIDsPerStatement)
+	// items stands for merged result documents across chunks
+	items := []any{}
+	for _, chunk := range chunks {
+		where := idsPredicate(chunk)
+		q := fmt.Sprintf("SELECT * FROM %s WHERE %s%s", escapeIdent(collection), where.Clause, s.andLive(collection))
+		//dittovet:ignore andLive is the SDK's own soft-delete clause
+		res, err := s.execWithArgs(ctx, q, where.Args)
+		if err != nil {
+			return nil, err
+		}
+		if len(chunks) == 1 {
+			return res, nil
+		}
+		items = append(items, resultItems(res)...)
+	}
+	return map[string]any{"items": items}, nil
+}
+
+// Exists reports whether a record with the given _id exists. Only _id is
+// projected so the document body is never transferred or decoded.
+func (s *service) Exists(ctx context.Context, collection, id string) (bool, error) {
+	return s.ExistsWhere(ctx, collection, Where("_id == :id", map[string]any{"id": id}))
+}
+
+// ExistsWhere reports whether any record matches the predicate, using a
+// minimal `SELECT _id ... LIMIT 1` projection.
+func (s *service) ExistsWhere(ctx context.Context, collection string, where Predicate) (bool, error) {
+	if collection == "" {
+		return false, errors.New("collection required")
+	}
+	if strings.TrimSpace(where.Clause) == "" {
+		return false, errors.New("predicate required")
+	}
+	q := fmt.Sprintf("SELECT _id FROM %s WHERE (%s)%s LIMIT 1", escapeIdent(collection), where.Clause, s.andLive(collection))
+	//dittovet:ignore andLive is the SDK's own soft-delete clause
+	res, err := s.execWithArgs(ctx, q, where.Args)
+	if err != nil {
+		return false, err
+	}
+	return len(resultItems(res)) > 0, nil
 }
 
 // GetRecords returns documents with optional LIMIT and ORDER BY.
+//
+// Deprecated: use GetRecordsWith, which takes a GetRecordsRequest.
 func (s *service) GetRecords(
 	// ctx stands for context
 	// collection stands for Ditto collection name
@@ -369,8 +1000,7 @@ func (s *service) GetRecords(
 	limit int,
 	sortBy, sortOrder string,
 ) (any, error) {
-	q := BuildSelect(collection, nil, limit, sortBy, sortOrder)
-	return s.execWithArgs(ctx, q, nil)
+	return s.FindRecords(ctx, collection, nil, QueryOptions{Limit: limit, SortBy: sortBy, SortOrder: sortOrder})
 }
 
 // UpdateRecord applies a JSON patch (field map) to a record by _id using
@@ -384,48 +1014,163 @@ func (s *service) UpdateRecord(
 	collection, id string,
 	patch map[string]any,
 ) (any, error) {
-	q, args, err := BuildUpdate(collection, id, patch)
+	// Array mutators are resolved against the current document first
+	patch, err := s.resolveArrayOps(ctx, collection, id, patch)
+	if err != nil {
+		return nil, err
+	}
+	q, args, err := buildUpdate(collection, id, patch, s.allowReserved)
 	if err != nil {
 		return nil, err
 	}
+	if err := s.checkPatchQuota(collection, patch); err != nil {
+		return nil, err
+	}
+	if err := s.archive(ctx, collection, Where("_id == :id", map[string]any{"id": id})); err != nil {
+		return nil, err
+	}
+	// Invalidated before the write so it is not served meanwhile, and after
+	// it so a read that raced the write does not cache the old document
+	s.cache.invalidate(collection, id)
+	defer s.cache.invalidate(collection, id)
 	return s.execWithArgs(ctx, q, args)
 }
 
+// UpdateWhere applies the same patch to every document matching the
+// predicate in a single parameterized UPDATE statement.
+func (s *service) UpdateWhere(
+	ctx context.Context,
+	collection string,
+	where Predicate,
+	patch map[string]any,
+) (any, error) {
+	q, args, err := buildUpdateWhere(collection, where, patch, s.allowReserved)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkPatchQuota(collection, patch); err != nil {
+		return nil, err
+	}
+	if err := s.archive(ctx, collection, where); err != nil {
+		return nil, err
+	}
+	s.cache.invalidateCollection(collection)
+	defer s.cache.invalidateCollection(collection)
+	return s.execWithArgs(ctx, q, args)
+}
+
+// UpdateMany applies the same patch to the documents with the given ids using
+// `_id IN (...)`. Long id lists are split into chunks of maxIDsPerStatement;
+// the result is the list of per-chunk responses in order. When the context
+// deadline gets too close to send the next chunk, UpdateMany stops and
+// returns a *PartialResult indexing ids, whose Continuation resumes the
+// update (see WithContinuation).
+func (s *service) UpdateMany(
+	ctx context.Context,
+	collection string,
+	ids []string,
+	patch map[string]any,
+) (any, error) {
+	ctx, cancel := s.opDeadline(ctx, opBulk)
+	defer cancel()
+	if len(ids) == 0 {
+		return nil, errors.New("ids required")
+	}
+	if err := s.checkPatchQuota(collection, patch); err != nil {
+		return nil, err
+	}
+	start, err := resumeIndex(ctx, "UpdateMany", len(ids))
+	if err != nil {
+		return nil, err
+	}
+	guard := s.newDeadlineGuard(ctx)
+	prog := s.progress(ctx, knownTotal(len(ids)))
+	prog.add(start)
+	// results stands for per-chunk responses
+	var results []any
+	next := start
+	for _, chunk := range chunkIDs(ids[start:], maxIDsPerStatement) {
+		if guard.near() {
+			return results, guard.partial("UpdateMany", start, next, len(ids), func(int) error { return nil })
+		}
+		t0 := s.now()
+		where := idsPredicate(chunk)
+		q, args, err := buildUpdateWhere(collection, where, patch, s.allowReserved)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.archive(ctx, collection, where); err != nil {
+			return results, err
+		}
+		res, err := s.execWithArgs(ctx, q, args)
+		s.cache.invalidate(collection, chunk...)
+		if err != nil {
+			return results, err
+		}
+		guard.observe(s.now().Sub(t0))
+		results = append(results, res)
+		next += len(chunk)
+		prog.add(len(chunk))
+	}
+	prog.finish()
+	return results, nil
+}
+
 // DeleteRecord removes a single record by _id.
 func (s *service) DeleteRecord(ctx context.Context, collection, id string) (any, error) {
-    // Use parameterized query to avoid injection issues
-    // q stands for query
-    // Pattern A (previous): EVICT with equality operator (commented out)
-    // q := fmt.Sprintf("EVICT FROM %s WHERE _id == :id", escapeIdent(collection))
-    // Pattern B (current): DELETE with single equals to match curl example
-    q := fmt.Sprintf("DELETE FROM %s WHERE _id = :id", escapeIdent(collection))
-    return s.execWithArgs(ctx, q, map[string]any{"id": id})
+	// Use parameterized query to avoid injection issues
+	// q stands for query
+	// Pattern A (previous): EVICT with equality operator (commented out)
+	// q := fmt.Sprintf("EVICT FROM %s WHERE _id == :id", escapeIdent(collection))
+	// Pattern B (current): DELETE with single equals to match curl example
+	// Soft-delete collections get a deleted_at tombstone instead
+	s.cache.invalidate(collection, id)
+	defer s.cache.invalidate(collection, id)
+	if s.softDeleted[collection] {
+		return s.softDelete(ctx, collection, Where("_id == :id", map[string]any{"id": id}))
+	}
+	q := fmt.Sprintf("DELETE FROM %s WHERE _id = :id", escapeIdent(collection))
+	return s.execWithArgs(ctx, q, map[string]any{"id": id})
 }
 
 // DeleteAllRecords removes all documents in a collection using a broad WHERE
 // clause. Ditto DQL has no TRUNCATE; use DELETE with LIKE to match all ids.
-func (s *service) DeleteAllRecords(ctx context.Context, collection string) (any, error) {
-    if collection == "" {
-        return nil, errors.New("collection required")
-    }
-    // Pattern A (previous): EVICT with LIKE (commented out)
-    // q := fmt.Sprintf("EVICT FROM %s WHERE _id LIKE :pattern", escapeIdent(collection))
-    // Pattern B (current): DELETE with LIKE
-    q := fmt.Sprintf("DELETE FROM %s WHERE _id LIKE :pattern", escapeIdent(collection))
-    return s.execWithArgs(ctx, q, map[string]any{"pattern": "%"})
+// The call must be confirmed with ConfirmDeleteAll unless the service was
+// built WithAllowDestructiveOps.
+func (s *service) DeleteAllRecords(ctx context.Context, collection string, confirm ...DestructiveOption) (any, error) {
+	ctx, cancel := s.opDeadline(ctx, opBulk)
+	defer cancel()
+	if collection == "" {
+		return nil, errors.New("collection required")
+	}
+	if err := s.confirmDestructive(ConfirmDeleteAll, collection, confirm); err != nil {
+		return nil, err
+	}
+	// Pattern A (previous): EVICT with LIKE (commented out)
+	// q := fmt.Sprintf("EVICT FROM %s WHERE _id LIKE :pattern", escapeIdent(collection))
+	// Pattern B (current): DELETE with LIKE
+	s.cache.invalidateCollection(collection)
+	defer s.cache.invalidateCollection(collection)
+	if s.softDeleted[collection] {
+		return s.softDelete(ctx, collection, Where("_id LIKE :pattern", map[string]any{"pattern": "%"}))
+	}
+	q := fmt.Sprintf("DELETE FROM %s WHERE _id LIKE :pattern", escapeIdent(collection))
+	return s.execWithArgs(ctx, q, map[string]any{"pattern": "%"})
 }
 
 // LatestRecord returns the most recent record according to the provided field
 // (descending order), limited to a single result.
+//
+// Deprecated: use LatestRecordWith, which takes a LatestRecordRequest.
 func (s *service) LatestRecord(ctx context.Context, collection, sortBy string) (any, error) {
 	// sortBy required
-	// q stands for query
-	q := BuildSelect(collection, nil, 1, sortBy, "DESC")
-	return s.execWithArgs(ctx, q, nil)
+	return s.FindRecords(ctx, collection, nil, QueryOptions{Limit: 1, SortBy: sortBy, SortOrder: "DESC"})
 }
 
 // Search builds a simple exact-match WHERE clause from the provided filters
 // and applies optional LIMIT and ORDER BY.
+//
+// Deprecated: use SearchWith, which takes a SearchRequest.
 func (s *service) Search(
 	// ctx stands for context
 	// collection stands for Ditto collection name
@@ -440,14 +1185,24 @@ func (s *service) Search(
 	sortBy, sortOrder string,
 ) (any, error) {
 	// Build SELECT with WHERE clauses for each filter
-	// q stands for query
-	q := BuildSelect(collection, filters, limit, sortBy, sortOrder)
-	return s.execWithArgs(ctx, q, nil)
+	return s.FindRecords(ctx, collection, filters, QueryOptions{Limit: limit, SortBy: sortBy, SortOrder: sortOrder})
+}
+
+// Execute runs an arbitrary DQL statement with bound query_args. Prefer the
+// parameterized helpers; when writing DQL by hand, pass values through args
+// rather than formatting them into the query string.
+func (s *service) Execute(ctx context.Context, query string, args map[string]any) (any, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, errors.New("query required")
+	}
+	return s.execWithArgs(ctx, query, args)
 }
 
 // exec posts a raw DQL query without additional arguments to Ditto's
 // /execute endpoint and decodes the JSON response.
 func (s *service) exec(ctx context.Context, query string) (any, error) {
+	ctx, cancel := s.statementDeadline(ctx, query)
+	defer cancel()
 	// Post to /{appID}/execute
 	// On non-2xx responses, return an error including an excerpt of both
 	// url status code, Ditto's error response body, and the original DQL
@@ -455,7 +1210,18 @@ func (s *service) exec(ctx context.Context, query string) (any, error) {
 	// b stands for byte slice of JSON payload
 	// req stands for HTTP request
 	// resp stands for HTTP response
-	url := fmt.Sprintf("%s/%s/execute", strings.TrimRight(s.BaseURL, "/"), s.AppID)
+	if err := s.checkWritable(query); err != nil {
+		return nil, err
+	}
+	if s.strictDQL {
+		if err := ValidateDQL(query, nil); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.checkParams(query); err != nil {
+		return nil, err
+	}
+	url := s.executeURL()
 	payload := map[string]string{"query": query}
 	b, _ := json.Marshal(payload)
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
@@ -465,6 +1231,8 @@ func (s *service) exec(ctx context.Context, query string) (any, error) {
 
 	// Set content type and execute request
 	req.Header.Set("Content-Type", "application/json")
+	s.ops.add()
+	defer s.ops.done()
 	resp, err := s.HTTP.Do(req)
 	if err != nil {
 		return nil, err
@@ -473,142 +1241,250 @@ func (s *service) exec(ctx context.Context, query string) (any, error) {
 	// Handle response
 	// Close body when done
 	// Check for non-2xx status codes
-	// Read response body for error snippet
-	// Trim and limit snippet length
-	// Trim and limit query length for error message
-	// Return formatted error
 	defer resp.Body.Close()
 	if resp.StatusCode/100 != 2 {
-		body, _ := io.ReadAll(resp.Body)
-		snippet := string(body)
-		if len(snippet) > 256 {
-			snippet = snippet[:256] + "..."
-		}
-		q := query
-		if len(q) > 200 {
-			q = q[:200] + "..."
-		}
-		return nil, fmt.Errorf(
-			"ditto http %d: %s | query: %s",
-			resp.StatusCode,
-			strings.TrimSpace(snippet),
-			q,
-		)
-	}
-	var out any
-	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
-		return nil, err
+		return nil, httpError(resp.StatusCode, resp.Body, query)
 	}
-	return out, nil
+	return s.decode(resp.Body)
 }
 
-// execWithArgs posts a DQL query and a query_args map to Ditto's /execute
-// endpoint. On non-2xx responses, it returns an error including an excerpt
-// of both Ditto's error response body and the original DQL.
+// execWithArgs runs a DQL query with a query_args map. Reads of a prefetched
+// query may be served from memory, and identical concurrent reads share one
+// round trip when WithSingleflight is enabled.
 func (s *service) execWithArgs(
 	ctx context.Context,
 	query string,
 	args map[string]any,
 ) (any, error) {
+	query = s.qualify(ctx, query)
+	ctx, cancel := s.statementDeadline(ctx, query)
+	defer cancel()
+	if res, ok := s.warm(query, args); ok {
+		return res, nil
+	}
+	if s.flights != nil && isReadStatement(query) {
+		return s.flights.do(ctx, query, args, func(ctx context.Context) (any, error) {
+			return s.post(ctx, query, args)
+		})
+	}
+	return s.post(ctx, query, args)
+}
+
+// post sends a DQL query and a query_args map to Ditto's /execute
+// endpoint. On non-2xx responses, it returns an error including an excerpt
+// of both Ditto's error response body and the original DQL.
+func (s *service) post(
+	ctx context.Context,
+	query string,
+	args map[string]any,
+) (res any, err error) {
 	// Post to /{appID}/execute with query_args
 	// On non-2xx responses, return an error including an excerpt of both
 	// url status code, Ditto's error response body, and the original DQL
-	// payload stands for request payload
-	// b stands for byte slice of JSON payload
 	// req stands for HTTP request
 	// resp stands for HTTP response
-	url := fmt.Sprintf("%s/%s/execute", strings.TrimRight(s.BaseURL, "/"), s.AppID)
-	payload := map[string]any{"query": query}
-	if args != nil {
-		payload["query_args"] = args
-	}
-	b, _ := json.Marshal(payload)
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	req, err := s.newExecuteRequest(ctx, query, args)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/json")
-	resp, err := s.HTTP.Do(req)
+	// Remembered reads revalidate with the server's ETag when there is one
+	condKey, prev, cond := s.conditionalLookup(query, args)
+	if prev != nil && prev.etag != "" {
+		req.Header.Set("If-None-Match", prev.etag)
+	}
+	reqID := s.requestID(ctx)
+	if reqID != "" {
+		req.Header.Set(requestIDHeader, reqID)
+	}
+	// Latency and response size feed the histograms, slow query log, and
+	// access log
+	s.ops.add()
+	defer s.ops.done()
+	start := s.now()
+	body := &countingReader{}
+	status := 0
+	defer func() {
+		d := s.now().Sub(start)
+		s.observe(query, args, d, req.ContentLength, body.n, res, err)
+		s.recordLatency(query, d, err)
+		s.logAccess(ctx, query, args, d, status, req.ContentLength, body.n, reqID, err)
+	}()
+	req = s.withConnTrace(req)
+	resp, err := s.send(req, query)
 	if err != nil {
+		s.connectionLost(err)
 		return nil, err
 	}
 	// Handle response
 	// Close body when done
 	// Check for non-2xx status codes
-	// Read response body for error snippet
 	defer resp.Body.Close()
+	body.r = resp.Body
+	status = resp.StatusCode
+	if id := resp.Header.Get(requestIDHeader); id != "" {
+		reqID = id
+	}
+	if cond && (resp.StatusCode/100 == 2 || resp.StatusCode == http.StatusNotModified) {
+		return s.decodeConditional(ctx, condKey, prev, resp, body)
+	}
 	if resp.StatusCode/100 != 2 {
-		body, _ := io.ReadAll(resp.Body)
-		snippet := string(body)
-		if len(snippet) > 256 {
-			snippet = snippet[:256] + "..."
-		}
-		q := query
-		if len(q) > 200 {
-			q = q[:200] + "..."
+		return nil, httpError(resp.StatusCode, body, query)
+	}
+	return s.decode(body)
+}
+
+// newExecuteRequest validates query (read-only mode, strict DQL, payload
+// size) and builds the POST to /{appID}/execute.
+func (s *service) newExecuteRequest(ctx context.Context, query string, args map[string]any) (*http.Request, error) {
+	// payload stands for request payload
+	// b stands for byte slice of JSON payload
+	if err := s.checkWritable(query); err != nil {
+		return nil, err
+	}
+	if s.strictDQL {
+		if err := ValidateDQL(query, args); err != nil {
+			return nil, err
 		}
-		return nil, fmt.Errorf(
-			"ditto http %d: %s | query: %s",
-			resp.StatusCode,
-			strings.TrimSpace(snippet),
-			q,
-		)
-	}
-	var out any
-	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+	}
+	if err := s.checkParams(query); err != nil {
 		return nil, err
 	}
-	return out, nil
+	url := s.executeURL()
+	payload := map[string]any{"query": query}
+	if args != nil {
+		payload["query_args"] = s.encodeArgs(args)
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkBodySize(b); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// HTTPError is returned for a non-2xx response from the Ditto HTTP API. Use
+// errors.As to inspect the status code.
+type HTTPError struct {
+	StatusCode int
+	Class      ErrorClass // what kind of failure the status and body indicate
+	Body       string     // response body excerpt
+	Query      string     // DQL excerpt
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("ditto http %d: %s | query: %s", e.StatusCode, e.Body, e.Query)
+}
+
+// httpError builds the error for a non-2xx response, with excerpts of the
+// response body and the DQL that caused it.
+func httpError(status int, body io.Reader, query string) error {
+	// Read response body for error snippet
+	raw, _ := io.ReadAll(body)
+	snippet := string(raw)
+	if len(snippet) > 256 {
+		snippet = snippet[:256] + "..."
+	}
+	q := query
+	if len(q) > 200 {
+		q = q[:200] + "..."
+	}
+	snippet = strings.TrimSpace(snippet)
+	return &HTTPError{StatusCode: status, Class: httpErrorClass(status, snippet), Body: snippet, Query: q}
 }
 
 // Query builders ----------------------------------------------------------------
 
 // BuildSelect constructs a DQL SELECT statement for the provided collection
-// with optional exact-match filters, limit, and ordering. Identifiers are
-// minimally escaped to avoid common syntax issues.
+// with optional exact-match filters, limit, and ordering. Identifiers that
+// are not plain names are backtick-quoted.
 func BuildSelect(
 	collection string,
 	filters map[string]string,
 	limit int,
 	sortBy, sortOrder string,
 ) string {
+	q, _ := buildSelect(collection, filters, "", QueryOptions{Limit: limit, SortBy: sortBy, SortOrder: sortOrder}, false)
+	return q
+}
+
+// buildSelect is BuildSelect driven by QueryOptions, with an extra raw clause
+// ANDed onto the filters for service-level conditions such as soft-delete.
+// With bind, filter values are returned as query_args (:filter_0, ...)
+// instead of being escaped into string literals.
+func buildSelect(
+	collection string,
+	filters map[string]string,
+	extra string,
+	o QueryOptions,
+	bind bool,
+) (string, map[string]any) {
 	// collection required
 	// b stands for strings.Builder to build the query
 	// i stands for index for AND clauses
 	var b strings.Builder
-	b.WriteString("SELECT * FROM ")
+	var args map[string]any
+	b.WriteString("SELECT ")
+	b.WriteString(projection(o.Fields))
+	b.WriteString(" FROM ")
 	b.WriteString(escapeIdent(collection))
-	if len(filters) > 0 {
+	if len(filters) > 0 || extra != "" {
 		b.WriteString(" WHERE ")
 		i := 0
-		for k, v := range filters {
+		// Sorted keys keep the generated DQL stable across calls
+		for _, k := range sortedKeys(filters) {
 			if i > 0 {
 				b.WriteString(" AND ")
 			}
-			b.WriteString(escapeIdent(k))
-			b.WriteString(" == \"")
-			b.WriteString(escapeString(v))
-			b.WriteString("\"")
+			b.WriteString(escapePath(k))
+			if bind {
+				if args == nil {
+					args = make(map[string]any, len(filters))
+				}
+				name := fmt.Sprintf("filter_%d", i)
+				args[name] = filters[k]
+				b.WriteString(" == :" + name)
+			} else {
+				b.WriteString(" == \"")
+				b.WriteString(escapeString(filters[k]))
+				b.WriteString("\"")
+			}
 			i++
 		}
+		if extra != "" {
+			if i > 0 {
+				b.WriteString(" AND ")
+			}
+			b.WriteString(extra)
+		}
 	}
 	// Optional ORDER sortBy
 	// and sortOrder ("ASC" or "DESC")
-	// and LIMIT limit
-	if sortBy != "" {
+	// and LIMIT limit / OFFSET offset
+	if o.SortBy != "" {
 		b.WriteString(" ORDER BY ")
-		b.WriteString(escapeIdent(sortBy))
-		if strings.ToUpper(sortOrder) == "DESC" {
+		b.WriteString(escapePath(o.SortBy))
+		if strings.ToUpper(o.SortOrder) == "DESC" {
 			b.WriteString(" DESC")
-		} else if strings.ToUpper(sortOrder) == "ASC" {
+		} else if strings.ToUpper(o.SortOrder) == "ASC" {
 			b.WriteString(" ASC")
 		}
 	}
-	if limit > 0 {
+	if o.Limit > 0 {
 		b.WriteString(" LIMIT ")
-		b.WriteString(fmt.Sprintf("%d", limit))
+		b.WriteString(fmt.Sprintf("%d", o.Limit))
 	}
-	return b.String()
+	if o.Offset > 0 {
+		b.WriteString(" OFFSET ")
+		b.WriteString(fmt.Sprintf("%d", o.Offset))
+	}
+	return b.String(), args
 }
 
 // BuildInsert constructs an INSERT DQL with a parameterized document (:doc).
@@ -631,6 +1507,11 @@ func BuildInsert(collection string, doc map[string]any) (string, map[string]any,
 // BuildUpdate constructs an UPDATE DQL with parameterized SET clauses and
 // a bound :id for the target record.
 func BuildUpdate(collection, id string, patch map[string]any) (string, map[string]any, error) {
+	return buildUpdate(collection, id, patch, false)
+}
+
+// buildUpdate is BuildUpdate with an override for reserved-field checks.
+func buildUpdate(collection, id string, patch map[string]any, allowReserved bool) (string, map[string]any, error) {
 	// collection and id required
 	// patch required and non-empty
 	if collection == "" || id == "" {
@@ -640,32 +1521,214 @@ func BuildUpdate(collection, id string, patch map[string]any) (string, map[strin
 	if len(patch) == 0 {
 		return "", nil, errors.New("patch is empty")
 	}
-	// parts collects SET clauses
-	var parts []string
-	args := map[string]any{"id": id}
-	for k, v := range patch {
-		pname := fmt.Sprintf("p_%s", k)
-		parts = append(parts, fmt.Sprintf("%s = :%s", escapeIdent(k), pname))
-		args[pname] = v
+	mut, args, err := buildMutation(patch, allowReserved)
+	if err != nil {
+		return "", nil, err
+	}
+	args["id"] = id
+	return fmt.Sprintf("UPDATE %s %s WHERE _id == :id", escapeIdent(collection), mut), args, nil
+}
+
+// buildMutation converts a patch map into the mutation part of an UPDATE:
+// parameterized SET assignments, plus an UNSET list for keys whose value is
+// the Unset sentinel; the Null sentinel sets a field to null. Parameters are
+// named p_0, p_1, ... in sorted key order, so field names with dots or dashes
+// never leak into parameter names. Keys that are empty, have empty path
+// segments, or collide with another key once escaped are rejected, as are
+// reserved fields such as _id unless allowReserved is set.
+func buildMutation(patch map[string]any, allowReserved bool) (string, map[string]any, error) {
+	// sets and unsets collect clauses, in sorted key order for stable output
+	// fields maps escaped field paths back to the original key
+	var sets, unsets []string
+	args := map[string]any{}
+	fields := map[string]string{}
+	for i, k := range sortedKeys(patch) {
+		field, err := fieldPath(k)
+		if err != nil {
+			return "", nil, err
+		}
+		if err := checkReserved(field, allowReserved); err != nil {
+			return "", nil, err
+		}
+		if prev, dup := fields[field]; dup {
+			return "", nil, fmt.Errorf("patch keys %q and %q both map to field %q", prev, k, field)
+		}
+		fields[field] = k
+		switch v := patch[k]; v {
+		case Unset:
+			unsets = append(unsets, field)
+		case Null:
+			sets = append(sets, fmt.Sprintf("%s = null", field))
+		default:
+			if _, ok := v.(ArrayOp); ok {
+				return "", nil, errArrayOpUnsupported
+			}
+			pname := fmt.Sprintf("p_%d", i)
+			sets = append(sets, fmt.Sprintf("%s = :%s", field, pname))
+			args[pname] = v
+		}
+	}
+	var clauses []string
+	if len(sets) > 0 {
+		clauses = append(clauses, "SET "+strings.Join(sets, ", "))
+	}
+	if len(unsets) > 0 {
+		clauses = append(clauses, "UNSET "+strings.Join(unsets, ", "))
+	}
+	return strings.Join(clauses, " "), args, nil
+}
+
+// ErrImmutableField is returned when a patch tries to modify a reserved field
+// such as _id. Use WithAllowReserved to override.
+var ErrImmutableField = errors.New("ditto: field is immutable")
+
+// reservedFields are top-level fields the server manages or forbids changing.
+var reservedFields = map[string]bool{"_id": true}
+
+// checkReserved rejects writes to reserved fields (or paths beneath them).
+func checkReserved(field string, allowReserved bool) error {
+	if allowReserved {
+		return nil
+	}
+	root, _, _ := strings.Cut(field, ".")
+	if reservedFields[root] {
+		return fmt.Errorf("%w: %s", ErrImmutableField, field)
+	}
+	return nil
+}
+
+// fieldPath validates a (possibly dotted) field path and returns it escaped.
+func fieldPath(k string) (string, error) {
+	if k == "" {
+		return "", errors.New("empty field name in patch")
+	}
+	segs := strings.Split(k, ".")
+	for i, seg := range segs {
+		if seg == "" {
+			return "", fmt.Errorf("invalid field path %q", k)
+		}
+		segs[i] = escapeIdent(seg)
+	}
+	return strings.Join(segs, "."), nil
+}
+
+// sortedKeys returns the keys of m in ascending order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
 	}
-	set := strings.Join(parts, ", ")
-	return fmt.Sprintf("UPDATE %s SET %s WHERE _id == :id", escapeIdent(collection), set), args, nil
+	sort.Strings(keys)
+	return keys
 }
 
-// escapeIdent performs minimal identifier sanitization suitable for DQL.
-// It removes backticks and replaces spaces with underscores.
+// escapeIdent quotes a single identifier (collection or field name) for DQL.
+// Plain identifiers (a letter or underscore, then letters, digits, and
+// underscores) that are not reserved words are returned as is; anything else
+// is wrapped in backticks with embedded backticks doubled, so "my-coll"
+// stays my-coll on the server instead of silently naming another collection.
+// The empty name becomes an empty quoted identifier, which the server (and
+// ValidateDQL) rejects.
+//
+// This is a breaking change: earlier releases replaced spaces with
+// underscores and dropped backticks, so "my coll" addressed my_coll. Callers
+// with data stored under the rewritten name must pass that name ("my_coll")
+// explicitly, or move the data with CopyCollection.
 func escapeIdent(s string) string {
-	// collection and field names should be simple identifiers
-	// Very basic identifier safety: replace backticks and spaces
-	s = strings.ReplaceAll(s, "`", "")
-	s = strings.ReplaceAll(s, " ", "_")
-	return s
+	if plainIdent(s) {
+		return s
+	}
+	return "`" + strings.ReplaceAll(s, "`", "``") + "`"
+}
+
+// plainIdent reports whether s can appear in DQL unquoted.
+func plainIdent(s string) bool {
+	if s == "" || dqlKeywords[strings.ToUpper(s)] {
+		return false
+	}
+	for i, r := range s {
+		if !isIdentRune(r, i > 0) {
+			return false
+		}
+	}
+	return true
 }
 
-// escapeString escapes double quotes in a string literal.
+// QuoteIdent quotes a collection or field name for splicing into DQL built
+// outside this package, the way the SDK's own builders do.
+func QuoteIdent(name string) string { return escapeIdent(name) }
+
+// QuotePath quotes a dotted field path for splicing into DQL, segment by
+// segment like QuoteIdent.
+func QuotePath(path string) string { return escapePath(path) }
+
+// escapePath quotes a dotted field path segment by segment with escapeIdent.
+// Empty segments are kept as empty quoted identifiers rather than dropped, so
+// a malformed path fails instead of addressing a different field.
+func escapePath(s string) string {
+	segs := strings.Split(s, ".")
+	for i, seg := range segs {
+		segs[i] = escapeIdent(seg)
+	}
+	return strings.Join(segs, ".")
+}
+
+// timestampLayout is a fixed-width UTC layout so stored timestamps compare
+// correctly as strings in DQL.
+const timestampLayout = "2006-01-02T15:04:05.000Z"
+
+// FormatTimestamp formats t the way the SDK stores timestamps (deleted_at,
+// archived_at, ...): fixed-width UTC with milliseconds, so values compare
+// correctly as strings in DQL.
+func FormatTimestamp(t time.Time) string {
+	return t.UTC().Format(timestampLayout)
+}
+
+// ParseTimestamp parses a stored timestamp written by FormatTimestamp or as
+// RFC 3339. It reports false for non-strings and unparseable values.
+func ParseTimestamp(v any) (time.Time, bool) {
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	if t, err := time.Parse(timestampLayout, s); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// escapeString escapes s for use inside a double-quoted DQL string literal:
+// backslashes and double quotes are backslash-escaped, control characters,
+// line and paragraph separators, and typographic quotes become \uXXXX
+// escapes, and invalid UTF-8 is replaced with U+FFFD.
 func escapeString(s string) string {
-	// Escape double quotes
-	return strings.ReplaceAll(s, "\"", "\\\"")
+	var b strings.Builder
+	for _, r := range strings.ToValidUTF8(s, "\uFFFD") {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\u2018', '\u2019', '\u201C', '\u201D', '\u2028', '\u2029':
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			if unicode.IsControl(r) {
+				fmt.Fprintf(&b, `\u%04X`, r)
+				continue
+			}
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
 }
 
 // Docker integration -----------------------------------------------------------
@@ -681,8 +1744,25 @@ type DockerRunner interface {
 	RunContainer(ctx context.Context, opts DockerOptions) error
 	StartContainer(ctx context.Context, name string) error
 	StopContainer(ctx context.Context, name string) error
+	// RemoveContainer force-removes the named container. A missing container
+	// is not an error.
+	RemoveContainer(ctx context.Context, name string) error
+	// Teardown stops the container and removes whatever TeardownOptions
+	// selects (container, volumes, image).
+	Teardown(ctx context.Context, opts DockerOptions, t TeardownOptions) error
 }
 
+// RecreatePolicy controls what InitDB does with an existing, exited container.
+type RecreatePolicy string
+
+const (
+	// RecreateNever starts the exited container in place (`docker start`).
+	RecreateNever RecreatePolicy = "never"
+	// RecreateAlways removes the exited container and runs a fresh one so
+	// image, mount, and port changes are picked up.
+	RecreateAlways RecreatePolicy = "always"
+)
+
 // DockerOptions collects parameters for starting a Ditto Edge container.
 type DockerOptions struct {
 	// Required settings
@@ -694,6 +1774,29 @@ type DockerOptions struct {
 	// Optional docker compose settings
 	ComposeFile    string // path to docker-compose.yml; empty means default discovery
 	ComposeService string // service name; defaults to "ditto-edge-server" if empty
+	// RecreatePolicy decides how an exited container is brought back; empty
+	// uses the runner default (never for Docker, always for Compose).
+	RecreatePolicy RecreatePolicy
+	// HostPort is the localhost port mapped to the container's HTTP API;
+	// defaults to 8090.
+	HostPort int
+	// Isolated gives each service instance its own container name, host
+	// port, and data directory, removed again on Close. The data directory
+	// is a fresh subdirectory of DataPath, or of the system temp directory
+	// when DataPath is empty. Plain Docker only.
+	Isolated bool
+	// StartupTimeout enables the startup log watcher: after InitDB starts the
+	// container it waits up to this long for a ready log line. Zero disables.
+	StartupTimeout time.Duration
+	// ReadyLogPattern and ErrorLogPattern are regular expressions matched
+	// against log lines during startup; empty uses built-in defaults.
+	ReadyLogPattern string
+	ErrorLogPattern string
+	// ReadyTimeout bounds how long InitDB polls the HTTP API after starting
+	// the container (default 30s; negative disables). ReadyInterval is the
+	// initial poll interval (default 250ms), doubled after each failure.
+	ReadyTimeout  time.Duration
+	ReadyInterval time.Duration
 }
 
 // dockerRunnerDefault implements DockerRunner via plain Docker CLI commands.
@@ -731,12 +1834,9 @@ func (d *dockerRunnerDefault) ContainerStatus(ctx context.Context, name string)
 	// running (up)
 
 	// running, exited, or not-found
-	cmd := exec.CommandContext(
-		ctx,
-		"bash",
-		"-lc",
-		fmt.Sprintf("docker ps -a --filter name=^/%s$ --format '{{.Status}}'", name),
-	)
+	script := fmt.Sprintf("docker ps -a --filter name=^/%s$ --format '{{.Status}}'", name)
+	traceCommand(ctx, DockerCommand{Name: "bash", Args: []string{"-lc", script}, ReadOnly: true})
+	cmd := exec.CommandContext(ctx, "bash", "-lc", script)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		return "", fmt.Errorf("docker ps: %w", err)
@@ -763,9 +1863,13 @@ func (d *dockerRunnerDefault) RunContainer(ctx context.Context, opts DockerOptio
 	// args stands for docker run arguments
 	// fmt stands for format
 	// If any required options are missing, return an error
+	port := opts.HostPort
+	if port == 0 {
+		port = 8090
+	}
 	args := []string{
 		"run", "-d", "--name", opts.ContainerName,
-		"-p", "127.0.0.1:8090:8090",
+		"-p", fmt.Sprintf("127.0.0.1:%d:8090", port),
 		"-v", fmt.Sprintf("%s:/config.yaml", opts.ConfigPath),
 		"-v", fmt.Sprintf("%s:/data", opts.DataPath),
 		opts.ImageName, "run", "-c", "/config.yaml",
@@ -786,6 +1890,56 @@ func (d *dockerRunnerDefault) StopContainer(ctx context.Context, name string) er
 	return runCmd(ctx, "docker", "stop", name)
 }
 
+// RemoveContainer force-removes a container, ignoring a missing one.
+func (d *dockerRunnerDefault) RemoveContainer(ctx context.Context, name string) error {
+	return removeContainer(ctx, name)
+}
+
+// Teardown stops the container and removes the container (with anonymous
+// volumes when requested) and the image.
+func (d *dockerRunnerDefault) Teardown(ctx context.Context, opts DockerOptions, t TeardownOptions) error {
+	_ = runCmd(ctx, "docker", "stop", opts.ContainerName)
+	if t.RemoveContainer || t.RemoveVolumes {
+		args := []string{"rm", "-f"}
+		if t.RemoveVolumes {
+			args = append(args, "-v")
+		}
+		args = append(args, opts.ContainerName)
+		if err := runCmd(ctx, "docker", args...); err != nil &&
+			!strings.Contains(strings.ToLower(err.Error()), "no such container") {
+			return fmt.Errorf("docker rm: %w", err)
+		}
+	}
+	if t.RemoveImage {
+		return removeImage(ctx, opts.ImageName)
+	}
+	return nil
+}
+
+// removeImage runs `docker rmi` and treats a missing image as success.
+func removeImage(ctx context.Context, imageName string) error {
+	if imageName == "" {
+		return nil
+	}
+	err := runCmd(ctx, "docker", "rmi", imageName)
+	if err != nil && strings.Contains(strings.ToLower(err.Error()), "no such image") {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("docker rmi: %w", err)
+	}
+	return nil
+}
+
+// removeContainer runs `docker rm -f` and treats "No such container" as success.
+func removeContainer(ctx context.Context, name string) error {
+	err := runCmd(ctx, "docker", "rm", "-f", name)
+	if err != nil && strings.Contains(strings.ToLower(err.Error()), "no such container") {
+		return nil
+	}
+	return err
+}
+
 // runCmd executes a CLI command and returns a formatted error including
 // stdout/stderr when the command fails.
 func runCmd(ctx context.Context, name string, args ...string) error {
@@ -794,6 +1948,9 @@ func runCmd(ctx context.Context, name string, args ...string) error {
 	// cmd stands for exec.CommandContext
 	// out stands for command output
 	// err stands for error
+	if !traceCommand(ctx, DockerCommand{Name: name, Args: args, ReadOnly: name == "docker" && readOnlyDocker(args)}) {
+		return nil
+	}
 	cmd := exec.CommandContext(ctx, name, args...)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
@@ -839,12 +1996,9 @@ func (d *composeRunnerDefault) ContainerStatus(ctx context.Context, name string)
 	// running, exited, or not-found
 
 	// Use docker ps on container_name because compose service maps to container_name
-	cmd := exec.CommandContext(
-		ctx,
-		"bash",
-		"-lc",
-		fmt.Sprintf("docker ps -a --filter name=^/%s$ --format '{{.Status}}'", name),
-	)
+	script := fmt.Sprintf("docker ps -a --filter name=^/%s$ --format '{{.Status}}'", name)
+	traceCommand(ctx, DockerCommand{Name: "bash", Args: []string{"-lc", script}, ReadOnly: true})
+	cmd := exec.CommandContext(ctx, "bash", "-lc", script)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		return "", fmt.Errorf("docker ps: %w", err)
@@ -882,15 +2036,48 @@ func (d *composeRunnerDefault) RunContainer(ctx context.Context, opts DockerOpti
 	return nil
 }
 
-// StartContainer starts a stopped compose service.
+// StartContainer starts a stopped compose-managed container in place. The name
+// is the container_name, so plain `docker start` is used rather than
+// `docker compose start`, which expects a service name.
 func (d *composeRunnerDefault) StartContainer(ctx context.Context, name string) error {
-	// Use docker compose start [service]
-	// If ComposeFile is provided, use -f to specify it
-	// If ComposeService is empty, default to "ditto-edge-server"
-	args := []string{"compose", "start", name}
-	return runCmd(ctx, "docker", args...)
+	return runCmd(ctx, "docker", "start", name)
 }
 
+// RemoveContainer force-removes the compose-managed container by name.
+func (d *composeRunnerDefault) RemoveContainer(ctx context.Context, name string) error {
+	return removeContainer(ctx, name)
+}
+
+// Teardown uses `docker compose down` for the service's project when removing
+// the container (adding -v for volumes), then removes the image if asked.
+func (d *composeRunnerDefault) Teardown(ctx context.Context, opts DockerOptions, t TeardownOptions) error {
+	if !t.RemoveContainer && !t.RemoveVolumes {
+		_ = runCmd(ctx, "docker", "stop", opts.ContainerName)
+	} else {
+		args := []string{"compose"}
+		if opts.ComposeFile != "" {
+			args = append(args, "-f", opts.ComposeFile)
+		}
+		args = append(args, "down")
+		if t.RemoveVolumes {
+			args = append(args, "-v")
+		}
+		if err := runCmd(ctx, "docker", args...); err != nil {
+			return fmt.Errorf("docker compose down: %w", err)
+		}
+		// Best effort: catch a container left behind outside the project
+		_ = removeContainer(ctx, opts.ContainerName)
+	}
+	if t.RemoveImage {
+		return removeImage(ctx, opts.ImageName)
+	}
+	return nil
+}
+
+// defaultRecreatePolicy keeps compose's historical behavior of recreating an
+// exited container via `docker compose up -d`.
+func (d *composeRunnerDefault) defaultRecreatePolicy() RecreatePolicy { return RecreateAlways }
+
 // StopContainer stops the compose service and then best-effort stops/removes
 // any lingering container by name.
 func (d *composeRunnerDefault) StopContainer(ctx context.Context, name string) error {
@@ -0,0 +1,290 @@
+// Package dittotest provides tools for testing applications built on the
+// ditto SDK: a fault-injecting HTTP transport for exercising retry,
+// breaker, and offline-queue logic deterministically, and a recorder that
+// captures real /execute interactions into fixtures for hermetic replay.
+// RunServiceConformance checks custom Service implementations against the
+// behavior of the real one, and Generate fills collections with seeded
+// synthetic documents for load tests and demos.
+package dittotest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Hammerstone-AU/ditto-go-sdk/ditto"
+)
+
+// FaultKind selects what a Fault does to a request.
+type FaultKind string
+
+const (
+	// FaultLatency delays the request by Latency, then sends it.
+	FaultLatency FaultKind = "latency"
+	// FaultTimeout fails with a network timeout after Latency, or blocks
+	// until the request context ends when Latency is zero.
+	FaultTimeout FaultKind = "timeout"
+	// FaultStatus answers with Status (default 503) and Body without
+	// reaching the server.
+	FaultStatus FaultKind = "status"
+	// FaultMalformed answers 200 with Body, or truncated JSON when Body is
+	// empty.
+	FaultMalformed FaultKind = "malformed"
+	// FaultReset fails as if the connection was reset.
+	FaultReset FaultKind = "reset"
+)
+
+// Duration is a time.Duration that reads from JSON as a string such as
+// "250ms".
+type Duration time.Duration
+
+// UnmarshalJSON accepts a duration string or a number of nanoseconds.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		var n int64
+		if err := json.Unmarshal(b, &n); err != nil {
+			return fmt.Errorf("duration: %s", b)
+		}
+		*d = Duration(n)
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalJSON writes the duration as a string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Fault is one rule of a Scenario. A request matches when Match (a regular
+// expression) matches its DQL statement. The fault fires for matching
+// requests after the first After (counted whether or not another fault
+// fired for them), at most Times times, each with Probability (zero means
+// always).
+type Fault struct {
+	Kind        FaultKind `json:"kind"`
+	Match       string    `json:"match,omitempty"`
+	After       int       `json:"after,omitempty"`
+	Times       int       `json:"times,omitempty"`
+	Probability float64   `json:"probability,omitempty"`
+	Latency     Duration  `json:"latency,omitempty"`
+	Status      int       `json:"status,omitempty"`
+	Body        string    `json:"body,omitempty"`
+}
+
+// Scenario lists faults in priority order; the first one that fires for a
+// request applies. Seed fixes the random draws so a run is reproducible.
+type Scenario struct {
+	Seed   uint64  `json:"seed"`
+	Faults []Fault `json:"faults"`
+}
+
+// LoadScenario reads a Scenario from a JSON file.
+func LoadScenario(path string) (Scenario, error) {
+	var sc Scenario
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return sc, err
+	}
+	if err := json.Unmarshal(b, &sc); err != nil {
+		return sc, fmt.Errorf("scenario %s: %w", path, err)
+	}
+	return sc, nil
+}
+
+// Injection records one fault applied by a FaultTransport.
+type Injection struct {
+	Request int // 1-based request number
+	Fault   int // index into Scenario.Faults
+	Kind    FaultKind
+	Query   string
+}
+
+// FaultTransport is an http.RoundTripper that injects the faults of a
+// Scenario into requests before passing the rest to Base. Install it on a
+// service with svc.HTTP.Transport = dittotest.NewFaultTransport(nil, sc).
+type FaultTransport struct {
+	Base    http.RoundTripper // nil means http.DefaultTransport
+	Sleeper ditto.Sleeper     // waits for Latency; nil means real timers
+
+	mu       sync.Mutex
+	faults   []Fault
+	match    []*regexp.Regexp
+	seen     []int // matching requests per fault
+	fired    []int // injections per fault
+	rng      *rand.Rand
+	requests int
+	log      []Injection
+}
+
+// NewFaultTransport returns a transport applying sc in front of base. It
+// panics if a Match expression does not compile.
+func NewFaultTransport(base http.RoundTripper, sc Scenario) *FaultTransport {
+	t := &FaultTransport{
+		Base:   base,
+		faults: sc.Faults,
+		match:  make([]*regexp.Regexp, len(sc.Faults)),
+		seen:   make([]int, len(sc.Faults)),
+		fired:  make([]int, len(sc.Faults)),
+		rng:    rand.New(rand.NewPCG(sc.Seed, sc.Seed)),
+	}
+	for i, f := range sc.Faults {
+		if f.Match != "" {
+			t.match[i] = regexp.MustCompile(f.Match)
+		}
+	}
+	return t
+}
+
+// Injections returns the faults applied so far, in order.
+func (t *FaultTransport) Injections() []Injection {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Injection(nil), t.log...)
+}
+
+// Reset clears the request counters and log and reseeds the random source,
+// so the scenario replays from the start.
+func (t *FaultTransport) Reset(seed uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	clear(t.seen)
+	clear(t.fired)
+	t.requests, t.log = 0, nil
+	t.rng = rand.New(rand.NewPCG(seed, seed))
+}
+
+// RoundTrip applies the first fault that fires for req, if any.
+func (t *FaultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	query, err := peekQuery(req)
+	if err != nil {
+		return nil, err
+	}
+	f, ok := t.pick(query)
+	if !ok {
+		return t.base().RoundTrip(req)
+	}
+	switch f.Kind {
+	case FaultLatency:
+		if err := t.sleep(req, f.Latency); err != nil {
+			return nil, err
+		}
+		return t.base().RoundTrip(req)
+	case FaultTimeout:
+		if f.Latency == 0 {
+			<-req.Context().Done()
+			return nil, req.Context().Err()
+		}
+		if err := t.sleep(req, f.Latency); err != nil {
+			return nil, err
+		}
+		return nil, &net.OpError{Op: "read", Net: "tcp", Err: timeoutError{}}
+	case FaultStatus:
+		status := f.Status
+		if status == 0 {
+			status = http.StatusServiceUnavailable
+		}
+		return respond(req, status, f.Body), nil
+	case FaultMalformed:
+		body := f.Body
+		if body == "" {
+			body = `{"items": [{"_id": "tru`
+		}
+		return respond(req, http.StatusOK, body), nil
+	case FaultReset:
+		return nil, &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+	}
+	return nil, fmt.Errorf("dittotest: unknown fault kind %q", f.Kind)
+}
+
+// pick advances the counters for query and returns the fault to apply.
+func (t *FaultTransport) pick(query string) (Fault, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.requests++
+	matched := make([]bool, len(t.faults))
+	for i := range t.faults {
+		if t.match[i] == nil || t.match[i].MatchString(query) {
+			matched[i] = true
+			t.seen[i]++
+		}
+	}
+	for i, f := range t.faults {
+		if !matched[i] || t.seen[i] <= f.After || (f.Times > 0 && t.fired[i] >= f.Times) {
+			continue
+		}
+		if f.Probability > 0 && t.rng.Float64() >= f.Probability {
+			continue
+		}
+		t.fired[i]++
+		t.log = append(t.log, Injection{Request: t.requests, Fault: i, Kind: f.Kind, Query: query})
+		return f, true
+	}
+	return Fault{}, false
+}
+
+func (t *FaultTransport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+func (t *FaultTransport) sleep(req *http.Request, d Duration) error {
+	sl := t.Sleeper
+	if sl == nil {
+		sl = ditto.SystemSleeper
+	}
+	return sl.Sleep(req.Context(), time.Duration(d))
+}
+
+// peekQuery reads the DQL statement from an /execute request body and
+// restores the body for the real round trip.
+func peekQuery(req *http.Request) (string, error) {
+	b, err := readBody(req)
+	if err != nil || b == nil {
+		return "", err
+	}
+	var payload struct {
+		Query string `json:"query"`
+	}
+	_ = json.Unmarshal(b, &payload)
+	return payload.Query, nil
+}
+
+// respond builds a synthetic response to req.
+func respond(req *http.Request, status int, body string) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader([]byte(body))),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// timeoutError is a net.Error reporting a timeout.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout (injected)" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
@@ -0,0 +1,246 @@
+package dittotest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/Hammerstone-AU/ditto-go-sdk/ditto"
+)
+
+// RunServiceConformance checks that svc behaves like the SDK's own Service
+// (ditto.NewService with default options) against a live Ditto server:
+// CRUD, exact-match search, sorting and pagination, and argument errors.
+// Custom implementations (mocks, caches, proxies) run it to show they can
+// stand in for the real one.
+//
+// Each run works in a fresh collection named conformance_<random>, emptied
+// with DeleteAllRecords when the test ends.
+func RunServiceConformance(t *testing.T, svc ditto.Service) {
+	t.Helper()
+	ctx := context.Background()
+	coll := "conformance_" + randomSuffix()
+	t.Cleanup(func() {
+		if _, err := svc.DeleteAllRecords(ctx, coll, ditto.ConfirmDeleteAll); err != nil {
+			t.Logf("cleanup %s: %v", coll, err)
+		}
+	})
+
+	// Five documents n=1..5; odd ones tagged "odd"
+	for i := 1; i <= 5; i++ {
+		tag := "even"
+		if i%2 == 1 {
+			tag = "odd"
+		}
+		doc := map[string]any{"_id": fmt.Sprintf("doc-%d", i), "n": i, "tag": tag}
+		if _, err := svc.CreateDocument(ctx, coll, doc); err != nil {
+			t.Fatalf("CreateDocument %v: %v", doc["_id"], err)
+		}
+	}
+
+	t.Run("GetRecord", func(t *testing.T) {
+		res, err := svc.GetRecord(ctx, coll, "doc-2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		docs := ditto.Documents(res)
+		if len(docs) != 1 || docs[0]["_id"] != "doc-2" || number(docs[0]["n"]) != 2 || docs[0]["tag"] != "even" {
+			t.Fatalf("GetRecord(doc-2) = %v", res)
+		}
+	})
+
+	t.Run("GetRecordMissing", func(t *testing.T) {
+		res, err := svc.GetRecord(ctx, coll, "missing")
+		if err != nil {
+			t.Fatalf("GetRecord of a missing id: want no error, got %v", err)
+		}
+		if docs := ditto.Documents(res); len(docs) != 0 {
+			t.Fatalf("GetRecord of a missing id returned %v", docs)
+		}
+	})
+
+	t.Run("GetRecordsByIDs", func(t *testing.T) {
+		res, err := svc.GetRecordsByIDs(ctx, coll, []string{"doc-1", "doc-3", "missing"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(res); !slices.Equal(got, []string{"doc-1", "doc-3"}) {
+			t.Fatalf("GetRecordsByIDs = %v, want [doc-1 doc-3]", got)
+		}
+		res, err = svc.GetRecordsByIDs(ctx, coll, nil)
+		if err != nil || len(ditto.Documents(res)) != 0 {
+			t.Fatalf("GetRecordsByIDs(nil) = %v, %v; want no documents", res, err)
+		}
+	})
+
+	t.Run("Exists", func(t *testing.T) {
+		if ok, err := svc.Exists(ctx, coll, "doc-1"); err != nil || !ok {
+			t.Fatalf("Exists(doc-1) = %v, %v", ok, err)
+		}
+		if ok, err := svc.Exists(ctx, coll, "missing"); err != nil || ok {
+			t.Fatalf("Exists(missing) = %v, %v", ok, err)
+		}
+		if ok, err := svc.ExistsWhere(ctx, coll, ditto.Where("n > :n", map[string]any{"n": 4})); err != nil || !ok {
+			t.Fatalf("ExistsWhere(n > 4) = %v, %v", ok, err)
+		}
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		res, err := svc.FindRecords(ctx, coll, map[string]string{"tag": "odd"}, ditto.QueryOptions{SortBy: "n", SortOrder: "ASC"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(res); !slices.Equal(got, []string{"doc-1", "doc-3", "doc-5"}) {
+			t.Fatalf("FindRecords(tag=odd) = %v", got)
+		}
+		res, err = svc.FindWhere(ctx, coll, ditto.Where("n <= :n", map[string]any{"n": 2}), ditto.QueryOptions{SortBy: "n"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(res); !slices.Equal(got, []string{"doc-1", "doc-2"}) {
+			t.Fatalf("FindWhere(n <= 2) = %v", got)
+		}
+	})
+
+	t.Run("Pagination", func(t *testing.T) {
+		var pages [][]string
+		for offset := 0; offset < 6; offset += 2 {
+			res, err := svc.FindRecords(ctx, coll, nil, ditto.QueryOptions{SortBy: "n", SortOrder: "ASC", Limit: 2, Offset: offset})
+			if err != nil {
+				t.Fatal(err)
+			}
+			pages = append(pages, ids(res))
+		}
+		want := [][]string{{"doc-1", "doc-2"}, {"doc-3", "doc-4"}, {"doc-5"}}
+		if !slices.EqualFunc(pages, want, slices.Equal[[]string]) {
+			t.Fatalf("pages = %v, want %v", pages, want)
+		}
+		res, err := svc.LatestRecordWith(ctx, ditto.LatestRecordRequest{Collection: coll, SortBy: "n"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(res); !slices.Equal(got, []string{"doc-5"}) {
+			t.Fatalf("LatestRecordWith(n) = %v, want [doc-5]", got)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		if _, err := svc.UpdateRecord(ctx, coll, "doc-4", map[string]any{"tag": "four"}); err != nil {
+			t.Fatal(err)
+		}
+		docs := get(t, svc, coll, "doc-4")
+		if len(docs) != 1 || docs[0]["tag"] != "four" || number(docs[0]["n"]) != 4 {
+			t.Fatalf("after UpdateRecord doc-4 = %v", docs)
+		}
+		if _, err := svc.UpdateWhere(ctx, coll, ditto.Where("n >= :n", map[string]any{"n": 5}), map[string]any{"big": true}); err != nil {
+			t.Fatal(err)
+		}
+		if docs := get(t, svc, coll, "doc-5"); len(docs) != 1 || docs[0]["big"] != true {
+			t.Fatalf("after UpdateWhere doc-5 = %v", docs)
+		}
+		if _, err := svc.UpdateMany(ctx, coll, []string{"doc-1", "doc-2"}, map[string]any{"small": true}); err != nil {
+			t.Fatal(err)
+		}
+		res, err := svc.FindWhere(ctx, coll, ditto.Where("small == true", nil), ditto.QueryOptions{SortBy: "n"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(res); !slices.Equal(got, []string{"doc-1", "doc-2"}) {
+			t.Fatalf("after UpdateMany small = %v", got)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if _, err := svc.DeleteRecord(ctx, coll, "doc-3"); err != nil {
+			t.Fatal(err)
+		}
+		if ok, err := svc.Exists(ctx, coll, "doc-3"); err != nil || ok {
+			t.Fatalf("Exists(doc-3) after delete = %v, %v", ok, err)
+		}
+		if docs := get(t, svc, coll, "doc-3"); len(docs) != 0 {
+			t.Fatalf("GetRecord(doc-3) after delete = %v", docs)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		if _, err := svc.GetRecordsByIDs(ctx, "", []string{"x"}); err == nil {
+			t.Error("GetRecordsByIDs with no collection: want error")
+		}
+		if _, err := svc.UpdateRecord(ctx, coll, "doc-1", map[string]any{}); err == nil {
+			t.Error("UpdateRecord with an empty patch: want error")
+		}
+		if _, err := svc.UpdateRecord(ctx, coll, "doc-1", map[string]any{"_id": "other"}); !errors.Is(err, ditto.ErrImmutableField) {
+			t.Errorf("UpdateRecord of _id: want ErrImmutableField, got %v", err)
+		}
+		if _, err := svc.ExistsWhere(ctx, coll, ditto.Predicate{}); err == nil {
+			t.Error("ExistsWhere with an empty predicate: want error")
+		}
+		if _, err := svc.Execute(ctx, "", nil); err == nil {
+			t.Error("Execute with an empty query: want error")
+		}
+		if _, err := svc.Execute(ctx, "SELEKT nonsense", nil); err == nil {
+			t.Error("Execute of invalid DQL: want error")
+		}
+	})
+
+	t.Run("DeleteAll", func(t *testing.T) {
+		if _, err := svc.DeleteAllRecords(ctx, coll, ditto.ConfirmDeleteAll); err != nil {
+			t.Fatal(err)
+		}
+		res, err := svc.FindRecords(ctx, coll, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if docs := ditto.Documents(res); len(docs) != 0 {
+			t.Fatalf("after DeleteAllRecords %d documents remain", len(docs))
+		}
+	})
+}
+
+// get returns the documents GetRecord reports for id.
+func get(t *testing.T, svc ditto.Service, coll, id string) []map[string]any {
+	t.Helper()
+	res, err := svc.GetRecord(context.Background(), coll, id)
+	if err != nil {
+		t.Fatalf("GetRecord(%s): %v", id, err)
+	}
+	return ditto.Documents(res)
+}
+
+// ids returns the _id of every document in res, in order.
+func ids(res any) []string {
+	var out []string
+	for _, d := range ditto.Documents(res) {
+		out = append(out, fmt.Sprint(d["_id"]))
+	}
+	return out
+}
+
+// number converts a decoded JSON number (float64 or json.Number) for
+// comparison.
+func number(v any) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case json.Number:
+		f, _ := n.Float64()
+		return f
+	}
+	return -1
+}
+
+// randomSuffix returns 8 random hex characters.
+func randomSuffix() string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,390 @@
+package dittotest
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Hammerstone-AU/ditto-go-sdk/ditto"
+)
+
+// defaultGenerateBatch is how many documents one INSERT of Generate carries.
+const defaultGenerateBatch = 500
+
+// Gen produces one field value of the i-th generated document, drawing
+// randomness only from r so a seeded Spec is reproducible.
+type Gen func(r *rand.Rand, i int) any
+
+// Spec describes synthetic documents for Generate.
+//
+// Template maps field names to values: a Gen is called for every document,
+// a map[string]any or []any is walked recursively, and anything else is
+// copied as is. Without an _id entry every document gets a random hex id.
+type Spec struct {
+	Collection string
+	Count      int
+	Template   map[string]any
+	// Seed fixes the random draws, so the same Spec yields the same
+	// documents (and, with upserts, Generate can be rerun safely).
+	Seed      uint64
+	BatchSize int // documents per INSERT; defaults to 500
+}
+
+// collectionName limits Generate to plain identifiers, which need no
+// escaping in the INSERT it builds.
+var collectionName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Generate fabricates spec.Count documents from spec.Template and upserts
+// them into spec.Collection through svc.Execute in batches. It returns the
+// _id of every document in order, for Ref links from a later Spec:
+//
+//	users, _ := dittotest.Generate(ctx, svc, dittotest.Spec{
+//		Collection: "users", Count: 100, Seed: 1,
+//		Template: map[string]any{"name": dittotest.FullName(), "email": dittotest.Email()},
+//	})
+//	dittotest.Generate(ctx, svc, dittotest.Spec{
+//		Collection: "orders", Count: 1000, Seed: 2,
+//		Template: map[string]any{
+//			"user_id": dittotest.Ref(users...),
+//			"total":   dittotest.FloatBetween(5, 500, 2),
+//			"placed":  dittotest.TimeBetween(time.Now().AddDate(0, -1, 0), time.Now()),
+//		},
+//	})
+func Generate(ctx context.Context, svc ditto.Service, spec Spec) ([]string, error) {
+	if !collectionName.MatchString(spec.Collection) {
+		return nil, fmt.Errorf("generate: invalid collection name %q", spec.Collection)
+	}
+	size := spec.BatchSize
+	if size <= 0 {
+		size = defaultGenerateBatch
+	}
+	g := newGenerator(spec)
+	ids := make([]string, 0, max(spec.Count, 0))
+	for start := 0; start < spec.Count; start += size {
+		end := min(start+size, spec.Count)
+		values := make([]string, 0, end-start)
+		args := map[string]any{}
+		for i := start; i < end; i++ {
+			doc := g.document(i)
+			ids = append(ids, fmt.Sprint(doc["_id"]))
+			name := fmt.Sprintf("d_%d", i-start)
+			values = append(values, ":"+name)
+			args[name] = doc
+		}
+		q := fmt.Sprintf("INSERT INTO %s DOCUMENTS (%s) ON ID CONFLICT DO UPDATE",
+			spec.Collection, strings.Join(values, "), ("))
+		//dittovet:ignore the collection matches collectionName; values are generated :d_N names
+		if _, err := svc.Execute(ctx, q, args); err != nil {
+			return ids[:start], fmt.Errorf("generate %s: documents %d-%d: %w", spec.Collection, start, end-1, err)
+		}
+	}
+	return ids, nil
+}
+
+// GenerateDocuments returns the documents Generate would write for spec,
+// without writing them.
+func GenerateDocuments(spec Spec) []map[string]any {
+	g := newGenerator(spec)
+	out := make([]map[string]any, max(spec.Count, 0))
+	for i := range out {
+		out[i] = g.document(i)
+	}
+	return out
+}
+
+// generator draws documents in index order from one seeded source.
+type generator struct {
+	template map[string]any
+	rng      *rand.Rand
+}
+
+func newGenerator(spec Spec) *generator {
+	return &generator{template: spec.Template, rng: rand.New(rand.NewPCG(spec.Seed, spec.Seed^0x9e3779b97f4a7c15))}
+}
+
+// document builds document i.
+func (g *generator) document(i int) map[string]any {
+	doc := g.fill(g.template, i).(map[string]any)
+	if _, ok := doc["_id"]; !ok {
+		doc["_id"] = fmt.Sprintf("%016x", g.rng.Uint64())
+	}
+	return doc
+}
+
+// fill expands one template value.
+func (g *generator) fill(v any, i int) any {
+	switch x := v.(type) {
+	case Gen:
+		return g.fill(x(g.rng, i), i)
+	case func(*rand.Rand, int) any:
+		return g.fill(x(g.rng, i), i)
+	case map[string]any:
+		out := make(map[string]any, len(x))
+		// Sorted keys keep the draws, and so the output, reproducible
+		for _, k := range sortedKeys(x) {
+			out[k] = g.fill(x[k], i)
+		}
+		return out
+	case []any:
+		out := make([]any, len(x))
+		for j, e := range x {
+			out[j] = g.fill(e, i)
+		}
+		return out
+	}
+	return v
+}
+
+// Sample word lists for the faker-style generators.
+var (
+	firstNames = []string{"Olivia", "Liam", "Amelia", "Noah", "Isla", "Jack", "Mia", "William", "Charlotte", "Oliver", "Ava", "Henry", "Grace", "Lucas", "Chloe", "Thomas", "Zoe", "James", "Ruby", "Leo", "Aisha", "Kenji", "Priya", "Mateo", "Ngaio", "Sione", "Mei", "Arjun"}
+	lastNames  = []string{"Smith", "Jones", "Williams", "Brown", "Wilson", "Taylor", "Nguyen", "Johnson", "Martin", "White", "Anderson", "Walker", "Thompson", "Thomas", "Lee", "Ryan", "Kelly", "King", "Harris", "Patel", "Singh", "Chen", "Wang", "Tupou", "Ngata", "Rossi", "Garcia", "Kim"}
+	cities     = []string{"Sydney", "Melbourne", "Brisbane", "Perth", "Adelaide", "Hobart", "Darwin", "Canberra", "Newcastle", "Geelong", "Townsville", "Cairns", "Auckland", "Wellington", "Christchurch", "Singapore", "Jakarta", "Tokyo", "Seattle", "Denver", "London", "Rotterdam", "Santiago"}
+	streets    = []string{"George", "King", "Queen", "Elizabeth", "Victoria", "Collins", "Bourke", "Pitt", "Hay", "Murray", "Station", "Church", "High", "Park", "Beach", "Mill", "River", "Hill"}
+	companies  = []string{"Acme", "Globex", "Initech", "Umbrella", "Stark", "Wayne", "Hooli", "Vandelay", "Wonka", "Tyrell", "Cyberdyne", "Soylent", "Massive Dynamic", "Aperture"}
+	suffixes   = []string{"Pty Ltd", "Group", "Holdings", "Logistics", "Mining", "Energy", "Systems", "Labs"}
+	domains    = []string{"example.com", "example.org", "example.net", "mail.test", "corp.test"}
+	words      = []string{"alpha", "bravo", "sensor", "pump", "valve", "engine", "route", "depot", "field", "signal", "battery", "cabin", "north", "south", "offline", "sync", "delta", "harbor", "ridge", "mesh", "relay", "cargo", "drill", "crane", "filter", "gauge", "lamp", "panel", "rotor", "track"}
+)
+
+// Seq returns format applied to the document index, e.g. Seq("user-%05d")
+// for readable sequential ids.
+func Seq(format string) Gen {
+	return func(_ *rand.Rand, i int) any { return fmt.Sprintf(format, i) }
+}
+
+// OneOf returns one of values, uniformly.
+func OneOf(values ...any) Gen {
+	return func(r *rand.Rand, _ int) any {
+		if len(values) == 0 {
+			return nil
+		}
+		return values[r.IntN(len(values))]
+	}
+}
+
+// Ref returns one of ids, uniformly: a link to a document of a collection
+// generated earlier (see Generate).
+func Ref(ids ...string) Gen {
+	return func(r *rand.Rand, _ int) any {
+		if len(ids) == 0 {
+			return nil
+		}
+		return ids[r.IntN(len(ids))]
+	}
+}
+
+// Weighted returns values[k] with probability weights[k] / sum(weights).
+func Weighted(values []any, weights []float64) Gen {
+	total := 0.0
+	for _, w := range weights[:min(len(weights), len(values))] {
+		total += w
+	}
+	return func(r *rand.Rand, _ int) any {
+		x := r.Float64() * total
+		for k, w := range weights[:min(len(weights), len(values))] {
+			if x < w {
+				return values[k]
+			}
+			x -= w
+		}
+		if len(values) == 0 {
+			return nil
+		}
+		return values[len(values)-1]
+	}
+}
+
+// Nullable returns nil with probability p and otherwise a value of g.
+func Nullable(p float64, g Gen) Gen {
+	return func(r *rand.Rand, i int) any {
+		if r.Float64() < p {
+			return nil
+		}
+		return g(r, i)
+	}
+}
+
+// IntBetween returns integers in [lo, hi].
+func IntBetween(lo, hi int64) Gen {
+	return func(r *rand.Rand, _ int) any {
+		if hi <= lo {
+			return lo
+		}
+		return lo + r.Int64N(hi-lo+1)
+	}
+}
+
+// FloatBetween returns numbers in [lo, hi) rounded to decimals places.
+func FloatBetween(lo, hi float64, decimals int) Gen {
+	scale := math.Pow(10, float64(decimals))
+	return func(r *rand.Rand, _ int) any {
+		return math.Round((lo+r.Float64()*(hi-lo))*scale) / scale
+	}
+}
+
+// Normal returns normally distributed numbers, e.g. sensor readings around
+// a set point, rounded to decimals places.
+func Normal(mean, stddev float64, decimals int) Gen {
+	scale := math.Pow(10, float64(decimals))
+	return func(r *rand.Rand, _ int) any {
+		return math.Round((mean+r.NormFloat64()*stddev)*scale) / scale
+	}
+}
+
+// Bool returns true with probability p.
+func Bool(p float64) Gen {
+	return func(r *rand.Rand, _ int) any { return r.Float64() < p }
+}
+
+// TimeBetween returns timestamps uniformly in [from, to), formatted like
+// ditto.FormatTimestamp so they compare correctly in DQL.
+func TimeBetween(from, to time.Time) Gen {
+	span := to.Sub(from)
+	return func(r *rand.Rand, _ int) any {
+		if span <= 0 {
+			return ditto.FormatTimestamp(from)
+		}
+		return ditto.FormatTimestamp(from.Add(time.Duration(r.Int64N(int64(span)))))
+	}
+}
+
+// TimeSeries returns start + i*step for document i, for evenly spaced
+// readings; jitter adds up to that much random offset either way.
+func TimeSeries(start time.Time, step, jitter time.Duration) Gen {
+	return func(r *rand.Rand, i int) any {
+		t := start.Add(time.Duration(i) * step)
+		if jitter > 0 {
+			t = t.Add(time.Duration(r.Int64N(2*int64(jitter)+1)) - jitter)
+		}
+		return ditto.FormatTimestamp(t)
+	}
+}
+
+// UUID returns random version 4 UUIDs.
+func UUID() Gen {
+	return func(r *rand.Rand, _ int) any {
+		hi, lo := r.Uint64(), r.Uint64()
+		hi = hi&^0xf000 | 0x4000
+		lo = lo&^(0xc<<60) | 0x8<<60
+		return fmt.Sprintf("%08x-%04x-%04x-%04x-%012x", hi>>32, hi>>16&0xffff, hi&0xffff, lo>>48, lo&0xffffffffffff)
+	}
+}
+
+// FirstName returns given names.
+func FirstName() Gen { return pick(firstNames) }
+
+// LastName returns family names.
+func LastName() Gen { return pick(lastNames) }
+
+// FullName returns "First Last" names.
+func FullName() Gen {
+	return func(r *rand.Rand, _ int) any {
+		return firstNames[r.IntN(len(firstNames))] + " " + lastNames[r.IntN(len(lastNames))]
+	}
+}
+
+// Email returns addresses under reserved example domains; the document
+// index keeps them unique.
+func Email() Gen {
+	return func(r *rand.Rand, i int) any {
+		first := strings.ToLower(firstNames[r.IntN(len(firstNames))])
+		last := strings.ToLower(lastNames[r.IntN(len(lastNames))])
+		return fmt.Sprintf("%s.%s%d@%s", first, last, i, domains[r.IntN(len(domains))])
+	}
+}
+
+// Phone returns numbers in the fictional +61 4xx 555 range.
+func Phone() Gen {
+	return func(r *rand.Rand, _ int) any {
+		return fmt.Sprintf("+61 4%02d 555 %03d", r.IntN(100), r.IntN(1000))
+	}
+}
+
+// Company returns company names.
+func Company() Gen {
+	return func(r *rand.Rand, _ int) any {
+		return companies[r.IntN(len(companies))] + " " + suffixes[r.IntN(len(suffixes))]
+	}
+}
+
+// City returns city names.
+func City() Gen { return pick(cities) }
+
+// Address returns street addresses.
+func Address() Gen {
+	return func(r *rand.Rand, _ int) any {
+		return fmt.Sprintf("%d %s St, %s", 1+r.IntN(400), streets[r.IntN(len(streets))], cities[r.IntN(len(cities))])
+	}
+}
+
+// LatLng returns {"lat", "lng"} points uniformly within the box.
+func LatLng(minLat, minLng, maxLat, maxLng float64) Gen {
+	return func(r *rand.Rand, _ int) any {
+		return map[string]any{
+			"lat": math.Round((minLat+r.Float64()*(maxLat-minLat))*1e6) / 1e6,
+			"lng": math.Round((minLng+r.Float64()*(maxLng-minLng))*1e6) / 1e6,
+		}
+	}
+}
+
+// Words returns n space-separated words.
+func Words(n int) Gen {
+	return func(r *rand.Rand, _ int) any {
+		out := make([]string, n)
+		for k := range out {
+			out[k] = words[r.IntN(len(words))]
+		}
+		return strings.Join(out, " ")
+	}
+}
+
+// ArrayOf returns arrays of between lo and hi values of g.
+func ArrayOf(lo, hi int, g Gen) Gen {
+	return func(r *rand.Rand, i int) any {
+		n := lo
+		if hi > lo {
+			n += r.IntN(hi - lo + 1)
+		}
+		out := make([]any, n)
+		for k := range out {
+			out[k] = g(r, i)
+		}
+		return out
+	}
+}
+
+// Format returns fmt.Sprintf(format, values...) where each Gen argument is
+// drawn first, e.g. Format("%s-%d", OneOf("pump", "valve"), IntBetween(1, 99)).
+func Format(format string, args ...any) Gen {
+	return func(r *rand.Rand, i int) any {
+		vals := make([]any, len(args))
+		for k, a := range args {
+			if g, ok := a.(Gen); ok {
+				vals[k] = g(r, i)
+			} else {
+				vals[k] = a
+			}
+		}
+		return fmt.Sprintf(format, vals...)
+	}
+}
+
+func pick(list []string) Gen {
+	return func(r *rand.Rand, _ int) any { return list[r.IntN(len(list))] }
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
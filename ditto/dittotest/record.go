@@ -0,0 +1,239 @@
+package dittotest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Mode selects whether a Recorder talks to a real server.
+type Mode int
+
+const (
+	// ModeReplay answers every request from the fixture file and fails
+	// requests it has no recording for.
+	ModeReplay Mode = iota
+	// ModeRecord sends requests to the server and records the responses;
+	// Save writes them to the fixture file.
+	ModeRecord
+	// ModeAuto replays when the fixture file exists and records otherwise.
+	ModeAuto
+)
+
+// ErrNoFixture is returned in replay mode for a request that was never
+// recorded.
+var ErrNoFixture = errors.New("dittotest: no recorded interaction")
+
+// Interaction is one recorded /execute exchange.
+type Interaction struct {
+	Query  string          `json:"query"`
+	Args   json.RawMessage `json:"args,omitempty"`
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body,omitempty"` // JSON response bodies
+	Text   string          `json:"text,omitempty"` // any other response body
+}
+
+// fixture is the file format of a Recorder.
+type fixture struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Recorder is an http.RoundTripper that records /execute interactions into
+// a fixture file and replays them, so tests run without a Ditto server but
+// against real responses. Requests match on the statement with whitespace
+// collapsed plus the canonical JSON of its arguments; identical requests
+// replay their recordings in order, repeating the last one.
+//
+// Install it with svc.HTTP.Transport = rec, and call Save after a
+// recording run.
+type Recorder struct {
+	Base http.RoundTripper // nil means http.DefaultTransport
+	// IgnoreArgs names query arguments left out of matching, such as
+	// generated IDs or timestamps that differ between runs.
+	IgnoreArgs []string
+
+	path      string
+	recording bool
+
+	mu     sync.Mutex
+	loaded []Interaction            // fixture contents, indexed on first use
+	played map[string][]Interaction // replay queues by match key
+	last   map[string]Interaction
+	taken  []Interaction // recorded this run
+}
+
+// NewRecorder opens the fixture at path in the given mode.
+func NewRecorder(path string, mode Mode) (*Recorder, error) {
+	r := &Recorder{path: path}
+	switch mode {
+	case ModeRecord:
+		r.recording = true
+		return r, nil
+	case ModeAuto:
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			r.recording = true
+			return r, nil
+		}
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fx fixture
+	if err := json.Unmarshal(b, &fx); err != nil {
+		return nil, fmt.Errorf("fixture %s: %w", path, err)
+	}
+	r.loaded = fx.Interactions
+	return r, nil
+}
+
+// Recording reports whether the recorder sends requests to the server.
+func (r *Recorder) Recording() bool { return r.recording }
+
+// Interactions returns the interactions recorded so far this run.
+func (r *Recorder) Interactions() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Interaction(nil), r.taken...)
+}
+
+// Save writes the recorded interactions to the fixture file. It does
+// nothing in replay mode.
+func (r *Recorder) Save() error {
+	if !r.recording {
+		return nil
+	}
+	r.mu.Lock()
+	fx := fixture{Interactions: append([]Interaction{}, r.taken...)}
+	r.mu.Unlock()
+	b, err := json.MarshalIndent(fx, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, append(b, '\n'), 0o644)
+}
+
+// RoundTrip records or replays req.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	b, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	var payload struct {
+		Query string          `json:"query"`
+		Args  json.RawMessage `json:"query_args"`
+	}
+	_ = json.Unmarshal(b, &payload)
+	if r.recording {
+		return r.record(req, payload.Query, payload.Args)
+	}
+	k := r.key(payload.Query, payload.Args)
+	r.mu.Lock()
+	if r.played == nil {
+		// Index lazily so IgnoreArgs set after NewRecorder applies.
+		r.played, r.last = map[string][]Interaction{}, map[string]Interaction{}
+		for _, in := range r.loaded {
+			lk := r.key(in.Query, in.Args)
+			r.played[lk] = append(r.played[lk], in)
+		}
+	}
+	in, ok := r.last[k]
+	if q := r.played[k]; len(q) > 0 {
+		in, ok = q[0], true
+		r.played[k], r.last[k] = q[1:], q[0]
+	}
+	r.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoFixture, payload.Query)
+	}
+	body := in.Text
+	if len(in.Body) > 0 {
+		body = string(in.Body)
+	}
+	return respond(req, in.Status, body), nil
+}
+
+// record sends req to the server and keeps the response.
+func (r *Recorder) record(req *http.Request, query string, args json.RawMessage) (*http.Response, error) {
+	base := r.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	in := Interaction{Query: query, Status: resp.StatusCode}
+	if len(args) > 0 && string(args) != "null" {
+		in.Args = canonicalJSON(args)
+	}
+	if json.Valid(body) {
+		in.Body = canonicalJSON(body)
+	} else {
+		in.Text = string(body)
+	}
+	r.mu.Lock()
+	r.taken = append(r.taken, in)
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// key normalizes a request for matching.
+func (r *Recorder) key(query string, args json.RawMessage) string {
+	k := strings.Join(strings.Fields(query), " ")
+	var m map[string]any
+	if len(args) == 0 || json.Unmarshal(args, &m) != nil || len(m) == 0 {
+		return k
+	}
+	for _, name := range r.IgnoreArgs {
+		delete(m, name)
+	}
+	b, _ := json.Marshal(m) // map keys marshal sorted
+	return k + "\x00" + string(b)
+}
+
+// canonicalJSON re-encodes b compactly with sorted object keys, keeping
+// numbers exact.
+func canonicalJSON(b []byte) json.RawMessage {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return b
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return b
+	}
+	return out
+}
+
+// readBody returns the request body and restores it for the round trip.
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	b, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(b))
+	return b, nil
+}
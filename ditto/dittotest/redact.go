@@ -0,0 +1,36 @@
+package dittotest
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/Hammerstone-AU/ditto-go-sdk/ditto"
+)
+
+// AssertRedacted checks that rules keep personal data out of the query log:
+// it redacts args the way WithQueryLogging does and fails t for every pii
+// value that still appears in the logged form. Use it with representative
+// arguments of each statement that can carry personal data:
+//
+//	dittotest.AssertRedacted(t, rules,
+//		map[string]any{"doc": map[string]any{"name": "Ada Lovelace", "email": "ada@example.com"}},
+//		"Ada Lovelace", "ada@example.com")
+func AssertRedacted(t testing.TB, rules ditto.RedactionRules, args map[string]any, pii ...string) {
+	t.Helper()
+	b, err := json.Marshal(ditto.RedactArgs(rules, args))
+	if err != nil {
+		t.Fatalf("dittotest: encode redacted args: %v", err)
+	}
+	logged := string(b)
+	for _, v := range pii {
+		if v == "" {
+			continue
+		}
+		// Compare the JSON-encoded form, which is what log handlers emit
+		enc, _ := json.Marshal(v)
+		if strings.Contains(logged, strings.Trim(string(enc), `"`)) {
+			t.Errorf("dittotest: %q is not redacted in logged args %s", v, logged)
+		}
+	}
+}
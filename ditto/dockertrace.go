@@ -0,0 +1,139 @@
+package ditto
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// DockerCommand is one command the default DockerRunners run, or would run
+// in dry-run mode.
+type DockerCommand struct {
+	Name     string
+	Args     []string
+	ReadOnly bool // only inspects the host (docker ps, image inspect, logs)
+}
+
+// String renders the command as it would be typed in a shell.
+func (c DockerCommand) String() string {
+	parts := []string{shellQuote(c.Name)}
+	for _, a := range c.Args {
+		parts = append(parts, shellQuote(a))
+	}
+	return strings.Join(parts, " ")
+}
+
+// shellQuote single-quotes s when it contains anything a shell would
+// interpret.
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=@,+", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// DockerTrace configures how the default DockerRunners report and execute
+// their commands.
+type DockerTrace struct {
+	// Logger receives every command at Info before it runs, with its exact
+	// argument list; nil logs nothing.
+	Logger *slog.Logger
+	// DryRun prints commands that would change the host instead of running
+	// them. Read-only commands (container status, image inspect, logs) still
+	// run so the printed plan reflects the host's actual state, and InitDB
+	// does not wait for a server that was never started.
+	DryRun bool
+	// Out receives one "dry-run: <command>" line per skipped command; nil
+	// means os.Stdout.
+	Out io.Writer
+}
+
+// dockerTraceKey is the context key for WithDockerTraceContext.
+type dockerTraceKey struct{}
+
+// WithDockerTrace traces the commands the service's DockerRunner runs in
+// InitDB, Close, Teardown, Status, and backups (see DockerTrace), so
+// operators can audit, or with DryRun preview, what the SDK does to the
+// host. It applies to NewDockerRunnerDefault and NewComposeRunnerDefault;
+// custom runners can read it with DockerTraceFrom.
+func (s *service) WithDockerTrace(t DockerTrace) *service {
+	s.dockerTrace = &t
+	return s
+}
+
+// WithDockerTraceContext returns a context that traces the Docker commands
+// run under it, overriding the service's WithDockerTrace, e.g. to dry-run a
+// single Teardown. It also works when calling a default runner directly.
+func WithDockerTraceContext(ctx context.Context, t DockerTrace) context.Context {
+	return context.WithValue(ctx, dockerTraceKey{}, &t)
+}
+
+// DockerTraceFrom returns the DockerTrace in effect for ctx, if any.
+func DockerTraceFrom(ctx context.Context) (DockerTrace, bool) {
+	t, ok := ctx.Value(dockerTraceKey{}).(*DockerTrace)
+	if !ok {
+		return DockerTrace{}, false
+	}
+	return *t, true
+}
+
+// dockerCtx carries the service's DockerTrace into runner calls unless ctx
+// already has one.
+func (s *service) dockerCtx(ctx context.Context) context.Context {
+	if s.dockerTrace == nil {
+		return ctx
+	}
+	if _, ok := ctx.Value(dockerTraceKey{}).(*DockerTrace); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, dockerTraceKey{}, s.dockerTrace)
+}
+
+// dockerDryRun reports whether commands under ctx are only printed.
+func dockerDryRun(ctx context.Context) bool {
+	t, ok := DockerTraceFrom(ctx)
+	return ok && t.DryRun
+}
+
+// traceCommand logs cmd per the trace on ctx and reports whether to run it;
+// in dry-run mode a command that changes the host is printed instead.
+func traceCommand(ctx context.Context, cmd DockerCommand) bool {
+	t, ok := DockerTraceFrom(ctx)
+	if !ok {
+		return true
+	}
+	skip := t.DryRun && !cmd.ReadOnly
+	if t.Logger != nil {
+		t.Logger.InfoContext(ctx, "docker command",
+			slog.String("command", cmd.String()),
+			slog.Bool("read_only", cmd.ReadOnly),
+			slog.Bool("dry_run", skip))
+	}
+	if skip {
+		out := t.Out
+		if out == nil {
+			out = os.Stdout
+		}
+		fmt.Fprintf(out, "dry-run: %s\n", cmd)
+	}
+	return !skip
+}
+
+// readOnlyDocker reports whether a docker invocation only inspects state.
+func readOnlyDocker(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	switch args[0] {
+	case "ps", "inspect", "logs", "version", "info", "images":
+		return true
+	case "image", "container":
+		return len(args) > 1 && (args[1] == "inspect" || args[1] == "ls")
+	}
+	return false
+}
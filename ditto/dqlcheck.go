@@ -0,0 +1,349 @@
+package ditto
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ErrInvalidDQL is returned by ValidateDQL, and by every request in strict
+// mode, for a statement that is not well formed.
+var ErrInvalidDQL = errors.New("ditto: invalid DQL")
+
+// WithStrictDQL validates every statement with ValidateDQL before it is
+// sent, so builder regressions surface as ErrInvalidDQL with a precise
+// reason instead of an opaque server error.
+func (s *service) WithStrictDQL() *service {
+	s.strictDQL = true
+	return s
+}
+
+// dqlToken is one lexical token of a statement.
+type dqlToken struct {
+	kind string // "ident", "keyword", "string", "number", "param", "punct"
+	text string
+	pos  int
+}
+
+// dqlKeywords are the reserved words the checker recognizes, upper case.
+var dqlKeywords = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "ORDER": true, "BY": true,
+	"ASC": true, "DESC": true, "LIMIT": true, "OFFSET": true, "INSERT": true,
+	"INTO": true, "DOCUMENTS": true, "ON": true, "ID": true, "CONFLICT": true,
+	"DO": true, "UPDATE": true, "NOTHING": true, "FAIL": true, "SET": true,
+	"UNSET": true, "DELETE": true, "EVICT": true, "EXPLAIN": true, "AND": true,
+	"OR": true, "NOT": true, "IN": true, "IS": true, "NULL": true, "LIKE": true,
+	"TRUE": true, "FALSE": true, "MISSING": true,
+}
+
+// ValidateDQL checks that query is a syntactically well-formed statement of
+// the forms this SDK builds (SELECT, INSERT, UPDATE, DELETE, EVICT, and
+// EXPLAIN of those): strings terminated, parentheses balanced, collection
+// names valid identifiers, clauses in order, and every :param bound in args.
+// It is not a full DQL parser; it catches builder mistakes, not every
+// statement the server would reject.
+func ValidateDQL(query string, args map[string]any) error {
+	toks, err := lexDQL(query)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidDQL, err)
+	}
+	if len(toks) == 0 {
+		return fmt.Errorf("%w: empty statement", ErrInvalidDQL)
+	}
+	depth := 0
+	for _, t := range toks {
+		switch {
+		case t.kind == "param":
+			if _, ok := args[t.text[1:]]; !ok {
+				return fmt.Errorf("%w: parameter %s at offset %d is not bound", ErrInvalidDQL, t.text, t.pos)
+			}
+		case t.text == "(" || t.text == "[" || t.text == "{":
+			depth++
+		case t.text == ")" || t.text == "]" || t.text == "}":
+			if depth--; depth < 0 {
+				return fmt.Errorf("%w: unbalanced %q at offset %d", ErrInvalidDQL, t.text, t.pos)
+			}
+		}
+	}
+	if depth != 0 {
+		return fmt.Errorf("%w: unclosed parenthesis", ErrInvalidDQL)
+	}
+	if err := checkStatement(toks); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidDQL, err)
+	}
+	return nil
+}
+
+// checkStatement verifies the clause structure of one statement.
+func checkStatement(toks []dqlToken) error {
+	p := &dqlParser{toks: toks}
+	if p.accept("EXPLAIN") && p.done() {
+		return errors.New("EXPLAIN without a statement")
+	}
+	switch verb := p.next(); verb.text {
+	case "SELECT":
+		if !p.skipTo(0, "FROM") {
+			return errors.New("SELECT without FROM")
+		}
+		if err := p.collection(); err != nil {
+			return err
+		}
+		return p.tail("WHERE", "ORDER", "LIMIT", "OFFSET")
+	case "INSERT":
+		if err := p.expect("INTO"); err != nil {
+			return err
+		}
+		if err := p.collection(); err != nil {
+			return err
+		}
+		if err := p.expect("DOCUMENTS"); err != nil {
+			return err
+		}
+		if !p.skipTo(0, "ON") {
+			return nil
+		}
+		for _, kw := range []string{"ID", "CONFLICT"} {
+			if err := p.expect(kw); err != nil {
+				return err
+			}
+		}
+		switch {
+		case p.accept("FAIL"):
+		case p.accept("DO"):
+			if !p.accept("UPDATE") && !p.accept("NOTHING") {
+				return errors.New("ON ID CONFLICT DO needs UPDATE or NOTHING")
+			}
+		default:
+			return errors.New("ON ID CONFLICT needs DO UPDATE, DO NOTHING, or FAIL")
+		}
+		if !p.done() {
+			return fmt.Errorf("unexpected %q after conflict clause", p.peek().text)
+		}
+		return nil
+	case "UPDATE":
+		if err := p.collection(); err != nil {
+			return err
+		}
+		if p.peek().text != "SET" && p.peek().text != "UNSET" {
+			return errors.New("UPDATE without SET or UNSET")
+		}
+		return p.tail("WHERE")
+	case "DELETE", "EVICT":
+		if err := p.expect("FROM"); err != nil {
+			return err
+		}
+		if err := p.collection(); err != nil {
+			return err
+		}
+		return p.tail("WHERE")
+	case "":
+		return errors.New("empty statement")
+	default:
+		return fmt.Errorf("unsupported statement %q", verb.text)
+	}
+}
+
+// dqlParser walks a token list.
+type dqlParser struct {
+	toks []dqlToken
+	i    int
+}
+
+func (p *dqlParser) done() bool { return p.i >= len(p.toks) }
+
+func (p *dqlParser) peek() dqlToken {
+	if p.done() {
+		return dqlToken{}
+	}
+	return p.toks[p.i]
+}
+
+func (p *dqlParser) next() dqlToken {
+	t := p.peek()
+	p.i++
+	return t
+}
+
+func (p *dqlParser) accept(kw string) bool {
+	if t := p.peek(); t.kind == "keyword" && t.text == kw {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *dqlParser) expect(kw string) error {
+	if !p.accept(kw) {
+		if p.done() {
+			return fmt.Errorf("expected %s at end of statement", kw)
+		}
+		return fmt.Errorf("expected %s at offset %d, found %q", kw, p.peek().pos, p.peek().text)
+	}
+	return nil
+}
+
+// collection consumes a collection name.
+func (p *dqlParser) collection() error {
+	t := p.next()
+	if t.kind != "ident" {
+		if t.text == "" {
+			return errors.New("missing collection name")
+		}
+		return fmt.Errorf("invalid collection name %q", t.text)
+	}
+	return nil
+}
+
+// skipTo advances to keyword kw at parenthesis depth 0 relative to the
+// current position, consuming it. It reports whether kw was found.
+func (p *dqlParser) skipTo(depth int, kw string) bool {
+	for ; !p.done(); p.i++ {
+		t := p.toks[p.i]
+		switch t.text {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+		}
+		if depth == 0 && t.kind == "keyword" && t.text == kw {
+			p.i++
+			return true
+		}
+	}
+	return false
+}
+
+// tail checks that the remaining top-level clauses appear in the given order,
+// each at most once, and that clauses are not empty.
+func (p *dqlParser) tail(order ...string) error {
+	rank := map[string]int{}
+	for i, kw := range order {
+		rank[kw] = i + 1
+	}
+	last, depth, clauseLen := 0, 0, -1
+	for ; !p.done(); p.i++ {
+		t := p.toks[p.i]
+		switch t.text {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+		}
+		r, ok := rank[t.text]
+		if t.kind != "keyword" || depth != 0 || !ok {
+			clauseLen++
+			continue
+		}
+		if clauseLen == 0 {
+			return fmt.Errorf("empty clause before %s", t.text)
+		}
+		if r <= last {
+			return fmt.Errorf("%s out of order at offset %d", t.text, t.pos)
+		}
+		last, clauseLen = r, 0
+		if t.text == "ORDER" {
+			if p.i+1 >= len(p.toks) || p.toks[p.i+1].text != "BY" {
+				return errors.New("ORDER without BY")
+			}
+			p.i++
+		}
+	}
+	if clauseLen == 0 {
+		return errors.New("statement ends with an empty clause")
+	}
+	return nil
+}
+
+// lexDQL splits query into tokens.
+func lexDQL(q string) ([]dqlToken, error) {
+	var toks []dqlToken
+	rs := []rune(q)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '\'' || r == '"':
+			j := i + 1
+			for ; j < len(rs) && rs[j] != r; j++ {
+				if rs[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(rs) {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			toks = append(toks, dqlToken{kind: "string", text: string(rs[i : j+1]), pos: i})
+			i = j + 1
+		case r == '`':
+			// A doubled backtick is a literal backtick in the name
+			var name []rune
+			j := i + 1
+			for ; j < len(rs); j++ {
+				if rs[j] == '`' {
+					if j+1 < len(rs) && rs[j+1] == '`' {
+						name = append(name, '`')
+						j++
+						continue
+					}
+					break
+				}
+				name = append(name, rs[j])
+			}
+			if j >= len(rs) {
+				return nil, fmt.Errorf("unterminated identifier at offset %d", i)
+			}
+			if len(name) == 0 {
+				return nil, fmt.Errorf("empty identifier at offset %d", i)
+			}
+			toks = append(toks, dqlToken{kind: "ident", text: string(name), pos: i})
+			i = j + 1
+		case r == ':':
+			j := i + 1
+			for j < len(rs) && isIdentRune(rs[j], j > i+1) {
+				j++
+			}
+			if j == i+1 {
+				return nil, fmt.Errorf("empty parameter name at offset %d", i)
+			}
+			toks = append(toks, dqlToken{kind: "param", text: string(rs[i:j]), pos: i})
+			i = j
+		case unicode.IsDigit(r):
+			j := i
+			for j < len(rs) && (unicode.IsDigit(rs[j]) || rs[j] == '.' || rs[j] == 'e' || rs[j] == 'E') {
+				j++
+			}
+			toks = append(toks, dqlToken{kind: "number", text: string(rs[i:j]), pos: i})
+			i = j
+		case isIdentRune(r, false):
+			j := i
+			for j < len(rs) && isIdentRune(rs[j], true) {
+				j++
+			}
+			word := string(rs[i:j])
+			if up := strings.ToUpper(word); dqlKeywords[up] {
+				toks = append(toks, dqlToken{kind: "keyword", text: up, pos: i})
+			} else {
+				toks = append(toks, dqlToken{kind: "ident", text: word, pos: i})
+			}
+			i = j
+		case strings.ContainsRune("()[]{},.*=!<>+-/%|&^~?", r):
+			j := i + 1
+			if j < len(rs) && strings.ContainsRune("=>", rs[j]) && strings.ContainsRune("=!<>", r) {
+				j++
+			}
+			toks = append(toks, dqlToken{kind: "punct", text: string(rs[i:j]), pos: i})
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected character %q at offset %d", r, i)
+		}
+	}
+	return toks, nil
+}
+
+// isIdentRune reports whether r may appear in an identifier; digits only
+// after the first rune.
+func isIdentRune(r rune, notFirst bool) bool {
+	return r == '_' || unicode.IsLetter(r) || (notFirst && unicode.IsDigit(r))
+}
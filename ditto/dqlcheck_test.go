@@ -0,0 +1,100 @@
+package ditto
+
+import (
+	"errors"
+	"testing"
+)
+
+// wherePredicate wraps a predicate in the SELECT that FindWhere sends.
+func wherePredicate(p Predicate) (string, map[string]any, error) {
+	q, _ := buildSelect("cars", nil, p.Clause, QueryOptions{Limit: 10}, false)
+	return q, p.Args, nil
+}
+
+func TestValidateDQLBuilders(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func() (string, map[string]any, error)
+	}{
+		{"BuildSelect", func() (string, map[string]any, error) {
+			return BuildSelect("cars", nil, 0, "", ""), nil, nil
+		}},
+		{"BuildSelect filtered", func() (string, map[string]any, error) {
+			return BuildSelect("my-cars", map[string]string{"make": `Ford "F"`, "owner.name": "Pat"}, 5, "year", "DESC"), nil, nil
+		}},
+		{"BuildSelectArgs", func() (string, map[string]any, error) {
+			q, args := BuildSelectArgs("cars", map[string]string{"make": "Ford", "order": "1"}, 5, "select", "ASC")
+			return q, args, nil
+		}},
+		{"buildSelect projection", func() (string, map[string]any, error) {
+			q, args := buildSelect("cars", map[string]string{"make": "Ford"}, "deleted_at IS NULL",
+				QueryOptions{Fields: []string{"_id", "specs.hp"}, Limit: 5, Offset: 5}, true)
+			return q, args, nil
+		}},
+		{"BuildInsert", func() (string, map[string]any, error) {
+			return BuildInsert("fleet cars", map[string]any{"_id": "c1"})
+		}},
+		{"BuildUpdate", func() (string, map[string]any, error) {
+			return BuildUpdate("cars", "c1", map[string]any{"color": "blue", "specs.hp": 300, "tyre-size": 17})
+		}},
+		{"BuildUpdate null and unset", func() (string, map[string]any, error) {
+			return BuildUpdate("cars", "c1", map[string]any{"a": Null, "b": Unset, "c": 1})
+		}},
+		{"BuildUpdate unset only", func() (string, map[string]any, error) {
+			return BuildUpdate("cars", "c1", map[string]any{"b": Unset})
+		}},
+		{"BuildPatch", func() (string, map[string]any, error) {
+			return BuildPatch("cars", "c1", Patch{Set: map[string]any{"color": "red"}, Unset: []string{"owner.name"}})
+		}},
+		{"BuildUpdateWhere", func() (string, map[string]any, error) {
+			return BuildUpdateWhere("cars", Where("make == :make", map[string]any{"make": "Ford"}), map[string]any{"color": "red"})
+		}},
+		{"BuildUpdateWhere ids", func() (string, map[string]any, error) {
+			return BuildUpdateWhere("cars", idsPredicate([]string{"a", "b", "c"}), map[string]any{"color": "red"})
+		}},
+		{"ArrayContains", func() (string, map[string]any, error) {
+			return wherePredicate(ArrayContains("tags.list", "ute"))
+		}},
+		{"BoundingBox", func() (string, map[string]any, error) {
+			return wherePredicate(BoundingBox("loc.lat", "loc.lng", GeoPoint{Lat: -34, Lng: 150}, GeoPoint{Lat: -33, Lng: 151}))
+		}},
+		{"BoundingBox across the antimeridian", func() (string, map[string]any, error) {
+			return wherePredicate(BoundingBox("lat", "lng", GeoPoint{Lat: -18, Lng: 179}, GeoPoint{Lat: -16, Lng: -179}))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, args, err := tt.build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := ValidateDQL(q, args); err != nil {
+				t.Fatalf("%s\n%v", q, err)
+			}
+		})
+	}
+}
+
+func TestValidateDQLRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		args  map[string]any
+	}{
+		{"empty collection", BuildSelect("", nil, 0, "", ""), nil},
+		{"unbound parameter", "SELECT * FROM cars WHERE make == :make", nil},
+		{"unterminated string", `SELECT * FROM cars WHERE make == "Ford`, nil},
+		{"unbalanced parenthesis", "SELECT * FROM cars WHERE (make == :m", map[string]any{"m": 1}},
+		{"clauses out of order", "SELECT * FROM cars LIMIT 1 WHERE a == 1", nil},
+		{"update without set", "UPDATE cars WHERE _id == :id", map[string]any{"id": 1}},
+		{"missing collection", "DELETE FROM WHERE _id == :id", map[string]any{"id": 1}},
+		{"empty statement", "  ", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateDQL(tt.query, tt.args); !errors.Is(err, ErrInvalidDQL) {
+				t.Fatalf("ValidateDQL(%q) = %v, want ErrInvalidDQL", tt.query, err)
+			}
+		})
+	}
+}
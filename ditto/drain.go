@@ -0,0 +1,117 @@
+package ditto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// defaultShutdownGrace bounds how long Close drains before cancelling.
+const defaultShutdownGrace = 10 * time.Second
+
+// inflight counts operations Close waits for: HTTP requests in progress
+// and queued async writes.
+type inflight struct {
+	mu   sync.Mutex
+	n    int
+	idle chan struct{} // closed when n drops to zero
+}
+
+func (f *inflight) add() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.n == 0 {
+		f.idle = make(chan struct{})
+	}
+	f.n++
+}
+
+func (f *inflight) done() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.n--
+	if f.n == 0 {
+		close(f.idle)
+	}
+}
+
+// count returns the number of operations in flight.
+func (f *inflight) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.n
+}
+
+// wait blocks until nothing is in flight or ctx ends.
+func (f *inflight) wait(ctx context.Context) error {
+	f.mu.Lock()
+	if f.n == 0 {
+		f.mu.Unlock()
+		return nil
+	}
+	idle := f.idle
+	f.mu.Unlock()
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// closeHook is a function run by Close before background work stops.
+type closeHook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// WithShutdownGrace sets how long Close waits for close hooks, in-flight
+// requests, and queued async writes before cancelling what is left and
+// stopping the container. The default is 10s; Close's own context can
+// shorten it.
+func (s *service) WithShutdownGrace(d time.Duration) *service {
+	s.shutdownGrace = d
+	return s
+}
+
+// OnClose registers fn to run when Close starts draining, while the server
+// is still up: stop an outbox relay, flush application buffers, and so on.
+// Hooks run in reverse order of registration, with a context bounded by
+// the shutdown grace period. Ingestors register themselves.
+func (s *service) OnClose(name string, fn func(ctx context.Context) error) {
+	s.closeMu.Lock()
+	defer s.closeMu.Unlock()
+	s.closeHooks = append(s.closeHooks, closeHook{name: name, fn: fn})
+}
+
+// drain runs the close hooks and waits for in-flight work, within the
+// grace period, then stops background goroutines.
+func (s *service) drain(ctx context.Context) error {
+	grace := s.shutdownGrace
+	if grace <= 0 {
+		grace = defaultShutdownGrace
+	}
+	gctx, cancel := context.WithTimeout(ctx, grace)
+	defer cancel()
+
+	s.closeMu.Lock()
+	hooks := s.closeHooks
+	s.closeHooks = nil
+	s.closeMu.Unlock()
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i].fn(gctx); err != nil {
+			errs = append(errs, fmt.Errorf("close hook %s: %w", hooks[i].name, err))
+		}
+	}
+	if err := s.ops.wait(gctx); err != nil {
+		errs = append(errs, fmt.Errorf("close: %d operations still in flight after grace period: %w", s.ops.count(), err))
+	}
+	s.stopBackground()
+	s.async.mu.Lock()
+	s.async.running = 0
+	s.async.mu.Unlock()
+	return errors.Join(errs...)
+}
@@ -0,0 +1,317 @@
+package ditto
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	crand "crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// encryptMagic starts every encrypted archive.
+const encryptMagic = "DITTOENC\x01"
+
+// Encrypted archives are sealed in chunks of encryptChunk plaintext bytes,
+// each framed by its 4-byte ciphertext length.
+const (
+	encryptChunk  = 64 << 10
+	encryptSuffix = ".enc"
+	maxKeyRef     = 4 << 10
+)
+
+// ErrDecrypt reports an encrypted archive that could not be opened: the key
+// is wrong or the data was modified or truncated.
+var ErrDecrypt = errors.New("decrypt: wrong key or corrupted archive")
+
+// KeyProvider supplies AES keys (16, 24, or 32 bytes) for encrypted
+// archives. EncryptionKey returns the key for a new archive and a reference
+// stored in its header; DecryptionKey resolves that reference back to the
+// key. References are not secret.
+type KeyProvider interface {
+	EncryptionKey(ctx context.Context) (key, ref []byte, err error)
+	DecryptionKey(ctx context.Context, ref []byte) ([]byte, error)
+}
+
+// StaticKey returns a KeyProvider using one fixed key, recorded in archive
+// headers by id so a mismatched key is reported clearly.
+func StaticKey(id string, key []byte) KeyProvider {
+	return staticKey{id: id, key: key}
+}
+
+type staticKey struct {
+	id  string
+	key []byte
+}
+
+func (k staticKey) EncryptionKey(context.Context) ([]byte, []byte, error) {
+	return k.key, []byte(k.id), nil
+}
+
+func (k staticKey) DecryptionKey(_ context.Context, ref []byte) ([]byte, error) {
+	if string(ref) != k.id {
+		return nil, fmt.Errorf("archive encrypted with key %q, have %q", ref, k.id)
+	}
+	return k.key, nil
+}
+
+// EnvKey returns a KeyProvider reading the key from environment variable
+// name, encoded as hex or standard base64. The variable is read on every
+// use so a rotated key takes effect without a restart.
+func EnvKey(name string) KeyProvider {
+	return envKey(name)
+}
+
+type envKey string
+
+func (k envKey) EncryptionKey(context.Context) ([]byte, []byte, error) {
+	key, err := k.load()
+	return key, []byte(k), err
+}
+
+func (k envKey) DecryptionKey(_ context.Context, ref []byte) ([]byte, error) {
+	if string(ref) != string(k) {
+		return nil, fmt.Errorf("archive encrypted with key from $%s, have $%s", ref, string(k))
+	}
+	return k.load()
+}
+
+func (k envKey) load() ([]byte, error) {
+	v := strings.TrimSpace(os.Getenv(string(k)))
+	if v == "" {
+		return nil, fmt.Errorf("encryption key $%s not set", string(k))
+	}
+	if key, err := hex.DecodeString(v); err == nil {
+		return key, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(v)
+	if err != nil {
+		return nil, fmt.Errorf("encryption key $%s: not hex or base64", string(k))
+	}
+	return key, nil
+}
+
+// KMSKeys returns a KeyProvider using envelope encryption: every archive
+// gets a fresh 256-bit data key, wrapped by the wrap callback (typically a
+// KMS Encrypt call) and stored in the header; unwrap recovers it on
+// restore.
+func KMSKeys(wrap, unwrap func(ctx context.Context, key []byte) ([]byte, error)) KeyProvider {
+	return kmsKeys{wrap: wrap, unwrap: unwrap}
+}
+
+type kmsKeys struct {
+	wrap, unwrap func(ctx context.Context, key []byte) ([]byte, error)
+}
+
+func (k kmsKeys) EncryptionKey(ctx context.Context) ([]byte, []byte, error) {
+	key := make([]byte, 32)
+	if _, err := crand.Read(key); err != nil {
+		return nil, nil, err
+	}
+	ref, err := k.wrap(ctx, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("wrap data key: %w", err)
+	}
+	return key, ref, nil
+}
+
+func (k kmsKeys) DecryptionKey(ctx context.Context, ref []byte) ([]byte, error) {
+	key, err := k.unwrap(ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("unwrap data key: %w", err)
+	}
+	return key, nil
+}
+
+// WithBackupKeys encrypts backups with kp (unless BackupOptions.Keys says
+// otherwise) and lets RestoreBackup open encrypted archives.
+func (s *service) WithBackupKeys(kp KeyProvider) *service {
+	s.backupKeys = kp
+	return s
+}
+
+// EncryptWriter returns a writer that AES-GCM encrypts everything written to
+// it into w, using a key from kp. Close must be called to seal the final
+// chunk; it does not close w. Use it to encrypt ExportCollection output.
+func EncryptWriter(ctx context.Context, w io.Writer, kp KeyProvider) (io.WriteCloser, error) {
+	key, ref, err := kp.EncryptionKey(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt: %w", err)
+	}
+	if len(ref) > maxKeyRef {
+		return nil, errors.New("encrypt: key reference too long")
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt: %w", err)
+	}
+	sw := &sealWriter{w: w, aead: aead}
+	if _, err := crand.Read(sw.prefix[:]); err != nil {
+		return nil, err
+	}
+	hdr := append([]byte(encryptMagic), sw.prefix[:]...)
+	hdr = binary.BigEndian.AppendUint16(hdr, uint16(len(ref)))
+	hdr = append(hdr, ref...)
+	if _, err := w.Write(hdr); err != nil {
+		return nil, err
+	}
+	return sw, nil
+}
+
+// DecryptReader returns a reader yielding the plaintext of an archive
+// written by EncryptWriter, resolving its key through kp. Reads fail with
+// ErrDecrypt if any chunk was modified, reordered, or cut off.
+func DecryptReader(ctx context.Context, r io.Reader, kp KeyProvider) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	hdr := make([]byte, len(encryptMagic)+8+2)
+	if _, err := io.ReadFull(br, hdr); err != nil || string(hdr[:len(encryptMagic)]) != encryptMagic {
+		return nil, errors.New("decrypt: not an encrypted archive")
+	}
+	or := &openReader{r: br}
+	copy(or.prefix[:], hdr[len(encryptMagic):])
+	ref := make([]byte, binary.BigEndian.Uint16(hdr[len(hdr)-2:]))
+	if _, err := io.ReadFull(br, ref); err != nil {
+		return nil, ErrDecrypt
+	}
+	key, err := kp.DecryptionKey(ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt: %w", err)
+	}
+	if or.aead, err = newGCM(key); err != nil {
+		return nil, fmt.Errorf("decrypt: %w", err)
+	}
+	return or, nil
+}
+
+// isEncrypted reports whether br starts with an encrypted archive header.
+func isEncrypted(br *bufio.Reader) bool {
+	head, _ := br.Peek(len(encryptMagic))
+	return bytes.Equal(head, []byte(encryptMagic))
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce derives the nonce of chunk seq; the AAD marks the final chunk
+// so truncation at a chunk boundary is detected.
+func chunkNonce(prefix [8]byte, seq uint32) []byte {
+	return binary.BigEndian.AppendUint32(prefix[:], seq)
+}
+
+func finalAAD(final bool) []byte {
+	if final {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// sealWriter buffers plaintext and writes sealed chunks.
+type sealWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	prefix [8]byte
+	seq    uint32
+	buf    []byte
+	closed bool
+}
+
+func (sw *sealWriter) Write(p []byte) (int, error) {
+	if sw.closed {
+		return 0, errors.New("encrypt: write after close")
+	}
+	sw.buf = append(sw.buf, p...)
+	// Seal a full chunk only once more data follows it, so the chunk Close
+	// seals is always the final one.
+	for len(sw.buf) > encryptChunk {
+		if err := sw.seal(sw.buf[:encryptChunk], false); err != nil {
+			return 0, err
+		}
+		sw.buf = append(sw.buf[:0], sw.buf[encryptChunk:]...)
+	}
+	return len(p), nil
+}
+
+func (sw *sealWriter) Close() error {
+	if sw.closed {
+		return nil
+	}
+	sw.closed = true
+	return sw.seal(sw.buf, true)
+}
+
+func (sw *sealWriter) seal(chunk []byte, final bool) error {
+	if sw.seq == ^uint32(0) {
+		return errors.New("encrypt: archive too large")
+	}
+	out := binary.BigEndian.AppendUint32(nil, uint32(len(chunk)+sw.aead.Overhead()))
+	out = sw.aead.Seal(out, chunkNonce(sw.prefix, sw.seq), chunk, finalAAD(final))
+	sw.seq++
+	_, err := sw.w.Write(out)
+	return err
+}
+
+// openReader reads and opens sealed chunks.
+type openReader struct {
+	r      *bufio.Reader
+	aead   cipher.AEAD
+	prefix [8]byte
+	seq    uint32
+	buf    []byte
+	done   bool
+}
+
+func (or *openReader) Read(p []byte) (int, error) {
+	for len(or.buf) == 0 {
+		if or.done {
+			return 0, io.EOF
+		}
+		if err := or.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, or.buf)
+	or.buf = or.buf[n:]
+	return n, nil
+}
+
+func (or *openReader) next() error {
+	var size [4]byte
+	if _, err := io.ReadFull(or.r, size[:]); err != nil {
+		return ErrDecrypt // ends before the final chunk
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n < uint32(or.aead.Overhead()) || n > encryptChunk+uint32(or.aead.Overhead()) {
+		return ErrDecrypt
+	}
+	sealed := make([]byte, n)
+	if _, err := io.ReadFull(or.r, sealed); err != nil {
+		return ErrDecrypt
+	}
+	nonce := chunkNonce(or.prefix, or.seq)
+	plain, err := or.aead.Open(nil, nonce, sealed, finalAAD(false))
+	if err != nil {
+		if plain, err = or.aead.Open(nil, nonce, sealed, finalAAD(true)); err != nil {
+			return ErrDecrypt
+		}
+		if _, err := or.r.ReadByte(); err != io.EOF {
+			return ErrDecrypt // data after the final chunk
+		}
+		or.done = true
+	}
+	or.seq++
+	or.buf = plain
+	return nil
+}
@@ -0,0 +1,128 @@
+package ditto
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+)
+
+// ErrorClass groups failures by what a caller can do about them. The default
+// RetryingService policy, the liveness watchdog, and application code all
+// decide from the same classification.
+type ErrorClass string
+
+const (
+	// ClassUnknown is any error not recognised below.
+	ClassUnknown ErrorClass = ""
+	// ClassNetwork is a failure to reach the server or a dropped connection.
+	ClassNetwork ErrorClass = "network"
+	// ClassTimeout is a deadline exceeded on the client or the server.
+	ClassTimeout ErrorClass = "timeout"
+	// ClassSyntax is a statement the client or server rejected as invalid.
+	ClassSyntax ErrorClass = "syntax"
+	// ClassConflict is a concurrent modification: a revision conflict, a lost
+	// lock, or a 409/412 response.
+	ClassConflict ErrorClass = "conflict"
+	// ClassNotFound is a missing document, key, or endpoint.
+	ClassNotFound ErrorClass = "not_found"
+	// ClassServer is a server-side failure or overload (5xx, 429).
+	ClassServer ErrorClass = "server"
+	// ClassClient is any other request the client must fix first: auth,
+	// payload limits, read-only mode, quotas.
+	ClassClient ErrorClass = "client"
+)
+
+// Retryable reports whether e is transient: 429, 408, and 5xx other than
+// 501 Not Implemented.
+func (e *HTTPError) Retryable() bool {
+	switch {
+	case e.StatusCode == http.StatusTooManyRequests, e.StatusCode == http.StatusRequestTimeout:
+		return true
+	case e.StatusCode == http.StatusNotImplemented:
+		return false
+	}
+	return e.StatusCode/100 == 5
+}
+
+// httpErrorClass classifies a non-2xx response.
+func httpErrorClass(status int, body string) ErrorClass {
+	switch status {
+	case http.StatusNotFound:
+		return ClassNotFound
+	case http.StatusConflict, http.StatusPreconditionFailed:
+		return ClassConflict
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return ClassTimeout
+	case http.StatusTooManyRequests:
+		return ClassServer
+	case http.StatusBadRequest:
+		// Ditto answers 400 for statements it cannot parse or plan
+		b := strings.ToLower(body)
+		for _, word := range []string{"syntax", "pars", "query", "dql"} {
+			if strings.Contains(b, word) {
+				return ClassSyntax
+			}
+		}
+		return ClassClient
+	}
+	if status/100 == 5 {
+		return ClassServer
+	}
+	return ClassClient
+}
+
+// ClassOf classifies err, unwrapping as needed. Typed errors carry their
+// class (HTTPError.Class); sentinel and transport errors are mapped here.
+func ClassOf(err error) ErrorClass {
+	if err == nil {
+		return ClassUnknown
+	}
+	var he *HTTPError
+	if errors.As(err, &he) {
+		return he.Class
+	}
+	var ne net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return ClassTimeout
+	case errors.Is(err, ErrInvalidDQL), errors.Is(err, ErrInlineLiteral), errors.Is(err, ErrUnknownFragment):
+		return ClassSyntax
+	case errors.Is(err, ErrConflict), errors.Is(err, ErrLockLost):
+		return ClassConflict
+	case errors.Is(err, ErrNotFound):
+		return ClassNotFound
+	case errors.Is(err, ErrReadOnly), errors.Is(err, ErrDocumentTooLarge), errors.Is(err, ErrQuotaExceeded),
+		errors.Is(err, ErrDestructiveOpNotConfirmed),
+		errors.Is(err, ErrImmutableField):
+		return ClassClient
+	case errors.As(err, &ne) && ne.Timeout():
+		return ClassTimeout
+	case errors.As(err, &ne), errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.ECONNREFUSED):
+		return ClassNetwork
+	}
+	return ClassUnknown
+}
+
+// Retryable reports whether err is a transient failure worth another
+// attempt: network errors, transport timeouts, and retryable HTTP statuses
+// (see HTTPError.Retryable). An ended caller context (cancelled or past its
+// deadline) is never retryable.
+func Retryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var he *HTTPError
+	if errors.As(err, &he) {
+		return he.Retryable()
+	}
+	switch ClassOf(err) {
+	case ClassNetwork, ClassTimeout:
+		return true
+	}
+	return false
+}
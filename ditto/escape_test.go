@@ -0,0 +1,108 @@
+package ditto
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func FuzzEscapeIdent(f *testing.F) {
+	for _, s := range []string{
+		"cars", "_id", "my-coll", "my coll", "a.b", "`", "``", "a`b", "select",
+		"Order", "1abc", "über", "日本", "a\nb", "x) OR 1==1 --", "\"quoted\"",
+	} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		got := escapeIdent(s)
+		if s == "" {
+			if got != "``" {
+				t.Fatalf("escapeIdent(%q) = %q, want ``", s, got)
+			}
+			return
+		}
+		if !utf8.ValidString(s) {
+			return
+		}
+		toks, err := lexDQL(got)
+		if err != nil {
+			t.Fatalf("escapeIdent(%q) = %q does not lex: %v", s, got, err)
+		}
+		if len(toks) != 1 || toks[0].kind != "ident" || toks[0].text != s {
+			t.Fatalf("escapeIdent(%q) = %q lexes as %+v, want one identifier %q", s, got, toks, s)
+		}
+	})
+}
+
+func FuzzEscapePath(f *testing.F) {
+	for _, s := range []string{
+		"a", "a.b", "a.b.c", "a..b", ".a", "a.", "a-b.c d", "`.`", "meta.select", "x.y) OR (1",
+	} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		if !utf8.ValidString(s) {
+			return
+		}
+		got := escapePath(s)
+		segs := strings.Split(s, ".")
+		if strings.Count(got, ".") < len(segs)-1 {
+			t.Fatalf("escapePath(%q) = %q dropped a segment", s, got)
+		}
+		for _, seg := range segs {
+			if seg == "" {
+				// Rejected by the lexer rather than silently collapsed
+				if _, err := lexDQL(got); err == nil {
+					t.Fatalf("escapePath(%q) = %q lexes despite an empty segment", s, got)
+				}
+				return
+			}
+		}
+		toks, err := lexDQL(got)
+		if err != nil {
+			t.Fatalf("escapePath(%q) = %q does not lex: %v", s, got, err)
+		}
+		if len(toks) != 2*len(segs)-1 {
+			t.Fatalf("escapePath(%q) = %q lexes as %+v", s, got, toks)
+		}
+		for i, tok := range toks {
+			if i%2 == 1 {
+				if tok.text != "." {
+					t.Fatalf("escapePath(%q) = %q: token %d is %q, want .", s, got, i, tok.text)
+				}
+				continue
+			}
+			if tok.kind != "ident" || tok.text != segs[i/2] {
+				t.Fatalf("escapePath(%q) = %q: token %d is %+v, want identifier %q", s, got, i, tok, segs[i/2])
+			}
+		}
+	})
+}
+
+func FuzzEscapeString(f *testing.F) {
+	for _, s := range []string{
+		"", "plain", `say "hi"`, `back\slash`, "line\nbreak", "tab\there", "\x00\x1f\x7f",
+		"  ", "“smart” ‘quotes’", "\xff\xfe", `\"`, `" OR 1==1 --`,
+	} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		got := escapeString(s)
+		lit := `"` + got + `"`
+		toks, err := lexDQL(lit)
+		if err != nil {
+			t.Fatalf("escapeString(%q) = %q does not lex: %v", s, got, err)
+		}
+		if len(toks) != 1 || toks[0].kind != "string" {
+			t.Fatalf("escapeString(%q) = %q lexes as %+v, want one string", s, got, toks)
+		}
+		back, err := strconv.Unquote(lit)
+		if err != nil {
+			t.Fatalf("escapeString(%q) = %q is not a valid literal: %v", s, got, err)
+		}
+		if want := strings.ToValidUTF8(s, "�"); back != want {
+			t.Fatalf("escapeString(%q) round-trips to %q, want %q", s, back, want)
+		}
+	})
+}
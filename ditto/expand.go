@@ -0,0 +1,71 @@
+package ditto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// RefSpec describes a reference field to expand: documents whose Field holds
+// an _id in Collection get the referenced document embedded under As.
+type RefSpec struct {
+	Field      string // field holding the referenced _id, e.g. "customer_id"
+	Collection string // collection the reference points into
+	As         string // key to embed under; defaults to Field without "_id"
+}
+
+// Expand performs client-side joins: for each spec it collects the distinct
+// reference ids across docs, fetches them with one GetRecordsByIDs call, and
+// embeds each referenced document under spec.As. Documents with a missing or
+// dangling reference are left without the key. docs are modified in place and
+// returned for convenience.
+func (s *service) Expand(ctx context.Context, docs []map[string]any, specs ...RefSpec) ([]map[string]any, error) {
+	for _, spec := range specs {
+		if spec.Field == "" || spec.Collection == "" {
+			return docs, errors.New("ref spec needs Field and Collection")
+		}
+		as := spec.As
+		if as == "" {
+			as = strings.TrimSuffix(strings.TrimSuffix(spec.Field, "_id"), "Id")
+			if as == "" || as == spec.Field {
+				as = spec.Field + "_doc"
+			}
+		}
+
+		// ids stands for distinct referenced ids, in first-seen order
+		var ids []string
+		seen := map[string]bool{}
+		for _, d := range docs {
+			ref, ok := d[spec.Field]
+			if !ok || ref == nil {
+				continue
+			}
+			id := fmt.Sprint(ref)
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) == 0 {
+			continue
+		}
+
+		res, err := s.GetRecordsByIDs(ctx, spec.Collection, ids)
+		if err != nil {
+			return docs, fmt.Errorf("expand %s: %w", spec.Field, err)
+		}
+		byID := map[string]map[string]any{}
+		for _, ref := range Documents(res) {
+			byID[fmt.Sprint(ref["_id"])] = ref
+		}
+		for _, d := range docs {
+			if ref, ok := d[spec.Field]; ok && ref != nil {
+				if target, ok := byID[fmt.Sprint(ref)]; ok {
+					d[as] = target
+				}
+			}
+		}
+	}
+	return docs, nil
+}
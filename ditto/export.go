@@ -0,0 +1,197 @@
+package ditto
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// maxImportLine bounds one NDJSON line read by ImportCollection.
+const maxImportLine = 16 << 20
+
+// ExportCollection writes every document of collection to w as NDJSON, one
+// document per line in _id order, and returns how many were written.
+// Soft-deleted documents are included so an import restores tombstones too.
+// WithExportCompression compresses the output, and with WithCheckpointFile
+// an interrupted export can be resumed.
+func (s *service) ExportCollection(ctx context.Context, collection string, w io.Writer) (int, error) {
+	ctx, cancel := s.opDeadline(ctx, opBulk)
+	defer cancel()
+	path := checkpointPath(ctx)
+	cp, err := loadCheckpoint(path, "export", collection)
+	if err != nil {
+		return 0, fmt.Errorf("export %s: %w", collection, err)
+	}
+	comp := exportCompression(ctx)
+	var where *Predicate
+	var offset int64
+	n := 0
+	if cp != nil {
+		if cp.Compression != compressionLabel(comp) {
+			return 0, fmt.Errorf("export %s: checkpoint %s was written with compression %q", collection, path, cp.Compression)
+		}
+		// Resume after the last document the checkpoint covers
+		if err := rewindOutput(w, cp.Offset); err != nil {
+			return 0, fmt.Errorf("export %s: %w", collection, err)
+		}
+		offset, n = cp.Offset, cp.Count
+		if cp.LastID != nil {
+			after := Where("_id > :after", map[string]any{"after": cp.LastID})
+			where = &after
+		}
+	} else if path != "" {
+		cp = &Checkpoint{Op: "export", Collection: collection, Compression: compressionLabel(comp)}
+	}
+	cw := &countingWriter{w: w}
+	var zw io.WriteCloser = nopWriteCloser{cw}
+	if comp != nil {
+		if zw, err = comp.NewWriter(cw); err != nil {
+			return 0, fmt.Errorf("export %s: %w", collection, err)
+		}
+	}
+	bw := bufio.NewWriter(zw)
+	enc := json.NewEncoder(bw)
+	prog := s.progress(ctx, s.countTotal(ctx, collection))
+	prog.add(n)
+	err = s.scanWhere(ctx, collection, where, QueryOptions{IncludeDeleted: true}, func(docs []map[string]any) error {
+		for _, d := range docs {
+			if err := enc.Encode(d); err != nil {
+				return err
+			}
+			n++
+		}
+		prog.add(len(docs))
+		if cp == nil {
+			return nil
+		}
+		// The page must be in w before the checkpoint claims it
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+		if comp != nil {
+			// End the compressed stream so a resume can append another
+			if err := zw.Close(); err != nil {
+				return err
+			}
+			var err error
+			if zw, err = comp.NewWriter(cw); err != nil {
+				return err
+			}
+			bw.Reset(zw)
+		}
+		cp.Count, cp.LastID, cp.Offset, cp.UpdatedAt = n, docs[len(docs)-1]["_id"], offset+cw.n, s.now()
+		return cp.save(path)
+	})
+	if err != nil {
+		return n, fmt.Errorf("export %s: %w", collection, err)
+	}
+	if err := bw.Flush(); err != nil {
+		return n, err
+	}
+	if err := zw.Close(); err != nil {
+		return n, err
+	}
+	prog.finish()
+	return n, removeCheckpoint(path)
+}
+
+// ImportCollection reads NDJSON documents from r and upserts them into
+// collection in batches, overwriting documents with the same _id. Blank
+// lines are skipped; every document must carry an _id. Input compressed
+// with Gzip or a codec added with RegisterCompression is recognized and
+// decompressed. With WithCheckpointFile an interrupted import can be resumed.
+func (s *service) ImportCollection(ctx context.Context, collection string, r io.Reader) (int, error) {
+	ctx, cancel := s.opDeadline(ctx, opBulk)
+	defer cancel()
+	path := checkpointPath(ctx)
+	cp, err := loadCheckpoint(path, "import", collection)
+	if err != nil {
+		return 0, fmt.Errorf("import %s: %w", collection, err)
+	}
+	skip, n := 0, 0
+	if cp != nil {
+		skip, n = cp.Line, cp.Count
+	} else if path != "" {
+		cp = &Checkpoint{Op: "import", Collection: collection}
+	}
+	rc, err := decompress(bufio.NewReader(r))
+	if err != nil {
+		return 0, fmt.Errorf("import %s: %w", collection, err)
+	}
+	defer rc.Close()
+	sc := bufio.NewScanner(rc)
+	sc.Buffer(make([]byte, 64*1024), maxImportLine)
+	var batch []map[string]any
+	prog := s.progress(ctx, knownTotal(-1))
+	prog.add(n)
+	line := 0
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := s.upsertDocs(ctx, collection, batch); err != nil {
+			return err
+		}
+		s.cache.invalidateCollection(collection)
+		n += len(batch)
+		prog.add(len(batch))
+		batch = batch[:0]
+		if cp == nil {
+			return nil
+		}
+		cp.Count, cp.Line, cp.UpdatedAt = n, line, s.now()
+		return cp.save(path)
+	}
+	for sc.Scan() {
+		line++
+		if line <= skip {
+			continue
+		}
+		doc, err := decodeNDJSONLine(sc.Bytes())
+		if err != nil {
+			return n, fmt.Errorf("import %s: line %d: %w", collection, line, err)
+		}
+		if doc == nil {
+			continue
+		}
+		if _, ok := doc["_id"]; !ok {
+			return n, fmt.Errorf("import %s: line %d: document without _id", collection, line)
+		}
+		batch = append(batch, doc)
+		if len(batch) == maxDocsPerInsert {
+			if err := flush(); err != nil {
+				return n, fmt.Errorf("import %s: %w", collection, err)
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return n, fmt.Errorf("import %s: %w", collection, err)
+	}
+	if err := flush(); err != nil {
+		return n, fmt.Errorf("import %s: %w", collection, err)
+	}
+	prog.finish()
+	return n, removeCheckpoint(path)
+}
+
+// decodeNDJSONLine decodes one JSON object, keeping numbers exact; blank
+// lines return nil.
+func decodeNDJSONLine(line []byte) (map[string]any, error) {
+	if len(bytes.TrimSpace(line)) == 0 {
+		return nil, nil
+	}
+	var doc map[string]any
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, errors.New("not a JSON object")
+	}
+	return doc, nil
+}
@@ -0,0 +1,157 @@
+package ditto
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Extract returns the values selected by a JSONPath-style expression from a
+// decoded response or document, in document order:
+//
+//	ditto.Extract(res, "$.items[*].payload.temperature")
+//
+// Supported syntax: $ (root), .name and ['name'] (field), [n] (array index,
+// negative counts from the end), [*] and .* (every element or field), and
+// ..name (name at any depth); the leading "$." may be left out. Steps that
+// don't match are skipped, so a missing field yields no values rather than
+// an error; only malformed expressions fail. Wildcards over objects visit
+// fields in key order.
+func Extract(v any, expr string) ([]any, error) {
+	steps, err := parsePath(expr)
+	if err != nil {
+		return nil, err
+	}
+	cur := []any{v}
+	for _, st := range steps {
+		var next []any
+		for _, c := range cur {
+			next = st.apply(c, next)
+		}
+		cur = next
+	}
+	return cur, nil
+}
+
+// pathStep is one step of an Extract expression.
+type pathStep struct {
+	field     string
+	index     int
+	kind      int // stepField, stepIndex, stepWildcard
+	recursive bool
+}
+
+const (
+	stepField = iota
+	stepIndex
+	stepWildcard
+)
+
+// apply appends the values st selects from v to out.
+func (st pathStep) apply(v any, out []any) []any {
+	switch st.kind {
+	case stepField:
+		if m, ok := v.(map[string]any); ok {
+			if x, ok := m[st.field]; ok {
+				out = append(out, x)
+			}
+		}
+	case stepIndex:
+		if arr, ok := v.([]any); ok {
+			i := st.index
+			if i < 0 {
+				i += len(arr)
+			}
+			if i >= 0 && i < len(arr) {
+				out = append(out, arr[i])
+			}
+		}
+	case stepWildcard:
+		switch x := v.(type) {
+		case []any:
+			out = append(out, x...)
+		case map[string]any:
+			for _, k := range sortedKeys(x) {
+				out = append(out, x[k])
+			}
+		}
+	}
+	if st.recursive {
+		switch x := v.(type) {
+		case []any:
+			for _, e := range x {
+				out = st.apply(e, out)
+			}
+		case map[string]any:
+			for _, k := range sortedKeys(x) {
+				out = st.apply(x[k], out)
+			}
+		}
+	}
+	return out
+}
+
+// parsePath parses an Extract expression into steps.
+func parsePath(expr string) ([]pathStep, error) {
+	fail := func(msg string) ([]pathStep, error) {
+		return nil, fmt.Errorf("extract %q: %s", expr, msg)
+	}
+	p := strings.TrimPrefix(strings.TrimSpace(expr), "$")
+	if p != "" && p[0] != '.' && p[0] != '[' {
+		p = "." + p // "items[0]" is short for "$.items[0]"
+	}
+	var steps []pathStep
+	for p != "" {
+		var st pathStep
+		switch {
+		case strings.HasPrefix(p, ".."):
+			st.recursive = true
+			p = p[2:]
+		case p[0] == '.':
+			p = p[1:]
+		case p[0] == '[':
+		default:
+			return fail("expected '.' or '[' at " + strconv.Quote(p))
+		}
+		if p == "" {
+			return fail("missing name after '.'")
+		}
+		if p[0] == '[' {
+			end := strings.IndexByte(p, ']')
+			if end < 0 {
+				return fail("unterminated '['")
+			}
+			in := strings.TrimSpace(p[1:end])
+			switch {
+			case in == "*":
+				st.kind = stepWildcard
+			case len(in) >= 2 && (in[0] == '\'' || in[0] == '"') && in[len(in)-1] == in[0]:
+				st.kind, st.field = stepField, in[1:len(in)-1]
+			default:
+				n, err := strconv.Atoi(in)
+				if err != nil {
+					return fail("bad index " + strconv.Quote(in))
+				}
+				st.kind, st.index = stepIndex, n
+			}
+			p = p[end+1:]
+		} else {
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			name := p[:end]
+			if name == "" {
+				return fail("empty field name")
+			}
+			if name == "*" {
+				st.kind = stepWildcard
+			} else {
+				st.kind, st.field = stepField, name
+			}
+			p = p[end:]
+		}
+		steps = append(steps, st)
+	}
+	return steps, nil
+}
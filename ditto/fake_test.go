@@ -0,0 +1,101 @@
+package ditto
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeExecute is an /execute endpoint that answers each statement with
+// handle and records what it was sent. An error from handle is sent as a
+// 500 response.
+type fakeExecute struct {
+	mu      sync.Mutex
+	queries []string
+	handle  func(query string, args map[string]any) any
+}
+
+// newFakeService returns a service talking to a fakeExecute server.
+func newFakeService(t *testing.T, handle func(query string, args map[string]any) any) (*service, *fakeExecute) {
+	t.Helper()
+	f := &fakeExecute{handle: handle}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Query string         `json:"query"`
+			Args  map[string]any `json:"query_args"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		f.queries = append(f.queries, body.Query)
+		f.mu.Unlock()
+		res := f.handle(body.Query, body.Args)
+		if err, ok := res.(error); ok {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if res == nil {
+			res = map[string]any{"items": []any{}}
+		}
+		_ = json.NewEncoder(w).Encode(res)
+	}))
+	t.Cleanup(srv.Close)
+	return NewService(srv.URL, "app"), f
+}
+
+// sent returns the statements received so far.
+func (f *fakeExecute) sent() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.queries...)
+}
+
+// items wraps documents in an /execute response.
+func items(docs ...map[string]any) map[string]any {
+	out := make([]any, len(docs))
+	for i, d := range docs {
+		out[i] = d
+	}
+	return map[string]any{"items": out}
+}
+
+// fakeClock is a Clock and Sleeper whose time only moves when advanced or
+// slept on, recording every sleep.
+type fakeClock struct {
+	mu     sync.Mutex
+	t      time.Time
+	sleeps []time.Duration
+}
+
+func newFakeClock(t time.Time) *fakeClock { return &fakeClock{t: t} }
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+// Advance moves the clock forward by d.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+// Sleep advances the clock by d instead of waiting.
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sleeps = append(c.sleeps, d)
+	c.t = c.t.Add(d)
+	return nil
+}
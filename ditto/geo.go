@@ -0,0 +1,130 @@
+package ditto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// earthRadiusMeters is the mean Earth radius used by Haversine.
+const earthRadiusMeters = 6371008.8
+
+// GeoPoint is a WGS84 latitude/longitude in degrees.
+type GeoPoint struct {
+	Lat float64
+	Lng float64
+}
+
+// GeoResult is a document returned by WithinRadius with its distance from the
+// query center.
+type GeoResult struct {
+	Doc            map[string]any
+	DistanceMeters float64
+}
+
+// Haversine returns the great-circle distance between a and b in meters.
+func Haversine(a, b GeoPoint) float64 {
+	lat1, lat2 := a.Lat*math.Pi/180, b.Lat*math.Pi/180
+	dLat := lat2 - lat1
+	dLng := (b.Lng - a.Lng) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// BoundingBox returns a predicate matching documents whose latField/lngField
+// lie within the box spanned by sw (south-west) and ne (north-east). A box
+// with sw.Lng greater than ne.Lng crosses the antimeridian and matches
+// longitudes from sw.Lng to 180 or from -180 to ne.Lng.
+func BoundingBox(latField, lngField string, sw, ne GeoPoint) Predicate {
+	lat, lng := escapePath(latField), escapePath(lngField)
+	lngClause := "%s >= :geo_min_lng AND %s <= :geo_max_lng"
+	if sw.Lng > ne.Lng {
+		lngClause = "(%s >= :geo_min_lng OR %s <= :geo_max_lng)"
+	}
+	return Predicate{
+		Clause: fmt.Sprintf(
+			"%s >= :geo_min_lat AND %s <= :geo_max_lat AND "+lngClause,
+			lat, lat, lng, lng,
+		),
+		Args: map[string]any{
+			"geo_min_lat": sw.Lat, "geo_max_lat": ne.Lat,
+			"geo_min_lng": sw.Lng, "geo_max_lng": ne.Lng,
+		},
+	}
+}
+
+// boxAround returns the south-west and north-east corners of a box that
+// contains every point within radius meters of center. Longitudes wrap at
+// the antimeridian, so sw.Lng > ne.Lng when the box crosses it; a box that
+// reaches a pole spans every longitude.
+func boxAround(center GeoPoint, radius float64) (GeoPoint, GeoPoint) {
+	dLat := radius / earthRadiusMeters * 180 / math.Pi
+	sw := GeoPoint{Lat: math.Max(-90, center.Lat-dLat), Lng: -180}
+	ne := GeoPoint{Lat: math.Min(90, center.Lat+dLat), Lng: 180}
+	cosLat := math.Cos(center.Lat * math.Pi / 180)
+	if sw.Lat == -90 || ne.Lat == 90 || cosLat <= 1e-9 {
+		return sw, ne
+	}
+	dLng := dLat / cosLat
+	if dLng >= 180 {
+		return sw, ne
+	}
+	sw.Lng, ne.Lng = wrapLng(center.Lng-dLng), wrapLng(center.Lng+dLng)
+	return sw, ne
+}
+
+// wrapLng maps a longitude in degrees into [-180, 180].
+func wrapLng(lng float64) float64 {
+	switch {
+	case lng < -180:
+		return lng + 360
+	case lng > 180:
+		return lng - 360
+	}
+	return lng
+}
+
+// WithinRadius returns documents whose latField/lngField lie within radius
+// meters of center, nearest first. DQL has no geo operators, so a bounding
+// box is queried server-side and the exact haversine filter and ordering are
+// applied client-side. QueryOptions.Limit caps the returned results after
+// distance filtering.
+func (s *service) WithinRadius(
+	ctx context.Context,
+	collection, latField, lngField string,
+	center GeoPoint,
+	radiusMeters float64,
+	opts ...QueryOptions,
+) ([]GeoResult, error) {
+	if radiusMeters <= 0 {
+		return nil, errors.New("radius must be positive")
+	}
+	o := mergeQueryOptions(opts)
+	limit := o.Limit
+	o.Limit, o.Offset = 0, 0 // applied after the exact distance filter
+
+	sw, ne := boxAround(center, radiusMeters)
+	res, err := s.FindWhere(ctx, collection, BoundingBox(latField, lngField, sw, ne), o)
+	if err != nil {
+		return nil, err
+	}
+	var out []GeoResult
+	for _, d := range Documents(res) {
+		lat, okLat := toFloat(lookupPath(d, latField))
+		lng, okLng := toFloat(lookupPath(d, lngField))
+		if !okLat || !okLng {
+			continue
+		}
+		if dist := Haversine(center, GeoPoint{Lat: lat, Lng: lng}); dist <= radiusMeters {
+			out = append(out, GeoResult{Doc: d, DistanceMeters: dist})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].DistanceMeters < out[j].DistanceMeters })
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
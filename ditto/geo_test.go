@@ -0,0 +1,41 @@
+package ditto
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBoxAroundAntimeridian(t *testing.T) {
+	tests := []struct {
+		name   string
+		center GeoPoint
+		near   GeoPoint // just across the antimeridian from center
+	}{
+		{"east of it", GeoPoint{Lat: -17, Lng: 179.99}, GeoPoint{Lat: -17, Lng: -179.99}},
+		{"west of it", GeoPoint{Lat: 65, Lng: -179.95}, GeoPoint{Lat: 65, Lng: 179.98}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			radius := 2 * Haversine(tt.center, tt.near)
+			sw, ne := boxAround(tt.center, radius)
+			if sw.Lng <= ne.Lng {
+				t.Fatalf("box %v-%v does not cross the antimeridian", sw, ne)
+			}
+			for _, p := range []GeoPoint{tt.center, tt.near} {
+				if p.Lng < sw.Lng && p.Lng > ne.Lng {
+					t.Errorf("box %v-%v misses %v", sw, ne, p)
+				}
+			}
+			if p := BoundingBox("lat", "lng", sw, ne); !strings.Contains(p.Clause, "(lng >= :geo_min_lng OR lng <= :geo_max_lng)") {
+				t.Errorf("clause %q does not split the longitude range", p.Clause)
+			}
+		})
+	}
+}
+
+func TestBoxAroundPole(t *testing.T) {
+	sw, ne := boxAround(GeoPoint{Lat: 89.99, Lng: 10}, 5000)
+	if sw.Lng != -180 || ne.Lng != 180 || ne.Lat != 90 {
+		t.Fatalf("box %v-%v, want every longitude up to the pole", sw, ne)
+	}
+}
@@ -0,0 +1,96 @@
+package ditto
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Get returns the value at path inside doc. Paths are dotted field names
+// with optional array indexes, e.g. "meta.created_at" or
+// "readings[0].value". It reports false when any step is missing or has the
+// wrong shape.
+func Get(doc map[string]any, path string) (any, bool) {
+	var cur any = doc
+	for _, seg := range strings.Split(path, ".") {
+		name, idx, ok := splitIndexes(seg)
+		if !ok {
+			return nil, false
+		}
+		if name != "" {
+			m, ok := cur.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			if cur, ok = m[name]; !ok {
+				return nil, false
+			}
+		}
+		for _, i := range idx {
+			arr, ok := cur.([]any)
+			if !ok || i >= len(arr) {
+				return nil, false
+			}
+			cur = arr[i]
+		}
+	}
+	return cur, true
+}
+
+// GetString returns the string at path inside doc.
+func GetString(doc map[string]any, path string) (string, bool) {
+	v, _ := Get(doc, path)
+	s, ok := v.(string)
+	return s, ok
+}
+
+// GetInt64 returns the integer at path inside doc. Whole float64 values and
+// json.Number (see WithPreciseNumbers) are accepted; fractions are not.
+func GetInt64(doc map[string]any, path string) (int64, bool) {
+	v, _ := Get(doc, path)
+	return toInt64(v)
+}
+
+// GetFloat64 returns the number at path inside doc.
+func GetFloat64(doc map[string]any, path string) (float64, bool) {
+	v, _ := Get(doc, path)
+	return toFloat(v)
+}
+
+// GetBool returns the boolean at path inside doc.
+func GetBool(doc map[string]any, path string) (bool, bool) {
+	v, _ := Get(doc, path)
+	b, ok := v.(bool)
+	return b, ok
+}
+
+// GetTime returns the timestamp at path inside doc, parsed as by
+// ParseTimestamp.
+func GetTime(doc map[string]any, path string) (time.Time, bool) {
+	v, _ := Get(doc, path)
+	return ParseTimestamp(v)
+}
+
+// splitIndexes splits a path segment like "readings[0][1]" into its field
+// name and array indexes.
+func splitIndexes(seg string) (string, []int, bool) {
+	i := strings.IndexByte(seg, '[')
+	if i < 0 {
+		return seg, nil, seg != ""
+	}
+	name, rest := seg[:i], seg[i:]
+	var idx []int
+	for rest != "" {
+		end := strings.IndexByte(rest, ']')
+		if rest[0] != '[' || end < 0 {
+			return "", nil, false
+		}
+		n, err := strconv.Atoi(rest[1:end])
+		if err != nil || n < 0 {
+			return "", nil, false
+		}
+		idx = append(idx, n)
+		rest = rest[end+1:]
+	}
+	return name, idx, true
+}
@@ -0,0 +1,172 @@
+package ditto
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// HashDocument returns the hex SHA-256 of doc's canonical encoding: object
+// keys sorted, numbers normalized so 1, 1.0, and json.Number("1") agree,
+// no insignificant whitespace. Two documents hash equally exactly when they
+// hold the same data, whichever decoding mode produced them.
+func HashDocument(doc map[string]any) (string, error) {
+	b, err := CanonicalJSON(doc)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// CanonicalJSON returns the canonical encoding hashed by HashDocument.
+func CanonicalJSON(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeCanonical(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// HashCollection returns a Merkle-style digest of collection, tombstones
+// included: each leaf hashes an _id with its document hash, leaves are
+// ordered by _id, and pairs are hashed up to a single root. Equal roots mean
+// equal collections; the empty collection hashes to the digest of nothing.
+func (s *service) HashCollection(ctx context.Context, collection string) (string, error) {
+	if collection == "" {
+		return "", errors.New("collection required")
+	}
+	var leaves [][]byte
+	err := s.scanWhere(ctx, collection, nil, QueryOptions{IncludeDeleted: true}, func(docs []map[string]any) error {
+		for _, d := range docs {
+			var buf bytes.Buffer
+			fmt.Fprintf(&buf, "%v\x00", d["_id"])
+			if err := writeCanonical(&buf, d); err != nil {
+				return err
+			}
+			sum := sha256.Sum256(buf.Bytes())
+			leaves = append(leaves, sum[:])
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("hash %s: %w", collection, err)
+	}
+	return hex.EncodeToString(merkleRoot(leaves)), nil
+}
+
+// merkleRoot folds leaves pairwise until one hash remains; an odd node is
+// carried up unchanged.
+func merkleRoot(level [][]byte) []byte {
+	if len(level) == 0 {
+		sum := sha256.Sum256(nil)
+		return sum[:]
+	}
+	for len(level) > 1 {
+		var next [][]byte
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			h := sha256.New()
+			h.Write(level[i])
+			h.Write(level[i+1])
+			next = append(next, h.Sum(nil))
+		}
+		level = next
+	}
+	return level[0]
+}
+
+// writeCanonical encodes v canonically into w.
+func writeCanonical(w *bytes.Buffer, v any) error {
+	switch x := v.(type) {
+	case nil:
+		w.WriteString("null")
+	case bool:
+		w.WriteString(strconv.FormatBool(x))
+	case string:
+		b, _ := json.Marshal(x)
+		w.Write(b)
+	case json.Number, float64, float32, int, int64, int32:
+		s, err := canonicalNumber(x)
+		if err != nil {
+			return err
+		}
+		w.WriteString(s)
+	case map[string]any:
+		w.WriteString("{")
+		for i, k := range sortedKeys(x) {
+			if i > 0 {
+				w.WriteString(",")
+			}
+			b, _ := json.Marshal(k)
+			w.Write(b)
+			w.WriteString(":")
+			if err := writeCanonical(w, x[k]); err != nil {
+				return err
+			}
+		}
+		w.WriteString("}")
+	case []any:
+		w.WriteString("[")
+		for i, e := range x {
+			if i > 0 {
+				w.WriteString(",")
+			}
+			if err := writeCanonical(w, e); err != nil {
+				return err
+			}
+		}
+		w.WriteString("]")
+	default:
+		// Typed values (structs, typed slices) go through their JSON form
+		b, err := json.Marshal(x)
+		if err != nil {
+			return err
+		}
+		var generic any
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		if err := dec.Decode(&generic); err != nil {
+			return err
+		}
+		return writeCanonical(w, generic)
+	}
+	return nil
+}
+
+// canonicalNumber renders integral values without a fraction or exponent
+// and everything else in the shortest round-tripping form.
+func canonicalNumber(v any) (string, error) {
+	switch n := v.(type) {
+	case int:
+		return strconv.Itoa(n), nil
+	case int32:
+		return strconv.FormatInt(int64(n), 10), nil
+	case int64:
+		return strconv.FormatInt(n, 10), nil
+	case float32:
+		v = float64(n)
+	case json.Number:
+		// Exact digits when it fits; floats beyond 2^53 are not exact anyway
+		if i, err := n.Int64(); err == nil {
+			return strconv.FormatInt(i, 10), nil
+		}
+	}
+	f, ok := toFloat(v)
+	if !ok || math.IsInf(f, 0) || math.IsNaN(f) {
+		return "", fmt.Errorf("cannot hash number %v", v)
+	}
+	if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+		return strconv.FormatInt(int64(f), 10), nil
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64), nil
+}
@@ -0,0 +1,161 @@
+package ditto
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+// hedging holds the WithHedgedReads settings and counters.
+type hedging struct {
+	delay     time.Duration
+	secondary string // base URL for the duplicate; empty means the primary
+	sent      atomic.Int64
+	won       atomic.Int64
+}
+
+// WithHedgedReads enables hedged reads for lossy, high-latency links: when
+// a SELECT has not answered after delay, a duplicate is sent (to secondary,
+// a base URL such as a second edge node, or to the same endpoint when
+// secondary is empty) and the first usable response wins; the other request
+// is cancelled. Writes are never hedged. A response with a status below 500
+// counts as usable, so a 4xx is returned as usual rather than waiting for
+// the duplicate. Status reports how many hedges were sent and won.
+func (s *service) WithHedgedReads(delay time.Duration, secondary string) *service {
+	s.hedge = &hedging{delay: delay, secondary: secondary}
+	return s
+}
+
+// send performs req, hedging it when enabled and query is a read.
+func (s *service) send(req *http.Request, query string) (*http.Response, error) {
+	if s.hedge == nil || !isReadStatement(query) {
+		return s.HTTP.Do(req)
+	}
+	dup, err := s.hedgeRequest(req)
+	if err != nil {
+		return s.HTTP.Do(req)
+	}
+	return s.hedgedDo(req, dup)
+}
+
+// hedgeAttempt is the outcome of one of the hedged requests.
+type hedgeAttempt struct {
+	resp   *http.Response
+	err    error
+	cancel context.CancelFunc
+	hedge  bool
+}
+
+// usable reports whether a should win the race.
+func (a hedgeAttempt) usable() bool {
+	return a.err == nil && a.resp.StatusCode < 500
+}
+
+// discard releases a losing attempt.
+func (a hedgeAttempt) discard() {
+	if a.resp != nil {
+		a.resp.Body.Close()
+	}
+	a.cancel()
+}
+
+// hedgedDo sends req and, after the hedge delay, dup, and returns the first
+// usable response. If an attempt fails while the other is still running the
+// other one is awaited; a primary that fails before the delay is returned
+// without hedging.
+func (s *service) hedgedDo(req, dup *http.Request) (*http.Response, error) {
+	h := s.hedge
+	results := make(chan hedgeAttempt, 2)
+	do := func(r *http.Request, hedge bool) {
+		ctx, cancel := context.WithCancel(req.Context())
+		resp, err := s.HTTP.Do(r.WithContext(ctx))
+		results <- hedgeAttempt{resp: resp, err: err, cancel: cancel, hedge: hedge}
+	}
+	wait, stopWait := context.WithCancel(req.Context())
+	defer stopWait()
+	fire := make(chan struct{})
+	go func() {
+		if s.sleep(wait, h.delay) == nil {
+			close(fire)
+		}
+	}()
+	go do(req, false)
+	pending := 1
+	for {
+		select {
+		case <-fire:
+			fire = nil
+			pending++
+			h.sent.Add(1)
+			go do(dup, true)
+		case a := <-results:
+			pending--
+			if !a.usable() && pending > 0 {
+				a.discard()
+				continue
+			}
+			// Cancel and drain the loser without holding up the caller
+			go func(n int) {
+				for ; n > 0; n-- {
+					(<-results).discard()
+				}
+			}(pending)
+			if a.err != nil {
+				a.cancel()
+				return nil, a.err
+			}
+			if a.hedge {
+				h.won.Add(1)
+			}
+			a.resp.Body = cancelOnClose{ReadCloser: a.resp.Body, cancel: a.cancel}
+			return a.resp, nil
+		}
+	}
+}
+
+// hedgeRequest copies req for the duplicate, retargeted at the secondary
+// endpoint when one is set.
+func (s *service) hedgeRequest(req *http.Request) (*http.Request, error) {
+	dup := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		dup.Body = body
+	}
+	if s.hedge.secondary != "" {
+		u, err := url.Parse(executeURLAt(s.hedge.secondary, s.AppID))
+		if err != nil {
+			return nil, err
+		}
+		dup.URL = u
+		dup.Host = ""
+	}
+	return dup, nil
+}
+
+// hedgeStatus reports the hedging counters for Status.
+func (s *service) hedgeStatus() map[string]any {
+	return map[string]any{
+		"delay": s.hedge.delay.String(),
+		"sent":  s.hedge.sent.Load(),
+		"won":   s.hedge.won.Load(),
+	}
+}
+
+// cancelOnClose releases the winning request's context once the caller
+// has read the body.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
@@ -0,0 +1,397 @@
+package ditto
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrIngestorClosed is returned by Add after the Ingestor is closed.
+var ErrIngestorClosed = errors.New("ditto: ingestor closed")
+
+// OverflowPolicy selects what Ingestor.Add does when the buffer is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock makes Add wait for a flush to free space.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest discards the oldest buffered document.
+	OverflowDropOldest
+	// OverflowSpill appends the document to IngestOptions.SpillPath; spilled
+	// documents are inserted once the buffer has drained.
+	OverflowSpill
+)
+
+// IngestOptions configures an Ingestor.
+type IngestOptions struct {
+	Capacity      int            // documents held in memory; defaults to 10000
+	BatchSize     int            // documents per INSERT; defaults to 500
+	FlushInterval time.Duration  // background flush period; defaults to 1s
+	Overflow      OverflowPolicy // behaviour when Capacity is reached
+	SpillPath     string         // NDJSON file for OverflowSpill
+	// OnError receives background flush errors; nil logs them. Failed
+	// batches stay buffered and are retried on the next flush.
+	OnError func(err error)
+}
+
+// IngestStats reports an Ingestor's counters.
+type IngestStats struct {
+	Buffered int   // documents currently in memory
+	Accepted int64 // documents accepted by Add
+	Flushed  int64 // documents inserted
+	Dropped  int64 // documents discarded by OverflowDropOldest
+	Spilled  int64 // documents written to the spill file
+	Failed   int64 // failed flush attempts
+}
+
+// Ingestor buffers documents in front of batched inserts into one
+// collection, absorbing bursts (e.g. sensor readings) with bounded memory.
+// Inserts use ON ID CONFLICT DO NOTHING, so a retried batch does not
+// overwrite documents that already landed.
+type Ingestor struct {
+	s          *service
+	collection string
+	opts       IngestOptions
+
+	flushMu sync.Mutex // serializes flushes
+
+	mu      sync.Mutex
+	buf     []map[string]any
+	space   chan struct{} // closed and replaced when a flush frees space
+	spill   *os.File
+	pending int // documents in the spill file not yet handed to a flush
+	stats   IngestStats
+	closed  bool
+
+	wake chan struct{} // requests an early flush
+	stop chan struct{} // closed by Close
+	done chan struct{} // closed when the flush loop exits
+}
+
+// NewIngestor starts an Ingestor for collection. Its flush loop runs until
+// the Ingestor or the service is closed; the service's Close closes the
+// Ingestor first, flushing what is still buffered.
+func (s *service) NewIngestor(collection string, opts IngestOptions) (*Ingestor, error) {
+	if collection == "" {
+		return nil, errors.New("collection required")
+	}
+	if opts.Capacity <= 0 {
+		opts.Capacity = 10000
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = maxDocsPerInsert
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	if opts.Overflow == OverflowSpill && opts.SpillPath == "" {
+		return nil, errors.New("OverflowSpill needs SpillPath")
+	}
+	in := &Ingestor{
+		s:          s,
+		collection: collection,
+		opts:       opts,
+		space:      make(chan struct{}),
+		wake:       make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	if opts.Overflow == OverflowSpill {
+		// Documents spilled before a restart are picked up by the first flush
+		if st, err := os.Stat(opts.SpillPath); err == nil && st.Size() > 0 {
+			in.pending = 1
+		}
+	}
+	s.goBackground("ingest:"+collection, in.loop)
+	s.OnClose("ingest:"+collection, in.Close)
+	return in, nil
+}
+
+// loop flushes every FlushInterval, or early when a batch fills up. done is
+// closed only on a clean exit, since a panicking loop is restarted.
+func (in *Ingestor) loop(ctx context.Context) {
+	for {
+		wait, cancel := context.WithCancel(ctx)
+		go func() {
+			select {
+			case <-in.wake:
+			case <-in.stop:
+			case <-wait.Done():
+			}
+			cancel()
+		}()
+		_ = in.s.sleep(wait, in.opts.FlushInterval)
+		cancel()
+		select {
+		case <-ctx.Done():
+			close(in.done)
+			return
+		case <-in.stop:
+			close(in.done)
+			return
+		default:
+		}
+		if err := in.Flush(ctx); err != nil && ctx.Err() == nil {
+			if in.opts.OnError != nil {
+				in.opts.OnError(err)
+			} else {
+				in.s.log().Warn("ditto ingest flush failed", "collection", in.collection, "error", err)
+			}
+		}
+	}
+}
+
+// kick asks the flush loop to run now.
+func (in *Ingestor) kick() {
+	select {
+	case in.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Add buffers doc for insertion. When the buffer is full the overflow
+// policy applies; with OverflowBlock, Add waits until space frees or ctx is
+// done.
+func (in *Ingestor) Add(ctx context.Context, doc map[string]any) error {
+	for {
+		in.mu.Lock()
+		if in.closed {
+			in.mu.Unlock()
+			return ErrIngestorClosed
+		}
+		if len(in.buf) < in.opts.Capacity {
+			in.buf = append(in.buf, doc)
+			in.stats.Accepted++
+			full := len(in.buf) >= in.opts.BatchSize
+			in.mu.Unlock()
+			if full {
+				in.kick()
+			}
+			return nil
+		}
+		switch in.opts.Overflow {
+		case OverflowDropOldest:
+			in.buf = append(in.buf[1:], doc)
+			in.stats.Accepted++
+			in.stats.Dropped++
+			in.mu.Unlock()
+			in.kick()
+			return nil
+		case OverflowSpill:
+			err := in.spillLocked(doc)
+			if err == nil {
+				in.stats.Accepted++
+				in.stats.Spilled++
+			}
+			in.mu.Unlock()
+			in.kick()
+			return err
+		}
+		space := in.space
+		in.mu.Unlock()
+		in.kick()
+		select {
+		case <-space:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// spillLocked appends doc to the spill file. in.mu must be held.
+func (in *Ingestor) spillLocked(doc map[string]any) error {
+	line, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("ingest spill: %w", err)
+	}
+	if in.spill == nil {
+		f, err := os.OpenFile(in.opts.SpillPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("ingest spill: %w", err)
+		}
+		in.spill = f
+	}
+	if _, err := in.spill.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("ingest spill: %w", err)
+	}
+	in.pending++
+	return nil
+}
+
+// Flush inserts everything buffered in memory, then any spilled documents.
+// A failed batch is returned to the front of the buffer.
+func (in *Ingestor) Flush(ctx context.Context) error {
+	in.flushMu.Lock()
+	defer in.flushMu.Unlock()
+	for {
+		in.mu.Lock()
+		n := min(len(in.buf), in.opts.BatchSize)
+		batch := in.buf[:n:n]
+		in.buf = in.buf[n:]
+		in.mu.Unlock()
+		if n == 0 {
+			break
+		}
+		if err := in.insert(ctx, batch); err != nil {
+			in.mu.Lock()
+			in.buf = append(batch, in.buf...)
+			in.mu.Unlock()
+			return err
+		}
+		in.freed()
+	}
+	return in.drainSpill(ctx)
+}
+
+// insert writes one batch and updates the counters.
+func (in *Ingestor) insert(ctx context.Context, batch []map[string]any) error {
+	err := in.s.checkInsertQuota(ctx, in.collection, batch...)
+	if err == nil {
+		err = insertDocs(ctx, in.s, in.collection, batch, "DO NOTHING")
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if err != nil {
+		in.stats.Failed++
+		return fmt.Errorf("ingest %s: %w", in.collection, err)
+	}
+	in.stats.Flushed += int64(len(batch))
+	return nil
+}
+
+// freed wakes Adds blocked on a full buffer.
+func (in *Ingestor) freed() {
+	in.mu.Lock()
+	close(in.space)
+	in.space = make(chan struct{})
+	in.mu.Unlock()
+}
+
+// drainSpill inserts spilled documents. The spill file is first renamed so
+// Adds can keep spilling; if a batch fails, the unsent remainder is kept in
+// the renamed file for the next flush.
+func (in *Ingestor) drainSpill(ctx context.Context) error {
+	if in.opts.Overflow != OverflowSpill {
+		return nil
+	}
+	draining := in.opts.SpillPath + ".draining"
+	if _, err := os.Stat(draining); errors.Is(err, os.ErrNotExist) {
+		in.mu.Lock()
+		if in.pending == 0 {
+			in.mu.Unlock()
+			return nil
+		}
+		if in.spill != nil {
+			in.spill.Close()
+			in.spill = nil
+		}
+		err := os.Rename(in.opts.SpillPath, draining)
+		if err == nil || errors.Is(err, os.ErrNotExist) {
+			in.pending = 0
+		}
+		in.mu.Unlock()
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("ingest spill: %w", err)
+		}
+	}
+
+	f, err := os.Open(draining)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("ingest spill: %w", err)
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	var consumed int64
+	for eof := false; !eof; {
+		var batch []map[string]any
+		var size int64
+		for len(batch) < in.opts.BatchSize && !eof {
+			line, err := r.ReadBytes('\n')
+			size += int64(len(line))
+			eof = err != nil
+			dec := json.NewDecoder(bytes.NewReader(line))
+			dec.UseNumber()
+			var d map[string]any
+			// A torn last line from a crash mid-spill is skipped
+			if dec.Decode(&d) == nil {
+				batch = append(batch, d)
+			}
+		}
+		if len(batch) > 0 {
+			if err := in.insert(ctx, batch); err != nil {
+				return errors.Join(err, keepRemainder(f, draining, consumed))
+			}
+		}
+		consumed += size
+	}
+	f.Close()
+	return os.Remove(draining)
+}
+
+// keepRemainder rewrites path with the bytes of f from offset on.
+func keepRemainder(f *os.File, path string, offset int64) error {
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, f); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Stats returns the Ingestor's counters.
+func (in *Ingestor) Stats() IngestStats {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	st := in.stats
+	st.Buffered = len(in.buf)
+	return st
+}
+
+// Close stops the flush loop and flushes what remains. Add returns
+// ErrIngestorClosed afterwards.
+func (in *Ingestor) Close(ctx context.Context) error {
+	in.mu.Lock()
+	if in.closed {
+		in.mu.Unlock()
+		return nil
+	}
+	in.closed = true
+	close(in.stop)
+	in.mu.Unlock()
+	in.freed() // release Adds blocked on a full buffer
+	select {
+	case <-in.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	err := in.Flush(ctx)
+	in.mu.Lock()
+	if in.spill != nil {
+		in.spill.Close()
+		in.spill = nil
+	}
+	in.mu.Unlock()
+	return err
+}
@@ -0,0 +1,94 @@
+package ditto
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+)
+
+// isolation records the per-instance resources allocated for an Isolated
+// DockerOptions so Close can release them.
+type isolation struct {
+	containerName string
+	dataDir       string
+	// original settings restored on cleanup so a later InitDB re-isolates
+	origName     string
+	origDataPath string
+	origBaseURL  string
+}
+
+// isolate derives a unique container name, a free localhost port, and a
+// private data directory for this service instance, then points BaseURL at
+// the chosen port. The data directory is created under DataPath when set, or
+// under the system temp directory otherwise. This lets several test packages
+// run their own Ditto containers on one host without clashing.
+func (s *service) isolate() error {
+	// suffix stands for random container name suffix
+	buf := make([]byte, 4)
+	if _, err := s.random().Read(buf); err != nil {
+		return fmt.Errorf("random suffix: %w", err)
+	}
+	suffix := hex.EncodeToString(buf)
+
+	name := s.dockerOpts.ContainerName
+	if name == "" {
+		name = "ditto-edge"
+	}
+	name = fmt.Sprintf("%s-%s", name, suffix)
+
+	port, err := freePort()
+	if err != nil {
+		return fmt.Errorf("free port: %w", err)
+	}
+
+	iso := &isolation{
+		containerName: name,
+		origName:      s.dockerOpts.ContainerName,
+		origDataPath:  s.dockerOpts.DataPath,
+		origBaseURL:   s.baseURL(),
+	}
+	// Instances sharing the caller's DataPath each get their own subdirectory
+	if parent := s.dockerOpts.DataPath; parent != "" {
+		if err := os.MkdirAll(parent, 0o755); err != nil {
+			return fmt.Errorf("data dir: %w", err)
+		}
+	}
+	dir, err := os.MkdirTemp(s.dockerOpts.DataPath, "ditto-data-"+suffix+"-")
+	if err != nil {
+		return fmt.Errorf("data dir: %w", err)
+	}
+	iso.dataDir = dir
+	s.dockerOpts.DataPath = dir
+
+	s.dockerOpts.ContainerName = name
+	s.dockerOpts.HostPort = port
+	s.setBaseURL(fmt.Sprintf("http://127.0.0.1:%d", port))
+	s.isolation = iso
+	return nil
+}
+
+// cleanupIsolation removes the isolated container and the data directory
+// created for it. Errors are ignored, matching Close.
+func (s *service) cleanupIsolation(ctx context.Context) {
+	if s.docker != nil {
+		_ = s.docker.RemoveContainer(ctx, s.isolation.containerName)
+	}
+	_ = os.RemoveAll(s.isolation.dataDir)
+	s.dockerOpts.ContainerName = s.isolation.origName
+	s.dockerOpts.DataPath = s.isolation.origDataPath
+	s.dockerOpts.HostPort = 0
+	s.setBaseURL(s.isolation.origBaseURL)
+	s.isolation = nil
+}
+
+// freePort asks the kernel for an unused localhost TCP port.
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,163 @@
+package ditto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// jsonSchemaDraft is the $schema URI written by InferSchema.
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// defaultSchemaSample is how many documents InferSchema reads when the
+// caller passes a non-positive sample size.
+const defaultSchemaSample = 1000
+
+// JSONSchema is the subset of JSON Schema produced by InferSchema. Type is a
+// single type name, or a list when a field holds values of several types
+// (including "null").
+type JSONSchema struct {
+	Schema     string                 `json:"$schema,omitempty"`
+	Title      string                 `json:"title,omitempty"`
+	Type       any                    `json:"type,omitempty"`
+	Format     string                 `json:"format,omitempty"`
+	Properties map[string]*JSONSchema `json:"properties,omitempty"`
+	Required   []string               `json:"required,omitempty"`
+	Items      *JSONSchema            `json:"items,omitempty"`
+}
+
+// WriteFile writes the schema as indented JSON to path.
+func (js *JSONSchema) WriteFile(path string) error {
+	b, err := json.MarshalIndent(js, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+// InferSchema samples up to sample documents of collection and infers a JSON
+// Schema describing them: the types seen for each field, nested objects and
+// array items, date-time strings, and which fields every sampled document has
+// (required).
+func (s *service) InferSchema(ctx context.Context, collection string, sample int) (*JSONSchema, error) {
+	if collection == "" {
+		return nil, errors.New("collection required")
+	}
+	if sample <= 0 {
+		sample = defaultSchemaSample
+	}
+	res, err := s.FindRecords(ctx, collection, nil, QueryOptions{Limit: sample})
+	if err != nil {
+		return nil, fmt.Errorf("infer schema %s: %w", collection, err)
+	}
+	js := InferSchemaFromDocs(Documents(res))
+	js.Schema = jsonSchemaDraft
+	js.Title = collection
+	return js, nil
+}
+
+// InferSchemaFromDocs infers a JSON Schema from already loaded documents.
+func InferSchemaFromDocs(docs []map[string]any) *JSONSchema {
+	var st shapeStats
+	for _, d := range docs {
+		st.add(d)
+	}
+	if len(docs) == 0 {
+		return &JSONSchema{Type: "object"}
+	}
+	return st.schema()
+}
+
+// shapeStats accumulates what has been observed at one position in the
+// documents.
+type shapeStats struct {
+	types     map[string]bool
+	objects   int                    // how many values were objects
+	props     map[string]*shapeStats // per-field stats across objects
+	propCount map[string]int         // objects that carried each field
+	items     *shapeStats            // element stats across arrays
+	strings   int
+	dateTimes int
+}
+
+func (st *shapeStats) add(v any) {
+	if st.types == nil {
+		st.types = map[string]bool{}
+	}
+	switch x := v.(type) {
+	case nil:
+		st.types["null"] = true
+	case bool:
+		st.types["boolean"] = true
+	case string:
+		st.types["string"] = true
+		st.strings++
+		if _, ok := ParseTimestamp(x); ok {
+			st.dateTimes++
+		}
+	case map[string]any:
+		st.types["object"] = true
+		st.objects++
+		if st.props == nil {
+			st.props = map[string]*shapeStats{}
+			st.propCount = map[string]int{}
+		}
+		for k, fv := range x {
+			if st.props[k] == nil {
+				st.props[k] = &shapeStats{}
+			}
+			st.props[k].add(fv)
+			st.propCount[k]++
+		}
+	case []any:
+		st.types["array"] = true
+		if st.items == nil {
+			st.items = &shapeStats{}
+		}
+		for _, e := range x {
+			st.items.add(e)
+		}
+	default:
+		if _, ok := toInt64(x); ok {
+			st.types["integer"] = true
+		} else if _, ok := toFloat(x); ok {
+			st.types["number"] = true
+		}
+	}
+}
+
+func (st *shapeStats) schema() *JSONSchema {
+	js := &JSONSchema{}
+	// integer is a subset of number; report the wider type when both appear
+	if st.types["integer"] && st.types["number"] {
+		delete(st.types, "integer")
+	}
+	types := sortedKeys(st.types)
+	switch len(types) {
+	case 0:
+	case 1:
+		js.Type = types[0]
+	default:
+		js.Type = types
+	}
+	if st.strings > 0 && st.dateTimes == st.strings {
+		js.Format = "date-time"
+	}
+	if st.props != nil {
+		js.Properties = make(map[string]*JSONSchema, len(st.props))
+		for _, k := range sortedKeys(st.props) {
+			js.Properties[k] = st.props[k].schema()
+			if st.propCount[k] == st.objects {
+				js.Required = append(js.Required, k)
+			}
+		}
+		sort.Strings(js.Required)
+	}
+	if st.items != nil && st.items.types != nil {
+		js.Items = st.items.schema()
+	}
+	return js
+}
@@ -0,0 +1,199 @@
+package ditto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when a requested key or record does not exist.
+var ErrNotFound = errors.New("ditto: not found")
+
+// KV is a key-value facade over a collection. Each key is one document
+// {_id, ns, key, value, expires_at}, so config-style callers never deal with
+// documents or DQL.
+type KV struct {
+	s          *service
+	collection string
+	namespace  string
+}
+
+// KV returns a key-value store backed by collection.
+func (s *service) KV(collection string) *KV {
+	return &KV{s: s, collection: collection}
+}
+
+// Namespace returns a view of the store whose keys are isolated under ns,
+// so several components can share one collection. Keys in different
+// namespaces, and in the root store, never map to the same document.
+func (kv *KV) Namespace(ns string) *KV {
+	return &KV{s: kv.s, collection: kv.collection, namespace: ns}
+}
+
+// idEscaper escapes the namespace separator (and the escape character
+// itself) inside namespaces and keys.
+var idEscaper = strings.NewReplacer("%", "%25", ":", "%3A")
+
+// docID maps a key to its document _id: the escaped key, prefixed by the
+// escaped namespace and ':' inside a namespace. Only the separator is a raw
+// ':', so no two namespace/key pairs share an _id, and keys without ':' or
+// '%' keep their plain _id.
+func (kv *KV) docID(key string) string {
+	if kv.namespace == "" {
+		return idEscaper.Replace(key)
+	}
+	return idEscaper.Replace(kv.namespace) + ":" + idEscaper.Replace(key)
+}
+
+// Set stores value under key with no expiry.
+func (kv *KV) Set(ctx context.Context, key string, value any) error {
+	return kv.SetTTL(ctx, key, value, 0)
+}
+
+// SetTTL stores value under key, expiring after ttl (zero means never).
+// Expired keys read as missing and are removed lazily.
+func (kv *KV) SetTTL(ctx context.Context, key string, value any, ttl time.Duration) error {
+	if key == "" {
+		return errors.New("key required")
+	}
+	doc := map[string]any{
+		"_id":        kv.docID(key),
+		"ns":         kv.namespace,
+		"key":        key,
+		"value":      value,
+		"expires_at": nil,
+	}
+	if ttl > 0 {
+		doc["expires_at"] = FormatTimestamp(kv.s.now().Add(ttl))
+	}
+	if err := kv.s.upsertDocs(ctx, kv.collection, []map[string]any{doc}); err != nil {
+		return fmt.Errorf("kv set %q: %w", key, err)
+	}
+	kv.s.cache.invalidate(kv.collection, kv.docID(key))
+	return nil
+}
+
+// Get decodes the value stored under key into out (a pointer). It returns
+// ErrNotFound for missing or expired keys.
+func (kv *KV) Get(ctx context.Context, key string, out any) error {
+	v, err := kv.raw(ctx, key)
+	if err != nil {
+		return err
+	}
+	if err := convertJSON(v, out); err != nil {
+		return fmt.Errorf("kv get %q: %w", key, err)
+	}
+	return nil
+}
+
+// GetString returns the string stored under key.
+func (kv *KV) GetString(ctx context.Context, key string) (string, error) {
+	var out string
+	err := kv.Get(ctx, key, &out)
+	return out, err
+}
+
+// GetInt64 returns the integer stored under key.
+func (kv *KV) GetInt64(ctx context.Context, key string) (int64, error) {
+	v, err := kv.raw(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := toInt64(v)
+	if !ok {
+		return 0, fmt.Errorf("kv %q: %T is not an integer", key, v)
+	}
+	return n, nil
+}
+
+// GetFloat64 returns the number stored under key.
+func (kv *KV) GetFloat64(ctx context.Context, key string) (float64, error) {
+	v, err := kv.raw(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	f, ok := toFloat(v)
+	if !ok {
+		return 0, fmt.Errorf("kv %q: %T is not a number", key, v)
+	}
+	return f, nil
+}
+
+// GetBool returns the boolean stored under key.
+func (kv *KV) GetBool(ctx context.Context, key string) (bool, error) {
+	var out bool
+	err := kv.Get(ctx, key, &out)
+	return out, err
+}
+
+// Delete removes key. Deleting a missing key is not an error.
+func (kv *KV) Delete(ctx context.Context, key string) error {
+	_, err := kv.s.DeleteRecord(ctx, kv.collection, kv.docID(key))
+	return err
+}
+
+// Keys lists the live keys in this namespace, sorted.
+func (kv *KV) Keys(ctx context.Context) ([]string, error) {
+	res, err := kv.s.FindWhere(ctx, kv.collection,
+		Where("ns == :ns", map[string]any{"ns": kv.namespace}),
+		QueryOptions{Fields: []string{"key", "expires_at"}, SortBy: "key", SortOrder: "ASC"},
+	)
+	if err != nil {
+		return nil, err
+	}
+	now := kv.s.now()
+	var keys []string
+	for _, d := range Documents(res) {
+		if expired(d, now) {
+			continue
+		}
+		if k, ok := d["key"].(string); ok {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+// raw fetches the stored value for key, treating expired entries as missing.
+func (kv *KV) raw(ctx context.Context, key string) (any, error) {
+	id := kv.docID(key)
+	res, err := kv.s.GetRecord(ctx, kv.collection, id)
+	if err != nil {
+		return nil, err
+	}
+	docs := Documents(res)
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("kv %q: %w", key, ErrNotFound)
+	}
+	if now := kv.s.now(); expired(docs[0], now) {
+		kv.deleteExpired(ctx, id, now)
+		return nil, fmt.Errorf("kv %q: %w", key, ErrNotFound)
+	}
+	return docs[0]["value"], nil
+}
+
+// deleteExpired removes the document for id only while it is still expired
+// at now, so a value a concurrent Set wrote after the read survives. Errors
+// are ignored; the key already reads as missing.
+func (kv *KV) deleteExpired(ctx context.Context, id string, now time.Time) {
+	kv.s.cache.invalidate(kv.collection, id)
+	where := Where("_id == :id AND expires_at <= :now", map[string]any{"id": id, "now": FormatTimestamp(now)})
+	if kv.s.softDeleted[kv.collection] {
+		_, _ = kv.s.softDelete(ctx, kv.collection, where)
+		return
+	}
+	q := fmt.Sprintf("DELETE FROM %s WHERE %s", escapeIdent(kv.collection), where.Clause)
+	_, _ = kv.s.execWithArgs(ctx, q, where.Args)
+}
+
+// expired reports whether a KV document's expires_at is in the past.
+func expired(doc map[string]any, now time.Time) bool {
+	s, _ := doc["expires_at"].(string)
+	if strings.TrimSpace(s) == "" {
+		return false
+	}
+	t, ok := ParseTimestamp(s)
+	return ok && !t.After(now)
+}
@@ -0,0 +1,124 @@
+package ditto
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKVDocIDsAreDistinct(t *testing.T) {
+	s := NewService("http://localhost:8090", "app")
+	root := s.KV("kv")
+	pairs := []struct{ ns, key string }{
+		{"", "a:b"},
+		{"", "a:b:c"},
+		{"", "a%3Ab"},
+		{"a", "b"},
+		{"a", "b:c"},
+		{"a:b", "c"},
+		{"a%3Ab", "c"},
+		{"a", "%3Ab"},
+	}
+	seen := map[string]string{}
+	for _, p := range pairs {
+		id := root.Namespace(p.ns).docID(p.key)
+		if p.ns == "" {
+			id = root.docID(p.key)
+		}
+		label := p.ns + " / " + p.key
+		if other, ok := seen[id]; ok {
+			t.Errorf("%s and %s share _id %q", label, other, id)
+		}
+		seen[id] = label
+	}
+	if got := root.Namespace("cfg").docID("theme"); got != "cfg:theme" {
+		t.Errorf("plain namespaced id = %q, want cfg:theme", got)
+	}
+	if got := root.docID("theme"); got != "theme" {
+		t.Errorf("plain id = %q, want theme", got)
+	}
+}
+
+func TestKVExpiredDeleteIsConditional(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	s, f := newFakeService(t, func(query string, args map[string]any) any {
+		if strings.HasPrefix(query, "SELECT") {
+			return items(map[string]any{"_id": "k", "value": 1, "expires_at": FormatTimestamp(now.Add(-time.Second))})
+		}
+		return nil
+	})
+	s.WithClock(newFakeClock(now))
+	if _, err := s.KV("kv").GetInt64(context.Background(), "k"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("err = %v, want ErrNotFound", err)
+	}
+	sent := f.sent()
+	del := sent[len(sent)-1]
+	if !strings.HasPrefix(del, "DELETE") || !strings.Contains(del, "expires_at <= :now") {
+		t.Fatalf("expired key removed with %q, want a delete conditional on expires_at", del)
+	}
+}
+
+func TestKVTTLExpiry(t *testing.T) {
+	ctx := context.Background()
+	clock := newFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	var (
+		mu   sync.Mutex
+		docs = map[string]map[string]any{}
+	)
+	s, _ := newFakeService(t, func(query string, args map[string]any) any {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case strings.HasPrefix(query, "INSERT"):
+			for _, v := range args {
+				d := v.(map[string]any)
+				docs[d["_id"].(string)] = d
+			}
+		case strings.HasPrefix(query, "SELECT"):
+			var out []map[string]any
+			for id, d := range docs {
+				if want, ok := args["id"]; !ok || want == id {
+					out = append(out, d)
+				}
+			}
+			return items(out...)
+		case strings.HasPrefix(query, "DELETE"):
+			if d, ok := docs[args["id"].(string)]; ok && d["expires_at"].(string) <= args["now"].(string) {
+				delete(docs, args["id"].(string))
+			}
+		}
+		return nil
+	})
+	s.WithClock(clock)
+	kv := s.KV("kv")
+	if err := kv.SetTTL(ctx, "session", "abc", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := kv.Set(ctx, "theme", "dark"); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(59 * time.Second)
+	if v, err := kv.GetString(ctx, "session"); err != nil || v != "abc" {
+		t.Fatalf("before expiry: %q, %v", v, err)
+	}
+	clock.Advance(time.Second)
+	if _, err := kv.GetString(ctx, "session"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("at expiry: %v, want ErrNotFound", err)
+	}
+	if keys, err := kv.Keys(ctx); err != nil || len(keys) != 1 || keys[0] != "theme" {
+		t.Fatalf("Keys = %v, %v; want [theme]", keys, err)
+	}
+	mu.Lock()
+	_, kept := docs["session"]
+	mu.Unlock()
+	if kept {
+		t.Fatal("expired key was not removed")
+	}
+	if v, err := kv.GetString(ctx, "theme"); err != nil || v != "dark" {
+		t.Fatalf("key without TTL: %q, %v", v, err)
+	}
+}
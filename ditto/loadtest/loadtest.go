@@ -0,0 +1,369 @@
+// Package loadtest drives a weighted mix of reads and writes against a
+// ditto.Service at a target request rate and reports latency percentiles
+// and error breakdowns, for qualifying hardware before field deployment.
+//
+// Requests are issued open-loop: they start on schedule whether or not
+// earlier ones have finished, up to Config.MaxInFlight at once, and latency
+// is measured from the scheduled start. A slow server therefore shows up as
+// higher latency and dropped requests rather than as a quietly lower rate.
+// Soak keeps a load up for hours while watching the client process for
+// goroutine, heap, and file descriptor leaks.
+//
+//	rep, err := loadtest.Run(ctx, svc, loadtest.Config{
+//		RPS:      200,
+//		Duration: 5 * time.Minute,
+//		Ops: []loadtest.Op{
+//			loadtest.GetRecords("readings", 50).Weighted(8),
+//			loadtest.Insert("readings", newReading).Weighted(2),
+//		},
+//	})
+//	rep.WriteText(os.Stdout)
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Hammerstone-AU/ditto-go-sdk/ditto"
+)
+
+// Defaults for Config fields left at zero.
+const (
+	DefaultMaxInFlight = 256
+	DefaultDuration    = time.Minute
+)
+
+// ErrDropped marks a scheduled request that was not sent because
+// MaxInFlight requests were already outstanding.
+var ErrDropped = errors.New("loadtest: dropped, too many requests in flight")
+
+// Op is one kind of request in the mix. Run calls Do with the sequence
+// number of the request, for unique ids or round-robin reads.
+type Op struct {
+	Name   string
+	Weight float64 // relative share of requests; zero counts as 1
+	Do     func(ctx context.Context, svc ditto.Service, seq int) error
+}
+
+// Weighted returns o with its Weight set to w.
+func (o Op) Weighted(w float64) Op {
+	o.Weight = w
+	return o
+}
+
+// Config configures Run.
+type Config struct {
+	RPS      float64       // target requests per second across all ops
+	Duration time.Duration // measured run time; defaults to one minute
+	// Warmup runs the same load first without recording it, so connection
+	// setup and caches do not skew the percentiles.
+	Warmup      time.Duration
+	MaxInFlight int // outstanding requests before new ones are dropped; defaults to 256
+	Ops         []Op
+	Seed        uint64 // fixes the op sequence for reproducible runs
+}
+
+// Latency summarizes recorded request durations.
+type Latency struct {
+	P50, P95, P99, Max, Mean time.Duration
+}
+
+// OpReport is the outcome of one op.
+type OpReport struct {
+	Name     string
+	Requests int
+	Errors   int
+	Latency  Latency
+	// ErrorKinds counts failures by ditto.ErrorClass, with the status code
+	// for HTTP errors (e.g. "server/503"); "dropped" counts ErrDropped.
+	ErrorKinds map[string]int
+}
+
+// Report is the outcome of Run.
+type Report struct {
+	Target   float64       // configured RPS
+	Achieved float64       // requests started per second, excluding drops
+	Duration time.Duration // measured time, excluding warmup
+	OpReport               // totals across ops, named "total"
+	Ops      []OpReport    // per op, in Config order
+}
+
+// Run generates load against svc for cfg.Warmup plus cfg.Duration, or until
+// ctx ends, waits for outstanding requests, and reports the measured part.
+func Run(ctx context.Context, svc ditto.Service, cfg Config) (*Report, error) {
+	if cfg.RPS <= 0 {
+		return nil, errors.New("loadtest: RPS must be positive")
+	}
+	if len(cfg.Ops) == 0 {
+		return nil, errors.New("loadtest: no Ops")
+	}
+	if cfg.Duration <= 0 {
+		cfg.Duration = DefaultDuration
+	}
+	if cfg.MaxInFlight <= 0 {
+		cfg.MaxInFlight = DefaultMaxInFlight
+	}
+	weights := make([]float64, len(cfg.Ops))
+	total := 0.0
+	for i, op := range cfg.Ops {
+		if op.Do == nil {
+			return nil, fmt.Errorf("loadtest: op %q has no Do", op.Name)
+		}
+		weights[i] = op.Weight
+		if weights[i] <= 0 {
+			weights[i] = 1
+		}
+		total += weights[i]
+	}
+	rng := rand.New(rand.NewPCG(cfg.Seed, cfg.Seed))
+	pick := func() int {
+		x := rng.Float64() * total
+		for i, w := range weights {
+			if x < w {
+				return i
+			}
+			x -= w
+		}
+		return len(weights) - 1
+	}
+
+	rec := newRecorder(len(cfg.Ops))
+	sem := make(chan struct{}, cfg.MaxInFlight)
+	var wg sync.WaitGroup
+	interval := time.Duration(float64(time.Second) / cfg.RPS)
+	start := time.Now()
+	measureFrom := start.Add(cfg.Warmup)
+	end := measureFrom.Add(cfg.Duration)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for seq := 0; ; seq++ {
+		due := start.Add(time.Duration(seq) * interval)
+		if !due.Before(end) {
+			break
+		}
+		if wait := time.Until(due); wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				end = time.Now()
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		op := pick()
+		measured := !due.Before(measureFrom)
+		select {
+		case sem <- struct{}{}:
+		default:
+			if measured {
+				rec.record(op, 0, ErrDropped)
+			}
+			continue
+		}
+		wg.Add(1)
+		go func(op, seq int, due time.Time) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			err := cfg.Ops[op].Do(ctx, svc, seq)
+			if measured {
+				rec.record(op, time.Since(due), err)
+			}
+		}(op, seq, due)
+	}
+	wg.Wait()
+
+	elapsed := end.Sub(measureFrom)
+	if elapsed <= 0 {
+		elapsed = time.Since(measureFrom)
+	}
+	rep := rec.report(cfg.Ops, elapsed)
+	rep.Target = cfg.RPS
+	return rep, ctx.Err()
+}
+
+// recorder collects request outcomes from concurrent workers.
+type recorder struct {
+	mu        sync.Mutex
+	latencies [][]time.Duration
+	errors    []map[string]int
+	requests  []int
+}
+
+func newRecorder(ops int) *recorder {
+	r := &recorder{latencies: make([][]time.Duration, ops), errors: make([]map[string]int, ops), requests: make([]int, ops)}
+	for i := range r.errors {
+		r.errors[i] = map[string]int{}
+	}
+	return r
+}
+
+// record adds one outcome; dropped requests have no latency.
+func (r *recorder) record(op int, d time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests[op]++
+	if err != nil {
+		r.errors[op][ErrorKind(err)]++
+	}
+	if !errors.Is(err, ErrDropped) {
+		r.latencies[op] = append(r.latencies[op], d)
+	}
+}
+
+// report builds the Report over elapsed measured time.
+func (r *recorder) report(ops []Op, elapsed time.Duration) *Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rep := &Report{Duration: elapsed}
+	var all []time.Duration
+	totals := OpReport{Name: "total", ErrorKinds: map[string]int{}}
+	for i, op := range ops {
+		or := OpReport{Name: op.Name, Requests: r.requests[i], ErrorKinds: r.errors[i], Latency: summarize(r.latencies[i])}
+		for k, n := range or.ErrorKinds {
+			or.Errors += n
+			totals.ErrorKinds[k] += n
+		}
+		totals.Requests += or.Requests
+		totals.Errors += or.Errors
+		all = append(all, r.latencies[i]...)
+		rep.Ops = append(rep.Ops, or)
+	}
+	totals.Latency = summarize(all)
+	rep.OpReport = totals
+	if secs := elapsed.Seconds(); secs > 0 {
+		rep.Achieved = float64(totals.Requests-totals.ErrorKinds["dropped"]) / secs
+	}
+	return rep
+}
+
+// summarize computes nearest-rank percentiles of ds, sorting it in place.
+func summarize(ds []time.Duration) Latency {
+	if len(ds) == 0 {
+		return Latency{}
+	}
+	sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+	rank := func(p float64) time.Duration {
+		i := int(p*float64(len(ds))+0.999999) - 1
+		return ds[min(max(i, 0), len(ds)-1)]
+	}
+	var sum time.Duration
+	for _, d := range ds {
+		sum += d
+	}
+	return Latency{P50: rank(.50), P95: rank(.95), P99: rank(.99), Max: ds[len(ds)-1], Mean: sum / time.Duration(len(ds))}
+}
+
+// ErrorKind names the kind of err for error breakdowns: "dropped", the
+// ditto.ErrorClass ("unknown" when unclassified), and for HTTP errors the
+// status code, e.g. "server/503".
+func ErrorKind(err error) string {
+	if errors.Is(err, ErrDropped) {
+		return "dropped"
+	}
+	kind := string(ditto.ClassOf(err))
+	if kind == "" {
+		kind = "unknown"
+	}
+	var he *ditto.HTTPError
+	if errors.As(err, &he) {
+		kind = fmt.Sprintf("%s/%d", kind, he.StatusCode)
+	}
+	return kind
+}
+
+// WriteText writes the report as an aligned table: one row per op and a
+// total, followed by the error breakdown.
+func (r *Report) WriteText(w io.Writer) error {
+	fmt.Fprintf(w, "target %.1f rps, achieved %.1f rps over %s\n\n", r.Target, r.Achieved, r.Duration.Round(time.Millisecond))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "op\trequests\terrors\tp50\tp95\tp99\tmax\tmean\t")
+	for _, o := range append(r.Ops, r.OpReport) {
+		l := o.Latency
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%s\t%s\t%s\t\n", o.Name, o.Requests, o.Errors,
+			round(l.P50), round(l.P95), round(l.P99), round(l.Max), round(l.Mean))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(r.ErrorKinds) == 0 {
+		return nil
+	}
+	fmt.Fprintln(w, "\nerrors:")
+	kinds := make([]string, 0, len(r.ErrorKinds))
+	for k := range r.ErrorKinds {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	for _, k := range kinds {
+		if _, err := fmt.Fprintf(w, "  %-16s %d\n", k, r.ErrorKinds[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// round shortens d for the table.
+func round(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	}
+	return d.Round(time.Microsecond)
+}
+
+// GetRecords reads the latest limit documents of collection.
+func GetRecords(collection string, limit int) Op {
+	return Op{Name: "get_records", Do: func(ctx context.Context, svc ditto.Service, _ int) error {
+		_, err := svc.GetRecords(ctx, collection, limit, "_id", "DESC")
+		return err
+	}}
+}
+
+// GetRecord reads documents of collection by id, cycling through ids.
+func GetRecord(collection string, ids []string) Op {
+	return Op{Name: "get_record", Do: func(ctx context.Context, svc ditto.Service, seq int) error {
+		if len(ids) == 0 {
+			return errors.New("loadtest: GetRecord needs ids")
+		}
+		_, err := svc.GetRecord(ctx, collection, ids[seq%len(ids)])
+		return err
+	}}
+}
+
+// Query runs a fixed DQL statement.
+func Query(name, query string, args map[string]any) Op {
+	return Op{Name: name, Do: func(ctx context.Context, svc ditto.Service, _ int) error {
+		_, err := svc.Execute(ctx, query, args)
+		return err
+	}}
+}
+
+// Insert creates one document per request from newDoc.
+func Insert(collection string, newDoc func(seq int) map[string]any) Op {
+	return Op{Name: "insert", Do: func(ctx context.Context, svc ditto.Service, seq int) error {
+		_, err := svc.CreateDocument(ctx, collection, newDoc(seq))
+		return err
+	}}
+}
+
+// Update patches documents of collection by id, cycling through ids.
+func Update(collection string, ids []string, patch func(seq int) map[string]any) Op {
+	return Op{Name: "update", Do: func(ctx context.Context, svc ditto.Service, seq int) error {
+		if len(ids) == 0 {
+			return errors.New("loadtest: Update needs ids")
+		}
+		_, err := svc.UpdateRecord(ctx, collection, ids[seq%len(ids)], patch(seq))
+		return err
+	}}
+}
@@ -0,0 +1,212 @@
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/Hammerstone-AU/ditto-go-sdk/ditto"
+)
+
+// Soak defaults for SoakConfig fields left at zero.
+const (
+	DefaultSampleEvery    = 30 * time.Second
+	DefaultSettle         = 5 * time.Second
+	DefaultGoroutineSlack = 25
+	DefaultFDSlack        = 8
+	DefaultHeapGrowth     = 0.5
+)
+
+// minHeapGrowth is the least heap growth flagged, so a small heap picking
+// up buffer pools and caches is not reported.
+const minHeapGrowth = 4 << 20
+
+// SoakConfig configures Soak.
+type SoakConfig struct {
+	// Load is the traffic kept up for the whole soak; Load.Duration is the
+	// soak length, typically hours.
+	Load        Config
+	SampleEvery time.Duration // defaults to 30s; each sample forces a GC
+	// Settle is how long to wait after the load before the final sample,
+	// so finished requests can release their goroutines and connections.
+	Settle time.Duration
+	// Idle, when set (usually the service's *http.Client or Transport),
+	// has its idle connections closed before the first and final samples,
+	// so pooled keep-alive connections are not mistaken for leaks.
+	Idle interface{ CloseIdleConnections() }
+	// Allowed growth before a leak is flagged: goroutines and open file
+	// descriptors as counts, heap as a fraction of the baseline (and at
+	// least 4 MiB).
+	GoroutineSlack int
+	FDSlack        int
+	HeapGrowth     float64
+	OnSample       func(Sample) // called with every sample, e.g. for a live log
+}
+
+// Sample is one reading of the client process.
+type Sample struct {
+	At         time.Duration // since the soak started
+	Goroutines int
+	HeapAlloc  uint64 // live heap bytes after a GC
+	OpenFDs    int    // -1 where the platform does not expose them
+}
+
+// Leak is a resource that kept growing or was not released.
+type Leak struct {
+	Resource string // "goroutines", "heap", or "fds"
+	Before   uint64
+	After    uint64
+	Detail   string
+}
+
+func (l Leak) String() string {
+	return fmt.Sprintf("%s %s: %d -> %d", l.Resource, l.Detail, l.Before, l.After)
+}
+
+// SoakReport is the outcome of Soak.
+type SoakReport struct {
+	*Report
+	Samples []Sample // pre-run, periodic, and final samples in order
+	Leaks   []Leak
+}
+
+// Soak runs cfg.Load against svc while sampling goroutine count, live heap,
+// and open file descriptors of this process, and flags leaks two ways:
+// growth from the first sample after warmup to the lowest of the last three
+// samples under load (steady growth, not noise), and growth from before the
+// load to after it has settled (resources never released, such as
+// connections kept by error paths). Run it in a process doing nothing else,
+// since every goroutine and descriptor of the process is counted.
+func Soak(ctx context.Context, svc ditto.Service, cfg SoakConfig) (*SoakReport, error) {
+	if cfg.SampleEvery <= 0 {
+		cfg.SampleEvery = DefaultSampleEvery
+	}
+	if cfg.Settle <= 0 {
+		cfg.Settle = DefaultSettle
+	}
+	if cfg.GoroutineSlack <= 0 {
+		cfg.GoroutineSlack = DefaultGoroutineSlack
+	}
+	if cfg.FDSlack <= 0 {
+		cfg.FDSlack = DefaultFDSlack
+	}
+	if cfg.HeapGrowth <= 0 {
+		cfg.HeapGrowth = DefaultHeapGrowth
+	}
+	start := time.Now()
+	out := &SoakReport{}
+	var mu sync.Mutex
+	take := func() Sample {
+		s := sample(start)
+		mu.Lock()
+		out.Samples = append(out.Samples, s)
+		mu.Unlock()
+		if cfg.OnSample != nil {
+			cfg.OnSample(s)
+		}
+		return s
+	}
+
+	closeIdle(cfg.Idle)
+	pre := take()
+	var during []Sample
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		t := time.NewTicker(cfg.SampleEvery)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				s := take()
+				if s.At >= cfg.Load.Warmup {
+					during = append(during, s)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	rep, err := Run(ctx, svc, cfg.Load)
+	close(stop)
+	<-done
+	if rep == nil {
+		return nil, err
+	}
+	out.Report = rep
+
+	select {
+	case <-time.After(cfg.Settle):
+	case <-ctx.Done():
+	}
+	closeIdle(cfg.Idle)
+	final := take()
+
+	if len(during) >= 4 {
+		base, tail := during[0], lowest(during[len(during)-3:])
+		out.Leaks = append(out.Leaks, compare(cfg, base, tail, "grew under steady load")...)
+	}
+	out.Leaks = append(out.Leaks, compare(cfg, pre, final, "not released after load")...)
+	return out, err
+}
+
+// compare flags the resources that grew from a to b beyond cfg's slack.
+func compare(cfg SoakConfig, a, b Sample, detail string) []Leak {
+	var leaks []Leak
+	if b.Goroutines > a.Goroutines+cfg.GoroutineSlack {
+		leaks = append(leaks, Leak{"goroutines", uint64(a.Goroutines), uint64(b.Goroutines), detail})
+	}
+	if a.OpenFDs >= 0 && b.OpenFDs > a.OpenFDs+cfg.FDSlack {
+		leaks = append(leaks, Leak{"fds", uint64(a.OpenFDs), uint64(b.OpenFDs), detail})
+	}
+	if b.HeapAlloc > a.HeapAlloc+minHeapGrowth && float64(b.HeapAlloc) > float64(a.HeapAlloc)*(1+cfg.HeapGrowth) {
+		leaks = append(leaks, Leak{"heap", a.HeapAlloc, b.HeapAlloc, detail})
+	}
+	return leaks
+}
+
+// lowest returns the per-resource minimum of ss, so one busy moment does
+// not look like growth.
+func lowest(ss []Sample) Sample {
+	m := ss[0]
+	for _, s := range ss[1:] {
+		m.Goroutines = min(m.Goroutines, s.Goroutines)
+		m.HeapAlloc = min(m.HeapAlloc, s.HeapAlloc)
+		m.OpenFDs = min(m.OpenFDs, s.OpenFDs)
+		m.At = s.At
+	}
+	return m
+}
+
+// sample reads the process after a GC.
+func sample(start time.Time) Sample {
+	runtime.GC()
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return Sample{
+		At:         time.Since(start),
+		Goroutines: runtime.NumGoroutine(),
+		HeapAlloc:  ms.HeapAlloc,
+		OpenFDs:    openFDs(),
+	}
+}
+
+// openFDs counts this process's open file descriptors, or -1.
+func openFDs() int {
+	for _, dir := range []string{"/proc/self/fd", "/dev/fd"} {
+		if entries, err := os.ReadDir(dir); err == nil {
+			return len(entries)
+		}
+	}
+	return -1
+}
+
+func closeIdle(c interface{ CloseIdleConnections() }) {
+	if c != nil {
+		c.CloseIdleConnections()
+	}
+}
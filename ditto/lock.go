@@ -0,0 +1,196 @@
+package ditto
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrLockLost is returned when a lease expired or was taken over before it
+// could be renewed or released.
+var ErrLockLost = errors.New("ditto: lock lost")
+
+// defaultLockTTL is the lease length used unless WithTTL is called.
+const defaultLockTTL = 30 * time.Second
+
+// Lock is a lease-based distributed lock stored as one document per key in a
+// Ditto collection. Ownership changes only through conditional UPDATEs
+// (owner match or expired lease), so several edge processes can coordinate
+// singleton jobs. Leases are compared by wall clock, so hosts need roughly
+// synchronized clocks relative to the TTL.
+type Lock struct {
+	svc        Service
+	collection string
+	key        string
+	owner      string
+	ttl        time.Duration
+	clock      Clock
+	sleeper    Sleeper
+
+	mu   sync.Mutex
+	stop context.CancelFunc // stops the heartbeat, if running
+}
+
+// NewLock returns a lock on key stored in collection, with a random owner id
+// and a 30s lease. It uses the clock, sleeper, and randomness injected into
+// svc, if any.
+func NewLock(svc Service, collection, key string) *Lock {
+	clock, sleeper, rnd := timeSourcesOf(svc)
+	buf := make([]byte, 8)
+	_, _ = rnd.Read(buf)
+	return &Lock{
+		svc:        svc,
+		collection: collection,
+		key:        key,
+		owner:      hex.EncodeToString(buf),
+		ttl:        defaultLockTTL,
+		clock:      clock,
+		sleeper:    sleeper,
+	}
+}
+
+// WithTTL sets the lease length.
+func (l *Lock) WithTTL(ttl time.Duration) *Lock {
+	if ttl > 0 {
+		l.ttl = ttl
+	}
+	return l
+}
+
+// Owner returns this lock holder's id.
+func (l *Lock) Owner() string { return l.owner }
+
+// TryAcquire attempts to take the lease once. It succeeds when the lock is
+// free, expired, or already held by this owner.
+func (l *Lock) TryAcquire(ctx context.Context) (bool, error) {
+	now := l.clock.Now()
+	ok, err := l.claim(ctx, now, true)
+	if err != nil || ok {
+		return ok, err
+	}
+	// No lock document yet: create it; a conflict means someone else won
+	exists, err := l.svc.Exists(ctx, l.collection, l.key)
+	if err != nil || exists {
+		return false, err
+	}
+	_, err = l.svc.CreateDocument(ctx, l.collection, map[string]any{
+		"_id":        l.key,
+		"owner":      l.owner,
+		"expires_at": FormatTimestamp(now.Add(l.ttl)),
+	})
+	if err != nil {
+		// Lost the insert race; report not acquired rather than an error
+		if exists, xerr := l.svc.Exists(ctx, l.collection, l.key); xerr == nil && exists {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Acquire blocks until the lease is taken or ctx is done, polling at a
+// fraction of the TTL.
+func (l *Lock) Acquire(ctx context.Context) error {
+	poll := max(l.ttl/10, 50*time.Millisecond)
+	for {
+		ok, err := l.TryAcquire(ctx)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		if err := l.sleeper.Sleep(ctx, poll); err != nil {
+			return err
+		}
+	}
+}
+
+// Renew extends the lease; ErrLockLost if another owner holds it now.
+func (l *Lock) Renew(ctx context.Context) error {
+	ok, err := l.claim(ctx, l.clock.Now(), false)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// Heartbeat renews the lease every TTL/3 in a goroutine until Release or ctx
+// is done. The returned channel yields ErrLockLost (or a renewal error) once
+// if the lease can't be kept, and is closed when the heartbeat stops.
+func (l *Lock) Heartbeat(ctx context.Context) <-chan error {
+	hctx, cancel := context.WithCancel(ctx)
+	l.mu.Lock()
+	if l.stop != nil {
+		l.stop()
+	}
+	l.stop = cancel
+	l.mu.Unlock()
+
+	out := make(chan error, 1)
+	go func() {
+		defer close(out)
+		every := max(l.ttl/3, 10*time.Millisecond)
+		for {
+			if l.sleeper.Sleep(hctx, every) != nil {
+				return
+			}
+			if err := l.Renew(hctx); err != nil {
+				if hctx.Err() == nil {
+					out <- err
+				}
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Release stops any heartbeat and frees the lease if this owner still holds
+// it; ErrLockLost otherwise.
+func (l *Lock) Release(ctx context.Context) error {
+	l.mu.Lock()
+	if l.stop != nil {
+		l.stop()
+		l.stop = nil
+	}
+	l.mu.Unlock()
+
+	res, err := l.svc.UpdateWhere(ctx, l.collection,
+		Where("_id == :key AND owner == :owner", map[string]any{"key": l.key, "owner": l.owner}),
+		map[string]any{"owner": Null, "expires_at": FormatTimestamp(time.Time{})},
+	)
+	if err != nil {
+		return fmt.Errorf("release lock: %w", err)
+	}
+	if len(mutatedIDs(res)) == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// claim conditionally sets owner and a fresh expiry. With takeover, a free
+// or expired lease may be claimed; otherwise only this owner's lease is
+// extended.
+func (l *Lock) claim(ctx context.Context, now time.Time, takeover bool) (bool, error) {
+	clause := "_id == :key AND owner == :owner"
+	args := map[string]any{"key": l.key, "owner": l.owner}
+	if takeover {
+		clause = "_id == :key AND (owner == :owner OR owner IS NULL OR expires_at < :now)"
+		args["now"] = FormatTimestamp(now)
+	}
+	res, err := l.svc.UpdateWhere(ctx, l.collection, Where(clause, args), map[string]any{
+		"owner":      l.owner,
+		"expires_at": FormatTimestamp(now.Add(l.ttl)),
+	})
+	if err != nil {
+		return false, fmt.Errorf("claim lock: %w", err)
+	}
+	return len(mutatedIDs(res)) > 0, nil
+}
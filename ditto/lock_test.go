@@ -0,0 +1,135 @@
+package ditto
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// leaseStore is a Service holding lock documents in memory. It applies the
+// conditions Lock sends through UpdateWhere by their arguments: with :now a
+// free, expired, or own lease can be claimed, without it only the owner's.
+type leaseStore struct {
+	Service // unused methods panic
+	clock   *fakeClock
+
+	mu   sync.Mutex
+	docs map[string]map[string]any
+}
+
+func newLeaseStore(clock *fakeClock) *leaseStore {
+	return &leaseStore{clock: clock, docs: map[string]map[string]any{}}
+}
+
+func (st *leaseStore) timeSources() (Clock, Sleeper, Rand) {
+	return st.clock, st.clock, SystemRand
+}
+
+func (st *leaseStore) Exists(ctx context.Context, collection, id string) (bool, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	_, ok := st.docs[id]
+	return ok, nil
+}
+
+func (st *leaseStore) CreateDocument(ctx context.Context, collection string, doc map[string]any) (any, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	id := doc["_id"].(string)
+	if _, ok := st.docs[id]; ok {
+		return nil, errors.New("duplicate _id")
+	}
+	st.docs[id] = doc
+	return map[string]any{"mutatedDocumentIds": []any{id}}, nil
+}
+
+func (st *leaseStore) UpdateWhere(ctx context.Context, collection string, where Predicate, patch map[string]any) (any, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	key := where.Args["key"].(string)
+	doc, ok := st.docs[key]
+	if !ok {
+		return map[string]any{}, nil
+	}
+	owned := doc["owner"] == where.Args["owner"]
+	if now, takeover := where.Args["now"].(string); takeover {
+		owned = owned || doc["owner"] == nil || doc["expires_at"].(string) < now
+	}
+	if !owned {
+		return map[string]any{}, nil
+	}
+	for k, v := range patch {
+		if v == Null {
+			v = nil
+		}
+		doc[k] = v
+	}
+	return map[string]any{"mutatedDocumentIds": []any{key}}, nil
+}
+
+func TestLockLeaseExpiry(t *testing.T) {
+	ctx := context.Background()
+	clock := newFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	st := newLeaseStore(clock)
+	a := NewLock(st, "locks", "job").WithTTL(10 * time.Second)
+	b := NewLock(st, "locks", "job").WithTTL(10 * time.Second)
+
+	if ok, err := a.TryAcquire(ctx); err != nil || !ok {
+		t.Fatalf("a.TryAcquire = %v, %v; want acquired", ok, err)
+	}
+	clock.Advance(9 * time.Second)
+	if ok, err := b.TryAcquire(ctx); err != nil || ok {
+		t.Fatalf("b.TryAcquire within the lease = %v, %v; want not acquired", ok, err)
+	}
+	// Renewing restarts the lease from the renewal time
+	if err := a.Renew(ctx); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(9 * time.Second)
+	if ok, _ := b.TryAcquire(ctx); ok {
+		t.Fatal("b took over a renewed lease")
+	}
+	clock.Advance(2 * time.Second)
+	if ok, err := b.TryAcquire(ctx); err != nil || !ok {
+		t.Fatalf("b.TryAcquire after expiry = %v, %v; want acquired", ok, err)
+	}
+	if err := a.Renew(ctx); !errors.Is(err, ErrLockLost) {
+		t.Fatalf("a.Renew after takeover = %v, want ErrLockLost", err)
+	}
+	if err := a.Release(ctx); !errors.Is(err, ErrLockLost) {
+		t.Fatalf("a.Release after takeover = %v, want ErrLockLost", err)
+	}
+	if err := b.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := a.TryAcquire(ctx); err != nil || !ok {
+		t.Fatalf("a.TryAcquire after release = %v, %v; want acquired", ok, err)
+	}
+}
+
+func TestLockAcquireWaitsForExpiry(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	clock := newFakeClock(start)
+	st := newLeaseStore(clock)
+	holder := NewLock(st, "locks", "job").WithTTL(10 * time.Second)
+	if ok, _ := holder.TryAcquire(ctx); !ok {
+		t.Fatal("holder did not acquire")
+	}
+
+	waiter := NewLock(st, "locks", "job").WithTTL(10 * time.Second)
+	if err := waiter.Acquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// Polls every TTL/10 until the holder's lease has run out
+	if got := clock.Now().Sub(start); got <= 10*time.Second || got > 11*time.Second {
+		t.Fatalf("acquired after %s, want just over the 10s lease", got)
+	}
+	for _, d := range clock.sleeps {
+		if d != time.Second {
+			t.Fatalf("slept %s between polls, want 1s", d)
+		}
+	}
+}
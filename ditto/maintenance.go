@@ -0,0 +1,143 @@
+package ditto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MaintenanceJob is periodic housekeeping run by the service, such as a
+// rollup or retention pass.
+type MaintenanceJob struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// MaintenanceStatus reports the outcome of a job's runs.
+type MaintenanceStatus struct {
+	Name      string
+	LastRun   time.Time // start of the most recent run; zero before the first
+	LastError error     // error of the most recent run
+	Runs      int64
+	Failures  int64
+}
+
+// maintenanceEntry is one scheduled job and its status.
+type maintenanceEntry struct {
+	job  MaintenanceJob
+	stop chan struct{} // closed by Unschedule
+
+	run    sync.Mutex // serializes runs of the job
+	mu     sync.Mutex
+	status MaintenanceStatus
+}
+
+// maintenance holds the scheduled jobs by name.
+type maintenance struct {
+	mu   sync.Mutex
+	jobs map[string]*maintenanceEntry
+}
+
+// ScheduleMaintenance runs job.Run every job.Interval in the background
+// until Unschedule or Close. The first run happens after one interval.
+func (s *service) ScheduleMaintenance(job MaintenanceJob) error {
+	if job.Name == "" || job.Run == nil {
+		return errors.New("maintenance job needs Name and Run")
+	}
+	if job.Interval <= 0 {
+		return errors.New("maintenance interval must be positive")
+	}
+	s.maint.mu.Lock()
+	if s.maint.jobs == nil {
+		s.maint.jobs = map[string]*maintenanceEntry{}
+	}
+	if _, dup := s.maint.jobs[job.Name]; dup {
+		s.maint.mu.Unlock()
+		return fmt.Errorf("maintenance job %q already scheduled", job.Name)
+	}
+	e := &maintenanceEntry{job: job, stop: make(chan struct{}), status: MaintenanceStatus{Name: job.Name}}
+	s.maint.jobs[job.Name] = e
+	s.maint.mu.Unlock()
+
+	s.goBackground("maintenance:"+job.Name, func(ctx context.Context) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			select {
+			case <-e.stop:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		for {
+			if s.sleep(ctx, job.Interval) != nil {
+				return
+			}
+			if err := s.runMaintenance(ctx, e); err != nil && ctx.Err() == nil {
+				s.log().Warn("ditto maintenance job failed", "job", job.Name, "error", err)
+			}
+		}
+	})
+	return nil
+}
+
+// RunMaintenance runs the named job now, waiting for a scheduled run of the
+// same job to finish first.
+func (s *service) RunMaintenance(ctx context.Context, name string) error {
+	s.maint.mu.Lock()
+	e := s.maint.jobs[name]
+	s.maint.mu.Unlock()
+	if e == nil {
+		return fmt.Errorf("maintenance job %q not scheduled", name)
+	}
+	return s.runMaintenance(ctx, e)
+}
+
+// runMaintenance performs one run of e and records its outcome.
+func (s *service) runMaintenance(ctx context.Context, e *maintenanceEntry) error {
+	e.run.Lock()
+	defer e.run.Unlock()
+	start := s.now()
+	err := e.job.Run(ctx)
+	e.mu.Lock()
+	e.status.LastRun, e.status.LastError = start, err
+	e.status.Runs++
+	if err != nil {
+		e.status.Failures++
+	}
+	e.mu.Unlock()
+	return err
+}
+
+// Unschedule stops the named job.
+func (s *service) Unschedule(name string) {
+	s.maint.mu.Lock()
+	e := s.maint.jobs[name]
+	delete(s.maint.jobs, name)
+	s.maint.mu.Unlock()
+	if e != nil {
+		close(e.stop)
+	}
+}
+
+// Maintenance returns the status of every scheduled job, sorted by name.
+func (s *service) Maintenance() []MaintenanceStatus {
+	s.maint.mu.Lock()
+	entries := make([]*maintenanceEntry, 0, len(s.maint.jobs))
+	for _, e := range s.maint.jobs {
+		entries = append(entries, e)
+	}
+	s.maint.mu.Unlock()
+	out := make([]MaintenanceStatus, 0, len(entries))
+	for _, e := range entries {
+		e.mu.Lock()
+		out = append(out, e.status)
+		e.mu.Unlock()
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
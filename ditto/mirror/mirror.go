@@ -0,0 +1,390 @@
+// Package mirror keeps a local read replica of Ditto collections in an
+// embedded SQL database (typically a SQLite file) so reads are served
+// locally within a staleness bound while writes go through to Ditto.
+//
+// The SDK depends only on the standard library, so the caller opens the
+// database with the driver of their choice (e.g. modernc.org/sqlite or
+// github.com/mattn/go-sqlite3) and passes the *sql.DB to Open. Statements
+// use SQLite syntax.
+package mirror
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Hammerstone-AU/ditto-go-sdk/ditto"
+)
+
+// pageSize is how many documents Sync reads from Ditto per request.
+const pageSize = 500
+
+// Options configures a Mirror.
+type Options struct {
+	Collections []string      // collections to mirror
+	MaxStale    time.Duration // reads older than this resync first; defaults to 5s
+	Interval    time.Duration // background resync period for Run; defaults to MaxStale/2
+	Clock       ditto.Clock   // time source for staleness; nil means system
+	Sleeper     ditto.Sleeper // waits between resyncs in Run; nil means real timers
+}
+
+// Mirror is a local replica of a set of collections.
+type Mirror struct {
+	svc  ditto.Service
+	db   *sql.DB
+	opts Options
+
+	mu      sync.Mutex
+	synced  map[string]time.Time
+	syncing map[string]*syncCall // in-flight Syncs by collection
+}
+
+// syncCall is one in-flight Sync shared by every caller of that collection.
+type syncCall struct {
+	done chan struct{}
+	err  error
+}
+
+// Open prepares db to mirror opts.Collections from svc, creating one table
+// per collection plus a table recording sync times. No data is copied until
+// the first read or Sync.
+func Open(ctx context.Context, svc ditto.Service, db *sql.DB, opts Options) (*Mirror, error) {
+	if svc == nil || db == nil {
+		return nil, errors.New("mirror needs a service and a database")
+	}
+	if len(opts.Collections) == 0 {
+		return nil, errors.New("mirror needs at least one collection")
+	}
+	if opts.MaxStale <= 0 {
+		opts.MaxStale = 5 * time.Second
+	}
+	if opts.Interval <= 0 {
+		// Run refreshes well before reads would find the copy stale
+		opts.Interval = opts.MaxStale / 2
+	}
+	m := &Mirror{svc: svc, db: db, opts: opts, synced: map[string]time.Time{}, syncing: map[string]*syncCall{}}
+	stmts := []string{`CREATE TABLE IF NOT EXISTS "mirror_sync" (collection TEXT PRIMARY KEY, synced_at TEXT NOT NULL)`}
+	for _, c := range opts.Collections {
+		stmts = append(stmts, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (id TEXT PRIMARY KEY, doc TEXT NOT NULL)`, table(c)))
+	}
+	for _, q := range stmts {
+		if _, err := db.ExecContext(ctx, q); err != nil {
+			return nil, fmt.Errorf("mirror schema: %w", err)
+		}
+	}
+	rows, err := db.QueryContext(ctx, `SELECT collection, synced_at FROM "mirror_sync"`)
+	if err != nil {
+		return nil, fmt.Errorf("mirror schema: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c, at string
+		if err := rows.Scan(&c, &at); err != nil {
+			return nil, err
+		}
+		if t, ok := ditto.ParseTimestamp(at); ok {
+			m.synced[c] = t
+		}
+	}
+	return m, rows.Err()
+}
+
+// Sync replaces the local copy of collection with the current contents in
+// Ditto. Concurrent Syncs of the same collection share one pass, which runs
+// to completion even if the caller that started it gives up; a caller whose
+// context ends stops waiting.
+func (m *Mirror) Sync(ctx context.Context, collection string) error {
+	if !m.mirrored(collection) {
+		return fmt.Errorf("collection %q is not mirrored", collection)
+	}
+	c := m.startSync(ctx, collection)
+	select {
+	case <-c.done:
+		return c.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// startSync joins the in-flight Sync of collection or starts one.
+func (m *Mirror) startSync(ctx context.Context, collection string) *syncCall {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if c, ok := m.syncing[collection]; ok {
+		return c
+	}
+	c := &syncCall{done: make(chan struct{})}
+	m.syncing[collection] = c
+	go func() {
+		defer func() {
+			m.mu.Lock()
+			delete(m.syncing, collection)
+			m.mu.Unlock()
+			close(c.done)
+		}()
+		c.err = m.sync(context.WithoutCancel(ctx), collection)
+	}()
+	return c
+}
+
+// sync copies collection page by page into one transaction, so the previous
+// copy stays readable until the new one commits and the collection is never
+// held in memory as a whole.
+func (m *Mirror) sync(ctx context.Context, collection string) error {
+	start := m.now()
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, "DELETE FROM "+table(collection)); err != nil {
+		return fmt.Errorf("mirror %s: clear: %w", collection, err)
+	}
+	for offset := 0; ; offset += pageSize {
+		res, err := m.svc.FindRecords(ctx, collection, nil, ditto.QueryOptions{
+			Limit: pageSize, Offset: offset, SortBy: "_id", SortOrder: "ASC",
+		})
+		if err != nil {
+			return fmt.Errorf("mirror %s: read: %w", collection, err)
+		}
+		page := ditto.Documents(res)
+		for _, d := range page {
+			if err := putRow(ctx, tx, collection, d); err != nil {
+				return fmt.Errorf("mirror %s: write: %w", collection, err)
+			}
+		}
+		if len(page) < pageSize {
+			break
+		}
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT OR REPLACE INTO "mirror_sync" (collection, synced_at) VALUES (?, ?)`,
+		collection, ditto.FormatTimestamp(start)); err != nil {
+		return fmt.Errorf("mirror %s: checkpoint: %w", collection, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.synced[collection] = start
+	m.mu.Unlock()
+	return nil
+}
+
+// SyncedAt reports when collection was last synced; zero if never.
+func (m *Mirror) SyncedAt(collection string) time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.synced[collection]
+}
+
+// Run resyncs every mirrored collection each Interval until ctx is done.
+// Failed syncs are retried on the next tick; reads made while a resync is
+// running are served from the previous copy.
+func (m *Mirror) Run(ctx context.Context) error {
+	sleeper := m.opts.Sleeper
+	if sleeper == nil {
+		sleeper = ditto.SystemSleeper
+	}
+	for {
+		for _, c := range m.opts.Collections {
+			_ = m.Sync(ctx, c)
+		}
+		if err := sleeper.Sleep(ctx, m.opts.Interval); err != nil {
+			return err
+		}
+	}
+}
+
+// Get returns one document from the local copy, resyncing first if the copy
+// is older than MaxStale and no refresh is already running. A missing document returns ditto.ErrNotFound.
+func (m *Mirror) Get(ctx context.Context, collection, id string) (map[string]any, error) {
+	if err := m.fresh(ctx, collection); err != nil {
+		return nil, err
+	}
+	var raw string
+	err := m.db.QueryRowContext(ctx, "SELECT doc FROM "+table(collection)+" WHERE id = ?", id).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s/%s", ditto.ErrNotFound, collection, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decode(raw)
+}
+
+// All returns every document in the local copy ordered by _id, resyncing
+// first if the copy is older than MaxStale and no refresh is already running.
+func (m *Mirror) All(ctx context.Context, collection string) ([]map[string]any, error) {
+	if err := m.fresh(ctx, collection); err != nil {
+		return nil, err
+	}
+	rows, err := m.db.QueryContext(ctx, "SELECT doc FROM "+table(collection)+" ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []map[string]any
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		d, err := decode(raw)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// Create inserts doc in Ditto and, on success, into the local copy.
+func (m *Mirror) Create(ctx context.Context, collection string, doc map[string]any) (any, error) {
+	res, err := m.svc.CreateDocument(ctx, collection, doc)
+	if err != nil || !m.mirrored(collection) {
+		return res, err
+	}
+	if _, ok := doc["_id"]; ok {
+		return res, m.refresh(ctx, collection, fmt.Sprint(doc["_id"]))
+	}
+	for _, id := range mutated(res) {
+		if err := m.refresh(ctx, collection, id); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// Update patches a document in Ditto and refreshes its local row.
+func (m *Mirror) Update(ctx context.Context, collection, id string, patch map[string]any) (any, error) {
+	res, err := m.svc.UpdateRecord(ctx, collection, id, patch)
+	if err != nil || !m.mirrored(collection) {
+		return res, err
+	}
+	return res, m.refresh(ctx, collection, id)
+}
+
+// Delete removes a document in Ditto and from the local copy.
+func (m *Mirror) Delete(ctx context.Context, collection, id string) (any, error) {
+	res, err := m.svc.DeleteRecord(ctx, collection, id)
+	if err != nil || !m.mirrored(collection) {
+		return res, err
+	}
+	_, err = m.db.ExecContext(ctx, "DELETE FROM "+table(collection)+" WHERE id = ?", id)
+	return res, err
+}
+
+// refresh reloads one document from Ditto into the local copy, removing the
+// row if the document no longer exists.
+func (m *Mirror) refresh(ctx context.Context, collection, id string) error {
+	res, err := m.svc.GetRecord(ctx, collection, id)
+	if err != nil {
+		return fmt.Errorf("mirror %s: refresh %s: %w", collection, id, err)
+	}
+	docs := ditto.Documents(res)
+	if len(docs) == 0 {
+		_, err = m.db.ExecContext(ctx, "DELETE FROM "+table(collection)+" WHERE id = ?", id)
+		return err
+	}
+	return putRow(ctx, m.db, collection, docs[0])
+}
+
+// fresh resyncs collection when its local copy is older than MaxStale. A
+// stale copy that is already being refreshed (by Run or another read) is
+// served as is rather than waiting for the refresh.
+func (m *Mirror) fresh(ctx context.Context, collection string) error {
+	if !m.mirrored(collection) {
+		return fmt.Errorf("collection %q is not mirrored", collection)
+	}
+	m.mu.Lock()
+	at := m.synced[collection]
+	_, refreshing := m.syncing[collection]
+	m.mu.Unlock()
+	if !at.IsZero() && (refreshing || m.now().Sub(at) <= m.opts.MaxStale) {
+		return nil
+	}
+	return m.Sync(ctx, collection)
+}
+
+// mirrored reports whether collection is one of the configured collections.
+func (m *Mirror) mirrored(collection string) bool {
+	for _, c := range m.opts.Collections {
+		if c == collection {
+			return true
+		}
+	}
+	return false
+}
+
+// now reads the Mirror clock.
+func (m *Mirror) now() time.Time {
+	if m.opts.Clock != nil {
+		return m.opts.Clock.Now()
+	}
+	return time.Now()
+}
+
+// execer is the subset of *sql.DB and *sql.Tx used by putRow.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// putRow upserts doc into the local table for collection.
+func putRow(ctx context.Context, db execer, collection string, doc map[string]any) error {
+	id, ok := doc["_id"]
+	if !ok {
+		return errors.New("document without _id")
+	}
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx,
+		"INSERT OR REPLACE INTO "+table(collection)+" (id, doc) VALUES (?, ?)",
+		fmt.Sprint(id), string(raw))
+	return err
+}
+
+// decode parses a stored document, keeping numbers exact as json.Number.
+func decode(raw string) (map[string]any, error) {
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.UseNumber()
+	var d map[string]any
+	if err := dec.Decode(&d); err != nil {
+		return nil, fmt.Errorf("mirror: corrupt row: %w", err)
+	}
+	return d, nil
+}
+
+// mutated returns the mutatedDocumentIds of a write result.
+func mutated(res any) []string {
+	m, ok := res.(map[string]any)
+	if !ok {
+		return nil
+	}
+	ids, _ := m["mutatedDocumentIds"].([]any)
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, fmt.Sprint(id))
+	}
+	return out
+}
+
+// table returns the quoted local table name for collection.
+func table(collection string) string {
+	var b strings.Builder
+	for _, r := range collection {
+		if r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return `"mirror_` + b.String() + `"`
+}
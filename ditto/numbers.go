@@ -0,0 +1,102 @@
+package ditto
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+)
+
+// WithPreciseNumbers makes the service decode numbers in responses as
+// json.Number instead of float64, so large int64 ids and decimal amounts keep
+// their exact digits. Use Scan to convert results into typed structs, or
+// json.Number's Int64/Float64/String methods on raw values.
+func (s *service) WithPreciseNumbers() *service {
+	s.preciseNumbers = true
+	return s
+}
+
+// decode reads one JSON value from r, honoring WithPreciseNumbers.
+func (s *service) decode(r io.Reader) (any, error) {
+	dec := json.NewDecoder(r)
+	if s.preciseNumbers {
+		dec.UseNumber()
+	}
+	var out any
+	if err := dec.Decode(&out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Scan converts the documents of a decoded response into dst, which must be
+// a pointer to a slice of structs or maps. Values round-trip through JSON
+// with numbers kept as json.Number, so with WithPreciseNumbers int64 and
+// decimal fields are filled exactly, and numbers landing in any or
+// map[string]any fields stay json.Number rather than float64.
+func Scan(res any, dst any) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("scan: dst must be a pointer to a slice, got %T", dst)
+	}
+	if err := convertJSON(resultItems(res), dst); err != nil {
+		return fmt.Errorf("scan: %w", err)
+	}
+	return nil
+}
+
+// convertJSON re-decodes v into dst through JSON without losing number
+// precision.
+func convertJSON(v any, dst any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return dec.Decode(dst)
+}
+
+// toFloat converts a decoded JSON number (float64 or json.Number) to float64.
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// toInt64 converts a decoded JSON number to int64 without going through
+// float64 when the value is a json.Number.
+func toInt64(v any) (int64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		if i, err := n.Int64(); err == nil {
+			return i, true
+		}
+		f, err := n.Float64()
+		if err != nil || f != math.Trunc(f) {
+			return 0, false
+		}
+		return int64(f), true
+	case float64:
+		if n != math.Trunc(n) {
+			return 0, false
+		}
+		return int64(n), true
+	case int:
+		return int64(n), true
+	case int64:
+		return n, true
+	}
+	return 0, false
+}
@@ -0,0 +1,285 @@
+package ditto
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Slow query log defaults; the log is on unless WithSlowQueryLog disables it.
+const (
+	defaultSlowThreshold = time.Second
+	defaultSlowKeep      = 100
+)
+
+// latencyBuckets are the upper bounds of the latency histogram buckets; a
+// final implicit bucket counts everything slower.
+var latencyBuckets = []time.Duration{
+	time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond,
+	25 * time.Millisecond, 50 * time.Millisecond, 100 * time.Millisecond,
+	250 * time.Millisecond, 500 * time.Millisecond, time.Second,
+	2500 * time.Millisecond, 5 * time.Second, 10 * time.Second,
+}
+
+// SlowQuery is one statement that took longer than the slow query threshold.
+// Args holds parameter names and types only, never values.
+type SlowQuery struct {
+	At            time.Time
+	Statement     string // e.g. "SELECT orders"
+	Query         string
+	Args          string // shape of query_args, e.g. "{id:string, p_0:float64}"
+	Duration      time.Duration
+	ResponseBytes int64
+	Err           string
+}
+
+// LatencyHistogram is a snapshot of the latencies of one statement kind.
+// Counts[i] counts requests no slower than Buckets[i]; the final entry of
+// Counts counts the rest.
+type LatencyHistogram struct {
+	Buckets []time.Duration
+	Counts  []int64
+	Total   int64
+	Errors  int64
+	Sum     time.Duration
+}
+
+// CollectionStats counts the traffic to one collection. Byte counts are
+// request bodies for writes and response bodies for reads, so they include
+// a little JSON framing around the documents.
+type CollectionStats struct {
+	Reads            int64
+	Writes           int64
+	Errors           int64
+	DocumentsRead    int64
+	DocumentsWritten int64 // documents reported mutated
+	BytesRead        int64
+	BytesWritten     int64
+}
+
+// ErrorRate returns the fraction of requests that failed.
+func (c CollectionStats) ErrorRate() float64 {
+	if n := c.Reads + c.Writes; n > 0 {
+		return float64(c.Errors) / float64(n)
+	}
+	return 0
+}
+
+// AvgDocumentBytes estimates the average document size from the bytes and
+// documents read and written.
+func (c CollectionStats) AvgDocumentBytes() float64 {
+	if n := c.DocumentsRead + c.DocumentsWritten; n > 0 {
+		return float64(c.BytesRead+c.BytesWritten) / float64(n)
+	}
+	return 0
+}
+
+// observer records per-statement latencies, per-collection traffic, and
+// recent slow queries.
+type observer struct {
+	mu        sync.Mutex
+	threshold time.Duration // zero disables the slow query log
+	keep      int
+	slow      []SlowQuery // ring buffer, oldest first once full
+	next      int
+	hist      map[string]*LatencyHistogram
+	coll      map[string]*CollectionStats
+}
+
+func newObserver() *observer {
+	return &observer{
+		threshold: defaultSlowThreshold,
+		keep:      defaultSlowKeep,
+		hist:      map[string]*LatencyHistogram{},
+		coll:      map[string]*CollectionStats{},
+	}
+}
+
+// WithSlowQueryLog sets the slow query threshold and how many recent slow
+// queries SlowQueries keeps. A zero threshold turns the log off; latency
+// histograms are always kept.
+func (s *service) WithSlowQueryLog(threshold time.Duration, keep int) *service {
+	if s.obs == nil {
+		s.obs = newObserver()
+	}
+	s.obs.mu.Lock()
+	defer s.obs.mu.Unlock()
+	if keep <= 0 {
+		keep = defaultSlowKeep
+	}
+	s.obs.threshold, s.obs.keep = threshold, keep
+	s.obs.slow, s.obs.next = nil, 0
+	return s
+}
+
+// WithLogger sets the logger used for slow queries and other diagnostics.
+// The default is slog.Default().
+func (s *service) WithLogger(l *slog.Logger) *service {
+	s.logger = l
+	return s
+}
+
+// log returns the configured logger or slog.Default().
+func (s *service) log() *slog.Logger {
+	if s.logger != nil {
+		return s.logger
+	}
+	return slog.Default()
+}
+
+// SlowQueries returns the recent slow queries, oldest first.
+func (s *service) SlowQueries() []SlowQuery {
+	if s.obs == nil {
+		return nil
+	}
+	s.obs.mu.Lock()
+	defer s.obs.mu.Unlock()
+	out := make([]SlowQuery, 0, len(s.obs.slow))
+	if len(s.obs.slow) == s.obs.keep {
+		out = append(out, s.obs.slow[s.obs.next:]...)
+		return append(out, s.obs.slow[:s.obs.next]...)
+	}
+	return append(out, s.obs.slow...)
+}
+
+// LatencyHistograms returns a snapshot of the latency histogram of each
+// statement kind seen so far, keyed like "SELECT orders".
+func (s *service) LatencyHistograms() map[string]LatencyHistogram {
+	if s.obs == nil {
+		return nil
+	}
+	s.obs.mu.Lock()
+	defer s.obs.mu.Unlock()
+	out := make(map[string]LatencyHistogram, len(s.obs.hist))
+	for k, h := range s.obs.hist {
+		cp := *h
+		cp.Counts = append([]int64(nil), h.Counts...)
+		out[k] = cp
+	}
+	return out
+}
+
+// CollectionStats returns a snapshot of the traffic to each collection seen
+// so far.
+func (s *service) CollectionStats() map[string]CollectionStats {
+	if s.obs == nil {
+		return nil
+	}
+	s.obs.mu.Lock()
+	defer s.obs.mu.Unlock()
+	out := make(map[string]CollectionStats, len(s.obs.coll))
+	for k, c := range s.obs.coll {
+		out[k] = *c
+	}
+	return out
+}
+
+// observe records one executed statement.
+func (s *service) observe(
+	query string, args map[string]any, d time.Duration, reqBytes, respBytes int64, res any, err error,
+) {
+	o := s.obs
+	if o == nil {
+		return
+	}
+	stmt := statementKey(query)
+	o.mu.Lock()
+	if verb, coll, ok := strings.Cut(stmt, " "); ok {
+		c := o.coll[coll]
+		if c == nil {
+			c = &CollectionStats{}
+			o.coll[coll] = c
+		}
+		if verb == "SELECT" {
+			c.Reads++
+		} else {
+			c.Writes++
+		}
+		switch {
+		case err != nil:
+			c.Errors++
+		case verb == "SELECT":
+			c.DocumentsRead += int64(len(resultItems(res)))
+			c.BytesRead += respBytes
+		default:
+			c.DocumentsWritten += int64(len(mutatedIDs(res)))
+			c.BytesWritten += reqBytes
+		}
+	}
+	h := o.hist[stmt]
+	if h == nil {
+		h = &LatencyHistogram{Buckets: latencyBuckets, Counts: make([]int64, len(latencyBuckets)+1)}
+		o.hist[stmt] = h
+	}
+	h.Counts[sort.Search(len(latencyBuckets), func(i int) bool { return d <= latencyBuckets[i] })]++
+	h.Total++
+	h.Sum += d
+	if err != nil {
+		h.Errors++
+	}
+	slow := o.threshold > 0 && d >= o.threshold
+	var sq SlowQuery
+	if slow {
+		sq = SlowQuery{At: s.now(), Statement: stmt, Query: query, Args: argsShape(args), Duration: d, ResponseBytes: respBytes}
+		if err != nil {
+			sq.Err = err.Error()
+		}
+		if len(o.slow) < o.keep {
+			o.slow = append(o.slow, sq)
+		} else {
+			o.slow[o.next] = sq
+			o.next = (o.next + 1) % o.keep
+		}
+	}
+	o.mu.Unlock()
+
+	if slow {
+		s.log().Warn("ditto slow query",
+			"statement", sq.Statement, "duration", d, "args", sq.Args,
+			"response_bytes", respBytes, "error", sq.Err)
+	}
+}
+
+// statementPattern extracts the verb and target collection of a statement.
+var statementPattern = regexp.MustCompile(`(?is)^\s*(SELECT\b.*?\bFROM|INSERT\s+INTO|UPDATE|DELETE\s+FROM|EVICT\s+FROM)\s+([A-Za-z0-9_]+)`)
+
+// statementKey names the kind of statement, e.g. "SELECT orders" or
+// "UPDATE users", so parameterized queries of any shape share a histogram.
+func statementKey(query string) string {
+	m := statementPattern.FindStringSubmatch(query)
+	if m == nil {
+		verb, _, _ := strings.Cut(strings.TrimSpace(query), " ")
+		return strings.ToUpper(verb)
+	}
+	verb, _, _ := strings.Cut(m[1], " ")
+	return strings.ToUpper(verb) + " " + m[2]
+}
+
+// argsShape describes args by name and type without revealing values.
+func argsShape(args map[string]any) string {
+	if len(args) == 0 {
+		return "{}"
+	}
+	parts := make([]string, 0, len(args))
+	for _, k := range sortedKeys(args) {
+		parts = append(parts, fmt.Sprintf("%s:%T", k, args[k]))
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
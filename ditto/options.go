@@ -0,0 +1,117 @@
+package ditto
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// QueryOptions tunes a read. Zero values mean "not set": no limit, no offset,
+// all fields, default order, soft-deleted documents hidden, and no extra
+// timeout beyond the caller's context and the HTTP client.
+type QueryOptions struct {
+	Limit          int
+	Offset         int
+	SortBy         string
+	SortOrder      string        // "ASC" or "DESC"; empty means server default
+	Fields         []string      // projection; empty selects *
+	IncludeDeleted bool          // include soft-deleted documents
+	Timeout        time.Duration // per-call timeout
+}
+
+// mergeQueryOptions folds opts left to right; later non-zero fields win.
+func mergeQueryOptions(opts []QueryOptions) QueryOptions {
+	var o QueryOptions
+	for _, x := range opts {
+		if x.Limit != 0 {
+			o.Limit = x.Limit
+		}
+		if x.Offset != 0 {
+			o.Offset = x.Offset
+		}
+		if x.SortBy != "" {
+			o.SortBy = x.SortBy
+		}
+		if x.SortOrder != "" {
+			o.SortOrder = x.SortOrder
+		}
+		if len(x.Fields) > 0 {
+			o.Fields = x.Fields
+		}
+		if x.IncludeDeleted {
+			o.IncludeDeleted = true
+		}
+		if x.Timeout != 0 {
+			o.Timeout = x.Timeout
+		}
+	}
+	return o
+}
+
+// FindRecords is the general read method: exact-match filters plus any
+// number of QueryOptions, merged with later values overriding earlier ones.
+func (s *service) FindRecords(
+	ctx context.Context,
+	collection string,
+	filters map[string]string,
+	opts ...QueryOptions,
+) (any, error) {
+	o := mergeQueryOptions(opts)
+	if o.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.Timeout)
+		defer cancel()
+	}
+	extra := s.liveClause(collection)
+	if o.IncludeDeleted {
+		extra = ""
+	}
+	q, args := buildSelect(collection, filters, extra, o, s.strictParams)
+	return s.execWithArgs(ctx, q, args)
+}
+
+// projection renders a SELECT field list; empty means *.
+func projection(fields []string) string {
+	if len(fields) == 0 {
+		return "*"
+	}
+	out := make([]string, len(fields))
+	for i, f := range fields {
+		out[i] = escapePath(f)
+	}
+	return strings.Join(out, ", ")
+}
+
+// WithAllowReserved lets update methods write reserved fields such as _id,
+// which otherwise fail with ErrImmutableField. Intended for power users who
+// know the server accepts the change.
+func (s *service) WithAllowReserved() *service {
+	s.allowReserved = true
+	return s
+}
+
+// FindWhere reads the documents matching a parameterized predicate, such as
+// ArrayContains or BoundingBox, with the usual QueryOptions.
+func (s *service) FindWhere(
+	ctx context.Context,
+	collection string,
+	where Predicate,
+	opts ...QueryOptions,
+) (any, error) {
+	if strings.TrimSpace(where.Clause) == "" {
+		return nil, errors.New("predicate required")
+	}
+	o := mergeQueryOptions(opts)
+	if o.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.Timeout)
+		defer cancel()
+	}
+	extra := "(" + where.Clause + ")"
+	if !o.IncludeDeleted {
+		extra += s.andLive(collection)
+	}
+	q, _ := buildSelect(collection, nil, extra, o, false)
+	return s.execWithArgs(ctx, q, where.Args)
+}
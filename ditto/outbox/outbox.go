@@ -0,0 +1,153 @@
+// Package outbox implements the transactional outbox pattern on top of a
+// Ditto collection: application events are recorded alongside data writes
+// and a Relay forwards them to external systems, checkpointing each batch.
+//
+// Ditto's HTTP API runs every statement in its own transaction, so Write
+// emulates atomicity: events are staged before the data write and only
+// released to the relay once it succeeds. A crash between the two leaves
+// staged events behind, which Staged reports for the application to resolve.
+package outbox
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Hammerstone-AU/ditto-go-sdk/ditto"
+)
+
+// Event status values stored in the outbox collection.
+const (
+	StatusStaged = "staged" // written, data write not yet confirmed
+	StatusReady  = "ready"  // awaiting relay
+	StatusSent   = "sent"   // published and checkpointed
+)
+
+// Event is an application domain event.
+type Event struct {
+	ID        string         `json:"_id"`
+	Type      string         `json:"type"`
+	Aggregate string         `json:"aggregate,omitempty"`
+	Payload   map[string]any `json:"payload,omitempty"`
+	CreatedAt string         `json:"created_at"`
+	Status    string         `json:"status"`
+}
+
+// Outbox stores events in one collection.
+type Outbox struct {
+	svc        ditto.Service
+	collection string
+	Clock      ditto.Clock // time source for event timestamps; nil means system
+	Rand       ditto.Rand  // source of generated event ids; nil means crypto/rand
+}
+
+// New returns an Outbox backed by collection.
+func New(svc ditto.Service, collection string) *Outbox {
+	return &Outbox{svc: svc, collection: collection}
+}
+
+// Write records events in the same logical operation as write: events are
+// staged, write runs, and the events are marked ready for the relay. If write
+// fails the staged events are removed and the write error returned.
+func (o *Outbox) Write(ctx context.Context, write func(ctx context.Context) error, events ...Event) error {
+	ids := make([]string, 0, len(events))
+	now := ditto.FormatTimestamp(o.now())
+	for i := range events {
+		if events[i].Type == "" {
+			return errors.New("event type required")
+		}
+		if events[i].ID == "" {
+			id, err := o.newID()
+			if err != nil {
+				return err
+			}
+			events[i].ID = id
+		}
+		events[i].CreatedAt = now
+		events[i].Status = StatusStaged
+		doc := map[string]any{
+			"_id":        events[i].ID,
+			"type":       events[i].Type,
+			"aggregate":  events[i].Aggregate,
+			"payload":    events[i].Payload,
+			"created_at": events[i].CreatedAt,
+			"status":     events[i].Status,
+		}
+		if _, err := o.svc.CreateDocument(ctx, o.collection, doc); err != nil {
+			o.discard(ctx, ids)
+			return fmt.Errorf("stage event: %w", err)
+		}
+		ids = append(ids, events[i].ID)
+	}
+	if write != nil {
+		if err := write(ctx); err != nil {
+			o.discard(ctx, ids)
+			return err
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	if _, err := o.svc.UpdateMany(ctx, o.collection, ids, map[string]any{"status": StatusReady}); err != nil {
+		return fmt.Errorf("release events: %w", err)
+	}
+	return nil
+}
+
+// Staged returns events left in the staged state for longer than olderThan,
+// i.e. writes interrupted between staging and release.
+func (o *Outbox) Staged(ctx context.Context, olderThan time.Duration) ([]Event, error) {
+	res, err := o.svc.FindWhere(ctx, o.collection, ditto.Where(
+		"status == :status AND created_at < :cutoff",
+		map[string]any{"status": StatusStaged, "cutoff": ditto.FormatTimestamp(o.now().Add(-olderThan))},
+	))
+	if err != nil {
+		return nil, err
+	}
+	var out []Event
+	if err := ditto.Scan(res, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Release marks staged events ready, e.g. after verifying their data write
+// did happen.
+func (o *Outbox) Release(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := o.svc.UpdateMany(ctx, o.collection, ids, map[string]any{"status": StatusReady})
+	return err
+}
+
+// discard removes staged events after a failed write (best effort).
+func (o *Outbox) discard(ctx context.Context, ids []string) {
+	for _, id := range ids {
+		_, _ = o.svc.DeleteRecord(ctx, o.collection, id)
+	}
+}
+
+// newID returns a random 128-bit hex event id.
+func (o *Outbox) newID() (string, error) {
+	b := make([]byte, 16)
+	read := rand.Read
+	if o.Rand != nil {
+		read = o.Rand.Read
+	}
+	if _, err := read(b); err != nil {
+		return "", fmt.Errorf("event id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// now reads the Outbox clock.
+func (o *Outbox) now() time.Time {
+	if o.Clock != nil {
+		return o.Clock.Now()
+	}
+	return time.Now()
+}
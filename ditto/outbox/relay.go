@@ -0,0 +1,91 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Hammerstone-AU/ditto-go-sdk/ditto"
+)
+
+// Publisher forwards a batch of events to an external system. It should be
+// idempotent on Event.ID: a batch is re-sent if the process stops after
+// publishing but before the checkpoint is written.
+type Publisher func(ctx context.Context, events []Event) error
+
+// Relay forwards ready events in created_at order and checkpoints each
+// published batch by marking its events sent, so every batch is delivered
+// once per checkpoint.
+type Relay struct {
+	Outbox    *Outbox
+	Publish   Publisher
+	BatchSize int           // events per publish; defaults to 100
+	Interval  time.Duration // poll interval for Run; defaults to 1s
+	Sleeper   ditto.Sleeper // waits between polls in Run; nil means real timers
+}
+
+// RelayOnce publishes up to one batch of ready events and returns how many
+// were forwarded.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	if r.Outbox == nil || r.Publish == nil {
+		return 0, errors.New("relay needs Outbox and Publish")
+	}
+	size := r.BatchSize
+	if size <= 0 {
+		size = 100
+	}
+	res, err := r.Outbox.svc.FindWhere(ctx, r.Outbox.collection,
+		ditto.Where("status == :status", map[string]any{"status": StatusReady}),
+		ditto.QueryOptions{SortBy: "created_at", SortOrder: "ASC", Limit: size},
+	)
+	if err != nil {
+		return 0, fmt.Errorf("read outbox: %w", err)
+	}
+	var events []Event
+	if err := ditto.Scan(res, &events); err != nil {
+		return 0, err
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+	if err := r.Publish(ctx, events); err != nil {
+		return 0, fmt.Errorf("publish: %w", err)
+	}
+	ids := make([]string, len(events))
+	for i, e := range events {
+		ids[i] = e.ID
+	}
+	if _, err := r.Outbox.svc.UpdateMany(ctx, r.Outbox.collection, ids, map[string]any{
+		"status":  StatusSent,
+		"sent_at": ditto.FormatTimestamp(r.Outbox.now()),
+	}); err != nil {
+		return 0, fmt.Errorf("checkpoint: %w", err)
+	}
+	return len(events), nil
+}
+
+// Run relays batches until ctx is cancelled, draining back-to-back while
+// events are found and sleeping Interval when idle or after an error.
+func (r *Relay) Run(ctx context.Context) error {
+	interval := r.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	for {
+		n, err := r.RelayOnce(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err == nil && n > 0 {
+			continue
+		}
+		sleeper := r.Sleeper
+		if sleeper == nil {
+			sleeper = ditto.SystemSleeper
+		}
+		if err := sleeper.Sleep(ctx, interval); err != nil {
+			return err
+		}
+	}
+}
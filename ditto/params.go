@@ -0,0 +1,51 @@
+package ditto
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInlineLiteral is returned in strict params mode for a statement that
+// carries a string literal instead of a bound parameter.
+var ErrInlineLiteral = errors.New("ditto: inline string literal in strict params mode")
+
+// WithStrictParams requires every value to travel in query_args. Filters of
+// FindRecords, Search, and the other read helpers are bound as :filter_N
+// parameters instead of being escaped into the query, so the client-side
+// string escaping is never used, and any statement containing a string
+// literal (including BuildSelect output with filters, and hand-written
+// Execute DQL) fails with ErrInlineLiteral before it is sent. Numbers in
+// LIMIT and OFFSET are not values and stay inline.
+func (s *service) WithStrictParams() *service {
+	s.strictParams = true
+	return s
+}
+
+// BuildSelectArgs is BuildSelect with the filter values bound as query_args
+// (:filter_0, :filter_1, ... in sorted field order) rather than inlined.
+func BuildSelectArgs(
+	collection string,
+	filters map[string]string,
+	limit int,
+	sortBy, sortOrder string,
+) (string, map[string]any) {
+	return buildSelect(collection, filters, "", QueryOptions{Limit: limit, SortBy: sortBy, SortOrder: sortOrder}, true)
+}
+
+// checkParams rejects string literals in strict params mode.
+func (s *service) checkParams(query string) error {
+	if !s.strictParams {
+		return nil
+	}
+	toks, err := lexDQL(query)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidDQL, err)
+	}
+	for _, t := range toks {
+		if t.kind == "string" {
+			// The literal itself is left out: it may be the sensitive value
+			return fmt.Errorf("%w: string at offset %d; bind it as a :param", ErrInlineLiteral, t.pos)
+		}
+	}
+	return nil
+}
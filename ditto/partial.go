@@ -0,0 +1,143 @@
+package ditto
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// minDeadlineReserve is the least time a bulk operation keeps in hand before
+// its context deadline; it stops starting new items once less is left.
+const minDeadlineReserve = 250 * time.Millisecond
+
+// ErrBadContinuation is returned for a continuation token that does not
+// belong to the operation it was passed to.
+var ErrBadContinuation = errors.New("ditto: continuation token does not match operation")
+
+// ErrNotAttempted marks the items of a PartialResult that were never sent.
+var ErrNotAttempted = errors.New("ditto: not attempted before deadline")
+
+// PartialResult is returned by ExecuteBatch and UpdateMany when the context
+// deadline was about to expire before every item was attempted. Indexes
+// refer to the input (statements, or ids for UpdateMany). Items are started
+// in order, so the ones not attempted always form the tail; pass
+// Continuation to WithContinuation to run the same call again from there.
+type PartialResult struct {
+	Op           string // "ExecuteBatch" or "UpdateMany"
+	Succeeded    []int
+	Failed       []int
+	NotAttempted []int
+	Continuation string
+	Err          error // the deadline, joined with the failures of attempted items
+}
+
+func (p *PartialResult) Error() string {
+	return fmt.Sprintf("ditto: %s stopped at deadline: %d succeeded, %d failed, %d not attempted",
+		p.Op, len(p.Succeeded), len(p.Failed), len(p.NotAttempted))
+}
+
+func (p *PartialResult) Unwrap() error { return p.Err }
+
+// continuationKey is the context key for WithContinuation.
+type continuationKey struct{}
+
+// WithContinuation returns a context that resumes a bulk operation from a
+// PartialResult.Continuation: the items already attempted are skipped and
+// reported as neither succeeded nor failed. The call must be repeated with
+// the same inputs; a token from another operation or input length fails
+// with ErrBadContinuation.
+func WithContinuation(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, continuationKey{}, token)
+}
+
+// continuationToken encodes where op stopped among total items.
+func continuationToken(op string, next, total int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(op + ":" + strconv.Itoa(next) + ":" + strconv.Itoa(total)))
+}
+
+// resumeIndex returns the first item to attempt for op over total items.
+func resumeIndex(ctx context.Context, op string, total int) (int, error) {
+	token, ok := ctx.Value(continuationKey{}).(string)
+	if !ok || token == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, ErrBadContinuation
+	}
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 || parts[0] != op {
+		return 0, ErrBadContinuation
+	}
+	next, err1 := strconv.Atoi(parts[1])
+	n, err2 := strconv.Atoi(parts[2])
+	if err1 != nil || err2 != nil || n != total || next < 0 || next > total {
+		return 0, ErrBadContinuation
+	}
+	return next, nil
+}
+
+// deadlineGuard decides when a bulk operation should stop starting items:
+// once the time left before the deadline is less than the slowest item so
+// far (at least minDeadlineReserve).
+type deadlineGuard struct {
+	s       *service
+	ctx     context.Context
+	mu      sync.Mutex
+	slowest time.Duration
+}
+
+func (s *service) newDeadlineGuard(ctx context.Context) *deadlineGuard {
+	return &deadlineGuard{s: s, ctx: ctx}
+}
+
+// near reports whether the deadline is too close to start another item.
+func (g *deadlineGuard) near() bool {
+	if g.ctx.Err() != nil {
+		return true
+	}
+	deadline, ok := g.ctx.Deadline()
+	if !ok {
+		return false
+	}
+	g.mu.Lock()
+	reserve := max(g.slowest, minDeadlineReserve)
+	g.mu.Unlock()
+	return deadline.Sub(g.s.now()) < reserve
+}
+
+// observe records how long one item took.
+func (g *deadlineGuard) observe(d time.Duration) {
+	g.mu.Lock()
+	g.slowest = max(g.slowest, d)
+	g.mu.Unlock()
+}
+
+// partial builds the PartialResult for op after items [0, next) of total
+// were started (from start on; earlier ones were skipped by a continuation)
+// and failed reports the failure of item i, if any.
+func (g *deadlineGuard) partial(op string, start, next, total int, failed func(i int) error) *PartialResult {
+	p := &PartialResult{Op: op, Continuation: continuationToken(op, next, total)}
+	errs := []error{context.DeadlineExceeded}
+	if err := g.ctx.Err(); err != nil {
+		errs[0] = err
+	}
+	for i := start; i < next; i++ {
+		if err := failed(i); err != nil {
+			p.Failed = append(p.Failed, i)
+			errs = append(errs, fmt.Errorf("item %d: %w", i, err))
+		} else {
+			p.Succeeded = append(p.Succeeded, i)
+		}
+	}
+	for i := next; i < total; i++ {
+		p.NotAttempted = append(p.NotAttempted, i)
+	}
+	p.Err = errors.Join(errs...)
+	return p
+}
@@ -0,0 +1,69 @@
+package ditto
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrDocumentTooLarge is matched (via errors.Is) by every
+// *DocumentTooLargeError.
+var ErrDocumentTooLarge = errors.New("ditto: document too large")
+
+// DocumentTooLargeError reports a document or request body over the limit
+// set with WithMaxPayloadBytes. It is returned before anything is sent.
+type DocumentTooLargeError struct {
+	Collection string // empty when the whole request body was measured
+	ID         string // document _id when known
+	Size       int    // encoded JSON size in bytes
+	Limit      int
+}
+
+func (e *DocumentTooLargeError) Error() string {
+	what := "request body"
+	if e.Collection != "" {
+		what = "document in " + e.Collection
+		if e.ID != "" {
+			what = fmt.Sprintf("document %s in %s", e.ID, e.Collection)
+		}
+	}
+	return fmt.Sprintf("ditto: %s is %d bytes, limit %d", what, e.Size, e.Limit)
+}
+
+func (e *DocumentTooLargeError) Unwrap() error { return ErrDocumentTooLarge }
+
+// WithMaxPayloadBytes caps the encoded size of documents passed to
+// CreateDocument and of every request body. Oversized writes fail with a
+// *DocumentTooLargeError carrying the size, instead of an opaque server
+// error after the upload. Zero disables the check.
+func (s *service) WithMaxPayloadBytes(n int) *service {
+	s.maxPayload = n
+	return s
+}
+
+// checkDocumentSize measures doc as it will be encoded.
+func (s *service) checkDocumentSize(collection string, doc map[string]any) error {
+	if s.maxPayload <= 0 {
+		return nil
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	if len(b) > s.maxPayload {
+		e := &DocumentTooLargeError{Collection: collection, Size: len(b), Limit: s.maxPayload}
+		if id, ok := doc["_id"]; ok {
+			e.ID = fmt.Sprint(id)
+		}
+		return e
+	}
+	return nil
+}
+
+// checkBodySize rejects an encoded request body over the limit.
+func (s *service) checkBodySize(body []byte) error {
+	if s.maxPayload > 0 && len(body) > s.maxPayload {
+		return &DocumentTooLargeError{Size: len(body), Limit: s.maxPayload}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package ditto
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// maxIDsPerStatement caps how many ids are bound into a single IN (...) list;
+// longer lists are split across several statements.
+const maxIDsPerStatement = 500
+
+// Predicate is a DQL boolean expression together with the parameters it
+// binds. Clause references parameters by name (":status"); Args supplies them.
+type Predicate struct {
+	Clause string
+	Args   map[string]any
+}
+
+// Where builds a Predicate from a DQL clause and its bound arguments, e.g.
+// Where("status == :status", map[string]any{"status": "open"}).
+func Where(clause string, args map[string]any) Predicate {
+	return Predicate{Clause: clause, Args: args}
+}
+
+// idsPredicate builds `_id IN (:id_0, :id_1, ...)` for the given ids.
+func idsPredicate(ids []string) Predicate {
+	names := make([]string, len(ids))
+	args := make(map[string]any, len(ids))
+	for i, id := range ids {
+		name := fmt.Sprintf("id_%d", i)
+		names[i] = ":" + name
+		args[name] = id
+	}
+	return Predicate{
+		//dittovet:ignore names are generated :id_N parameters
+		Clause: fmt.Sprintf("_id IN (%s)", strings.Join(names, ", ")),
+		Args:   args,
+	}
+}
+
+// chunkIDs splits ids into slices of at most size elements.
+func chunkIDs(ids []string, size int) [][]string {
+	var out [][]string
+	for len(ids) > size {
+		out = append(out, ids[:size])
+		ids = ids[size:]
+	}
+	if len(ids) > 0 {
+		out = append(out, ids)
+	}
+	return out
+}
+
+// mergeArgs copies src into dst, failing on a parameter name used by both.
+func mergeArgs(dst, src map[string]any) error {
+	for k, v := range src {
+		if _, dup := dst[k]; dup {
+			return fmt.Errorf("parameter %q bound twice", k)
+		}
+		dst[k] = v
+	}
+	return nil
+}
+
+// BuildUpdateWhere constructs an UPDATE DQL applying patch to every document
+// matching the predicate. SET values and predicate arguments are bound as
+// parameters; a predicate parameter clashing with a SET parameter is an error.
+func BuildUpdateWhere(collection string, where Predicate, patch map[string]any) (string, map[string]any, error) {
+	return buildUpdateWhere(collection, where, patch, false)
+}
+
+// buildUpdateWhere is BuildUpdateWhere with an override for reserved-field
+// checks.
+func buildUpdateWhere(collection string, where Predicate, patch map[string]any, allowReserved bool) (string, map[string]any, error) {
+	if collection == "" {
+		return "", nil, errors.New("collection required")
+	}
+	if strings.TrimSpace(where.Clause) == "" {
+		return "", nil, errors.New("predicate required")
+	}
+	if len(patch) == 0 {
+		return "", nil, errors.New("patch is empty")
+	}
+	mut, args, err := buildMutation(patch, allowReserved)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := mergeArgs(args, where.Args); err != nil {
+		return "", nil, err
+	}
+	return fmt.Sprintf("UPDATE %s %s WHERE %s", escapeIdent(collection), mut, where.Clause), args, nil
+}
@@ -0,0 +1,23 @@
+package ditto
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildUpdateWhereRejectsParamCollision(t *testing.T) {
+	where := Where("make == :p_0", map[string]any{"p_0": "Ford"})
+	_, _, err := BuildUpdateWhere("cars", where, map[string]any{"color": "blue"})
+	if err == nil || !strings.Contains(err.Error(), "bound twice") {
+		t.Fatalf("err = %v, want a parameter collision", err)
+	}
+}
+
+func TestBuildUpdateWhereRejectsInvalidFields(t *testing.T) {
+	where := Where("make == :make", map[string]any{"make": "Ford"})
+	for _, key := range []string{"", "a..b", "a."} {
+		if q, _, err := BuildUpdateWhere("cars", where, map[string]any{key: 1}); err == nil {
+			t.Errorf("key %q: got %s, want an error", key, q)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package ditto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PrefetchSpec registers a hot query kept warm in memory.
+type PrefetchSpec struct {
+	Name     string
+	Query    string
+	Args     map[string]any
+	Interval time.Duration // refresh period
+	// MaxStale, when positive, lets ordinary reads of exactly this query and
+	// args (via any method that issues it) be served from the warm result as
+	// long as it is no older than MaxStale. Zero keeps the result available
+	// only through Prefetched.
+	MaxStale time.Duration
+}
+
+// PrefetchedResult is a warm result with its staleness metadata.
+type PrefetchedResult struct {
+	Result    any
+	FetchedAt time.Time     // zero until the first successful refresh
+	Age       time.Duration // time since FetchedAt
+	LastError error         // error of the most recent refresh, if it failed
+}
+
+// prefetchEntry is one registered query and its latest result.
+type prefetchEntry struct {
+	spec PrefetchSpec
+	key  string
+	stop chan struct{} // closed by StopPrefetch
+
+	mu        sync.Mutex
+	result    any
+	fetchedAt time.Time
+	lastErr   error
+}
+
+// prefetcher holds the registered queries by name and by flight key.
+type prefetcher struct {
+	mu     sync.Mutex
+	byName map[string]*prefetchEntry
+	byKey  map[string]*prefetchEntry
+}
+
+// Prefetch registers a query that the service refreshes every
+// spec.Interval in the background until StopPrefetch or Close, trading
+// freshness for latency on slow links. Only reads can be prefetched.
+func (s *service) Prefetch(spec PrefetchSpec) error {
+	if spec.Name == "" || strings.TrimSpace(spec.Query) == "" {
+		return errors.New("prefetch needs Name and Query")
+	}
+	if spec.Interval <= 0 {
+		return errors.New("prefetch interval must be positive")
+	}
+	if !isReadStatement(spec.Query) {
+		return fmt.Errorf("prefetch %s: only SELECT statements can be prefetched", spec.Name)
+	}
+	// Match the statement execWithArgs sends, collection prefix included
+	spec.Query = s.qualify(context.Background(), spec.Query)
+	key, err := flightKey(spec.Query, spec.Args)
+	if err != nil {
+		return fmt.Errorf("prefetch %s: %w", spec.Name, err)
+	}
+
+	s.prefetch.mu.Lock()
+	if s.prefetch.byName == nil {
+		s.prefetch.byName = map[string]*prefetchEntry{}
+		s.prefetch.byKey = map[string]*prefetchEntry{}
+	}
+	if _, dup := s.prefetch.byName[spec.Name]; dup {
+		s.prefetch.mu.Unlock()
+		return fmt.Errorf("prefetch %q already registered", spec.Name)
+	}
+	e := &prefetchEntry{spec: spec, key: key, stop: make(chan struct{})}
+	s.prefetch.byName[spec.Name] = e
+	s.prefetch.byKey[key] = e
+	s.prefetch.mu.Unlock()
+
+	s.goBackground("prefetch:"+spec.Name, func(ctx context.Context) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			select {
+			case <-e.stop:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		for {
+			res, err := s.post(ctx, spec.Query, spec.Args)
+			if ctx.Err() != nil {
+				return
+			}
+			e.mu.Lock()
+			if err == nil {
+				e.result, e.fetchedAt = res, s.now()
+			}
+			e.lastErr = err
+			e.mu.Unlock()
+			if s.sleep(ctx, spec.Interval) != nil {
+				return
+			}
+		}
+	})
+	return nil
+}
+
+// Prefetched returns the warm result of the named query. ok is false when
+// the name is unknown or no refresh has succeeded yet.
+func (s *service) Prefetched(name string) (PrefetchedResult, bool) {
+	s.prefetch.mu.Lock()
+	e := s.prefetch.byName[name]
+	s.prefetch.mu.Unlock()
+	if e == nil {
+		return PrefetchedResult{}, false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	out := PrefetchedResult{Result: e.result, FetchedAt: e.fetchedAt, LastError: e.lastErr}
+	if e.fetchedAt.IsZero() {
+		return out, false
+	}
+	out.Age = s.now().Sub(e.fetchedAt)
+	return out, true
+}
+
+// StopPrefetch stops refreshing the named query and drops its result.
+func (s *service) StopPrefetch(name string) {
+	s.prefetch.mu.Lock()
+	e := s.prefetch.byName[name]
+	if e != nil {
+		delete(s.prefetch.byName, name)
+		delete(s.prefetch.byKey, e.key)
+	}
+	s.prefetch.mu.Unlock()
+	if e != nil {
+		close(e.stop)
+	}
+}
+
+// warm returns a prefetched result for query and args when one is
+// registered with MaxStale and fresh enough.
+func (s *service) warm(query string, args map[string]any) (any, bool) {
+	s.prefetch.mu.Lock()
+	empty := len(s.prefetch.byKey) == 0
+	s.prefetch.mu.Unlock()
+	if empty {
+		return nil, false
+	}
+	key, err := flightKey(query, args)
+	if err != nil {
+		return nil, false
+	}
+	s.prefetch.mu.Lock()
+	e := s.prefetch.byKey[key]
+	s.prefetch.mu.Unlock()
+	if e == nil || e.spec.MaxStale <= 0 {
+		return nil, false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.fetchedAt.IsZero() || s.now().Sub(e.fetchedAt) > e.spec.MaxStale {
+		return nil, false
+	}
+	return e.result, true
+}
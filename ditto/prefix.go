@@ -0,0 +1,115 @@
+package ditto
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// prefixKey is the context key for WithCollectionPrefixContext.
+type prefixKey struct{}
+
+// WithCollectionPrefix prefixes every collection name in the statements the
+// service sends (e.g. "staging_" turns orders into staging_orders), so
+// several environments can share one edge database. A prefix set on the
+// context with WithCollectionPrefixContext overrides it. Prefixed names
+// are quoted when needed, so a prefix such as "staging-" is fine; it panics
+// if prefix contains a backtick.
+func (s *service) WithCollectionPrefix(prefix string) *service {
+	mustValidPrefix(prefix)
+	s.collPrefix = prefix
+	return s
+}
+
+// WithCollectionPrefixContext returns a context whose statements use prefix
+// instead of the service's collection prefix; an empty prefix disables
+// prefixing for the context. It panics if prefix contains a backtick.
+func WithCollectionPrefixContext(ctx context.Context, prefix string) context.Context {
+	mustValidPrefix(prefix)
+	return context.WithValue(ctx, prefixKey{}, prefix)
+}
+
+// mustValidPrefix rejects prefixes that could not be part of a quoted
+// collection name without escaping.
+func mustValidPrefix(prefix string) {
+	if strings.Contains(prefix, "`") {
+		panic(fmt.Sprintf("ditto: collection prefix %q contains a backtick", prefix))
+	}
+}
+
+// collectionPrefix returns the prefix in effect for ctx.
+func (s *service) collectionPrefix(ctx context.Context) string {
+	if p, ok := ctx.Value(prefixKey{}).(string); ok {
+		return p
+	}
+	return s.collPrefix
+}
+
+// contextPrefixed reports whether ctx overrides the service prefix, in which
+// case per-collection state keyed by bare names (the record cache) is
+// bypassed.
+func (s *service) contextPrefixed(ctx context.Context) bool {
+	p, ok := ctx.Value(prefixKey{}).(string)
+	return ok && p != s.collPrefix
+}
+
+// collectionRef matches a collection reference after FROM, INTO, or UPDATE,
+// optionally introduced by the COLLECTION keyword; group 2 is the name,
+// plain or backtick-quoted with doubled backticks inside.
+var collectionRef = regexp.MustCompile("(?i)\\b((?:FROM|INTO|UPDATE)\\s+(?:COLLECTION\\s+)?)(`(?:[^`]|``)*`|[A-Za-z_][A-Za-z0-9_:]*)")
+
+// qualify rewrites the collection references in query with the prefix in
+// effect for ctx. Each name is unquoted, prefixed, and escaped again, so
+// the result is always a single identifier. String literals are left
+// untouched, as are system collections (names containing ':').
+func (s *service) qualify(ctx context.Context, query string) string {
+	prefix := s.collectionPrefix(ctx)
+	if prefix == "" {
+		return query
+	}
+	var b strings.Builder
+	for i, part := range splitLiterals(query) {
+		if i%2 == 1 {
+			b.WriteString(part)
+			continue
+		}
+		b.WriteString(collectionRef.ReplaceAllStringFunc(part, func(m string) string {
+			sub := collectionRef.FindStringSubmatch(m)
+			name := sub[2]
+			if strings.HasPrefix(name, "`") {
+				name = strings.ReplaceAll(name[1:len(name)-1], "``", "`")
+			}
+			if strings.Contains(name, ":") {
+				return m
+			}
+			return sub[1] + escapeIdent(prefix+name)
+		}))
+	}
+	return b.String()
+}
+
+// splitLiterals splits query into alternating code and quoted-literal
+// parts (code first), so rewrites can skip string contents.
+func splitLiterals(query string) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(query); i++ {
+		q := query[i]
+		if q != '\'' && q != '"' {
+			continue
+		}
+		parts = append(parts, query[start:i])
+		j := i + 1
+		for j < len(query) && query[j] != q {
+			if query[j] == '\\' {
+				j++
+			}
+			j++
+		}
+		j = min(j+1, len(query))
+		parts = append(parts, query[i:j])
+		start, i = j, j-1
+	}
+	return append(parts, query[start:])
+}
@@ -0,0 +1,80 @@
+package ditto
+
+import (
+	"context"
+	"testing"
+)
+
+func TestQualify(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		query  string
+		want   string
+	}{
+		{
+			name:   "plain prefix",
+			prefix: "staging_",
+			query:  "SELECT * FROM orders WHERE _id == :id LIMIT 1",
+			want:   "SELECT * FROM staging_orders WHERE _id == :id LIMIT 1",
+		},
+		{
+			name:   "prefix that needs quoting",
+			prefix: "staging-",
+			query:  "SELECT * FROM orders WHERE _id == :id LIMIT 1",
+			want:   "SELECT * FROM `staging-orders` WHERE _id == :id LIMIT 1",
+		},
+		{
+			name:   "already quoted name",
+			prefix: "staging_",
+			query:  "UPDATE `my orders` SET a = 1",
+			want:   "UPDATE `staging_my orders` SET a = 1",
+		},
+		{
+			name:   "quoted name with escaped backtick",
+			prefix: "s ",
+			query:  "INSERT INTO `a``b` DOCUMENTS (:doc)",
+			want:   "INSERT INTO `s a``b` DOCUMENTS (:doc)",
+		},
+		{
+			name:   "quoted name that no longer needs quotes",
+			prefix: "s_",
+			query:  "DELETE FROM `orders` WHERE _id == :id",
+			want:   "DELETE FROM s_orders WHERE _id == :id",
+		},
+		{
+			name:   "literals and system collections untouched",
+			prefix: "s-",
+			query:  "SELECT * FROM `__system:info` WHERE note == \"FROM orders\"",
+			want:   "SELECT * FROM `__system:info` WHERE note == \"FROM orders\"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewService("http://localhost:8090", "app").WithCollectionPrefix(tt.prefix)
+			got := s.qualify(context.Background(), tt.query)
+			if got != tt.want {
+				t.Fatalf("got  %s\nwant %s", got, tt.want)
+			}
+			if err := ValidateDQL(got, map[string]any{"id": 1, "doc": 1}); err != nil {
+				t.Fatalf("%s: %v", got, err)
+			}
+		})
+	}
+}
+
+func TestCollectionPrefixRejectsBacktick(t *testing.T) {
+	for name, set := range map[string]func(){
+		"service": func() { NewService("http://localhost:8090", "app").WithCollectionPrefix("a`b") },
+		"context": func() { WithCollectionPrefixContext(context.Background(), "`") },
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("no panic")
+				}
+			}()
+			set()
+		})
+	}
+}
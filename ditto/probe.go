@@ -0,0 +1,98 @@
+package ditto
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// defaultProbeTimeout bounds each custom probe run by Status.
+const defaultProbeTimeout = 5 * time.Second
+
+// ProbeFunc checks one dependency for Status and returns a value worth
+// reporting (a count, a version) or an error.
+type ProbeFunc func(ctx context.Context) (any, error)
+
+// ProbeResult is the outcome of one custom probe, reported by Status under
+// "probes".
+type ProbeResult struct {
+	OK      bool          `json:"ok"`
+	Value   any           `json:"value,omitempty"`
+	Error   string        `json:"error,omitempty"`
+	Latency time.Duration `json:"latency"`
+}
+
+// namedProbe is a probe registered with WithProbe.
+type namedProbe struct {
+	name string
+	fn   ProbeFunc
+}
+
+// WithProbeQuery sets the statement Status and the Docker readiness wait use
+// to check the HTTP API. The default reads one document of an SDK-reserved
+// collection, so it touches no application data and works on any database.
+func (s *service) WithProbeQuery(query string) *service {
+	s.probeStatement = query
+	return s
+}
+
+// WithProbe registers an additional check run by Status; its result appears
+// under "probes" keyed by name. Probes run concurrently, each bounded by 5s
+// unless the Status context is shorter. Registering a name again replaces
+// the earlier probe.
+func (s *service) WithProbe(name string, fn ProbeFunc) *service {
+	for i, p := range s.probes {
+		if p.name == name {
+			s.probes[i].fn = fn
+			return s
+		}
+	}
+	s.probes = append(s.probes, namedProbe{name: name, fn: fn})
+	return s
+}
+
+// QueryProbe returns a ProbeFunc that runs query through svc and reports
+// how many items it returned, e.g. to check that a collection is reachable.
+func QueryProbe(svc Service, query string, args map[string]any) ProbeFunc {
+	return func(ctx context.Context) (any, error) {
+		res, err := svc.Execute(ctx, query, args)
+		if err != nil {
+			return nil, err
+		}
+		return len(resultItems(res)), nil
+	}
+}
+
+// probeQuery returns the configured probe statement.
+func (s *service) probeQuery() string {
+	if s.probeStatement != "" {
+		return s.probeStatement
+	}
+	return defaultProbeQuery
+}
+
+// runProbes runs every registered probe concurrently.
+func (s *service) runProbes(ctx context.Context) map[string]ProbeResult {
+	out := make(map[string]ProbeResult, len(s.probes))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, p := range s.probes {
+		wg.Add(1)
+		go func(p namedProbe) {
+			defer wg.Done()
+			pctx, cancel := context.WithTimeout(ctx, defaultProbeTimeout)
+			defer cancel()
+			start := s.now()
+			v, err := p.fn(pctx)
+			r := ProbeResult{OK: err == nil, Value: v, Latency: s.now().Sub(start)}
+			if err != nil {
+				r.Error = err.Error()
+			}
+			mu.Lock()
+			out[p.name] = r
+			mu.Unlock()
+		}(p)
+	}
+	wg.Wait()
+	return out
+}
@@ -0,0 +1,100 @@
+package ditto
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ProgressFunc receives progress of a long-running operation: done items
+// so far, the expected total (-1 when unknown), and the average rate in
+// items per second since the operation started. It is called after every
+// page or batch and once more when the operation succeeds. Calls never
+// overlap, but they come from the goroutines doing the work, so fn should
+// return quickly.
+type ProgressFunc func(done, total int64, rate float64)
+
+// progressKey is the context key for WithProgress.
+type progressKey struct{}
+
+// WithProgress returns a context that reports the progress of the bulk
+// operation it is passed to: ExportCollection and ImportCollection
+// (documents), CopyCollection and RenameCollection (documents copied),
+// UpdateMany and ApplyRetention (documents removed or updated), and
+// ExecuteBatch (statements). Where the total is not known up front (imports,
+// filtered copies, retention) it is -1; for exports and whole-collection
+// copies it comes from a COUNT(*) issued only when progress is requested.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// progressTracker counts done items for a ProgressFunc. A nil tracker
+// ignores every call, so operations can report unconditionally.
+type progressTracker struct {
+	fn    ProgressFunc
+	now   func() time.Time
+	start time.Time
+	mu    sync.Mutex
+	done  int64
+	total int64
+}
+
+// progress returns a tracker for the ProgressFunc on ctx, or nil if there is
+// none. total is only called when a tracker is needed.
+func (s *service) progress(ctx context.Context, total func() int64) *progressTracker {
+	fn, ok := ctx.Value(progressKey{}).(ProgressFunc)
+	if !ok || fn == nil {
+		return nil
+	}
+	return &progressTracker{fn: fn, now: s.now, start: s.now(), total: total()}
+}
+
+// knownTotal returns a fixed total for progress.
+func knownTotal(n int) func() int64 {
+	return func() int64 { return int64(n) }
+}
+
+// countTotal returns the size of collection for progress, or -1 if it
+// cannot be counted.
+func (s *service) countTotal(ctx context.Context, collection string) func() int64 {
+	return func() int64 {
+		n, err := s.countDocs(ctx, collection)
+		if err != nil {
+			return -1
+		}
+		return int64(n)
+	}
+}
+
+// add records n more items done and reports.
+func (p *progressTracker) add(n int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done += int64(n)
+	p.report()
+}
+
+// finish reports the final count, fixing an unknown total to it.
+func (p *progressTracker) finish() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.total < 0 {
+		p.total = p.done
+	}
+	p.report()
+}
+
+// report calls fn with the current counts; p.mu is held.
+func (p *progressTracker) report() {
+	rate := 0.0
+	if secs := p.now().Sub(p.start).Seconds(); secs > 0 {
+		rate = float64(p.done) / secs
+	}
+	p.fn(p.done, p.total, rate)
+}
@@ -0,0 +1,314 @@
+package ditto
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// queueCompactAfter is how many acknowledged records the log may accumulate
+// before Drain rewrites it with only the pending entries.
+const queueCompactAfter = 1000
+
+// queueRecord is one line of the queue log: a queued statement ("put") or
+// the acknowledgement that it was applied ("ack").
+type queueRecord struct {
+	Seq   uint64         `json:"seq"`
+	Kind  string         `json:"kind"`
+	Query string         `json:"query,omitempty"`
+	Args  map[string]any `json:"args,omitempty"`
+	At    string         `json:"at,omitempty"`
+}
+
+// QueueStats reports the state of a Queue.
+type QueueStats struct {
+	Depth     int       // statements waiting to be sent
+	Enqueued  int64     // statements enqueued since open
+	Sent      int64     // statements applied since open
+	Failed    int64     // send attempts that failed since open
+	Oldest    time.Time // enqueue time of the oldest pending statement
+	Recovered int       // pending statements found in the log at open
+	Truncated bool      // a torn or corrupt tail was dropped at open
+}
+
+// Queue is a disk-backed FIFO of mutations for offline operation. Every
+// statement is appended to a log with a CRC-32 checksum per line before
+// Enqueue returns, so queued writes survive a restart; Drain sends them in
+// order and records an acknowledgement after each.
+//
+// Nothing is sent automatically, not even after a restart: call Drain when
+// the server is reachable, e.g. right after OpenQueue and whenever
+// connectivity returns. Delivery is at-least-once: a crash (or a failed
+// acknowledgement write) after a statement was applied but before its ack
+// reached the log sends that statement again on the next Drain, so queued
+// statements should be idempotent, e.g. upserts keyed by _id.
+type Queue struct {
+	svc   Service
+	path  string
+	clock Clock
+
+	drainMu sync.Mutex // one Drain at a time, so no statement is sent twice
+
+	mu      sync.Mutex
+	f       *os.File
+	pending []queueRecord
+	nextSeq uint64
+	acked   int // ack records in the log since the last compaction
+	stats   QueueStats
+}
+
+// OpenQueue opens (creating if needed) the queue log at path and recovers
+// any statements not yet acknowledged into memory, in their original order.
+// It does not send them; the caller replays them with Drain. A torn last
+// line from a crash is dropped; corruption earlier in the log is an error.
+func OpenQueue(svc Service, path string) (*Queue, error) {
+	if svc == nil || path == "" {
+		return nil, errors.New("queue needs a service and a path")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	clock, _, _ := timeSourcesOf(svc)
+	q := &Queue{svc: svc, path: path, clock: clock, f: f, nextSeq: 1}
+	if err := q.load(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("queue %s: %w", path, err)
+	}
+	q.stats.Recovered = len(q.pending)
+	return q, nil
+}
+
+// load replays the log into memory and positions the file for appends.
+func (q *Queue) load() error {
+	r := bufio.NewReader(q.f)
+	var good int64
+	puts := map[uint64]queueRecord{}
+	var order []uint64
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			break
+		}
+		rec, perr := parseQueueLine(line)
+		if perr != nil || err != nil {
+			// Only the final line may be torn by a crash mid-write
+			if _, more := r.Peek(1); more == nil {
+				return fmt.Errorf("corrupt record at offset %d: %v", good, perr)
+			}
+			q.stats.Truncated = true
+			break
+		}
+		good += int64(len(line))
+		switch rec.Kind {
+		case "put":
+			puts[rec.Seq] = rec
+			order = append(order, rec.Seq)
+		case "ack":
+			delete(puts, rec.Seq)
+			q.acked++
+		}
+		if rec.Seq >= q.nextSeq {
+			q.nextSeq = rec.Seq + 1
+		}
+	}
+	for _, seq := range order {
+		if rec, ok := puts[seq]; ok {
+			q.pending = append(q.pending, rec)
+		}
+	}
+	if err := q.f.Truncate(good); err != nil {
+		return err
+	}
+	_, err := q.f.Seek(good, io.SeekStart)
+	return err
+}
+
+// parseQueueLine verifies and decodes "<crc32 hex> <json>\n".
+func parseQueueLine(line []byte) (queueRecord, error) {
+	var rec queueRecord
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	sum, body, ok := bytes.Cut(line, []byte(" "))
+	if !ok {
+		return rec, errors.New("missing checksum")
+	}
+	if fmt.Sprintf("%08x", crc32.ChecksumIEEE(body)) != string(sum) {
+		return rec, errors.New("checksum mismatch")
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&rec); err != nil {
+		return rec, err
+	}
+	return rec, nil
+}
+
+// append writes one record and syncs it to disk.
+func (q *Queue) append(rec queueRecord) error {
+	body, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line := fmt.Sprintf("%08x %s\n", crc32.ChecksumIEEE(body), body)
+	if _, err := q.f.WriteString(line); err != nil {
+		return err
+	}
+	return q.f.Sync()
+}
+
+// Enqueue durably queues a mutation. It returns once the statement is on
+// disk; it is sent by Drain.
+func (q *Queue) Enqueue(st Statement) error {
+	if strings.TrimSpace(st.Query) == "" {
+		return errors.New("query required")
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.f == nil {
+		return errors.New("queue closed")
+	}
+	rec := queueRecord{Seq: q.nextSeq, Kind: "put", Query: st.Query, Args: st.Args, At: FormatTimestamp(q.clock.Now())}
+	if err := q.append(rec); err != nil {
+		return fmt.Errorf("queue append: %w", err)
+	}
+	q.nextSeq++
+	q.pending = append(q.pending, rec)
+	q.stats.Enqueued++
+	return nil
+}
+
+// Depth returns how many statements are waiting to be sent.
+func (q *Queue) Depth() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+// Stats returns queue depth and counters.
+func (q *Queue) Stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	st := q.stats
+	st.Depth = len(q.pending)
+	if len(q.pending) > 0 {
+		st.Oldest, _ = ParseTimestamp(q.pending[0].At)
+	}
+	return st
+}
+
+// Drain sends pending statements in order until the queue is empty, ctx is
+// done, or a statement fails; the failed statement stays at the head so
+// order is preserved. It returns how many statements were sent. A statement
+// is acknowledged only after Execute succeeds, so one applied just before a
+// crash is sent again (at-least-once delivery). Statements
+// are sent without holding the queue lock, so Enqueue is never blocked by
+// the network; ones enqueued during a Drain are sent by the same Drain.
+func (q *Queue) Drain(ctx context.Context) (int, error) {
+	q.drainMu.Lock()
+	defer q.drainMu.Unlock()
+	sent := 0
+	for {
+		q.mu.Lock()
+		if q.f == nil {
+			q.mu.Unlock()
+			return sent, errors.New("queue closed")
+		}
+		batch := append([]queueRecord(nil), q.pending...)
+		q.mu.Unlock()
+		if len(batch) == 0 {
+			break
+		}
+		for _, rec := range batch {
+			if err := ctx.Err(); err != nil {
+				return sent, err
+			}
+			if _, err := q.svc.Execute(ctx, rec.Query, rec.Args); err != nil {
+				q.mu.Lock()
+				q.stats.Failed++
+				q.mu.Unlock()
+				return sent, fmt.Errorf("queue drain seq %d: %w", rec.Seq, err)
+			}
+			if err := q.ack(rec); err != nil {
+				return sent, err
+			}
+			sent++
+		}
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.f != nil && q.acked >= queueCompactAfter {
+		if err := q.compact(); err != nil {
+			return sent, fmt.Errorf("queue compact: %w", err)
+		}
+	}
+	return sent, nil
+}
+
+// ack records that rec was sent and removes it from the head of pending.
+// Only Drain removes records, so the head is rec.
+func (q *Queue) ack(rec queueRecord) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.f == nil {
+		return errors.New("queue closed")
+	}
+	if err := q.append(queueRecord{Seq: rec.Seq, Kind: "ack"}); err != nil {
+		return fmt.Errorf("queue ack: %w", err)
+	}
+	q.pending = q.pending[1:]
+	q.acked++
+	q.stats.Sent++
+	return nil
+}
+
+// compact rewrites the log with only the pending records.
+func (q *Queue) compact() error {
+	tmp := q.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	old := q.f
+	q.f = f
+	for _, rec := range q.pending {
+		if err := q.append(rec); err != nil {
+			q.f = old
+			f.Close()
+			os.Remove(tmp)
+			return err
+		}
+	}
+	if err := os.Rename(tmp, q.path); err != nil {
+		q.f = old
+		f.Close()
+		return err
+	}
+	old.Close()
+	q.acked = 0
+	return nil
+}
+
+// Close closes the log. Pending statements remain on disk for the next open.
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.f == nil {
+		return nil
+	}
+	err := q.f.Close()
+	q.f = nil
+	return err
+}
@@ -0,0 +1,133 @@
+package ditto
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// openTestQueue opens a queue at path whose statements go to a fake server,
+// returning the statements the server received.
+func openTestQueue(t *testing.T, path string) (*Queue, *fakeExecute) {
+	t.Helper()
+	s, f := newFakeService(t, func(query string, args map[string]any) any { return nil })
+	q, err := OpenQueue(s, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { q.Close() })
+	return q, f
+}
+
+func TestQueueRecoversAfterTornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.log")
+	q, _ := openTestQueue(t, path)
+	for _, id := range []string{"a", "b", "c"} {
+		if err := q.Enqueue(Statement{Query: "INSERT INTO t DOCUMENTS (:d)", Args: map[string]any{"d": id}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q.Close()
+
+	// A crash mid-write leaves a partial last line
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`1a2b3c4d {"seq":4,"kind":"pu`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	q, srv := openTestQueue(t, path)
+	st := q.Stats()
+	if !st.Truncated || st.Recovered != 3 || st.Depth != 3 {
+		t.Fatalf("stats after reopen = %+v, want 3 recovered and a truncated tail", st)
+	}
+	if n := len(srv.sent()); n != 0 {
+		t.Fatalf("%d statements sent on open, want none before Drain", n)
+	}
+	// New statements append after the recovered ones, not after the torn line
+	if err := q.Enqueue(Statement{Query: "INSERT INTO t DOCUMENTS (:d)", Args: map[string]any{"d": "d"}}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := q.Drain(context.Background()); err != nil || n != 4 {
+		t.Fatalf("Drain = %d, %v; want 4 sent", n, err)
+	}
+	q.Close()
+
+	q, _ = openTestQueue(t, path)
+	if st := q.Stats(); st.Truncated || st.Recovered != 0 {
+		t.Fatalf("stats after drain and reopen = %+v, want a clean empty queue", st)
+	}
+}
+
+func TestQueueRejectsCorruptionBeforeTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.log")
+	q, _ := openTestQueue(t, path)
+	for i := 0; i < 2; i++ {
+		if err := q.Enqueue(Statement{Query: "DELETE FROM t WHERE _id == :id", Args: map[string]any{"id": i}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q.Close()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b[12] ^= 0xff // inside the first record
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, _ := newFakeService(t, func(query string, args map[string]any) any { return nil })
+	if _, err := OpenQueue(s, path); err == nil || !strings.Contains(err.Error(), "corrupt record") {
+		t.Fatalf("OpenQueue = %v, want a corrupt record error", err)
+	}
+}
+
+func TestQueueCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.log")
+	q, srv := openTestQueue(t, path)
+	ctx := context.Background()
+	for i := 0; i < queueCompactAfter; i++ {
+		if err := q.Enqueue(Statement{Query: "UPDATE t SET n = :n WHERE _id == :id", Args: map[string]any{"n": i, "id": "x"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, err := q.Drain(ctx); err != nil || n != queueCompactAfter {
+		t.Fatalf("Drain = %d, %v", n, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 0 {
+		t.Fatalf("log is %d bytes after draining %d acks, want it compacted to empty", info.Size(), queueCompactAfter)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("temporary compaction file left behind: %v", err)
+	}
+
+	// The compacted log keeps working: statements queued afterwards survive
+	// a reopen in order
+	want := []string{"DELETE FROM t WHERE _id == :a", "DELETE FROM t WHERE _id == :b"}
+	for _, query := range want {
+		if err := q.Enqueue(Statement{Query: query, Args: map[string]any{"a": 1, "b": 2}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q.Close()
+	q, srv = openTestQueue(t, path)
+	if st := q.Stats(); st.Recovered != 2 {
+		t.Fatalf("recovered %d after compaction, want 2", st.Recovered)
+	}
+	if _, err := q.Drain(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.sent(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("replayed %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,120 @@
+package ditto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrQuotaExceeded is returned when a write would break a collection quota.
+var ErrQuotaExceeded = errors.New("ditto: quota exceeded")
+
+// defaultQuotaCheckInterval is how long a counted document total is trusted
+// before it is refreshed from the database.
+const defaultQuotaCheckInterval = time.Minute
+
+// Quota limits what the SDK will write into one collection. Zero fields are
+// unlimited.
+type Quota struct {
+	MaxDocumentBytes int // encoded JSON size of an inserted document or patch
+	MaxDocuments     int // documents in the collection, tombstones included
+	// CheckInterval is how often the document count is re-read; between
+	// checks inserts are added to the last count, so the limit errs on the
+	// side of rejecting.
+	CheckInterval time.Duration
+}
+
+// quotaState is a Quota plus the last observed document count.
+type quotaState struct {
+	Quota
+	mu      sync.Mutex
+	count   int
+	checked time.Time
+}
+
+// WithQuota enforces q on writes made through the SDK to collection, so a
+// runaway producer cannot fill a small edge device. Violations fail with
+// ErrQuotaExceeded before anything is sent.
+func (s *service) WithQuota(collection string, q Quota) *service {
+	if q.CheckInterval <= 0 {
+		q.CheckInterval = defaultQuotaCheckInterval
+	}
+	if s.quotas == nil {
+		s.quotas = map[string]*quotaState{}
+	}
+	s.quotas[collection] = &quotaState{Quota: q}
+	return s
+}
+
+// checkInsertQuota validates docs about to be inserted into collection and
+// reserves room for them in the document count.
+func (s *service) checkInsertQuota(ctx context.Context, collection string, docs ...map[string]any) error {
+	qs := s.quotas[collection]
+	if qs == nil {
+		return nil
+	}
+	for _, d := range docs {
+		if err := qs.checkSize(collection, d); err != nil {
+			return err
+		}
+	}
+	if qs.MaxDocuments <= 0 {
+		return nil
+	}
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+	if s.now().Sub(qs.checked) >= qs.CheckInterval {
+		n, err := s.countDocs(ctx, collection)
+		if err != nil {
+			return fmt.Errorf("quota %s: count: %w", collection, err)
+		}
+		qs.count, qs.checked = n, s.now()
+	}
+	if qs.count+len(docs) > qs.MaxDocuments {
+		return fmt.Errorf("%w: %s holds %d of %d documents", ErrQuotaExceeded, collection, qs.count, qs.MaxDocuments)
+	}
+	qs.count += len(docs)
+	return nil
+}
+
+// checkPatchQuota validates the size of an update patch.
+func (s *service) checkPatchQuota(collection string, patch map[string]any) error {
+	if qs := s.quotas[collection]; qs != nil {
+		return qs.checkSize(collection, patch)
+	}
+	return nil
+}
+
+func (qs *quotaState) checkSize(collection string, v map[string]any) error {
+	if qs.MaxDocumentBytes <= 0 {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if len(b) > qs.MaxDocumentBytes {
+		return fmt.Errorf("%w: %s document is %d bytes, limit %d", ErrQuotaExceeded, collection, len(b), qs.MaxDocumentBytes)
+	}
+	return nil
+}
+
+// countDocs returns the number of documents in collection.
+func (s *service) countDocs(ctx context.Context, collection string) (int, error) {
+	res, err := s.execWithArgs(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s", escapeIdent(collection)), nil)
+	if err != nil {
+		return 0, err
+	}
+	// The count comes back as the single value of the single row
+	for _, d := range Documents(res) {
+		for _, v := range d {
+			if n, ok := toInt64(v); ok {
+				return int(n), nil
+			}
+		}
+	}
+	return 0, errors.New("unexpected COUNT(*) response")
+}
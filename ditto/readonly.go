@@ -0,0 +1,51 @@
+package ditto
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrReadOnly is returned for any statement that could write while the
+// service is in read-only mode.
+var ErrReadOnly = errors.New("ditto: service is read-only")
+
+// readOnlyVerbs are the leading DQL keywords allowed in read-only mode.
+var readOnlyVerbs = map[string]bool{"SELECT": true, "EXPLAIN": true}
+
+// WithReadOnly puts the service in read-only mode: every mutating method,
+// including Execute with a non-SELECT statement and helpers built on top of
+// the service, fails with ErrReadOnly before a request is sent. Meant for
+// reporting services and dashboards that must never write to edge databases.
+func (s *service) WithReadOnly() *service {
+	s.readOnly = true
+	return s
+}
+
+// checkWritable rejects query when the service is read-only and the
+// statement is not a plain read. It is applied where requests are sent, so
+// no write path can bypass it.
+func (s *service) checkWritable(query string) error {
+	if !s.readOnly {
+		return nil
+	}
+	if isReadStatement(query) {
+		return nil
+	}
+	return fmt.Errorf("%w: refusing %s", ErrReadOnly, statementVerb(query))
+}
+
+// statementVerb returns the leading keyword of query, upper case. Any
+// whitespace ends it, so "SELECT\n*" is a SELECT.
+func statementVerb(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToUpper(fields[0])
+}
+
+// isReadStatement reports whether query cannot write.
+func isReadStatement(query string) bool {
+	return readOnlyVerbs[statementVerb(query)]
+}
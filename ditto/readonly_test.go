@@ -0,0 +1,32 @@
+package ditto
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckWritable(t *testing.T) {
+	s := NewService("http://localhost:8090", "app").WithReadOnly()
+	for _, q := range []string{
+		"SELECT * FROM cars",
+		"SELECT\n* FROM cars",
+		"SELECT\t* FROM cars",
+		"  \n\tselect * FROM cars",
+		"EXPLAIN\nSELECT * FROM cars",
+	} {
+		if err := s.checkWritable(q); err != nil {
+			t.Errorf("checkWritable(%q) = %v, want allowed", q, err)
+		}
+	}
+	for _, q := range []string{
+		"UPDATE cars SET a = 1",
+		"DELETE\nFROM cars",
+		"INSERT\tINTO cars DOCUMENTS (:d)",
+		"",
+		"   ",
+	} {
+		if err := s.checkWritable(q); !errors.Is(err, ErrReadOnly) {
+			t.Errorf("checkWritable(%q) = %v, want ErrReadOnly", q, err)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package ditto
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Readiness defaults used when DockerOptions leaves ReadyTimeout and
+// ReadyInterval at zero.
+const (
+	defaultReadyTimeout  = 30 * time.Second
+	defaultReadyInterval = 250 * time.Millisecond
+	maxReadyInterval     = 5 * time.Second
+	defaultProbeQuery    = "SELECT * FROM ditto_sdk_probe LIMIT 1"
+)
+
+// waitReady polls the Ditto HTTP API with a lightweight query until it
+// succeeds, backing off exponentially from ReadyInterval up to
+// maxReadyInterval. It gives up after ReadyTimeout with the last probe error.
+// A negative ReadyTimeout disables the wait.
+func (s *service) waitReady(ctx context.Context) error {
+	maxWait := s.dockerOpts.ReadyTimeout
+	if maxWait < 0 {
+		return nil
+	}
+	if maxWait == 0 {
+		maxWait = defaultReadyTimeout
+	}
+	interval := s.dockerOpts.ReadyInterval
+	if interval <= 0 {
+		interval = defaultReadyInterval
+	}
+
+	deadline := s.now().Add(maxWait)
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		// Bound each probe so a hung connection doesn't eat the whole budget
+		pctx, cancel := context.WithTimeout(ctx, interval+time.Second)
+		_, lastErr = s.execWithArgs(pctx, s.probeQuery(), nil)
+		cancel()
+		if lastErr == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if s.now().Add(interval).After(deadline) {
+			return fmt.Errorf("ditto http not ready after %s (%d attempts): %w", maxWait, attempt, lastErr)
+		}
+		if err := s.sleep(ctx, interval); err != nil {
+			return err
+		}
+		interval *= 2
+		if interval > maxReadyInterval {
+			interval = maxReadyInterval
+		}
+	}
+}
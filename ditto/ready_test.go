@@ -0,0 +1,63 @@
+package ditto
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWaitReadyBackoff(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int // probes that fail before the server answers
+		wantSleep []time.Duration
+		wantErr   bool
+	}{
+		{
+			name:      "ready on the fourth probe",
+			failures:  3,
+			wantSleep: []time.Duration{250 * time.Millisecond, 500 * time.Millisecond, time.Second},
+		},
+		{
+			// Doubling stops at maxReadyInterval, and the wait gives up when
+			// the next sleep would pass the 20s budget
+			name:     "never ready",
+			failures: -1,
+			wantSleep: []time.Duration{
+				250 * time.Millisecond, 500 * time.Millisecond, time.Second, 2 * time.Second,
+				4 * time.Second, 5 * time.Second, 5 * time.Second,
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probes := 0
+			s, _ := newFakeService(t, func(query string, args map[string]any) any {
+				probes++
+				if tt.failures < 0 || probes <= tt.failures {
+					return errors.New("starting up")
+				}
+				return nil
+			})
+			clock := newFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+			s.WithClock(clock).WithSleeper(clock)
+			s.dockerOpts = DockerOptions{ReadyTimeout: 20 * time.Second, ReadyInterval: 250 * time.Millisecond}
+
+			err := s.waitReady(context.Background())
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "not ready after 20s") {
+					t.Fatalf("err = %v, want a not-ready error", err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(clock.sleeps, tt.wantSleep) {
+				t.Fatalf("sleeps = %v, want %v", clock.sleeps, tt.wantSleep)
+			}
+		})
+	}
+}
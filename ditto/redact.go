@@ -0,0 +1,108 @@
+package ditto
+
+import (
+	"path"
+	"strings"
+)
+
+// Redacted replaces argument values that RedactionRules match.
+const Redacted = "[REDACTED]"
+
+// RedactionRules select the query_args values kept out of logs.
+type RedactionRules struct {
+	// ArgNames are glob patterns (path.Match syntax, case-insensitive)
+	// matched against argument names, e.g. "*email*" or "name". A matching
+	// argument is redacted whole.
+	ArgNames []string
+	// FieldPaths are dotted paths into argument values, starting with the
+	// argument name; "*" matches any one segment and array elements are
+	// walked transparently. "doc.customer.email" redacts that field of the
+	// doc argument, "*.phone" the phone field of any argument.
+	FieldPaths []string
+}
+
+// WithQueryLogging adds the statement and its arguments to each access log
+// record (see WithAccessLog), as attributes query and args. Argument values
+// matched by rules are replaced with Redacted before they reach the logger;
+// everything else is logged verbatim, so list every argument or field that
+// can carry personal data.
+func (s *service) WithQueryLogging(rules RedactionRules) *service {
+	s.queryLog = &rules
+	return s
+}
+
+// RedactArgs returns a copy of args with the values matched by rules
+// replaced with Redacted. args itself is not modified.
+func RedactArgs(rules RedactionRules, args map[string]any) map[string]any {
+	if args == nil {
+		return nil
+	}
+	paths := make([][]string, len(rules.FieldPaths))
+	for i, p := range rules.FieldPaths {
+		paths[i] = strings.Split(p, ".")
+	}
+	out := make(map[string]any, len(args))
+	for k, v := range args {
+		if rules.matchName(k) {
+			out[k] = Redacted
+			continue
+		}
+		out[k] = redactValue(v, []string{k}, paths)
+	}
+	return out
+}
+
+// matchName reports whether an ArgNames pattern matches name.
+func (r RedactionRules) matchName(name string) bool {
+	name = strings.ToLower(name)
+	for _, p := range r.ArgNames {
+		if ok, _ := path.Match(strings.ToLower(p), name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// redactValue copies v, redacting the fields whose path from the argument
+// name (at) matches one of paths.
+func redactValue(v any, at []string, paths [][]string) any {
+	for _, p := range paths {
+		if matchSegments(p, at) {
+			return Redacted
+		}
+	}
+	switch t := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(t))
+		for k, x := range t {
+			out[k] = redactValue(x, append(at[:len(at):len(at)], k), paths)
+		}
+		return out
+	case []any:
+		out := make([]any, len(t))
+		for i, x := range t {
+			out[i] = redactValue(x, at, paths)
+		}
+		return out
+	case []map[string]any:
+		out := make([]any, len(t))
+		for i, x := range t {
+			out[i] = redactValue(x, at, paths)
+		}
+		return out
+	}
+	return v
+}
+
+// matchSegments reports whether a FieldPaths pattern matches path at.
+func matchSegments(pattern, at []string) bool {
+	if len(pattern) != len(at) {
+		return false
+	}
+	for i, p := range pattern {
+		if p != "*" && p != at[i] {
+			return false
+		}
+	}
+	return true
+}
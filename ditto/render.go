@@ -0,0 +1,191 @@
+package ditto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+)
+
+// maxCellWidth truncates table cells so one large field can't push the
+// other columns off screen.
+const maxCellWidth = 60
+
+// ANSI colors used by RenderJSON.
+const (
+	ansiReset  = "\x1b[0m"
+	ansiKey    = "\x1b[34m" // blue
+	ansiString = "\x1b[32m" // green
+	ansiNumber = "\x1b[33m" // yellow
+	ansiBool   = "\x1b[35m" // magenta
+	ansiNull   = "\x1b[90m" // grey
+)
+
+// RenderTable writes the documents of res (a decoded response or a
+// []map[string]any) to w as an aligned text table, one row per document.
+// columns selects and orders the columns; by default every top-level field
+// appears, _id first and the rest sorted. Nested values are shown as
+// compact JSON and long cells are truncated.
+func RenderTable(w io.Writer, res any, columns ...string) error {
+	docs, ok := res.([]map[string]any)
+	if !ok {
+		docs = Documents(res)
+	}
+	if len(columns) == 0 {
+		columns = tableColumns(docs)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(columns, "\t"))
+	rule := make([]string, len(columns))
+	for i, c := range columns {
+		rule[i] = strings.Repeat("-", utf8.RuneCountInString(c))
+	}
+	fmt.Fprintln(tw, strings.Join(rule, "\t"))
+	cells := make([]string, len(columns))
+	for _, d := range docs {
+		for i, c := range columns {
+			v, ok := d[c]
+			cells[i] = ""
+			if ok {
+				cells[i] = tableCell(v)
+			}
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	fmt.Fprintf(tw, "(%d rows)\n", len(docs))
+	return tw.Flush()
+}
+
+// tableColumns returns the union of the top-level fields of docs, _id first.
+func tableColumns(docs []map[string]any) []string {
+	seen := map[string]bool{}
+	for _, d := range docs {
+		for k := range d {
+			seen[k] = true
+		}
+	}
+	cols := make([]string, 0, len(seen))
+	if seen["_id"] {
+		cols = append(cols, "_id")
+		delete(seen, "_id")
+	}
+	return append(cols, sortedKeys(seen)...)
+}
+
+// tableCell formats v for one table cell on a single line.
+func tableCell(v any) string {
+	var s string
+	switch x := v.(type) {
+	case string:
+		s = x
+	case nil:
+		s = "null"
+	case map[string]any, []any:
+		b, err := json.Marshal(x)
+		if err != nil {
+			s = fmt.Sprint(x)
+		} else {
+			s = string(b)
+		}
+	default:
+		s = fmt.Sprint(x)
+	}
+	s = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ").Replace(s)
+	if utf8.RuneCountInString(s) > maxCellWidth {
+		s = string([]rune(s)[:maxCellWidth-1]) + "…"
+	}
+	return s
+}
+
+// RenderJSON writes v to w as indented JSON with sorted keys. With color,
+// keys and scalar values are highlighted with ANSI escapes for terminals.
+func RenderJSON(w io.Writer, v any, color bool) error {
+	bw := bufio.NewWriter(w)
+	if err := writeJSON(bw, v, "", color); err != nil {
+		return err
+	}
+	bw.WriteByte('\n')
+	return bw.Flush()
+}
+
+// writeJSON writes v at the given indent.
+func writeJSON(w *bufio.Writer, v any, indent string, color bool) error {
+	paint := func(code, s string) {
+		if color {
+			w.WriteString(code + s + ansiReset)
+		} else {
+			w.WriteString(s)
+		}
+	}
+	switch x := v.(type) {
+	case map[string]any:
+		if len(x) == 0 {
+			w.WriteString("{}")
+			return nil
+		}
+		w.WriteString("{\n")
+		for i, k := range sortedKeys(x) {
+			w.WriteString(indent + "  ")
+			kb, _ := json.Marshal(k)
+			paint(ansiKey, string(kb))
+			w.WriteString(": ")
+			if err := writeJSON(w, x[k], indent+"  ", color); err != nil {
+				return err
+			}
+			if i < len(x)-1 {
+				w.WriteByte(',')
+			}
+			w.WriteByte('\n')
+		}
+		w.WriteString(indent + "}")
+	case []any:
+		if len(x) == 0 {
+			w.WriteString("[]")
+			return nil
+		}
+		w.WriteString("[\n")
+		for i, e := range x {
+			w.WriteString(indent + "  ")
+			if err := writeJSON(w, e, indent+"  ", color); err != nil {
+				return err
+			}
+			if i < len(x)-1 {
+				w.WriteByte(',')
+			}
+			w.WriteByte('\n')
+		}
+		w.WriteString(indent + "]")
+	case nil:
+		paint(ansiNull, "null")
+	case bool:
+		paint(ansiBool, strconv.FormatBool(x))
+	case string:
+		b, _ := json.Marshal(x)
+		paint(ansiString, string(b))
+	case float64, json.Number, int, int64:
+		b, err := json.Marshal(x)
+		if err != nil {
+			return err
+		}
+		paint(ansiNumber, string(b))
+	default:
+		// Typed values (structs, []map[string]any): render through JSON
+		b, err := json.Marshal(x)
+		if err != nil {
+			return err
+		}
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		var generic any
+		if err := dec.Decode(&generic); err != nil {
+			return err
+		}
+		return writeJSON(w, generic, indent, color)
+	}
+	return nil
+}
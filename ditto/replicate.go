@@ -0,0 +1,144 @@
+package ditto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ReplicateConflict decides what happens when a replicated document already
+// exists on the destination.
+type ReplicateConflict string
+
+const (
+	// ReplicateSourceWins overwrites the destination document.
+	ReplicateSourceWins ReplicateConflict = "source-wins"
+	// ReplicateNewerWins keeps whichever side has the greater watermark
+	// field; destination documents without it are overwritten.
+	ReplicateNewerWins ReplicateConflict = "newer-wins"
+	// ReplicateKeepDestination never overwrites existing documents.
+	ReplicateKeepDestination ReplicateConflict = "keep-destination"
+)
+
+// defaultWatermarkField is the timestamp field Replicate uses to find
+// documents changed since the last run.
+const defaultWatermarkField = "updated_at"
+
+// ReplicateOptions configures Replicate.
+type ReplicateOptions struct {
+	// WatermarkField holds a sortable timestamp (as written by
+	// FormatTimestamp); defaults to updated_at.
+	WatermarkField string
+	// Since maps collection -> watermark from a previous run's result.
+	// Collections without an entry are copied in full.
+	Since    map[string]string
+	Conflict ReplicateConflict // defaults to ReplicateSourceWins
+	Batch    int               // documents per page; defaults to maxDocsPerInsert
+}
+
+// ReplicateResult reports one Replicate run.
+type ReplicateResult struct {
+	Copied  map[string]int
+	Skipped map[string]int
+	// Watermarks maps collection -> greatest watermark seen; pass it back as
+	// ReplicateOptions.Since for the next incremental run.
+	Watermarks map[string]string
+}
+
+// Replicate copies documents of the given collections from src to dst,
+// outside of Ditto's own sync, e.g. between an edge node and a central
+// instance. With Since set only documents whose watermark field is greater
+// than the stored watermark are read. Both sides may be any Service.
+func Replicate(ctx context.Context, src, dst Service, collections []string, opts ReplicateOptions) (*ReplicateResult, error) {
+	if src == nil || dst == nil {
+		return nil, errors.New("source and destination services required")
+	}
+	field := opts.WatermarkField
+	if field == "" {
+		field = defaultWatermarkField
+	}
+	if opts.Conflict == "" {
+		opts.Conflict = ReplicateSourceWins
+	}
+	batch := opts.Batch
+	if batch <= 0 {
+		batch = maxDocsPerInsert
+	}
+
+	out := &ReplicateResult{Copied: map[string]int{}, Skipped: map[string]int{}, Watermarks: map[string]string{}}
+	for _, c := range collections {
+		since := opts.Since[c]
+		out.Watermarks[c] = since
+		for offset := 0; ; offset += batch {
+			page := QueryOptions{Limit: batch, Offset: offset, SortBy: field, SortOrder: "ASC", IncludeDeleted: true}
+			var res any
+			var err error
+			if since != "" {
+				res, err = src.FindWhere(ctx, c, Where(escapePath(field)+" > :since", map[string]any{"since": since}), page)
+			} else {
+				res, err = src.FindRecords(ctx, c, nil, page)
+			}
+			if err != nil {
+				return out, fmt.Errorf("replicate %s: read: %w", c, err)
+			}
+			docs := Documents(res)
+			write, err := replicateFilter(ctx, dst, c, field, opts.Conflict, docs)
+			if err != nil {
+				return out, fmt.Errorf("replicate %s: %w", c, err)
+			}
+			onConflict := "DO UPDATE"
+			if opts.Conflict == ReplicateKeepDestination {
+				onConflict = "DO NOTHING"
+			}
+			if err := insertDocs(ctx, dst, c, write, onConflict); err != nil {
+				return out, fmt.Errorf("replicate %s: write: %w", c, err)
+			}
+			out.Copied[c] += len(write)
+			out.Skipped[c] += len(docs) - len(write)
+			for _, d := range docs {
+				if w, ok := d[field].(string); ok && w > out.Watermarks[c] {
+					out.Watermarks[c] = w
+				}
+			}
+			if len(docs) < batch {
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+// replicateFilter drops the documents the conflict policy says to keep on
+// the destination. Only ReplicateNewerWins needs to look at dst.
+func replicateFilter(ctx context.Context, dst Service, collection, field string, policy ReplicateConflict, docs []map[string]any) ([]map[string]any, error) {
+	switch policy {
+	case ReplicateSourceWins, ReplicateKeepDestination:
+		return docs, nil
+	case ReplicateNewerWins:
+	default:
+		return nil, fmt.Errorf("unknown conflict policy %q", policy)
+	}
+	if len(docs) == 0 {
+		return docs, nil
+	}
+	ids := make([]string, len(docs))
+	for i, d := range docs {
+		ids[i] = fmt.Sprint(d["_id"])
+	}
+	res, err := dst.GetRecordsByIDs(ctx, collection, ids)
+	if err != nil {
+		return nil, fmt.Errorf("read destination: %w", err)
+	}
+	current := map[string]any{}
+	for _, d := range Documents(res) {
+		current[fmt.Sprint(d["_id"])] = d[field]
+	}
+	var keep []map[string]any
+	for i, d := range docs {
+		if theirs, ok := current[ids[i]]; ok && compareValues(d[field], theirs) <= 0 {
+			continue
+		}
+		keep = append(keep, d)
+	}
+	return keep, nil
+}
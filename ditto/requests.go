@@ -0,0 +1,74 @@
+package ditto
+
+import (
+	"context"
+	"errors"
+)
+
+// GetRecordsRequest lists documents of a collection. New read options are
+// added to QueryOptions rather than to method signatures.
+type GetRecordsRequest struct {
+	Collection string
+	QueryOptions
+}
+
+// SearchRequest filters documents by exact-match field values.
+type SearchRequest struct {
+	Collection string
+	Filters    map[string]string
+	QueryOptions
+}
+
+// LatestRecordRequest fetches the newest document ordered by SortBy.
+type LatestRecordRequest struct {
+	Collection string
+	SortBy     string
+	QueryOptions
+}
+
+// UpdateRecordRequest patches one document by _id.
+type UpdateRecordRequest struct {
+	Collection string
+	ID         string
+	Patch      map[string]any
+}
+
+// UpdateWhereRequest patches every document matching Where.
+type UpdateWhereRequest struct {
+	Collection string
+	Where      Predicate
+	Patch      map[string]any
+}
+
+// GetRecordsWith is the request-struct form of GetRecords.
+func (s *service) GetRecordsWith(ctx context.Context, req GetRecordsRequest) (any, error) {
+	return s.FindRecords(ctx, req.Collection, nil, req.QueryOptions)
+}
+
+// SearchWith is the request-struct form of Search.
+func (s *service) SearchWith(ctx context.Context, req SearchRequest) (any, error) {
+	return s.FindRecords(ctx, req.Collection, req.Filters, req.QueryOptions)
+}
+
+// LatestRecordWith is the request-struct form of LatestRecord; Limit and
+// SortOrder default to 1 and DESC.
+func (s *service) LatestRecordWith(ctx context.Context, req LatestRecordRequest) (any, error) {
+	if req.SortBy == "" {
+		return nil, errors.New("sortBy required")
+	}
+	return s.FindRecords(ctx, req.Collection, nil,
+		QueryOptions{Limit: 1, SortOrder: "DESC"},
+		req.QueryOptions,
+		QueryOptions{SortBy: req.SortBy},
+	)
+}
+
+// UpdateRecordWith is the request-struct form of UpdateRecord.
+func (s *service) UpdateRecordWith(ctx context.Context, req UpdateRecordRequest) (any, error) {
+	return s.UpdateRecord(ctx, req.Collection, req.ID, req.Patch)
+}
+
+// UpdateWhereWith is the request-struct form of UpdateWhere.
+func (s *service) UpdateWhereWith(ctx context.Context, req UpdateWhereRequest) (any, error) {
+	return s.UpdateWhere(ctx, req.Collection, req.Where, req.Patch)
+}
@@ -0,0 +1,91 @@
+package ditto
+
+import (
+	"fmt"
+	"strings"
+)
+
+// resultItems returns the "items" array from a decoded /execute response, or
+// nil when the response has no items.
+func resultItems(res any) []any {
+	m, ok := res.(map[string]any)
+	if !ok {
+		return nil
+	}
+	items, _ := m["items"].([]any)
+	return items
+}
+
+// Documents returns the items of a decoded /execute response that are JSON
+// objects, e.g. the documents returned by GetRecords or Search.
+func Documents(res any) []map[string]any {
+	items := resultItems(res)
+	docs := make([]map[string]any, 0, len(items))
+	for _, it := range items {
+		if doc, ok := it.(map[string]any); ok {
+			docs = append(docs, doc)
+		}
+	}
+	return docs
+}
+
+// QueryResult is a typed view of a decoded /execute response. Values keep the
+// representation they were decoded with: json.Number under WithPreciseNumbers
+// (so int64 ids and decimal amounts are exact end to end), float64 otherwise.
+type QueryResult struct {
+	Items      []any
+	MutatedIDs []any
+}
+
+// ResultOf wraps a response returned by Execute, GetRecords, FindWhere, and
+// the other query methods.
+func ResultOf(res any) QueryResult {
+	return QueryResult{Items: resultItems(res), MutatedIDs: mutatedIDs(res)}
+}
+
+// Documents returns the items that are JSON objects.
+func (r QueryResult) Documents() []map[string]any {
+	return Documents(map[string]any{"items": r.Items})
+}
+
+// Scan converts the items into dst, a pointer to a slice of structs or maps,
+// like the package-level Scan.
+func (r QueryResult) Scan(dst any) error {
+	return Scan(map[string]any{"items": r.Items}, dst)
+}
+
+// ScanOne converts the first item into dst, a pointer to a struct or map. It
+// returns ErrNotFound when the result is empty.
+func (r QueryResult) ScanOne(dst any) error {
+	if len(r.Items) == 0 {
+		return ErrNotFound
+	}
+	if err := convertJSON(r.Items[0], dst); err != nil {
+		return fmt.Errorf("scan: %w", err)
+	}
+	return nil
+}
+
+// mutatedIDs returns the "mutatedDocumentIds" reported by Ditto for a
+// mutating statement.
+func mutatedIDs(res any) []any {
+	m, ok := res.(map[string]any)
+	if !ok {
+		return nil
+	}
+	ids, _ := m["mutatedDocumentIds"].([]any)
+	return ids
+}
+
+// lookupPath returns the value at a dotted path inside doc, or nil.
+func lookupPath(doc map[string]any, path string) any {
+	var cur any = doc
+	for _, seg := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil
+		}
+		cur = m[seg]
+	}
+	return cur
+}
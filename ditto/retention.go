@@ -0,0 +1,183 @@
+package ditto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// RetentionPolicy declares how long documents in a collection are kept.
+// MaxAge and KeepLast may be combined; a document is removed when either
+// rule selects it.
+type RetentionPolicy struct {
+	Collection string
+	TimeField  string        // timestamp field ordering documents; defaults to "created_at"
+	MaxAge     time.Duration // remove documents older than this; zero disables
+	KeepLast   int           // keep only the newest KeepLast documents per Key; zero disables
+	Key        string        // grouping field for KeepLast; empty means the whole collection
+	// Delete removes documents with DELETE, which syncs the removal to
+	// peers. The default EVICT only frees local storage.
+	Delete   bool
+	Interval time.Duration // schedule period for ScheduleRetention; defaults to 1h
+}
+
+// RetentionReport describes one retention pass. In a dry run nothing is
+// removed and IDs lists what would be.
+type RetentionReport struct {
+	Collection string
+	DryRun     bool
+	Verb       string    // "EVICT" or "DELETE"
+	Cutoff     time.Time // MaxAge horizon; zero when MaxAge is unset
+	Expired    int       // documents selected by MaxAge (dry run only; see Removed)
+	Trimmed    int       // documents selected by KeepLast
+	Removed    int       // documents reported removed by the server
+	IDs        []string  // selected ids, sorted; dry run only
+}
+
+// ApplyRetention enforces p once. With dryRun it only reports which
+// documents the policy selects.
+func (s *service) ApplyRetention(ctx context.Context, p RetentionPolicy, dryRun bool) (RetentionReport, error) {
+	ctx, cancel := s.opDeadline(ctx, opBulk)
+	defer cancel()
+	p, err := p.withDefaults()
+	if err != nil {
+		return RetentionReport{}, err
+	}
+	rep := RetentionReport{Collection: p.Collection, DryRun: dryRun, Verb: "EVICT"}
+	if p.Delete {
+		rep.Verb = "DELETE"
+	}
+	selected := map[string]bool{}
+	prog := s.progress(ctx, knownTotal(-1))
+
+	if p.MaxAge > 0 {
+		rep.Cutoff = s.now().Add(-p.MaxAge)
+		where := Where(fmt.Sprintf("%s < :cutoff", escapePath(p.TimeField)),
+			map[string]any{"cutoff": FormatTimestamp(rep.Cutoff)})
+		if dryRun {
+			err := s.scanWhere(ctx, p.Collection, &where, QueryOptions{Fields: []string{"_id"}, IncludeDeleted: true},
+				func(docs []map[string]any) error {
+					for _, d := range docs {
+						selected[fmt.Sprint(d["_id"])] = true
+						rep.Expired++
+					}
+					return nil
+				})
+			if err != nil {
+				return rep, fmt.Errorf("retention %s: %w", p.Collection, err)
+			}
+		} else {
+			q := fmt.Sprintf("%s FROM %s WHERE %s", rep.Verb, escapeIdent(p.Collection), where.Clause)
+			//dittovet:ignore rep.Verb is DELETE or EVICT, set by the SDK
+			res, err := s.execWithArgs(ctx, q, where.Args)
+			if err != nil {
+				return rep, fmt.Errorf("retention %s: %w", p.Collection, err)
+			}
+			rep.Removed += len(mutatedIDs(res))
+			prog.add(len(mutatedIDs(res)))
+			s.cache.invalidateCollection(p.Collection)
+		}
+	}
+
+	if p.KeepLast > 0 {
+		trim, err := s.retentionTrim(ctx, p)
+		if err != nil {
+			return rep, fmt.Errorf("retention %s: %w", p.Collection, err)
+		}
+		var ids []string
+		for _, id := range trim {
+			if !selected[id] {
+				selected[id] = true
+				ids = append(ids, id)
+			}
+		}
+		rep.Trimmed = len(ids)
+		if !dryRun {
+			if err := s.removeIDs(ctx, rep.Verb, p.Collection, ids, prog); err != nil {
+				return rep, fmt.Errorf("retention %s: %w", p.Collection, err)
+			}
+			rep.Removed += len(ids)
+			s.cache.invalidate(p.Collection, ids...)
+		}
+	}
+
+	if dryRun {
+		rep.IDs = sortedKeys(selected)
+	}
+	prog.finish()
+	return rep, nil
+}
+
+// retentionTrim returns the ids beyond the newest p.KeepLast per key.
+func (s *service) retentionTrim(ctx context.Context, p RetentionPolicy) ([]string, error) {
+	type entry struct {
+		id string
+		ts any
+	}
+	groups := map[string][]entry{}
+	fields := []string{"_id", p.TimeField}
+	if p.Key != "" {
+		fields = append(fields, p.Key)
+	}
+	err := s.scanWhere(ctx, p.Collection, nil, QueryOptions{Fields: fields, IncludeDeleted: true},
+		func(docs []map[string]any) error {
+			for _, d := range docs {
+				var k string
+				if p.Key != "" {
+					k = fmt.Sprint(lookupPath(d, p.Key))
+				}
+				groups[k] = append(groups[k], entry{fmt.Sprint(d["_id"]), lookupPath(d, p.TimeField)})
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, es := range groups {
+		if len(es) <= p.KeepLast {
+			continue
+		}
+		sort.SliceStable(es, func(i, j int) bool { return compareValues(es[i].ts, es[j].ts) > 0 })
+		for _, e := range es[p.KeepLast:] {
+			out = append(out, e.id)
+		}
+	}
+	return out, nil
+}
+
+// ScheduleRetention enforces p every p.Interval as a maintenance job named
+// "retention:<collection>".
+func (s *service) ScheduleRetention(p RetentionPolicy) error {
+	p, err := p.withDefaults()
+	if err != nil {
+		return err
+	}
+	return s.ScheduleMaintenance(MaintenanceJob{
+		Name:     "retention:" + p.Collection,
+		Interval: p.Interval,
+		Run: func(ctx context.Context) error {
+			_, err := s.ApplyRetention(ctx, p, false)
+			return err
+		},
+	})
+}
+
+// withDefaults validates p and fills in defaulted fields.
+func (p RetentionPolicy) withDefaults() (RetentionPolicy, error) {
+	if p.Collection == "" {
+		return p, errors.New("collection required")
+	}
+	if p.MaxAge <= 0 && p.KeepLast <= 0 {
+		return p, errors.New("retention policy needs MaxAge or KeepLast")
+	}
+	if p.TimeField == "" {
+		p.TimeField = "created_at"
+	}
+	if p.Interval <= 0 {
+		p.Interval = time.Hour
+	}
+	return p, nil
+}
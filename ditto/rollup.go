@@ -0,0 +1,226 @@
+package ditto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RollupJob declares a downsampling job: readings in Source are aggregated
+// per Key and Window into Target, and raw readings older than Retention are
+// evicted once they have been rolled up.
+type RollupJob struct {
+	Name      string
+	Source    string        // raw telemetry collection
+	Target    string        // rollup collection
+	TimeField string        // timestamp field; defaults to "ts"
+	Key       string        // grouping field, e.g. "device_id"; empty rolls up all readings together
+	Values    []string      // numeric fields to aggregate
+	Window    time.Duration // bucket width, e.g. time.Minute
+	// Lag holds back the newest windows so late readings can arrive; a
+	// window is rolled up once it closed at least Lag ago. Defaults to
+	// Window. Readings arriving later than that are not rolled up.
+	Lag       time.Duration
+	Retention time.Duration // evict raw readings older than this; zero keeps them
+	Interval  time.Duration // schedule period; defaults to Window
+}
+
+// RollupResult reports one run of a RollupJob.
+type RollupResult struct {
+	From, To time.Time // rolled-up range, From inclusive (zero on the first run), To exclusive
+	Readings int       // source documents aggregated
+	Buckets  int       // rollup documents written
+	Evicted  bool      // whether an EVICT ran
+}
+
+// rollupBucket accumulates one key and window.
+type rollupBucket struct {
+	key    any
+	start  time.Time
+	count  int
+	fields map[string]*rollupStat
+}
+
+// rollupStat accumulates one value field.
+type rollupStat struct {
+	n             int
+	sum, min, max float64
+}
+
+// ScheduleRollup validates job and schedules it as a maintenance job named
+// job.Name. It can also be run on demand with RunRollup.
+func (s *service) ScheduleRollup(job RollupJob) error {
+	job, err := job.withDefaults()
+	if err != nil {
+		return err
+	}
+	return s.ScheduleMaintenance(MaintenanceJob{
+		Name:     job.Name,
+		Interval: job.Interval,
+		Run: func(ctx context.Context) error {
+			_, err := s.RunRollup(ctx, job)
+			return err
+		},
+	})
+}
+
+// RunRollup performs one pass of job. It continues from the newest
+// window_end already in Target (or from the oldest raw reading on the first
+// run, so nothing is evicted before it has been rolled up), aggregates every
+// closed window since, upserts the buckets, and then evicts raw readings
+// that are both past Retention and rolled up.
+//
+// Each Target document has _id "<key>@<window_start>" (just
+// "@<window_start>" without a Key), the key field, window_start, window_end,
+// count, and for every value field an object with count, sum, avg, min, and
+// max.
+func (s *service) RunRollup(ctx context.Context, job RollupJob) (RollupResult, error) {
+	job, err := job.withDefaults()
+	if err != nil {
+		return RollupResult{}, err
+	}
+	now := s.now()
+	res := RollupResult{To: now.Add(-job.Lag).Truncate(job.Window)}
+
+	latest, err := s.LatestRecordWith(ctx, LatestRecordRequest{Collection: job.Target, SortBy: "window_end"})
+	if err != nil {
+		return res, fmt.Errorf("rollup %s: read checkpoint: %w", job.Name, err)
+	}
+	// Without a checkpoint From stays zero: the first run has no lower bound
+	if docs := Documents(latest); len(docs) > 0 {
+		res.From, _ = ParseTimestamp(docs[0]["window_end"])
+	}
+
+	if res.From.IsZero() || res.From.Before(res.To) {
+		tf := escapePath(job.TimeField)
+		clause := fmt.Sprintf("%s < :rollup_to", tf)
+		args := map[string]any{"rollup_to": FormatTimestamp(res.To)}
+		if !res.From.IsZero() {
+			clause = fmt.Sprintf("%s >= :rollup_from AND %s", tf, clause)
+			args["rollup_from"] = FormatTimestamp(res.From)
+		}
+		buckets := map[string]*rollupBucket{}
+		where := Where(clause, args)
+		err := s.scanWhere(ctx, job.Source, &where, QueryOptions{}, func(docs []map[string]any) error {
+			for _, d := range docs {
+				if job.add(buckets, d) {
+					res.Readings++
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return res, fmt.Errorf("rollup %s: read source: %w", job.Name, err)
+		}
+		out := make([]map[string]any, 0, len(buckets))
+		for id, b := range buckets {
+			out = append(out, job.document(id, b))
+		}
+		if err := s.upsertDocs(ctx, job.Target, out); err != nil {
+			return res, fmt.Errorf("rollup %s: write: %w", job.Name, err)
+		}
+		res.Buckets = len(out)
+	}
+
+	if job.Retention > 0 {
+		cutoff := now.Add(-job.Retention)
+		if res.To.Before(cutoff) {
+			cutoff = res.To
+		}
+		q := fmt.Sprintf("EVICT FROM %s WHERE %s < :cutoff", escapeIdent(job.Source), escapePath(job.TimeField))
+		if _, err := s.execWithArgs(ctx, q, map[string]any{"cutoff": FormatTimestamp(cutoff)}); err != nil {
+			return res, fmt.Errorf("rollup %s: evict: %w", job.Name, err)
+		}
+		s.cache.invalidateCollection(job.Source)
+		res.Evicted = true
+	}
+	return res, nil
+}
+
+// withDefaults validates j and fills in defaulted fields.
+func (j RollupJob) withDefaults() (RollupJob, error) {
+	if j.Name == "" || j.Source == "" || j.Target == "" {
+		return j, errors.New("rollup job needs Name, Source, and Target")
+	}
+	if j.Source == j.Target {
+		return j, errors.New("rollup cannot target its own source")
+	}
+	if len(j.Values) == 0 {
+		return j, errors.New("rollup job needs at least one value field")
+	}
+	if j.Window <= 0 {
+		return j, errors.New("rollup window must be positive")
+	}
+	if j.TimeField == "" {
+		j.TimeField = "ts"
+	}
+	if j.Lag <= 0 {
+		j.Lag = j.Window
+	}
+	if j.Interval <= 0 {
+		j.Interval = j.Window
+	}
+	return j, nil
+}
+
+// add folds one reading into its bucket, reporting whether it had a
+// parseable timestamp.
+func (j RollupJob) add(buckets map[string]*rollupBucket, d map[string]any) bool {
+	ts, ok := ParseTimestamp(lookupPath(d, j.TimeField))
+	if !ok {
+		return false
+	}
+	start := ts.Truncate(j.Window)
+	var key any
+	id := "@" + FormatTimestamp(start)
+	if j.Key != "" {
+		key = lookupPath(d, j.Key)
+		id = fmt.Sprint(key) + id
+	}
+	b, ok := buckets[id]
+	if !ok {
+		b = &rollupBucket{key: key, start: start, fields: map[string]*rollupStat{}}
+		buckets[id] = b
+	}
+	b.count++
+	for _, f := range j.Values {
+		v, ok := toFloat(lookupPath(d, f))
+		if !ok {
+			continue
+		}
+		st, ok := b.fields[f]
+		if !ok {
+			st = &rollupStat{min: v, max: v}
+			b.fields[f] = st
+		}
+		st.n++
+		st.sum += v
+		st.min = min(st.min, v)
+		st.max = max(st.max, v)
+	}
+	return true
+}
+
+// document renders bucket b as a Target document.
+func (j RollupJob) document(id string, b *rollupBucket) map[string]any {
+	doc := map[string]any{
+		"_id":          id,
+		"window_start": FormatTimestamp(b.start),
+		"window_end":   FormatTimestamp(b.start.Add(j.Window)),
+		"count":        b.count,
+	}
+	if j.Key != "" {
+		doc[j.Key] = b.key
+	}
+	for f, st := range b.fields {
+		doc[f] = map[string]any{
+			"count": st.n,
+			"sum":   st.sum,
+			"avg":   st.sum / float64(st.n),
+			"min":   st.min,
+			"max":   st.max,
+		}
+	}
+	return doc
+}
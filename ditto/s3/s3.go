@@ -0,0 +1,432 @@
+// Package s3 stores export and backup archives in S3-compatible object
+// storage (AWS S3, MinIO, and similar) using only the standard library.
+// A Client implements ditto.BackupStore, so Backup can stream archives
+// straight to a bucket and RestoreBackupFrom can read them back without
+// intermediate local files.
+//
+// Uploads of unknown length use multipart upload with PartSize parts held
+// in memory one at a time, so memory use stays bounded regardless of the
+// archive size.
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Hammerstone-AU/ditto-go-sdk/ditto"
+)
+
+// Part size limits of the S3 multipart API.
+const (
+	minPartSize     = 5 << 20
+	defaultPartSize = 8 << 20
+	maxParts        = 10000
+)
+
+// ErrNotFound is returned by Open for a missing object.
+var ErrNotFound = errors.New("s3: object not found")
+
+// Client reads and writes objects in one bucket.
+type Client struct {
+	Endpoint     string // e.g. "https://s3.eu-west-1.amazonaws.com" or "http://minio:9000"
+	Region       string // signing region; MinIO accepts "us-east-1"
+	Bucket       string
+	Prefix       string // key prefix prepended to every name, e.g. "edge-01/"
+	AccessKey    string
+	SecretKey    string
+	SessionToken string // for temporary credentials
+	// VirtualHost addresses the bucket as a subdomain of the endpoint
+	// (bucket.s3.amazonaws.com); the default path style
+	// (endpoint/bucket/key) is what MinIO expects.
+	VirtualHost bool
+	PartSize    int // multipart part size; defaults to 8 MiB, minimum 5 MiB
+	HTTP        *http.Client
+	Clock       ditto.Clock // signing time source; nil means system
+}
+
+// Client must satisfy ditto.BackupStore
+var _ ditto.BackupStore = (*Client)(nil)
+
+// Object is one entry returned by ListObjects.
+type Object struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// Upload stores the contents of r under name. Content up to one part is
+// sent in a single PUT; larger content uses multipart upload, which is
+// aborted if any part fails.
+func (c *Client) Upload(ctx context.Context, name string, r io.Reader) error {
+	size := c.partSize()
+	first, err := readPart(r, size)
+	if err != nil {
+		return err
+	}
+	if len(first) < size {
+		return c.do(ctx, http.MethodPut, name, nil, first, nil)
+	}
+
+	var created struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := c.do(ctx, http.MethodPost, name, url.Values{"uploads": {""}}, nil, &created); err != nil {
+		return fmt.Errorf("s3 create upload %s: %w", name, err)
+	}
+	type part struct {
+		Number int    `xml:"PartNumber"`
+		ETag   string `xml:"ETag"`
+	}
+	var parts []part
+	abort := func(err error) error {
+		q := url.Values{"uploadId": {created.UploadID}}
+		_ = c.do(context.WithoutCancel(ctx), http.MethodDelete, name, q, nil, nil)
+		return fmt.Errorf("s3 upload %s: %w", name, err)
+	}
+	for buf, n := first, 1; len(buf) > 0; n++ {
+		if n > maxParts {
+			return abort(errors.New("object exceeds the multipart part limit; raise PartSize"))
+		}
+		q := url.Values{"partNumber": {fmt.Sprint(n)}, "uploadId": {created.UploadID}}
+		resp, err := c.send(ctx, http.MethodPut, name, q, buf)
+		if err != nil {
+			return abort(err)
+		}
+		resp.Body.Close()
+		parts = append(parts, part{Number: n, ETag: resp.Header.Get("ETag")})
+		if len(buf) < size {
+			break
+		}
+		if buf, err = readPart(r, size); err != nil {
+			return abort(err)
+		}
+	}
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []part   `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return abort(err)
+	}
+	if err := c.do(ctx, http.MethodPost, name, url.Values{"uploadId": {created.UploadID}}, body, nil); err != nil {
+		return abort(err)
+	}
+	return nil
+}
+
+// Open returns a reader streaming the object stored under name.
+func (c *Client) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	resp, err := c.send(ctx, http.MethodGet, name, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Remove deletes the object stored under name; a missing object is not an
+// error.
+func (c *Client) Remove(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, name, nil, nil, nil)
+}
+
+// List returns the names (without Client.Prefix) of objects starting with
+// prefix.
+func (c *Client) List(ctx context.Context, prefix string) ([]string, error) {
+	objs, err := c.ListObjects(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(objs))
+	for i, o := range objs {
+		names[i] = o.Key
+	}
+	return names, nil
+}
+
+// ListObjects returns the objects whose names start with prefix, following
+// continuation tokens. Keys are reported without Client.Prefix.
+func (c *Client) ListObjects(ctx context.Context, prefix string) ([]Object, error) {
+	var out []Object
+	token := ""
+	for {
+		q := url.Values{"list-type": {"2"}, "prefix": {c.Prefix + prefix}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		var res struct {
+			Contents []struct {
+				Key          string `xml:"Key"`
+				Size         int64  `xml:"Size"`
+				LastModified string `xml:"LastModified"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		if err := c.doBucket(ctx, http.MethodGet, q, &res); err != nil {
+			return nil, fmt.Errorf("s3 list: %w", err)
+		}
+		for _, o := range res.Contents {
+			t, _ := time.Parse(time.RFC3339, o.LastModified)
+			out = append(out, Object{Key: strings.TrimPrefix(o.Key, c.Prefix), Size: o.Size, LastModified: t})
+		}
+		if !res.IsTruncated || res.NextContinuationToken == "" {
+			return out, nil
+		}
+		token = res.NextContinuationToken
+	}
+}
+
+// do sends a request for object name and decodes an XML response into out
+// (ignored when nil).
+func (c *Client) do(ctx context.Context, method, name string, q url.Values, body []byte, out any) error {
+	resp, err := c.send(ctx, method, name, q, body)
+	if err != nil {
+		if method == http.MethodDelete && errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	return decodeXML(resp.Body, out)
+}
+
+// doBucket sends a bucket-level request.
+func (c *Client) doBucket(ctx context.Context, method string, q url.Values, out any) error {
+	req, err := c.request(ctx, method, "", q, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.roundTrip(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decodeXML(resp.Body, out)
+}
+
+// send performs a signed request for object name, returning the response
+// for 2xx statuses.
+func (c *Client) send(ctx context.Context, method, name string, q url.Values, body []byte) (*http.Response, error) {
+	if name == "" {
+		return nil, errors.New("s3: object name required")
+	}
+	req, err := c.request(ctx, method, c.Prefix+name, q, body)
+	if err != nil {
+		return nil, err
+	}
+	return c.roundTrip(req)
+}
+
+// roundTrip sends req and turns non-2xx responses into errors.
+func (c *Client) roundTrip(req *http.Request) (*http.Response, error) {
+	hc := c.HTTP
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	var e struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	_ = xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&e)
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, req.URL.Path)
+	}
+	return nil, fmt.Errorf("s3 http %d: %s %s | %s %s", resp.StatusCode, e.Code, e.Message, req.Method, req.URL.Path)
+}
+
+// request builds a SigV4-signed request for key (empty for the bucket).
+func (c *Client) request(ctx context.Context, method, key string, q url.Values, body []byte) (*http.Request, error) {
+	if c.Endpoint == "" || c.Bucket == "" {
+		return nil, errors.New("s3: Endpoint and Bucket required")
+	}
+	base, err := url.Parse(strings.TrimRight(c.Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	path := "/" + c.Bucket
+	if c.VirtualHost {
+		base.Host = c.Bucket + "." + base.Host
+		path = ""
+	}
+	if key != "" {
+		path += "/" + key
+	}
+	if path == "" {
+		path = "/"
+	}
+	u := *base
+	u.Path = path
+	u.RawPath = awsEscape(path, true)
+	u.RawQuery = canonicalQuery(q)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	if body == nil {
+		req.Body = http.NoBody
+	}
+	c.sign(req, body)
+	return req, nil
+}
+
+// sign adds AWS Signature Version 4 headers to req.
+func (c *Client) sign(req *http.Request, body []byte) {
+	now := time.Now()
+	if c.Clock != nil {
+		now = c.Clock.Now()
+	}
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	region := c.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	sum := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(sum[:])
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if c.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.SessionToken)
+	}
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	if c.SessionToken != "" {
+		headers["x-amz-security-token"] = c.SessionToken
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonHeaders strings.Builder
+	for _, k := range names {
+		canonHeaders.WriteString(k + ":" + strings.TrimSpace(headers[k]) + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonHeaders.String(),
+		signed,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + region + "/s3/aws4_request"
+	creq := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(creq[:])
+
+	key := hmacSHA256([]byte("AWS4"+c.SecretKey), day)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	sig := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.AccessKey, scope, signed, sig))
+}
+
+// partSize returns the configured multipart part size.
+func (c *Client) partSize() int {
+	if c.PartSize <= 0 {
+		return defaultPartSize
+	}
+	return max(c.PartSize, minPartSize)
+}
+
+// readPart reads up to size bytes, returning fewer only at the end of r.
+func readPart(r io.Reader, size int) ([]byte, error) {
+	buf := make([]byte, size)
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	return buf[:n], err
+}
+
+// decodeXML decodes an XML response body into out, draining it when out is
+// nil.
+func decodeXML(r io.Reader, out any) error {
+	if out == nil {
+		_, _ = io.Copy(io.Discard, r)
+		return nil
+	}
+	if err := xml.NewDecoder(r).Decode(out); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// canonicalQuery encodes q with sorted keys and AWS escaping, as SigV4
+// requires.
+func canonicalQuery(q url.Values) string {
+	if len(q) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		vs := append([]string(nil), q[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			parts = append(parts, awsEscape(k, false)+"="+awsEscape(v, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsEscape percent-encodes s as SigV4 specifies: everything except
+// unreserved characters, and '/' too unless keepSlash.
+func awsEscape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch >= 'A' && ch <= 'Z', ch >= 'a' && ch <= 'z', ch >= '0' && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~':
+			b.WriteByte(ch)
+		case ch == '/' && keepSlash:
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+// hmacSHA256 returns HMAC-SHA256(key, data).
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,166 @@
+package ditto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrUnknownSchema is returned when an envelope names a schema or version
+// the registry cannot bring up to date.
+var ErrUnknownSchema = errors.New("ditto: unknown schema")
+
+// UpgradeFunc converts the data of one schema version to the next version.
+type UpgradeFunc func(data map[string]any) (map[string]any, error)
+
+// SchemaRegistry records the current version of each schema and the upgrade
+// steps from older versions, so documents written by older application
+// builds can be read with today's model.
+type SchemaRegistry struct {
+	mu       sync.RWMutex
+	current  map[string]int
+	upgrades map[string]map[int]UpgradeFunc // name -> from-version -> step
+}
+
+// NewSchemaRegistry returns an empty registry.
+func NewSchemaRegistry() *SchemaRegistry {
+	return &SchemaRegistry{current: map[string]int{}, upgrades: map[string]map[int]UpgradeFunc{}}
+}
+
+// Register declares version as the current version of schema name.
+func (r *SchemaRegistry) Register(name string, version int) *SchemaRegistry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.current[name] = version
+	return r
+}
+
+// AddUpgrade registers fn to convert name data from version from to from+1.
+func (r *SchemaRegistry) AddUpgrade(name string, from int, fn UpgradeFunc) *SchemaRegistry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.upgrades[name] == nil {
+		r.upgrades[name] = map[int]UpgradeFunc{}
+	}
+	r.upgrades[name][from] = fn
+	return r
+}
+
+// Envelope is a versioned document: {_id, _schema: "order:v3", data: {...}}.
+type Envelope struct {
+	ID      string
+	Schema  string
+	Version int
+	Data    map[string]any
+}
+
+// Tag returns the _schema value for the envelope, e.g. "order:v3".
+func (e Envelope) Tag() string { return SchemaTag(e.Schema, e.Version) }
+
+// SchemaTag formats a schema name and version as stored in _schema.
+func SchemaTag(name string, version int) string {
+	return name + ":v" + strconv.Itoa(version)
+}
+
+// ParseSchemaTag splits a _schema value such as "order:v3".
+func ParseSchemaTag(tag string) (string, int, error) {
+	name, v, ok := strings.Cut(tag, ":v")
+	if !ok || name == "" {
+		return "", 0, fmt.Errorf("%w: malformed tag %q", ErrUnknownSchema, tag)
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return "", 0, fmt.Errorf("%w: malformed tag %q", ErrUnknownSchema, tag)
+	}
+	return name, n, nil
+}
+
+// Wrap builds the stored form of data at the current version of name.
+func (r *SchemaRegistry) Wrap(id, name string, data map[string]any) (map[string]any, error) {
+	r.mu.RLock()
+	v, ok := r.current[name]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s not registered", ErrUnknownSchema, name)
+	}
+	return map[string]any{"_id": id, "_schema": SchemaTag(name, v), "data": data}, nil
+}
+
+// Unwrap decodes a stored envelope and applies upgrade steps until its data
+// is at the current version of its schema.
+func (r *SchemaRegistry) Unwrap(doc map[string]any) (Envelope, error) {
+	tag, _ := doc["_schema"].(string)
+	name, v, err := ParseSchemaTag(tag)
+	if err != nil {
+		return Envelope{}, err
+	}
+	data, _ := doc["data"].(map[string]any)
+	if data == nil {
+		data = map[string]any{}
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cur, ok := r.current[name]
+	if !ok {
+		return Envelope{}, fmt.Errorf("%w: %s not registered", ErrUnknownSchema, name)
+	}
+	if v > cur {
+		// Written by a newer build; we cannot down-convert
+		return Envelope{}, fmt.Errorf("%w: %s is newer than v%d", ErrUnknownSchema, tag, cur)
+	}
+	for ; v < cur; v++ {
+		step := r.upgrades[name][v]
+		if step == nil {
+			return Envelope{}, fmt.Errorf("%w: no upgrade for %s", ErrUnknownSchema, SchemaTag(name, v))
+		}
+		if data, err = step(data); err != nil {
+			return Envelope{}, fmt.Errorf("upgrade %s: %w", SchemaTag(name, v), err)
+		}
+	}
+	return Envelope{ID: fmt.Sprint(doc["_id"]), Schema: name, Version: cur, Data: data}, nil
+}
+
+// WithSchemaRegistry installs the registry used by PutEnvelope and
+// GetEnvelope.
+func (s *service) WithSchemaRegistry(r *SchemaRegistry) *service {
+	s.schemas = r
+	return s
+}
+
+// PutEnvelope stores data under id wrapped at the current version of schema.
+func (s *service) PutEnvelope(ctx context.Context, collection, id, schema string, data map[string]any) error {
+	if s.schemas == nil {
+		return errors.New("no schema registry; call WithSchemaRegistry")
+	}
+	doc, err := s.schemas.Wrap(id, schema, data)
+	if err != nil {
+		return err
+	}
+	if err := s.upsertDocs(ctx, collection, []map[string]any{doc}); err != nil {
+		return err
+	}
+	s.cache.invalidate(collection, id)
+	return nil
+}
+
+// GetEnvelope reads the envelope stored under id, up-converted to the current
+// schema version. The stored document is left as is; write it back with
+// PutEnvelope to persist the upgrade.
+func (s *service) GetEnvelope(ctx context.Context, collection, id string) (Envelope, error) {
+	if s.schemas == nil {
+		return Envelope{}, errors.New("no schema registry; call WithSchemaRegistry")
+	}
+	res, err := s.GetRecord(ctx, collection, id)
+	if err != nil {
+		return Envelope{}, err
+	}
+	docs := Documents(res)
+	if len(docs) == 0 {
+		return Envelope{}, fmt.Errorf("envelope %s: %w", id, ErrNotFound)
+	}
+	return s.schemas.Unwrap(docs[0])
+}
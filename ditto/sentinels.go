@@ -0,0 +1,26 @@
+package ditto
+
+import "errors"
+
+// patchSentinel marks special patch values that can't be expressed with a
+// plain Go value in map[string]any.
+type patchSentinel struct{ name string }
+
+var (
+	// Null sets a field to null: patch{"nickname": ditto.Null}. A nil value
+	// does the same; Null makes the intent explicit next to Unset.
+	Null = &patchSentinel{"null"}
+	// Unset removes a field from the document: patch{"nickname": ditto.Unset}.
+	Unset = &patchSentinel{"unset"}
+)
+
+// MarshalJSON encodes Null as null and refuses Unset, which only has meaning
+// inside an update patch.
+func (p *patchSentinel) MarshalJSON() ([]byte, error) {
+	if p == Null {
+		return []byte("null"), nil
+	}
+	return nil, errors.New("ditto.Unset is only valid as an update patch value")
+}
+
+func (p *patchSentinel) String() string { return "ditto." + p.name }
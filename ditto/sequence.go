@@ -0,0 +1,77 @@
+package ditto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// defaultSequenceCollection holds one counter document per sequence name.
+const defaultSequenceCollection = "sequences"
+
+// maxSequenceAttempts bounds compare-and-set retries in NextSequence.
+const maxSequenceAttempts = 10
+
+// WithSequenceCollection sets the collection used by NextSequence (default
+// "sequences").
+func (s *service) WithSequenceCollection(collection string) *service {
+	s.sequenceCollection = collection
+	return s
+}
+
+// NextSequence atomically increments the named counter and returns the new
+// value, starting at 1. Each step is a compare-and-set UPDATE on the current
+// value, retried with a short backoff when another writer wins, so values
+// stay unique and increasing across edge app restarts.
+func (s *service) NextSequence(ctx context.Context, name string) (int64, error) {
+	if name == "" {
+		return 0, errors.New("sequence name required")
+	}
+	coll := s.sequenceCollection
+	if coll == "" {
+		coll = defaultSequenceCollection
+	}
+	backoff := 5 * time.Millisecond
+	for attempt := 0; attempt < maxSequenceAttempts; attempt++ {
+		s.cache.invalidate(coll, name)
+		res, err := s.GetRecord(ctx, coll, name)
+		if err != nil {
+			return 0, err
+		}
+		docs := Documents(res)
+		if len(docs) == 0 {
+			// First use: create the counter. DO NOTHING turns a racing
+			// writer's counter into an empty result (retry the increment
+			// path) while any other failure is returned as is
+			q := fmt.Sprintf("INSERT INTO %s DOCUMENTS (:doc) ON ID CONFLICT DO NOTHING", escapeIdent(coll))
+			ins, err := s.execWithArgs(ctx, q, map[string]any{"doc": map[string]any{"_id": name, "value": 1}})
+			if err != nil {
+				return 0, err
+			}
+			if len(mutatedIDs(ins)) > 0 {
+				return 1, nil
+			}
+		} else {
+			cur, ok := toInt64(docs[0]["value"])
+			if !ok {
+				return 0, fmt.Errorf("sequence %q: value is not an integer", name)
+			}
+			upd, err := s.UpdateWhere(ctx, coll,
+				Where("_id == :name AND value == :cur", map[string]any{"name": name, "cur": cur}),
+				map[string]any{"value": cur + 1},
+			)
+			if err != nil {
+				return 0, err
+			}
+			if len(mutatedIDs(upd)) > 0 {
+				return cur + 1, nil
+			}
+		}
+		if err := s.sleep(ctx, backoff); err != nil {
+			return 0, err
+		}
+		backoff *= 2
+	}
+	return 0, fmt.Errorf("sequence %q: %w after %d attempts", name, ErrConflict, maxSequenceAttempts)
+}
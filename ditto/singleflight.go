@@ -0,0 +1,99 @@
+package ditto
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// flightGroup collapses concurrent identical calls into one execution.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// flightCall is one in-flight execution and its outcome.
+type flightCall struct {
+	done chan struct{}
+	res  any
+	err  error
+}
+
+// flightTimeout bounds a shared call whose first caller set no deadline.
+const flightTimeout = 30 * time.Second
+
+// WithSingleflight makes concurrent identical reads (same statement and
+// arguments) share one HTTP round trip, e.g. a burst of GetRecord calls
+// during a UI refresh. Every caller receives the same decoded response, which
+// must not be mutated. A caller whose context ends stops waiting without
+// affecting the others: the shared request keeps the first caller's context
+// values and deadline (30s without one) but not its cancellation.
+func (s *service) WithSingleflight() *service {
+	s.flights = &flightGroup{calls: map[string]*flightCall{}}
+	return s
+}
+
+// do runs fn once for all concurrent callers with the same query and args.
+// fn runs detached from any one caller's cancellation, and a panic in it is
+// returned to every caller as an error rather than leaving them waiting.
+func (g *flightGroup) do(ctx context.Context, query string, args map[string]any, fn func(context.Context) (any, error)) (any, error) {
+	key, err := flightKey(query, args)
+	if err != nil {
+		// Arguments that cannot be normalized are simply not shared
+		return fn(ctx)
+	}
+	g.mu.Lock()
+	c, ok := g.calls[key]
+	if !ok {
+		c = &flightCall{done: make(chan struct{})}
+		g.calls[key] = c
+		go g.run(ctx, key, c, fn)
+	}
+	g.mu.Unlock()
+	select {
+	case <-c.done:
+		return c.res, c.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// run executes the shared call c and releases its waiters.
+func (g *flightGroup) run(ctx context.Context, key string, c *flightCall, fn func(context.Context) (any, error)) {
+	// The caller's deadline carries over; otherwise flightTimeout is measured
+	// by the context's own timer, so no wall-clock reading is mixed with an
+	// injected Clock
+	detached := context.WithoutCancel(ctx)
+	var (
+		fctx   context.Context
+		cancel context.CancelFunc
+	)
+	if deadline, ok := ctx.Deadline(); ok {
+		fctx, cancel = context.WithDeadline(detached, deadline)
+	} else {
+		fctx, cancel = context.WithTimeout(detached, flightTimeout)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			c.res, c.err = nil, fmt.Errorf("ditto: shared request panicked: %v", r)
+		}
+		cancel()
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+	c.res, c.err = fn(fctx)
+}
+
+// flightKey normalizes a statement (whitespace collapsed) and its arguments
+// (canonical JSON) into a map key.
+func flightKey(query string, args map[string]any) (string, error) {
+	b, err := CanonicalJSON(args)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(strings.Fields(query), " ") + "\x00" + string(b), nil
+}
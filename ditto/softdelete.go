@@ -0,0 +1,71 @@
+package ditto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// softDeleteField is the tombstone field set by soft deletes.
+const softDeleteField = "deleted_at"
+
+// WithSoftDelete enables soft-delete for the named collections. DeleteRecord
+// and DeleteAllRecords set deleted_at instead of removing documents, and all
+// read helpers add `deleted_at IS NULL`. Use PurgeDeleted to remove old
+// tombstones for good.
+func (s *service) WithSoftDelete(collections ...string) *service {
+	if s.softDeleted == nil {
+		s.softDeleted = map[string]bool{}
+	}
+	for _, c := range collections {
+		s.softDeleted[c] = true
+	}
+	return s
+}
+
+// PurgeDeleted physically deletes documents in a soft-delete collection whose
+// tombstone is older than olderThan. A zero olderThan purges every tombstone.
+func (s *service) PurgeDeleted(ctx context.Context, collection string, olderThan time.Duration) (any, error) {
+	if collection == "" {
+		return nil, errors.New("collection required")
+	}
+	cutoff := FormatTimestamp(s.now().Add(-olderThan))
+	q := fmt.Sprintf(
+		"DELETE FROM %s WHERE %s IS NOT NULL AND %s <= :cutoff",
+		escapeIdent(collection), softDeleteField, softDeleteField,
+	)
+	return s.execWithArgs(ctx, q, map[string]any{"cutoff": cutoff})
+}
+
+// softDelete tombstones the live documents matching where.
+func (s *service) softDelete(ctx context.Context, collection string, where Predicate) (any, error) {
+	q, args, err := buildUpdateWhere(
+		collection,
+		//dittovet:ignore andLive is the SDK's own soft-delete clause
+		Where("("+where.Clause+")"+s.andLive(collection), where.Args),
+		map[string]any{softDeleteField: FormatTimestamp(s.now())},
+		s.allowReserved,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return s.execWithArgs(ctx, q, args)
+}
+
+// liveClause returns the filter excluding tombstoned documents for
+// soft-delete collections, or "" otherwise.
+func (s *service) liveClause(collection string) string {
+	if !s.softDeleted[collection] {
+		return ""
+	}
+	return softDeleteField + " IS NULL"
+}
+
+// andLive returns liveClause prefixed with " AND ", or "".
+func (s *service) andLive(collection string) string {
+	if c := s.liveClause(collection); c != "" {
+		return " AND " + c
+	}
+	return ""
+}
@@ -0,0 +1,160 @@
+package ditto
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Default log patterns used by the startup watcher when DockerOptions leaves
+// ReadyLogPattern/ErrorLogPattern empty. The error pattern only matches the
+// server's error and fatal level markers (logfmt, JSON, or a level column
+// after the timestamp) and panics, so info lines that merely mention an
+// error, such as error_count=0, do not fail startup.
+const (
+	defaultReadyLogPattern = `(?i)(server started|listening on|http server running)`
+	defaultErrorLogPattern = `(?i:\blevel=(error|fatal)\b|"level"\s*:\s*"(error|fatal)")|^(\S+\s+)?(ERROR|FATAL)\b|panicked at|^panic:`
+	startupExcerptLines    = 20
+)
+
+// LogStreamer is implemented by DockerRunners that can follow container logs.
+// InitDB uses it, when available, to watch the container start up.
+type LogStreamer interface {
+	// StreamLogs follows the container's combined stdout/stderr starting at
+	// since. Closing the reader stops following.
+	StreamLogs(ctx context.Context, name string, since time.Time) (io.ReadCloser, error)
+}
+
+// StartupError reports why the Ditto container did not become ready, with
+// the most recent log lines for context.
+type StartupError struct {
+	Container string
+	Reason    string   // "error log line", "container exited", or "timeout"
+	Crashed   bool     // true when the server logged an error or stopped
+	Excerpt   []string // last log lines seen before giving up
+}
+
+func (e *StartupError) Error() string {
+	msg := fmt.Sprintf("ditto container %s not ready: %s", e.Container, e.Reason)
+	if len(e.Excerpt) > 0 {
+		msg += "\n" + strings.Join(e.Excerpt, "\n")
+	}
+	return msg
+}
+
+// awaitStartupLogs tails the container logs after a start and waits for a
+// ready line, an error line, or the stream ending. It separates slow startup
+// (timeout) from a crash (error line or container exit) and returns a
+// StartupError carrying the log excerpt. No-op unless StartupTimeout is set
+// and the runner implements LogStreamer.
+func (s *service) awaitStartupLogs(ctx context.Context, since time.Time) error {
+	ls, ok := s.docker.(LogStreamer)
+	if !ok || s.dockerOpts.StartupTimeout <= 0 {
+		return nil
+	}
+	ready, err := compileLogPattern(s.dockerOpts.ReadyLogPattern, defaultReadyLogPattern)
+	if err != nil {
+		return fmt.Errorf("ready log pattern: %w", err)
+	}
+	bad, err := compileLogPattern(s.dockerOpts.ErrorLogPattern, defaultErrorLogPattern)
+	if err != nil {
+		return fmt.Errorf("error log pattern: %w", err)
+	}
+
+	wctx, cancel := context.WithTimeout(ctx, s.dockerOpts.StartupTimeout)
+	defer cancel()
+	name := s.dockerOpts.ContainerName
+	rc, err := ls.StreamLogs(wctx, name, since)
+	if err != nil {
+		return fmt.Errorf("stream logs: %w", err)
+	}
+	defer rc.Close()
+
+	// tail stands for ring of recent log lines kept for the error excerpt
+	var tail []string
+	sc := bufio.NewScanner(rc)
+	for sc.Scan() {
+		line := sc.Text()
+		tail = append(tail, line)
+		if len(tail) > startupExcerptLines {
+			tail = tail[1:]
+		}
+		if ready.MatchString(line) {
+			return nil
+		}
+		if bad.MatchString(line) {
+			return &StartupError{Container: name, Reason: "error log line", Crashed: true, Excerpt: tail}
+		}
+	}
+	if errors.Is(wctx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		return &StartupError{Container: name, Reason: "timeout", Excerpt: tail}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	// Stream ended on its own: the container stopped (crash or crash loop)
+	reason := "container exited"
+	if st, err := s.docker.ContainerStatus(ctx, name); err == nil && st != "running" {
+		reason = fmt.Sprintf("container %s", st)
+	}
+	return &StartupError{Container: name, Reason: reason, Crashed: true, Excerpt: tail}
+}
+
+// compileLogPattern compiles pattern, falling back to def when empty.
+func compileLogPattern(pattern, def string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		pattern = def
+	}
+	return regexp.Compile(pattern)
+}
+
+// StreamLogs follows `docker logs` for the container from since onward.
+func (d *dockerRunnerDefault) StreamLogs(ctx context.Context, name string, since time.Time) (io.ReadCloser, error) {
+	return followLogs(ctx, name, since)
+}
+
+// StreamLogs follows `docker logs` for the compose-managed container.
+func (d *composeRunnerDefault) StreamLogs(ctx context.Context, name string, since time.Time) (io.ReadCloser, error) {
+	return followLogs(ctx, name, since)
+}
+
+// followLogs starts `docker logs --follow` and returns a reader over its
+// merged stdout/stderr. Closing the reader kills the process.
+func followLogs(ctx context.Context, name string, since time.Time) (io.ReadCloser, error) {
+	args := []string{"logs", "--follow"}
+	if !since.IsZero() {
+		args = append(args, "--since", since.UTC().Format(time.RFC3339))
+	}
+	args = append(args, name)
+	traceCommand(ctx, DockerCommand{Name: "docker", Args: args, ReadOnly: true})
+	cctx, cancel := context.WithCancel(ctx)
+	cmd := exec.CommandContext(cctx, "docker", args...)
+	pr, pw := io.Pipe()
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return nil, fmt.Errorf("docker logs: %w", err)
+	}
+	go func() {
+		pw.CloseWithError(cmd.Wait())
+	}()
+	return &logReader{PipeReader: pr, cancel: cancel}, nil
+}
+
+// logReader stops the `docker logs` process when closed.
+type logReader struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+}
+
+func (r *logReader) Close() error {
+	r.cancel()
+	return r.PipeReader.Close()
+}
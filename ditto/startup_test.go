@@ -0,0 +1,34 @@
+package ditto
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestDefaultErrorLogPattern(t *testing.T) {
+	re := regexp.MustCompile(defaultErrorLogPattern)
+	for _, line := range []string{
+		`time=2024-05-01T10:00:00Z level=error msg="bind failed"`,
+		`level=FATAL msg="data dir unwritable"`,
+		`{"timestamp":"2024-05-01T10:00:00Z","level":"ERROR","message":"bind failed"}`,
+		`2024-05-01T10:00:00.123Z ERROR ditto_server: bind failed`,
+		`FATAL: config invalid`,
+		`thread 'main' panicked at src/main.rs:10:5:`,
+		`panic: runtime error: index out of range`,
+	} {
+		if !re.MatchString(line) {
+			t.Errorf("%q not matched", line)
+		}
+	}
+	for _, line := range []string{
+		`time=2024-05-01T10:00:00Z level=info msg="sync stats" error_count=0`,
+		`level=info msg="no error"`,
+		`{"level":"info","message":"recovered from error"}`,
+		`2024-05-01T10:00:00.123Z INFO ditto_server: error budget ok, not fatal`,
+		`level=warn msg="retrying after error"`,
+	} {
+		if re.MatchString(line) {
+			t.Errorf("%q matched", line)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package ditto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// defaultStreamRetries is how many times a broken stream is reopened.
+const defaultStreamRetries = 3
+
+// StreamOptions configures StreamQuery.
+type StreamOptions struct {
+	// Retries is how many times the stream reconnects after a transport or
+	// decode error; zero means defaultStreamRetries, negative disables.
+	Retries int
+	// Backoff is the wait before the first reconnect, doubling after each;
+	// defaults to 250ms.
+	Backoff time.Duration
+}
+
+// DocStream iterates over the documents of a query as they are decoded from
+// the response, in the style of sql.Rows:
+//
+//	st := svc.StreamQuery(ctx, "SELECT * FROM events ORDER BY _id", nil)
+//	defer st.Close()
+//	for st.Next() {
+//		use(st.Doc())
+//	}
+//	if err := st.Err(); err != nil { ... }
+type DocStream struct {
+	s     *service
+	ctx   context.Context
+	query string
+	args  map[string]any
+	opts  StreamOptions
+
+	body      io.ReadCloser
+	dec       *json.Decoder
+	doc       map[string]any
+	delivered int // documents handed to the caller so far
+	skip      int // documents to discard after a reconnect
+	retries   int
+	err       error
+	done      bool
+
+	tracked  bool            // counted in the service's in-flight operations
+	start    time.Time       // first open, for observe
+	reqBytes int64           // request bytes across reconnects
+	read     *countingReader // response bytes of the current connection
+	total    int64           // response bytes of earlier connections
+}
+
+// StreamQuery runs a read and yields its documents one at a time while the
+// response is still arriving, instead of buffering the whole result. Ditto's
+// HTTP API has no server-side cursor, so if the connection breaks the query
+// is re-run and the documents already delivered are skipped; give the query
+// a deterministic ORDER BY for resumption to be exact.
+//
+// The stream is not subject to the HTTP client's total Timeout, which would
+// cut off long results; bound it with ctx instead. An open stream counts as
+// an in-flight operation that Close waits for, and is reported to
+// WithObserver and WithConnStats like any other request.
+func (s *service) StreamQuery(ctx context.Context, query string, args map[string]any, opts ...StreamOptions) *DocStream {
+	st := &DocStream{s: s, ctx: ctx, query: s.qualify(ctx, query), args: args}
+	if len(opts) > 0 {
+		st.opts = opts[0]
+	}
+	if st.opts.Retries == 0 {
+		st.opts.Retries = defaultStreamRetries
+	}
+	if st.opts.Backoff <= 0 {
+		st.opts.Backoff = 250 * time.Millisecond
+	}
+	if !isReadStatement(query) {
+		st.err = errors.New("StreamQuery only supports SELECT statements")
+		st.done = true
+	}
+	return st
+}
+
+// Next advances to the next document, reporting false at the end of the
+// results or on error.
+func (st *DocStream) Next() bool {
+	for !st.done {
+		if st.dec == nil {
+			if err := st.open(); err != nil && !st.retry(err) {
+				return false
+			}
+			continue
+		}
+		if !st.dec.More() {
+			st.finish(nil)
+			return false
+		}
+		var doc map[string]any
+		if err := st.dec.Decode(&doc); err != nil {
+			st.closeBody()
+			if !st.retry(fmt.Errorf("decode stream: %w", err)) {
+				return false
+			}
+			continue
+		}
+		if st.skip > 0 {
+			st.skip--
+			continue
+		}
+		st.doc = doc
+		st.delivered++
+		return true
+	}
+	return false
+}
+
+// Doc returns the current document.
+func (st *DocStream) Doc() map[string]any { return st.doc }
+
+// Err returns the error that ended the stream, if any.
+func (st *DocStream) Err() error { return st.err }
+
+// Close releases the connection. It is safe to call more than once.
+func (st *DocStream) Close() error {
+	st.finish(nil)
+	return nil
+}
+
+// open issues the query and positions the decoder at the first item.
+func (st *DocStream) open() error {
+	req, err := st.s.newExecuteRequest(st.ctx, st.query, st.args)
+	if err != nil {
+		st.finish(err)
+		return nil
+	}
+	if !st.tracked {
+		st.tracked = true
+		st.start = st.s.now()
+		st.s.ops.add()
+	}
+	st.reqBytes += req.ContentLength
+	req = st.s.withConnTrace(req)
+	resp, err := st.s.streamClient().Do(req)
+	if err != nil {
+		st.s.connectionLost(err)
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		// Server-side rejections are not worth retrying
+		st.finish(httpError(resp.StatusCode, resp.Body, st.query))
+		return nil
+	}
+	st.body = resp.Body
+	st.read = &countingReader{r: resp.Body}
+	st.dec = json.NewDecoder(st.read)
+	if st.s.preciseNumbers {
+		st.dec.UseNumber()
+	}
+	if err := seekItems(st.dec); err != nil {
+		st.closeBody()
+		return err
+	}
+	st.skip = st.delivered
+	return nil
+}
+
+// retry schedules a reconnect after err, or ends the stream with it.
+func (st *DocStream) retry(err error) bool {
+	if st.done {
+		return false
+	}
+	if st.ctx.Err() != nil || st.retries >= st.opts.Retries {
+		st.finish(err)
+		return false
+	}
+	wait := st.opts.Backoff << st.retries
+	st.retries++
+	if serr := st.s.sleep(st.ctx, wait); serr != nil {
+		st.finish(serr)
+		return false
+	}
+	return true
+}
+
+func (st *DocStream) finish(err error) {
+	if st.err == nil {
+		st.err = err
+	}
+	st.done = true
+	st.closeBody()
+	if st.tracked {
+		st.tracked = false
+		st.s.observe(st.query, st.args, st.s.now().Sub(st.start), st.reqBytes, st.total, nil, st.err)
+		st.s.ops.done()
+	}
+}
+
+func (st *DocStream) closeBody() {
+	if st.body != nil {
+		st.body.Close()
+	}
+	if st.read != nil {
+		st.total += st.read.n
+	}
+	st.body, st.dec, st.read = nil, nil, nil
+}
+
+// streamClient is the service's client without its total Timeout, which
+// would cut off a stream that legitimately runs longer; the transport (and
+// so WithConnStats and dialer options) is shared.
+func (s *service) streamClient() *http.Client {
+	if s.HTTP.Timeout == 0 {
+		return s.HTTP
+	}
+	c := *s.HTTP
+	c.Timeout = 0
+	return &c
+}
+
+// seekItems advances dec past the opening of the top-level "items" array,
+// skipping any fields before it.
+func seekItems(dec *json.Decoder) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '{' {
+		return fmt.Errorf("unexpected response start %v", tok)
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if key, _ := tok.(string); key == "items" {
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			if d, ok := tok.(json.Delim); !ok || d != '[' {
+				return fmt.Errorf("items is not an array")
+			}
+			return nil
+		}
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return err
+		}
+	}
+	return errors.New("response has no items")
+}
@@ -0,0 +1,149 @@
+package ditto
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ErrUnknownFragment is returned when a template references a fragment that
+// was never defined.
+var ErrUnknownFragment = errors.New("ditto: unknown query fragment")
+
+// fragmentRef matches a {{name}} placeholder; fragmentName a valid name.
+var (
+	fragmentRef  = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+	fragmentName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// Fragment is a reusable piece of DQL: a WHERE condition, a projection, an
+// ORDER BY list. Text may reference other fragments as {{name}} and
+// parameters as :name; Args binds some or all of those parameters.
+type Fragment struct {
+	Text string
+	Args map[string]any
+}
+
+// Templates composes DQL from named fragments so common clauses live in one
+// place instead of being copy-pasted across services:
+//
+//	t := ditto.NewTemplates()
+//	t.Define("active", "deleted_at IS NULL AND status == :active", map[string]any{"active": "open"})
+//	t.Define("mine", "owner == :owner", nil)
+//	q, args, err := t.Render("SELECT * FROM tasks WHERE {{active}} AND {{mine}}",
+//		map[string]any{"owner": userID})
+//
+// Fragments are spliced in as text, so only developer-written DQL belongs in
+// them; values always travel as bound parameters. A Templates is safe for
+// concurrent use.
+type Templates struct {
+	mu    sync.RWMutex
+	frags map[string]Fragment
+}
+
+// NewTemplates returns an empty fragment registry.
+func NewTemplates() *Templates {
+	return &Templates{frags: map[string]Fragment{}}
+}
+
+// Define registers a fragment under name. Redefining a name is an error so
+// two packages can't silently replace each other's fragments.
+func (t *Templates) Define(name, text string, args map[string]any) error {
+	if !fragmentName.MatchString(name) {
+		return fmt.Errorf("fragment name %q: must be an identifier", name)
+	}
+	if strings.TrimSpace(text) == "" {
+		return fmt.Errorf("fragment %q: empty text", name)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, dup := t.frags[name]; dup {
+		return fmt.Errorf("fragment %q already defined", name)
+	}
+	t.frags[name] = Fragment{Text: text, Args: args}
+	return nil
+}
+
+// Render expands every {{name}} in query, recursively, and merges the
+// arguments of the fragments used with args. A parameter bound to
+// different values by two fragments, or by a fragment and args, is an
+// error, as is a :param left unbound or a fragment that includes itself.
+func (t *Templates) Render(query string, args map[string]any) (string, map[string]any, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	merged := map[string]any{}
+	owner := map[string]string{}
+	for k, v := range args {
+		merged[k], owner[k] = v, "args"
+	}
+	out, err := t.expand(query, merged, owner, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	toks, err := lexDQL(out)
+	if err != nil {
+		return "", nil, fmt.Errorf("render: %w", err)
+	}
+	for _, tok := range toks {
+		if tok.kind != "param" {
+			continue
+		}
+		if _, ok := merged[tok.text[1:]]; !ok {
+			return "", nil, fmt.Errorf("render: parameter %s is not bound", tok.text)
+		}
+	}
+	return out, merged, nil
+}
+
+// Where renders the named fragment as a Predicate for FindWhere,
+// UpdateWhere, and the other predicate methods.
+func (t *Templates) Where(name string, args map[string]any) (Predicate, error) {
+	q, merged, err := t.Render("{{"+name+"}}", args)
+	if err != nil {
+		return Predicate{}, err
+	}
+	return Where(q, merged), nil
+}
+
+// expand replaces the placeholders in text, recording fragment arguments in
+// merged. stack holds the fragments being expanded, to catch cycles.
+func (t *Templates) expand(text string, merged map[string]any, owner map[string]string, stack []string) (string, error) {
+	var err error
+	out := fragmentRef.ReplaceAllStringFunc(text, func(ref string) string {
+		if err != nil {
+			return ""
+		}
+		name := fragmentRef.FindStringSubmatch(ref)[1]
+		for _, s := range stack {
+			if s == name {
+				err = fmt.Errorf("fragment cycle: %s -> %s", strings.Join(stack, " -> "), name)
+				return ""
+			}
+		}
+		f, ok := t.frags[name]
+		if !ok {
+			err = fmt.Errorf("%w: %q", ErrUnknownFragment, name)
+			return ""
+		}
+		for k, v := range f.Args {
+			if prev, bound := merged[k]; bound && !reflect.DeepEqual(prev, v) {
+				err = fmt.Errorf("parameter %q bound differently by %s and fragment %q", k, owner[k], name)
+				return ""
+			}
+			if _, bound := owner[k]; !bound {
+				owner[k] = fmt.Sprintf("fragment %q", name)
+			}
+			merged[k] = v
+		}
+		var sub string
+		sub, err = t.expand(f.Text, merged, owner, append(stack, name))
+		return sub
+	})
+	if err != nil {
+		return "", err
+	}
+	return out, nil
+}
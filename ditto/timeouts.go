@@ -0,0 +1,87 @@
+package ditto
+
+import (
+	"context"
+	"time"
+)
+
+// OperationTimeouts are default deadlines by operation class, applied only
+// when the caller's context has none. Read and Write bound one statement;
+// Bulk bounds a whole multi-statement operation (UpdateMany,
+// DeleteAllRecords, ExecuteBatch, import/export, copy, backup, dedupe,
+// retention). Zero fields take the DefaultOperationTimeouts value; a
+// negative field disables the default for its class.
+type OperationTimeouts struct {
+	Read  time.Duration
+	Write time.Duration
+	Bulk  time.Duration
+}
+
+// DefaultOperationTimeouts fills the zero fields of WithOperationTimeouts.
+var DefaultOperationTimeouts = OperationTimeouts{
+	Read:  5 * time.Second,
+	Write: 10 * time.Second,
+	Bulk:  120 * time.Second,
+}
+
+// opClass selects an OperationTimeouts field.
+type opClass int
+
+const (
+	opRead opClass = iota
+	opWrite
+	opBulk
+)
+
+// WithOperationTimeouts enables per-class default deadlines. The HTTP
+// client's overall Timeout is cleared so it no longer caps bulk operations;
+// the class deadlines (or the caller's context) bound every request instead.
+func (s *service) WithOperationTimeouts(t OperationTimeouts) *service {
+	d := DefaultOperationTimeouts
+	if t.Read == 0 {
+		t.Read = d.Read
+	}
+	if t.Write == 0 {
+		t.Write = d.Write
+	}
+	if t.Bulk == 0 {
+		t.Bulk = d.Bulk
+	}
+	s.opTimeouts = &t
+	if s.HTTP != nil {
+		s.HTTP.Timeout = 0
+	}
+	return s
+}
+
+// opDeadline applies the default deadline for class when ctx has none.
+func (s *service) opDeadline(ctx context.Context, class opClass) (context.Context, context.CancelFunc) {
+	if s.opTimeouts == nil {
+		return ctx, func() {}
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	d := s.opTimeouts.Read
+	switch class {
+	case opWrite:
+		d = s.opTimeouts.Write
+	case opBulk:
+		d = s.opTimeouts.Bulk
+	}
+	if ad, ok := s.adaptiveDeadline(class); ok {
+		d = ad
+	}
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// statementDeadline applies the read or write deadline for query.
+func (s *service) statementDeadline(ctx context.Context, query string) (context.Context, context.CancelFunc) {
+	if isReadStatement(query) {
+		return s.opDeadline(ctx, opRead)
+	}
+	return s.opDeadline(ctx, opWrite)
+}
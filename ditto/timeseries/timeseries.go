@@ -0,0 +1,233 @@
+// Package timeseries layers sensor-style time-series helpers over a Ditto
+// collection: append-only ingestion with automatic timestamp and device_id
+// fields, windowed reads, client-side downsampling, and retention enforcement
+// via EVICT.
+package timeseries
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Hammerstone-AU/ditto-go-sdk/ditto"
+)
+
+// Default field names written by Append.
+const (
+	DefaultTimeField   = "ts"
+	DefaultDeviceField = "device_id"
+)
+
+// Series is a time-series view of one collection.
+type Series struct {
+	svc         ditto.Service
+	collection  string
+	TimeField   string      // timestamp field; defaults to "ts"
+	DeviceField string      // device id field; defaults to "device_id"
+	Clock       ditto.Clock // time source for Append, Last, and retention; nil means system
+}
+
+// New returns a Series over collection using the default field names.
+func New(svc ditto.Service, collection string) *Series {
+	return &Series{
+		svc:         svc,
+		collection:  collection,
+		TimeField:   DefaultTimeField,
+		DeviceField: DefaultDeviceField,
+	}
+}
+
+// Append inserts one reading for deviceID. The time and device fields are
+// filled in automatically (the time field only when absent from fields, so
+// callers can backfill). Readings are never updated in place.
+func (s *Series) Append(ctx context.Context, deviceID string, fields map[string]any) (any, error) {
+	if deviceID == "" {
+		return nil, errors.New("device id required")
+	}
+	doc := make(map[string]any, len(fields)+2)
+	for k, v := range fields {
+		doc[k] = v
+	}
+	if t, ok := doc[s.TimeField].(time.Time); ok {
+		doc[s.TimeField] = ditto.FormatTimestamp(t)
+	} else if _, ok := doc[s.TimeField]; !ok {
+		doc[s.TimeField] = ditto.FormatTimestamp(s.now())
+	}
+	doc[s.DeviceField] = deviceID
+	return s.svc.CreateDocument(ctx, s.collection, doc)
+}
+
+// Last returns readings for deviceID (all devices when empty) from the last
+// window, oldest first.
+func (s *Series) Last(ctx context.Context, deviceID string, window time.Duration) ([]map[string]any, error) {
+	now := s.now()
+	return s.Range(ctx, deviceID, now.Add(-window), now)
+}
+
+// Range returns readings for deviceID (all devices when empty) with
+// from <= time < to, oldest first.
+func (s *Series) Range(ctx context.Context, deviceID string, from, to time.Time) ([]map[string]any, error) {
+	tf := ditto.QuotePath(s.TimeField)
+	clause := fmt.Sprintf("%s >= :ts_from AND %s < :ts_to", tf, tf)
+	args := map[string]any{
+		"ts_from": ditto.FormatTimestamp(from),
+		"ts_to":   ditto.FormatTimestamp(to),
+	}
+	if deviceID != "" {
+		clause = fmt.Sprintf("%s == :ts_device AND %s", ditto.QuotePath(s.DeviceField), clause)
+		args["ts_device"] = deviceID
+	}
+	res, err := s.svc.FindWhere(ctx, s.collection, ditto.Where(clause, args),
+		ditto.QueryOptions{SortBy: s.TimeField, SortOrder: "ASC"})
+	if err != nil {
+		return nil, err
+	}
+	return ditto.Documents(res), nil
+}
+
+// EnforceRetention evicts readings older than maxAge from the local store.
+func (s *Series) EnforceRetention(ctx context.Context, maxAge time.Duration) (any, error) {
+	if maxAge <= 0 {
+		return nil, errors.New("retention must be positive")
+	}
+	q := fmt.Sprintf("EVICT FROM %s WHERE %s < :cutoff", ditto.QuoteIdent(s.collection), ditto.QuotePath(s.TimeField))
+	return s.svc.Execute(ctx, q, map[string]any{
+		"cutoff": ditto.FormatTimestamp(s.now().Add(-maxAge)),
+	})
+}
+
+// Aggregate selects how Downsample combines the values in a bucket.
+type Aggregate int
+
+const (
+	Mean Aggregate = iota
+	Min
+	Max
+	Sum
+	Count
+	First
+	Last
+)
+
+// Point is one downsampled bucket.
+type Point struct {
+	Start time.Time
+	Value float64
+	N     int // readings in the bucket
+}
+
+// Downsample groups readings into buckets of width bucket (aligned to the
+// Unix epoch) and combines valueField in each with agg. Readings without a
+// parseable timestamp or numeric value are skipped. Points are returned in
+// time order.
+func Downsample(docs []map[string]any, timeField, valueField string, bucket time.Duration, agg Aggregate) []Point {
+	if bucket <= 0 {
+		return nil
+	}
+	type acc struct {
+		vals []float64
+	}
+	type reading struct {
+		t time.Time
+		v float64
+	}
+	var rs []reading
+	for _, d := range docs {
+		t, ok := ditto.ParseTimestamp(d[timeField])
+		if !ok {
+			continue
+		}
+		v, ok := number(d[valueField])
+		if !ok {
+			continue
+		}
+		rs = append(rs, reading{t, v})
+	}
+	sort.SliceStable(rs, func(i, j int) bool { return rs[i].t.Before(rs[j].t) })
+
+	var out []Point
+	var cur *acc
+	var start time.Time
+	flush := func() {
+		if cur != nil {
+			out = append(out, Point{Start: start, Value: combine(cur.vals, agg), N: len(cur.vals)})
+		}
+	}
+	for _, r := range rs {
+		b := bucketStart(r.t, bucket)
+		if cur == nil || !b.Equal(start) {
+			flush()
+			cur, start = &acc{}, b
+		}
+		cur.vals = append(cur.vals, r.v)
+	}
+	flush()
+	return out
+}
+
+// bucketStart returns the start of the bucket containing t, counting
+// buckets from the Unix epoch. time.Truncate counts from Go's zero time
+// instead, which only agrees for widths that divide a day evenly.
+func bucketStart(t time.Time, bucket time.Duration) time.Time {
+	ns := t.UnixNano()
+	off := ns % int64(bucket)
+	if off < 0 {
+		off += int64(bucket)
+	}
+	return time.Unix(0, ns-off).In(t.Location())
+}
+
+// combine reduces vals (in time order) with agg.
+func combine(vals []float64, agg Aggregate) float64 {
+	switch agg {
+	case Min:
+		m := math.Inf(1)
+		for _, v := range vals {
+			m = math.Min(m, v)
+		}
+		return m
+	case Max:
+		m := math.Inf(-1)
+		for _, v := range vals {
+			m = math.Max(m, v)
+		}
+		return m
+	case Count:
+		return float64(len(vals))
+	case First:
+		return vals[0]
+	case Last:
+		return vals[len(vals)-1]
+	}
+	var sum float64
+	for _, v := range vals {
+		sum += v
+	}
+	if agg == Sum {
+		return sum
+	}
+	return sum / float64(len(vals))
+}
+
+// number converts a decoded JSON number to float64.
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case interface{ Float64() (float64, error) }:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// now reads the Series clock.
+func (s *Series) now() time.Time {
+	if s.Clock != nil {
+		return s.Clock.Now()
+	}
+	return time.Now()
+}
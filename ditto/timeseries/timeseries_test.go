@@ -0,0 +1,46 @@
+package timeseries
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Hammerstone-AU/ditto-go-sdk/ditto"
+)
+
+func TestDownsampleEpochAligned(t *testing.T) {
+	at := func(s string) string {
+		tm, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ditto.FormatTimestamp(tm)
+	}
+	docs := []map[string]any{
+		{"ts": at("2024-05-01T00:00:00Z"), "v": 1.0},
+		{"ts": at("2024-05-01T00:01:00Z"), "v": 3.0},
+		{"ts": at("2024-05-01T00:05:00Z"), "v": 10.0},
+		{"ts": at("1969-12-31T23:58:00Z"), "v": 7.0},
+	}
+	// 7 minutes does not divide a day, so epoch and zero-time alignment differ
+	bucket := 7 * time.Minute
+	got := Downsample(docs, "ts", "v", bucket, Mean)
+	if len(got) != 3 {
+		t.Fatalf("got %d points, want 3: %+v", len(got), got)
+	}
+	for _, p := range got {
+		if p.Start.UnixNano()%int64(bucket) != 0 {
+			t.Errorf("bucket %s is not aligned to the Unix epoch", p.Start)
+		}
+	}
+	// 2024-05-01T00:00Z is 28575360 minutes after the epoch, 2 minutes into
+	// its 7-minute bucket
+	if want := time.Date(2024, 4, 30, 23, 58, 0, 0, time.UTC); !got[1].Start.Equal(want) || got[1].Value != 2 || got[1].N != 2 {
+		t.Errorf("second point = %+v, want start %s with mean 2 of 2", got[1], want)
+	}
+	if want := time.Date(2024, 5, 1, 0, 5, 0, 0, time.UTC); !got[2].Start.Equal(want) || got[2].Value != 10 {
+		t.Errorf("third point = %+v, want start %s", got[2], want)
+	}
+	if want := time.Date(1969, 12, 31, 23, 53, 0, 0, time.UTC); !got[0].Start.Equal(want) {
+		t.Errorf("pre-epoch point starts %s, want %s", got[0].Start, want)
+	}
+}
@@ -0,0 +1,168 @@
+package ditto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ValidationRules declares what documents of a collection must satisfy.
+// Fields are dotted paths.
+type ValidationRules struct {
+	Required []string                          // fields that must be present and non-null
+	Enum     map[string][]any                  // field -> allowed values (absent fields pass)
+	Refs     []RefSpec                         // fields whose value must be an _id in Collection
+	Check    func(doc map[string]any) []string // custom checks; returns messages
+}
+
+// Violation is one rule a document broke.
+type Violation struct {
+	DocID   string
+	Field   string
+	Rule    string // "required", "enum", "ref", or "check"
+	Message string
+}
+
+// ValidationReport is the result of ValidateCollection.
+type ValidationReport struct {
+	Collection string
+	Scanned    int
+	Violations []Violation
+}
+
+// Valid reports whether no violations were found.
+func (r *ValidationReport) Valid() bool { return len(r.Violations) == 0 }
+
+// ByDocument groups violations by document _id.
+func (r *ValidationReport) ByDocument() map[string][]Violation {
+	out := map[string][]Violation{}
+	for _, v := range r.Violations {
+		out[v.DocID] = append(out[v.DocID], v)
+	}
+	return out
+}
+
+// ValidateCollection scans every document of collection and reports the
+// documents that break rules. Referential checks are batched per RefSpec
+// after the scan. A non-nil error means the scan itself failed; rule
+// violations are only ever reported in the report.
+func (s *service) ValidateCollection(ctx context.Context, collection string, rules ValidationRules) (*ValidationReport, error) {
+	if collection == "" {
+		return nil, errors.New("collection required")
+	}
+	for _, ref := range rules.Refs {
+		if ref.Field == "" || ref.Collection == "" {
+			return nil, errors.New("ref spec needs Field and Collection")
+		}
+	}
+	rep := &ValidationReport{Collection: collection}
+	// refs stands for referenced id -> documents holding it, per RefSpec
+	refs := make([]map[string][]string, len(rules.Refs))
+	for i := range refs {
+		refs[i] = map[string][]string{}
+	}
+
+	err := s.scanCollection(ctx, collection, func(docs []map[string]any) error {
+		for _, d := range docs {
+			rep.Scanned++
+			rep.check(d, rules)
+			id := fmt.Sprint(d["_id"])
+			for i, ref := range rules.Refs {
+				if v := lookupPath(d, ref.Field); v != nil {
+					key := fmt.Sprint(v)
+					refs[i][key] = append(refs[i][key], id)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return rep, fmt.Errorf("validate %s: %w", collection, err)
+	}
+
+	for i, ref := range rules.Refs {
+		missing, err := s.missingIDs(ctx, ref.Collection, sortedKeys(refs[i]))
+		if err != nil {
+			return rep, fmt.Errorf("validate %s: ref %s: %w", collection, ref.Field, err)
+		}
+		for _, target := range missing {
+			for _, id := range refs[i][target] {
+				rep.Violations = append(rep.Violations, Violation{
+					DocID:   id,
+					Field:   ref.Field,
+					Rule:    "ref",
+					Message: fmt.Sprintf("%q not found in %s", target, ref.Collection),
+				})
+			}
+		}
+	}
+	return rep, nil
+}
+
+// check applies the per-document rules to d.
+func (r *ValidationReport) check(d map[string]any, rules ValidationRules) {
+	id := fmt.Sprint(d["_id"])
+	for _, f := range rules.Required {
+		if lookupPath(d, f) == nil {
+			r.Violations = append(r.Violations, Violation{DocID: id, Field: f, Rule: "required", Message: "missing"})
+		}
+	}
+	for _, f := range sortedKeys(rules.Enum) {
+		v := lookupPath(d, f)
+		if v == nil {
+			continue
+		}
+		ok := false
+		for _, allowed := range rules.Enum[f] {
+			if compareValues(v, allowed) == 0 {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			r.Violations = append(r.Violations, Violation{
+				DocID: id, Field: f, Rule: "enum",
+				Message: fmt.Sprintf("%v not in %v", v, rules.Enum[f]),
+			})
+		}
+	}
+	if rules.Check != nil {
+		for _, msg := range rules.Check(d) {
+			r.Violations = append(r.Violations, Violation{DocID: id, Rule: "check", Message: msg})
+		}
+	}
+}
+
+// missingIDs returns the ids that have no document in collection.
+func (s *service) missingIDs(ctx context.Context, collection string, ids []string) ([]string, error) {
+	found := map[string]bool{}
+	for _, chunk := range chunkIDs(ids, maxIDsPerStatement) {
+		where := idsPredicate(chunk)
+		q := fmt.Sprintf("SELECT _id FROM %s WHERE %s", escapeIdent(collection), where.Clause)
+		res, err := s.execWithArgs(ctx, q, where.Args)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range Documents(res) {
+			found[fmt.Sprint(d["_id"])] = true
+		}
+	}
+	var missing []string
+	for _, id := range ids {
+		if !found[id] {
+			missing = append(missing, id)
+		}
+	}
+	return missing, nil
+}
+
+// String summarizes the report, one violation per line.
+func (r *ValidationReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d documents scanned, %d violations\n", r.Collection, r.Scanned, len(r.Violations))
+	for _, v := range r.Violations {
+		fmt.Fprintf(&b, "  %s %s %s: %s\n", v.DocID, v.Rule, v.Field, v.Message)
+	}
+	return b.String()
+}
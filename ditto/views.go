@@ -0,0 +1,241 @@
+package ditto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ViewFunc computes the documents of a materialized view from the source
+// documents. Every returned document must carry a stable _id.
+type ViewFunc func(source []map[string]any) ([]map[string]any, error)
+
+// View describes a derived collection maintained from a source collection.
+type View struct {
+	Name     string     // target collection holding the computed documents
+	Source   string     // source collection
+	Where    *Predicate // optional filter applied to the source
+	Compute  ViewFunc
+	Interval time.Duration // refresh period; zero means refresh on demand only
+}
+
+// viewRegistry holds registered views.
+type viewRegistry struct {
+	mu    sync.Mutex
+	views map[string]View
+}
+
+// RegisterView registers a materialized view. When v.Interval is positive a
+// background refresher keeps it up to date until Close. Views can also be
+// refreshed on demand with RefreshView, e.g. from a change notification.
+func (s *service) RegisterView(v View) error {
+	if v.Name == "" || v.Source == "" || v.Compute == nil {
+		return errors.New("view needs Name, Source, and Compute")
+	}
+	if v.Name == v.Source {
+		return errors.New("view cannot target its own source")
+	}
+	s.views.mu.Lock()
+	if s.views.views == nil {
+		s.views.views = map[string]View{}
+	}
+	if _, dup := s.views.views[v.Name]; dup {
+		s.views.mu.Unlock()
+		return fmt.Errorf("view %q already registered", v.Name)
+	}
+	s.views.views[v.Name] = v
+	s.views.mu.Unlock()
+
+	if v.Interval > 0 {
+		s.goBackground("view:"+v.Name, func(ctx context.Context) {
+			for {
+				_ = s.refreshView(ctx, v)
+				if s.sleep(ctx, v.Interval) != nil {
+					return
+				}
+			}
+		})
+	}
+	return nil
+}
+
+// RefreshView recomputes the named view now: it reads the source, runs
+// Compute, upserts the results, and deletes view documents no longer
+// produced.
+func (s *service) RefreshView(ctx context.Context, name string) error {
+	s.views.mu.Lock()
+	v, ok := s.views.views[name]
+	s.views.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("view %q not registered", name)
+	}
+	return s.refreshView(ctx, v)
+}
+
+// refreshView performs one refresh of v.
+func (s *service) refreshView(ctx context.Context, v View) error {
+	q := fmt.Sprintf("SELECT * FROM %s", escapeIdent(v.Source))
+	var args map[string]any
+	if v.Where != nil {
+		q += " WHERE " + v.Where.Clause
+		args = v.Where.Args
+	}
+	res, err := s.execWithArgs(ctx, q, args)
+	if err != nil {
+		return fmt.Errorf("view %s: read source: %w", v.Name, err)
+	}
+	docs, err := v.Compute(Documents(res))
+	if err != nil {
+		return fmt.Errorf("view %s: compute: %w", v.Name, err)
+	}
+
+	keep := map[string]bool{}
+	for _, d := range docs {
+		id, ok := d["_id"]
+		if !ok {
+			return fmt.Errorf("view %s: computed document without _id", v.Name)
+		}
+		keep[fmt.Sprint(id)] = true
+	}
+	existing, err := s.selectIDs(ctx, v.Name)
+	if err != nil {
+		return fmt.Errorf("view %s: read view: %w", v.Name, err)
+	}
+	if err := s.upsertDocs(ctx, v.Name, docs); err != nil {
+		return fmt.Errorf("view %s: write: %w", v.Name, err)
+	}
+	var stale []string
+	for _, id := range existing {
+		if !keep[id] {
+			stale = append(stale, id)
+		}
+	}
+	if err := s.deleteIDs(ctx, v.Name, stale); err != nil {
+		return fmt.Errorf("view %s: prune: %w", v.Name, err)
+	}
+	return nil
+}
+
+// LatestPerKey returns a ViewFunc keeping, for each distinct value of key,
+// the source document with the greatest orderBy value (e.g. the latest
+// reading per device). The view document's _id is the key value.
+func LatestPerKey(key, orderBy string) ViewFunc {
+	return func(source []map[string]any) ([]map[string]any, error) {
+		latest := map[string]map[string]any{}
+		for _, d := range source {
+			k, ok := d[key]
+			if !ok {
+				continue
+			}
+			id := fmt.Sprint(k)
+			if cur, ok := latest[id]; !ok || compareValues(d[orderBy], cur[orderBy]) > 0 {
+				latest[id] = d
+			}
+		}
+		out := make([]map[string]any, 0, len(latest))
+		for id, d := range latest {
+			doc := make(map[string]any, len(d))
+			for k, v := range d {
+				doc[k] = v
+			}
+			doc["source_id"] = d["_id"]
+			doc["_id"] = id
+			out = append(out, doc)
+		}
+		sort.Slice(out, func(i, j int) bool { return fmt.Sprint(out[i]["_id"]) < fmt.Sprint(out[j]["_id"]) })
+		return out, nil
+	}
+}
+
+// Rollup returns a ViewFunc aggregating the numeric field value per key and
+// time window (e.g. hourly). timeField must hold a timestamp string as written
+// by this SDK or RFC 3339. Each output document has key, window_start, count,
+// sum, avg, min, and max; its _id is "<key>@<window_start>".
+func Rollup(key, timeField, value string, window time.Duration) ViewFunc {
+	type agg struct {
+		key      any
+		start    time.Time
+		count    int
+		sum      float64
+		min, max float64
+	}
+	return func(source []map[string]any) ([]map[string]any, error) {
+		if window <= 0 {
+			return nil, errors.New("rollup window must be positive")
+		}
+		buckets := map[string]*agg{}
+		for _, d := range source {
+			ts, ok := ParseTimestamp(d[timeField])
+			if !ok {
+				continue
+			}
+			v, ok := toFloat(d[value])
+			if !ok {
+				continue
+			}
+			start := ts.Truncate(window)
+			id := fmt.Sprintf("%v@%s", d[key], FormatTimestamp(start))
+			b, ok := buckets[id]
+			if !ok {
+				b = &agg{key: d[key], start: start, min: v, max: v}
+				buckets[id] = b
+			}
+			b.count++
+			b.sum += v
+			b.min = min(b.min, v)
+			b.max = max(b.max, v)
+		}
+		out := make([]map[string]any, 0, len(buckets))
+		for id, b := range buckets {
+			out = append(out, map[string]any{
+				"_id":          id,
+				key:            b.key,
+				"window_start": FormatTimestamp(b.start),
+				"count":        b.count,
+				"sum":          b.sum,
+				"avg":          b.sum / float64(b.count),
+				"min":          b.min,
+				"max":          b.max,
+			})
+		}
+		sort.Slice(out, func(i, j int) bool { return out[i]["_id"].(string) < out[j]["_id"].(string) })
+		return out, nil
+	}
+}
+
+// compareValues orders two decoded JSON scalars: numbers numerically,
+// everything else by string form. Missing values sort first.
+func compareValues(a, b any) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		default:
+			return 1
+		}
+	}
+	fa, aok := toFloat(a)
+	fb, bok := toFloat(b)
+	if aok && bok {
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	}
+	sa, sb := fmt.Sprint(a), fmt.Sprint(b)
+	switch {
+	case sa < sb:
+		return -1
+	case sa > sb:
+		return 1
+	}
+	return 0
+}
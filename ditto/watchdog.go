@@ -0,0 +1,184 @@
+package ditto
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Watchdog defaults used when WatchdogOptions leaves fields at zero.
+const (
+	defaultWatchdogInterval = 10 * time.Second
+	defaultWatchdogFailures = 3
+)
+
+// Resolver returns the current base URL of the edge node, e.g. from mDNS,
+// DNS-SD, or a device registry. Returning the URL already in use is fine.
+type Resolver func(ctx context.Context) (string, error)
+
+// WatchdogOptions configures WithResolver.
+type WatchdogOptions struct {
+	Interval time.Duration // liveness probe period; defaults to 10s
+	Failures int           // consecutive failed probes before re-resolving; defaults to 3
+}
+
+// watchdog tracks liveness for WithResolver.
+type watchdog struct {
+	resolve  Resolver
+	opts     WatchdogOptions
+	wake     chan struct{} // a request failed to connect
+	mu       sync.Mutex
+	failures int
+	resolved time.Time // last successful re-resolution
+	lastErr  string    // last resolver error
+}
+
+// WithResolver starts a liveness watchdog for deployments where the edge
+// node's address changes (DHCP). It probes the HTTP API every
+// opts.Interval with the Status probe statement; after opts.Failures
+// consecutive failures it calls resolve and, if the URL changed, points
+// the service at the new one so requests reattach without a restart. A
+// request that fails to connect triggers a probe right away. The watchdog
+// stops with Close.
+func (s *service) WithResolver(resolve Resolver, opts WatchdogOptions) *service {
+	if opts.Interval <= 0 {
+		opts.Interval = defaultWatchdogInterval
+	}
+	if opts.Failures <= 0 {
+		opts.Failures = defaultWatchdogFailures
+	}
+	w := &watchdog{resolve: resolve, opts: opts, wake: make(chan struct{}, 1)}
+	s.watch = w
+	s.goBackground("watchdog", func(ctx context.Context) { s.runWatchdog(ctx, w) })
+	return s
+}
+
+// SRVResolver resolves the base URL from a DNS SRV record (unicast DNS-SD),
+// e.g. SRVResolver("ditto", "tcp", "edge.local", "http") looks up
+// _ditto._tcp.edge.local and returns http://target:port for the
+// highest-priority target.
+func SRVResolver(service, proto, name, scheme string) Resolver {
+	return func(ctx context.Context) (string, error) {
+		_, addrs, err := net.DefaultResolver.LookupSRV(ctx, service, proto, name)
+		if err != nil {
+			return "", err
+		}
+		if len(addrs) == 0 {
+			return "", fmt.Errorf("no SRV records for _%s._%s.%s", service, proto, name)
+		}
+		host := strings.TrimSuffix(addrs[0].Target, ".")
+		return fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(host, fmt.Sprint(addrs[0].Port))), nil
+	}
+}
+
+// runWatchdog probes until ctx ends.
+func (s *service) runWatchdog(ctx context.Context, w *watchdog) {
+	for {
+		wait, cancel := context.WithCancel(ctx)
+		go func() {
+			select {
+			case <-w.wake:
+			case <-wait.Done():
+			}
+			cancel()
+		}()
+		_ = s.sleep(wait, w.opts.Interval)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+
+		pctx, pcancel := context.WithTimeout(ctx, w.opts.Interval)
+		_, err := s.execWithArgs(pctx, s.probeQuery(), nil)
+		pcancel()
+		w.mu.Lock()
+		if err == nil {
+			w.failures = 0
+			w.mu.Unlock()
+			continue
+		}
+		w.failures++
+		due := w.failures >= w.opts.Failures
+		w.mu.Unlock()
+		if due && ctx.Err() == nil {
+			s.reresolve(ctx, w)
+		}
+	}
+}
+
+// reresolve asks the resolver for the base URL and switches to it.
+func (s *service) reresolve(ctx context.Context, w *watchdog) {
+	rctx, cancel := context.WithTimeout(ctx, w.opts.Interval)
+	defer cancel()
+	url, err := w.resolve(rctx)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err != nil {
+		w.lastErr = err.Error()
+		s.log().Warn("ditto base URL resolution failed", "error", err)
+		return
+	}
+	w.lastErr = ""
+	w.failures = 0
+	if old := s.baseURL(); url != "" && url != old {
+		s.setBaseURL(url)
+		w.resolved = s.now()
+		s.log().Info("ditto base URL re-resolved", "old", redactURL(old), "new", redactURL(url))
+	}
+}
+
+// connectionLost wakes the watchdog after a request failed to connect.
+func (s *service) connectionLost(err error) {
+	if s.watch == nil || !Retryable(err) {
+		return
+	}
+	if c := ClassOf(err); c != ClassNetwork && c != ClassTimeout {
+		return
+	}
+	select {
+	case s.watch.wake <- struct{}{}:
+	default:
+	}
+}
+
+// watchdogStatus reports the watchdog's state for Status.
+func (s *service) watchdogStatus() map[string]any {
+	w := s.watch
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	st := map[string]any{"failures": w.failures}
+	if !w.resolved.IsZero() {
+		st["resolvedAt"] = w.resolved
+	}
+	if w.lastErr != "" {
+		st["resolveError"] = w.lastErr
+	}
+	return st
+}
+
+// baseURL returns the base URL in use; the watchdog may change it.
+func (s *service) baseURL() string {
+	s.urlMu.RLock()
+	defer s.urlMu.RUnlock()
+	return s.BaseURL
+}
+
+// setBaseURL switches the base URL for subsequent requests.
+func (s *service) setBaseURL(url string) {
+	s.urlMu.Lock()
+	defer s.urlMu.Unlock()
+	s.BaseURL = url
+}
+
+// executeURL is the /{appID}/execute endpoint at the current base URL.
+func (s *service) executeURL() string {
+	return executeURLAt(s.baseURL(), s.AppID)
+}
+
+// executeURLAt is the /{appID}/execute endpoint under base.
+func executeURLAt(base, appID string) string {
+	return fmt.Sprintf("%s/%s/execute", strings.TrimRight(base, "/"), appID)
+}
@@ -26,7 +26,10 @@ func main() {
     if _, err := service.CreateDocument(context.Background(), "greetings", doc); err != nil {
         log.Fatal(err)
     }
-    out, err := service.GetRecords(context.Background(), "greetings", 10, "_id", "DESC")
+    out, err := service.GetRecordsWith(context.Background(), ditto.GetRecordsRequest{
+        Collection:   "greetings",
+        QueryOptions: ditto.QueryOptions{Limit: 10, SortBy: "_id", SortOrder: "DESC"},
+    })
     if err != nil {
         log.Fatal(err)
     }